@@ -0,0 +1,62 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+// ApplyStatus describes the progress of applying committed entries to the
+// state machine of a Raft peer, see Config.MaxApplyLag.
+type ApplyStatus struct {
+	// Applying is the highest log index handed out for application.
+	Applying uint64
+	// Applied is the highest log index known to be applied, either acknowledged
+	// or reported with RawNode.ReportApplyProgress.
+	Applied uint64
+	// QueueDepth is the apply queue depth last reported with
+	// RawNode.ReportApplyProgress.
+	QueueDepth uint64
+	// Throttled is true if committed entries are held back because the apply
+	// lag reached Config.MaxApplyLag.
+	Throttled bool
+	// ProposalsBlocked is true if proposals are dropped because the apply lag
+	// reached Config.MaxProposalApplyLag.
+	ProposalsBlocked bool
+}
+
+// reportApplyProgress records the apply progress reported by the application.
+// See RawNode.ReportApplyProgress.
+func (r *raft) reportApplyProgress(applied, queueDepth uint64) {
+	r.raftLog.reportApplyProgress(applied, queueDepth)
+}
+
+// applyBehind returns true if proposals must be dropped because the
+// application of committed entries is too far behind.
+func (r *raft) applyBehind() bool {
+	if r.maxProposalApplyLag == 0 {
+		return false
+	}
+	l := r.raftLog
+	return max(l.committed-l.appliedIndex(), l.applyQueueDepth) >= r.maxProposalApplyLag
+}
+
+// applyStatus returns the ApplyStatus of the node.
+func (r *raft) applyStatus() ApplyStatus {
+	l := r.raftLog
+	return ApplyStatus{
+		Applying:         l.applying,
+		Applied:          l.appliedIndex(),
+		QueueDepth:       l.applyQueueDepth,
+		Throttled:        l.applyThrottled(),
+		ProposalsBlocked: r.applyBehind(),
+	}
+}
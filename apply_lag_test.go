@@ -0,0 +1,86 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// TestApplyLagProposalsDropped checks that proposals are dropped while the
+// number of committed but unapplied entries is at Config.MaxProposalApplyLag,
+// both on the leader and on a follower.
+func TestApplyLagProposalsDropped(t *testing.T) {
+	for _, leader := range []bool{false, true} {
+		t.Run("", func(t *testing.T) {
+			storage := newTestMemoryStorage(withPeers(1, 2))
+			require.NoError(t, storage.Append(index(1).terms(1, 1, 1, 1, 1)))
+			require.NoError(t, storage.SetHardState(pb.HardState{Term: 1, Commit: 5}))
+			cfg := newTestConfig(1, 10, 1, storage)
+			cfg.MaxProposalApplyLag = 3
+			r := newRaft(cfg)
+			if leader {
+				r.becomeCandidate()
+				r.becomeLeader()
+			} else {
+				r.becomeFollower(1, 2)
+			}
+			// All committed entries are handed out for application.
+			r.raftLog.acceptApplying(5, 0 /* size */, true /* allowUnstable */)
+
+			prop := pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{Data: []byte("foo")}}}
+			requireDropped := func() {
+				t.Helper()
+				err := r.Step(prop)
+				var pErr *ProposalDroppedError
+				require.ErrorAs(t, err, &pErr)
+				require.Equal(t, ProposalDropApplyBehind, pErr.Reason)
+				require.True(t, getBasicStatus(r).Apply.ProposalsBlocked)
+			}
+			requireDropped()
+
+			// The application reports progress, and proposals are accepted.
+			r.reportApplyProgress(3, 0 /* queueDepth */)
+			require.NoError(t, r.Step(prop))
+			require.Equal(t, ApplyStatus{Applying: 5, Applied: 3}, getBasicStatus(r).Apply)
+
+			// A deep apply queue blocks the proposals again.
+			r.reportApplyProgress(3, 3 /* queueDepth */)
+			requireDropped()
+
+			// Acknowledging the applied entries drains the queue.
+			r.appliedTo(5, 0 /* size */)
+			require.NoError(t, r.Step(prop))
+		})
+	}
+}
+
+// TestApplyLagDisabled checks that the apply progress reports have no effect
+// if the apply lag is not limited.
+func TestApplyLagDisabled(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1))
+	require.NoError(t, storage.Append(index(1).terms(1, 1, 1, 1, 1)))
+	require.NoError(t, storage.SetHardState(pb.HardState{Term: 1, Commit: 5}))
+	rn, err := NewRawNode(newTestConfig(1, 10, 1, storage))
+	require.NoError(t, err)
+
+	rn.ReportApplyProgress(0, 100 /* queueDepth */)
+	require.Equal(t, ApplyStatus{}, rn.BasicStatus().Apply)
+	rd := rn.Ready()
+	require.Len(t, rd.CommittedEntries, 5)
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+	"go.etcd.io/raft/v3/tracker"
+)
+
+// Codec compresses the log entries sent in MsgApp messages, see
+// Config.EntryCodecs.
+type Codec interface {
+	// ID identifies the codec on the wire. It must be in [1, 63], and all
+	// nodes in the group must use the same ID for the same compression format.
+	ID() uint32
+	// Compress returns the compressed form of the given data.
+	Compress(data []byte) ([]byte, error)
+	// Decompress returns the data that was compressed by Compress.
+	Decompress(data []byte) ([]byte, error)
+}
+
+// FlateCodecID is the ID of FlateCodec.
+const FlateCodecID = 1
+
+// FlateCodec is a Codec using the DEFLATE format of the compress/flate package.
+type FlateCodec struct {
+	// Level is the compression level, see flate.NewWriter. Zero means
+	// flate.DefaultCompression.
+	Level int
+}
+
+var _ Codec = FlateCodec{}
+
+// ID implements the Codec interface.
+func (FlateCodec) ID() uint32 { return FlateCodecID }
+
+// Compress implements the Codec interface.
+func (c FlateCodec) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress implements the Codec interface.
+func (FlateCodec) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// maxCompressionRatio bounds the compression ratio used to estimate how many
+// entries fit into a compressed MsgApp.
+const maxCompressionRatio = 8
+
+func validateCodecs(codecs []Codec) error {
+	var seen uint64
+	for _, c := range codecs {
+		id := c.ID()
+		if id == 0 || id > 63 {
+			return fmt.Errorf("codec ID %d is out of range [1, 63]", id)
+		}
+		if seen&(1<<id) != 0 {
+			return fmt.Errorf("duplicate codec ID %d", id)
+		}
+		seen |= 1 << id
+	}
+	return nil
+}
+
+// codecMask returns the bitmask of the given codecs, as advertised in
+// pb.Message.SupportedCodecs.
+func codecMask(codecs []Codec) uint64 {
+	var mask uint64
+	for _, c := range codecs {
+		mask |= 1 << c.ID()
+	}
+	return mask
+}
+
+// appendCodec returns the codec to compress the entries sent to the given
+// follower with, or nil if they are sent uncompressed.
+func (r *raft) appendCodec(pr *tracker.Progress) Codec {
+	for _, c := range r.codecs {
+		if pr.Codecs&(1<<c.ID()) != 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// compressedFetchSize returns the max size of the uncompressed entries to
+// fetch for a MsgApp of the given max size compressed with the codec. The
+// entries are expected to compress as well as the previous ones did.
+func (r *raft) compressedFetchSize(c Codec, maxSize entryEncodingSize) entryEncodingSize {
+	if c == nil || maxSize >= noLimit/maxCompressionRatio {
+		return maxSize
+	}
+	return entryEncodingSize(float64(maxSize) * r.compressionRatio)
+}
+
+// compressAppend compresses the entries of the given MsgApp with the codec.
+// If the compressed entries exceed maxSize, the MsgApp is cut down to fewer
+// entries. Returns the entries included in the MsgApp and their compressed
+// size, or false if the entries are to be sent uncompressed.
+func (r *raft) compressAppend(m *pb.Message, c Codec, maxSize entryEncodingSize) ([]pb.Entry, uint64, bool) {
+	ents := m.Entries
+	if uint64(entsSize(ents)) < r.entryCompressionThreshold {
+		return nil, 0, false
+	}
+	for {
+		data, err := (&pb.Message{Entries: ents}).Marshal()
+		if err != nil {
+			panic(err)
+		}
+		compressed, err := c.Compress(data)
+		if err != nil {
+			r.logger.Errorf("%x failed to compress entries with codec %d: %v", r.id, c.ID(), err)
+			return nil, 0, false
+		}
+		r.compressionRatio = min(max(float64(len(data))/float64(max(len(compressed), 1)), 1), maxCompressionRatio)
+		if entryEncodingSize(len(compressed)) > maxSize && len(ents) > 1 {
+			// The entries compressed worse than expected.
+			ents = ents[:len(ents)/2]
+			continue
+		}
+		m.Entries = nil
+		m.EntriesCodec = c.ID()
+		m.CompressedEntries = compressed
+		return ents, uint64(len(compressed)), true
+	}
+}
+
+// decompressEntries returns the entries of a MsgApp compressed with
+// compressAppend.
+func (r *raft) decompressEntries(m pb.Message) ([]pb.Entry, error) {
+	for _, c := range r.codecs {
+		if c.ID() != m.EntriesCodec {
+			continue
+		}
+		data, err := c.Decompress(m.CompressedEntries)
+		if err != nil {
+			return nil, err
+		}
+		var dm pb.Message
+		if err := dm.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return dm.Entries, nil
+	}
+	return nil, fmt.Errorf("unknown codec %d", m.EntriesCodec)
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+type testCodec uint32
+
+func (c testCodec) ID() uint32                           { return uint32(c) }
+func (testCodec) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (testCodec) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+func TestFlateCodec(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("foo"), bytes.Repeat([]byte("bar"), 1000)} {
+		compressed, err := FlateCodec{}.Compress(data)
+		require.NoError(t, err)
+		got, err := FlateCodec{}.Decompress(compressed)
+		require.NoError(t, err)
+		require.Equal(t, len(data), len(got))
+		require.Equal(t, string(data), string(got))
+	}
+}
+
+func TestValidateCodecs(t *testing.T) {
+	for _, tt := range []struct {
+		codecs []Codec
+		ok     bool
+	}{
+		{codecs: nil, ok: true},
+		{codecs: []Codec{FlateCodec{}, testCodec(63)}, ok: true},
+		{codecs: []Codec{testCodec(0)}},
+		{codecs: []Codec{testCodec(64)}},
+		{codecs: []Codec{FlateCodec{}, testCodec(FlateCodecID)}},
+	} {
+		t.Run("", func(t *testing.T) {
+			cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1)))
+			cfg.EntryCodecs = tt.codecs
+			require.Equal(t, tt.ok, cfg.validate() == nil)
+		})
+	}
+}
+
+// TestCompressedAppend checks that the leader compresses the entries sent to a
+// follower which supports the codec, fits more entries into MsgApp messages
+// once it learns that the entries compress well, and that the follower
+// decompresses them.
+func TestCompressedAppend(t *testing.T) {
+	const maxSize = 1000
+	cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	cfg.MaxSizePerMsg = maxSize
+	cfg.EntryCodecs = []Codec{testCodec(5), FlateCodec{}}
+	r := newRaft(cfg)
+	r.becomeCandidate()
+	r.becomeLeader()
+	for i := 0; i < 100; i++ {
+		require.True(t, r.appendEntry(pb.Entry{Data: bytes.Repeat([]byte("a"), 100)}))
+	}
+	r.readMessages()
+
+	pr := r.trk.Progress[2]
+	pr.Codecs = 1 << FlateCodecID
+	pr.BecomeReplicate()
+	for r.maybeSendAppend(2, false /* sendIfEmpty */) {
+	}
+	msgs := r.readMessages()
+	// Without compression, only 9 entries fit into a MsgApp.
+	require.Less(t, len(msgs), 100/9)
+
+	follower := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	follower.codecs = []Codec{FlateCodec{}}
+	follower.becomeFollower(r.Term, 1)
+	for _, m := range msgs {
+		require.Equal(t, pb.MsgApp, m.Type)
+		require.Equal(t, uint32(FlateCodecID), m.EntriesCodec)
+		require.Empty(t, m.Entries)
+		require.LessOrEqual(t, len(m.CompressedEntries), maxSize)
+		require.NoError(t, follower.Step(m))
+	}
+	require.Equal(t, r.raftLog.allEntries(), follower.raftLog.allEntries())
+}
+
+type errCodec struct{}
+
+func (errCodec) ID() uint32                        { return 5 }
+func (errCodec) Compress([]byte) ([]byte, error)   { return nil, errors.New("compress") }
+func (errCodec) Decompress([]byte) ([]byte, error) { return nil, errors.New("decompress") }
+
+// TestUncompressedAppendSize checks that the entries fetched to be compressed
+// are cut down to MaxSizePerMsg when they are sent uncompressed: when they are
+// below the compression threshold, or fail to compress.
+func TestUncompressedAppendSize(t *testing.T) {
+	const maxSize = 100
+	for _, tt := range []struct {
+		name      string
+		codec     Codec
+		threshold uint64
+	}{
+		{name: "threshold", codec: FlateCodec{}, threshold: 1 << 20},
+		{name: "error", codec: errCodec{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+			cfg.MaxSizePerMsg = maxSize
+			cfg.EntryCodecs = []Codec{tt.codec}
+			cfg.EntryCompressionThreshold = tt.threshold
+			r := newRaft(cfg)
+			r.becomeCandidate()
+			r.becomeLeader()
+			for i := 0; i < 100; i++ {
+				require.True(t, r.appendEntry(pb.Entry{Data: []byte("foo")}))
+			}
+			r.readMessages()
+			// The previous entries compressed very well.
+			r.compressionRatio = maxCompressionRatio
+
+			pr := r.trk.Progress[2]
+			pr.Codecs = 1 << tt.codec.ID()
+			pr.BecomeReplicate()
+			require.True(t, r.maybeSendAppend(2, false /* sendIfEmpty */))
+			msgs := r.readMessages()
+			require.Len(t, msgs, 1)
+			require.Zero(t, msgs[0].EntriesCodec)
+			require.NotEmpty(t, msgs[0].Entries)
+			require.LessOrEqual(t, entsSize(msgs[0].Entries), entryEncodingSize(maxSize))
+			require.Equal(t, msgs[0].Index+uint64(len(msgs[0].Entries))+1, pr.Next)
+		})
+	}
+}
+
+// TestCompressedAppendUnsupported checks that a follower drops a MsgApp with
+// entries compressed by a codec it doesn't support.
+func TestCompressedAppendUnsupported(t *testing.T) {
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	r.becomeFollower(1, 1)
+	require.NoError(t, r.Step(pb.Message{
+		From: 1, To: 2, Type: pb.MsgApp, Term: 1,
+		EntriesCodec: FlateCodecID, CompressedEntries: []byte("foo"),
+	}))
+	require.Empty(t, r.readMessages())
+	require.Equal(t, uint64(0), r.raftLog.lastIndex())
+}
+
+// TestCodecsAdvertised checks that followers advertise their codecs to the
+// leader.
+func TestCodecsAdvertised(t *testing.T) {
+	cfg := newTestConfig(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	cfg.EntryCodecs = []Codec{FlateCodec{}, testCodec(5)}
+	r := newRaft(cfg)
+	r.becomeFollower(1, 1)
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Type: pb.MsgHeartbeat, Term: 1}))
+	msgs := r.readMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, uint64(1<<FlateCodecID|1<<5), msgs[0].SupportedCodecs)
+
+	leader := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	leader.becomeCandidate()
+	leader.becomeLeader()
+	msgs[0].Term = leader.Term
+	require.NoError(t, leader.Step(msgs[0]))
+	require.Equal(t, uint64(1<<FlateCodecID|1<<5), leader.trk.Progress[2].Codecs)
+	// The leader doesn't compress anything since it has no codecs.
+	require.Nil(t, leader.appendCodec(leader.trk.Progress[2]))
+}
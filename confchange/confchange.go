@@ -112,6 +112,8 @@ func (c Changer) LeaveJoint() (tracker.Config, tracker.ProgressMap, error) {
 
 		if !isVoter && !isLearner {
 			delete(trk, id)
+			nilAwareDelete(&cfg.Witnesses, id)
+			setPriority(&cfg, id, 0)
 		}
 	}
 	*outgoingPtr(&cfg.Voters) = nil
@@ -156,12 +158,42 @@ func (c Changer) apply(cfg *tracker.Config, trk tracker.ProgressMap, ccs ...pb.C
 			continue
 		}
 		switch cc.Type {
-		case pb.ConfChangeAddNode:
-			c.makeVoter(cfg, trk, cc.NodeID)
-		case pb.ConfChangeAddLearnerNode:
-			c.makeLearner(cfg, trk, cc.NodeID)
+		case pb.ConfChangeAddNode, pb.ConfChangeAddLearnerNode, pb.ConfChangeAddLearnerNodeAutoPromote:
+			// A witness doesn't have the payloads of the log entries, so it
+			// can't turn into a peer that needs them.
+			if _, ok := cfg.Witnesses[cc.NodeID]; ok {
+				return fmt.Errorf("can't turn witness %d into a voter or learner", cc.NodeID)
+			}
+			switch cc.Type {
+			case pb.ConfChangeAddNode:
+				c.makeVoter(cfg, trk, cc.NodeID)
+			case pb.ConfChangeAddLearnerNode:
+				c.makeLearner(cfg, trk, cc.NodeID)
+				nilAwareDelete(&cfg.LearnersAutoPromote, cc.NodeID)
+			default:
+				// Demoting a voter only to promote it back right away would
+				// be pointless.
+				if pr, ok := trk[cc.NodeID]; ok && !pr.IsLearner {
+					return fmt.Errorf("can't auto-promote voter %d", cc.NodeID)
+				}
+				c.makeLearner(cfg, trk, cc.NodeID)
+				nilAwareAdd(&cfg.LearnersAutoPromote, cc.NodeID)
+			}
+		case pb.ConfChangeAddWitness:
+			if err := c.makeWitness(cfg, trk, cc.NodeID); err != nil {
+				return err
+			}
 		case pb.ConfChangeRemoveNode:
 			c.remove(cfg, trk, cc.NodeID)
+		case pb.ConfChangeSetPriority:
+			// A witness can never become the leader, so a priority would be
+			// meaningless for it.
+			if pr, ok := trk[cc.NodeID]; !ok {
+				return fmt.Errorf("can't set priority of unknown node %d", cc.NodeID)
+			} else if pr.IsWitness {
+				return fmt.Errorf("can't set priority of witness %d", cc.NodeID)
+			}
+			setPriority(cfg, cc.NodeID, cc.Priority)
 		case pb.ConfChangeUpdateNode:
 		default:
 			return fmt.Errorf("unexpected conf type %d", cc.Type)
@@ -185,6 +217,7 @@ func (c Changer) makeVoter(cfg *tracker.Config, trk tracker.ProgressMap, id uint
 	pr.IsLearner = false
 	nilAwareDelete(&cfg.Learners, id)
 	nilAwareDelete(&cfg.LearnersNext, id)
+	nilAwareDelete(&cfg.LearnersAutoPromote, id)
 	incoming(cfg.Voters)[id] = struct{}{}
 }
 
@@ -227,7 +260,31 @@ func (c Changer) makeLearner(cfg *tracker.Config, trk tracker.ProgressMap, id ui
 	}
 }
 
-// remove this peer as a voter or learner from the incoming config.
+// makeWitness adds the given ID as a witness in the incoming majority config,
+// or converts a learner into one. Voters can't be turned into witnesses.
+func (c Changer) makeWitness(cfg *tracker.Config, trk tracker.ProgressMap, id uint64) error {
+	pr := trk[id]
+	if pr == nil {
+		c.initProgress(cfg, trk, id, false /* isLearner */)
+		trk[id].IsWitness = true
+		nilAwareAdd(&cfg.Witnesses, id)
+		return nil
+	}
+	if !pr.IsWitness && !pr.IsLearner {
+		return fmt.Errorf("can't turn voter %d into a witness", id)
+	}
+	pr.IsLearner = false
+	pr.IsWitness = true
+	nilAwareDelete(&cfg.Learners, id)
+	nilAwareDelete(&cfg.LearnersAutoPromote, id)
+	// A witness can never become the leader, so its priority is dropped.
+	setPriority(cfg, id, 0)
+	nilAwareAdd(&cfg.Witnesses, id)
+	incoming(cfg.Voters)[id] = struct{}{}
+	return nil
+}
+
+// remove this peer as a voter, witness or learner from the incoming config.
 func (c Changer) remove(cfg *tracker.Config, trk tracker.ProgressMap, id uint64) {
 	if _, ok := trk[id]; !ok {
 		return
@@ -236,10 +293,13 @@ func (c Changer) remove(cfg *tracker.Config, trk tracker.ProgressMap, id uint64)
 	delete(incoming(cfg.Voters), id)
 	nilAwareDelete(&cfg.Learners, id)
 	nilAwareDelete(&cfg.LearnersNext, id)
+	nilAwareDelete(&cfg.LearnersAutoPromote, id)
 
 	// If the peer is still a voter in the outgoing config, keep the Progress.
 	if _, onRight := outgoing(cfg.Voters)[id]; !onRight {
 		delete(trk, id)
+		nilAwareDelete(&cfg.Witnesses, id)
+		setPriority(cfg, id, 0)
 	}
 }
 
@@ -262,6 +322,7 @@ func (c Changer) initProgress(cfg *tracker.Config, trk tracker.ProgressMap, id u
 		Match:     0,
 		Next:      max(c.LastIndex, 1), // invariant: Match < Next
 		Inflights: tracker.NewInflights(c.Tracker.MaxInflight, c.Tracker.MaxInflightBytes),
+		RateLimit: c.Tracker.NewRateLimit(),
 		IsLearner: isLearner,
 		// When a node is first added, we should mark it as recently active.
 		// Otherwise, CheckQuorum may cause us to step down if it is invoked
@@ -302,6 +363,36 @@ func checkInvariants(cfg tracker.Config, trk tracker.ProgressMap) error {
 			return fmt.Errorf("%d is in LearnersNext, but is already marked as learner", id)
 		}
 	}
+	// Witnesses are voters, and are marked as such.
+	for id := range cfg.Witnesses {
+		if _, ok := cfg.Voters.IDs()[id]; !ok {
+			return fmt.Errorf("%d is in Witnesses, but not in Voters", id)
+		}
+		if !trk[id].IsWitness {
+			return fmt.Errorf("%d is in Witnesses, but is not marked as witness", id)
+		}
+	}
+	for id, pr := range trk {
+		if _, ok := cfg.Witnesses[id]; pr.IsWitness && !ok {
+			return fmt.Errorf("%d is marked as witness, but is not in Witnesses", id)
+		}
+	}
+	// Priorities are only set for voters and learners, other than witnesses.
+	for id, prio := range cfg.Priorities {
+		if prio == 0 {
+			return fmt.Errorf("%d has zero priority in Priorities", id)
+		}
+		if pr, ok := trk[id]; !ok {
+			return fmt.Errorf("%d is in Priorities, but has no progress", id)
+		} else if pr.IsWitness {
+			return fmt.Errorf("%d is in Priorities, but is a witness", id)
+		}
+	}
+	for id := range cfg.LearnersAutoPromote {
+		if _, ok := cfg.Learners[id]; !ok {
+			return fmt.Errorf("%d is in LearnersAutoPromote, but not in Learners", id)
+		}
+	}
 	// Conversely Learners and Voters doesn't intersect at all.
 	for id := range cfg.Learners {
 		if _, ok := outgoing(cfg.Voters)[id]; ok {
@@ -368,6 +459,22 @@ func nilAwareAdd(m *map[uint64]struct{}, id uint64) {
 	(*m)[id] = struct{}{}
 }
 
+// setPriority sets the priority of the given node, nil'ing the map if it is
+// empty after.
+func setPriority(cfg *tracker.Config, id, prio uint64) {
+	if prio == 0 {
+		delete(cfg.Priorities, id)
+		if len(cfg.Priorities) == 0 {
+			cfg.Priorities = nil
+		}
+		return
+	}
+	if cfg.Priorities == nil {
+		cfg.Priorities = map[uint64]uint64{}
+	}
+	cfg.Priorities[id] = prio
+}
+
 // nilAwareDelete deletes from a map, nil'ing the map itself if it is empty after.
 func nilAwareDelete(m *map[uint64]struct{}, id uint64) {
 	if *m == nil {
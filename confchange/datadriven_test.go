@@ -43,8 +43,11 @@ func TestConfChangeDataDriven(t *testing.T) {
 		// syntax:
 		// - vn: make n a voter,
 		// - ln: make n a learner,
-		// - rn: remove n, and
-		// - un: update n.
+		// - an: make n a learner that is promoted automatically,
+		// - wn: make n a witness,
+		// - rn: remove n,
+		// - un: update n, and
+		// - pn=k: set the priority of n to k.
 		datadriven.RunTest(t, path, func(t *testing.T, d *datadriven.TestData) string {
 			defer func() {
 				c.LastIndex++
@@ -64,14 +67,30 @@ func TestConfChangeDataDriven(t *testing.T) {
 					cc.Type = pb.ConfChangeAddNode
 				case 'l':
 					cc.Type = pb.ConfChangeAddLearnerNode
+				case 'a':
+					cc.Type = pb.ConfChangeAddLearnerNodeAutoPromote
+				case 'w':
+					cc.Type = pb.ConfChangeAddWitness
 				case 'r':
 					cc.Type = pb.ConfChangeRemoveNode
 				case 'u':
 					cc.Type = pb.ConfChangeUpdateNode
+				case 'p':
+					cc.Type = pb.ConfChangeSetPriority
 				default:
 					return fmt.Sprintf("unknown input: %s", tok)
 				}
-				id, err := strconv.ParseUint(tok[1:], 10, 64)
+				idStr := tok[1:]
+				if cc.Type == pb.ConfChangeSetPriority {
+					var prioStr string
+					idStr, prioStr, _ = strings.Cut(idStr, "=")
+					prio, err := strconv.ParseUint(prioStr, 10, 64)
+					if err != nil {
+						return err.Error()
+					}
+					cc.Priority = prio
+				}
+				id, err := strconv.ParseUint(idStr, 10, 64)
 				if err != nil {
 					return err.Error()
 				}
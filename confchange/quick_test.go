@@ -154,7 +154,9 @@ func (confChanges) Generate(rand *rand.Rand, _ int) reflect.Value {
 		return 1 + uint64(num())
 	}
 	typ := func() pb.ConfChangeType {
-		return pb.ConfChangeType(rand.Intn(len(pb.ConfChangeType_name)))
+		// NB: witnesses are not generated, as they can't be converted to and
+		// from voters, so random changes involving them would mostly fail.
+		return pb.ConfChangeType(rand.Intn(int(pb.ConfChangeAddWitness)))
 	}
 	return reflect.ValueOf(genCC(num, id, typ))
 }
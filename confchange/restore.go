@@ -15,6 +15,8 @@
 package confchange
 
 import (
+	"slices"
+
 	pb "go.etcd.io/raft/v3/raftpb"
 	"go.etcd.io/raft/v3/tracker"
 )
@@ -52,14 +54,22 @@ func toConfChangeSingle(cs pb.ConfState) (out []pb.ConfChangeSingle, in []pb.Con
 	//
 	// as desired.
 
+	// Witnesses are added as such, both in the outgoing and incoming config.
+	witnesses := make(map[uint64]struct{}, len(cs.Witnesses))
+	for _, id := range cs.Witnesses {
+		witnesses[id] = struct{}{}
+	}
+	addVoter := func(id uint64) pb.ConfChangeSingle {
+		if _, ok := witnesses[id]; ok {
+			return pb.ConfChangeSingle{Type: pb.ConfChangeAddWitness, NodeID: id}
+		}
+		return pb.ConfChangeSingle{Type: pb.ConfChangeAddNode, NodeID: id}
+	}
+
 	for _, id := range cs.VotersOutgoing {
 		// If there are outgoing voters, first add them one by one so that the
 		// (non-joint) config has them all.
-		out = append(out, pb.ConfChangeSingle{
-			Type:   pb.ConfChangeAddNode,
-			NodeID: id,
-		})
-
+		out = append(out, addVoter(id))
 	}
 
 	// We're done constructing the outgoing slice, now on to the incoming one
@@ -74,14 +84,19 @@ func toConfChangeSingle(cs pb.ConfState) (out []pb.ConfChangeSingle, in []pb.Con
 	}
 	// Then we'll add the incoming voters and learners.
 	for _, id := range cs.Voters {
-		in = append(in, pb.ConfChangeSingle{
-			Type:   pb.ConfChangeAddNode,
-			NodeID: id,
-		})
+		in = append(in, addVoter(id))
+	}
+	autoPromote := make(map[uint64]struct{}, len(cs.LearnersAutoPromote))
+	for _, id := range cs.LearnersAutoPromote {
+		autoPromote[id] = struct{}{}
 	}
 	for _, id := range cs.Learners {
+		typ := pb.ConfChangeAddLearnerNode
+		if _, ok := autoPromote[id]; ok {
+			typ = pb.ConfChangeAddLearnerNodeAutoPromote
+		}
 		in = append(in, pb.ConfChangeSingle{
-			Type:   pb.ConfChangeAddLearnerNode,
+			Type:   typ,
 			NodeID: id,
 		})
 	}
@@ -93,6 +108,20 @@ func toConfChangeSingle(cs pb.ConfState) (out []pb.ConfChangeSingle, in []pb.Con
 			NodeID: id,
 		})
 	}
+	// Finally, set the priorities, in the order of IDs to keep this
+	// deterministic.
+	ids := make([]uint64, 0, len(cs.Priorities))
+	for id := range cs.Priorities {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		in = append(in, pb.ConfChangeSingle{
+			Type:     pb.ConfChangeSetPriority,
+			NodeID:   id,
+			Priority: cs.Priorities[id],
+		})
+	}
 	return out, in
 }
 
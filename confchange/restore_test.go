@@ -79,6 +79,38 @@ func (rndConfChange) Generate(rand *rand.Rand, _ int) reflect.Value {
 		}
 	}
 
+	// Some of the incoming voters may be witnesses. If such a voter is also in
+	// the outgoing config, it is a witness there as well.
+	for _, id := range cs.Voters {
+		if rand.Intn(4) == 0 {
+			cs.Witnesses = append(cs.Witnesses, id)
+		}
+	}
+
+	// Some of the nodes other than witnesses may have a priority.
+	witnesses := make(map[uint64]bool, len(cs.Witnesses))
+	for _, id := range cs.Witnesses {
+		witnesses[id] = true
+	}
+	for _, sl := range [][]uint64{cs.Voters, cs.Learners, cs.VotersOutgoing} {
+		for _, id := range sl {
+			if witnesses[id] || rand.Intn(3) != 0 {
+				continue
+			}
+			if cs.Priorities == nil {
+				cs.Priorities = map[uint64]uint64{}
+			}
+			cs.Priorities[id] = uint64(1 + rand.Intn(5))
+		}
+	}
+
+	// Some of the learners may be promoted automatically.
+	for _, id := range cs.Learners {
+		if rand.Intn(2) == 0 {
+			cs.LearnersAutoPromote = append(cs.LearnersAutoPromote, id)
+		}
+	}
+
 	cs.AutoLeave = len(cs.VotersOutgoing) > 0 && rand.Intn(2) == 1
 	return reflect.ValueOf(rndConfChange(cs))
 }
@@ -103,6 +135,8 @@ func TestRestore(t *testing.T) {
 			cs.Learners,
 			cs.VotersOutgoing,
 			cs.LearnersNext,
+			cs.Witnesses,
+			cs.LearnersAutoPromote,
 		} {
 			sort.Slice(sl, func(i, j int) bool { return sl[i] < sl[j] })
 		}
@@ -126,6 +160,8 @@ func TestRestore(t *testing.T) {
 		{Voters: ids(1, 2, 3)},
 		{Voters: ids(1, 2, 3), Learners: ids(4, 5, 6)},
 		{Voters: ids(1, 2, 3), Learners: ids(5), VotersOutgoing: ids(1, 2, 4, 6), LearnersNext: ids(4)},
+		{Voters: ids(1, 2, 3), Learners: ids(4), VotersOutgoing: ids(1, 5), Priorities: map[uint64]uint64{2: 3, 4: 1, 5: 2}},
+		{Voters: ids(1, 2), Learners: ids(3, 4, 5), LearnersAutoPromote: ids(3, 5)},
 	} {
 		if !f(cs) {
 			t.FailNow() // f() already logged a nice t.Error()
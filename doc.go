@@ -105,7 +105,10 @@ in the order they were returned by Ready.
 Second, all persisted log entries must be made available via an
 implementation of the Storage interface. The provided MemoryStorage
 type can be used for this (if you repopulate its state upon a
-restart), or you can supply your own disk-backed implementation.
+restart), or you can supply your own disk-backed implementation. The log
+entries and the state machine snapshots can be kept on different backends by
+implementing the LogStorage and StateStorage interfaces separately, and setting
+Config.LogStorage and Config.StateStorage instead of Config.Storage.
 
 Third, when you receive a message from another node, pass it to Node.Step:
 
@@ -153,6 +156,16 @@ If the proposal is committed, data will appear in committed entries with type
 raftpb.EntryNormal. There is no guarantee that a proposed command will be
 committed; you may have to re-propose after a timeout.
 
+To serve a linearizable read from the local state machine, on the leader or a
+follower, call:
+
+	w, err := n.ReadIndexWithWaiter(ctx)
+
+and serve the read once w.Wait(ctx) returns nil, which happens when the read
+index has been applied. The read fails with ErrReadIndexDropped if there is no
+known leader, and with ErrReadIndexLeaderChanged or ErrReadIndexTimeout if the
+request may have been lost; in these cases it can be retried.
+
 To add or remove a node in a cluster, build ConfChange struct 'cc' and call:
 
 	n.ProposeConfChange(ctx, cc)
@@ -164,6 +177,24 @@ raftpb.EntryConfChange will be returned. You must apply it to node through:
 	cc.Unmarshal(data)
 	n.ApplyConfChange(cc)
 
+A node can also be added as a witness (raftpb.ConfChangeAddWitness). A witness
+is a voter that never becomes the leader, and receives the normal entries of
+the log and the snapshots without their payloads. The committed normal entries
+are returned in Ready.CommittedEntries without payloads too, and the
+application running a witness must not apply them to its state machine, only
+the configuration changes. A witness can't be turned into a regular voter or
+learner, and a voter can't be turned into a witness.
+
+Nodes can be given election priorities (raftpb.ConfChangeSetPriority). Voters
+with a lower priority than the highest one wait longer before campaigning, and
+a follower with a higher priority than the leader asks it to transfer the
+leadership once it has caught up.
+
+A learner added with raftpb.ConfChangeAddLearnerNodeAutoPromote is promoted to
+a voter by the leader once it has caught up with the log (see
+Config.AutoPromoteMaxLag). The leader proposes the conf change itself, and
+reports the outcome in Ready.LearnerPromotions.
+
 Note: An ID represents a unique node in a cluster for all time. A
 given ID MUST be used only once even if the old node has been removed.
 This means that for example IP addresses make poor node IDs since they
@@ -257,6 +288,15 @@ application to the local state machine (apply). Those will look something like:
 	  }
 	}
 
+The apply thread can fall behind the rest of the node, in which case the
+MsgStorageApply messages pile up in its queue. Config.MaxApplyLag bounds the
+number of committed entries handed out ahead of the apply progress, and
+Config.MaxProposalApplyLag makes the node drop proposals while too many
+committed entries are not applied. The apply progress is acknowledged by the
+MsgStorageApply responses, and the apply thread can report it earlier, along
+with the depth of its queue, using Node.ReportApplyProgress. The state of the
+backpressure is reported in Status.Apply.
+
 # Implementation notes
 
 This implementation is up to date with the final Raft thesis
@@ -365,6 +405,19 @@ stale log entries:
 	indicates that the snapshot succeeded and the leader sets follower's
 	progress to probe and resumes its log replication.
 
+	'MsgSnapChunk' carries a chunk of the snapshot data, when the leader streams
+	snapshots (see Config.SnapshotSource) instead of sending them in a single
+	'MsgSnap'. The Index field holds the byte offset of the chunk, and the
+	RejectHint field holds the total size of the data. The follower writes the
+	chunks to its Config.SnapshotSink, and restores the snapshot once all of
+	them have been received.
+
+	'MsgSnapChunkResp' is a response to 'MsgSnapChunk'. Its Index field holds
+	the number of bytes of the snapshot data the follower has received, from
+	which the leader continues the transfer, and the RejectHint field holds the
+	index of the snapshot. A rejection aborts the transfer, and the leader
+	considers the follower's progress as probe.
+
 	'MsgHeartbeat' sends heartbeat from leader. When 'MsgHeartbeat' is passed
 	to candidate and message's term is higher than candidate's, the candidate
 	reverts back to follower and updates its committed index from the one in
@@ -378,6 +431,23 @@ stale log entries:
 	responded. And only when the leader's last committed index is greater than
 	follower's Match index, the leader runs 'sendAppend` method.
 
+	If leader leases are enabled (see Config.LeaseTicks), 'MsgHeartbeat' also
+	carries the leader's local time in the Index field. The follower promises
+	not to vote for another candidate for the lease duration, and echoes the
+	time back in 'MsgHeartbeatResp', from which the leader computes the lease
+	expiry supported by a quorum.
+
+	'MsgQuiesce' is sent by the leader instead of 'MsgHeartbeat' when quiescence
+	is enabled (see Config.Quiescence) and all followers have caught up with its
+	log. It carries the leader's last log index and term, and its commit index.
+	A follower whose log matches stops its election timer until it is woken up.
+	Otherwise, it responds with 'MsgUnquiesce'.
+
+	'MsgUnquiesce' wakes up a quiesced node. When passed to a node's Step method
+	locally, or when received by the leader from a follower, the node restarts
+	its timers. A leader sends heartbeats to wake up its followers, and a
+	follower woken up locally sends 'MsgUnquiesce' to its leader.
+
 	'MsgUnreachable' tells that request(message) wasn't delivered. When
 	'MsgUnreachable' is passed to leader's Step method, the leader discovers
 	that the follower that sent this 'MsgUnreachable' is not reachable, often
@@ -0,0 +1,269 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func newTestConfig(s *Storage) *raft.Config {
+	return &raft.Config{
+		ID:              1,
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		Storage:         s,
+		MaxSizePerMsg:   1 << 20,
+		MaxInflightMsgs: 256,
+		Logger:          discardLogger,
+	}
+}
+
+// stabilize handles the Ready structs emitted by the node until it goes quiet,
+// persisting them in the storage. It returns the data of the committed normal
+// entries.
+func stabilize(t *testing.T, n raft.Node, s *Storage) []string {
+	t.Helper()
+	var committed []string
+	for {
+		select {
+		case rd := <-n.Ready():
+			require.NoError(t, s.Save(rd.HardState, rd.Entries, rd.Snapshot, rd.MustSync))
+			for _, e := range rd.CommittedEntries {
+				switch e.Type {
+				case pb.EntryNormal:
+					if len(e.Data) > 0 {
+						committed = append(committed, string(e.Data))
+					}
+				case pb.EntryConfChange:
+					var cc pb.ConfChange
+					require.NoError(t, cc.Unmarshal(e.Data))
+					n.ApplyConfChange(cc)
+				}
+			}
+			n.Advance()
+		case <-time.After(10 * time.Millisecond):
+			return committed
+		}
+	}
+}
+
+// proposeAll proposes the given commands on a single-node group and waits for
+// them to commit.
+func proposeAll(t *testing.T, n raft.Node, s *Storage, cmds ...string) []string {
+	t.Helper()
+	var committed []string
+	for _, cmd := range cmds {
+		require.NoError(t, n.Propose(context.Background(), []byte(cmd)))
+		committed = append(committed, stabilize(t, n, s)...)
+	}
+	return committed
+}
+
+// startSingleNode bootstraps a single-node group on an empty storage, elects
+// it, and commits the given commands.
+func startSingleNode(t *testing.T, s *Storage, cmds ...string) {
+	t.Helper()
+	n := raft.StartNode(newTestConfig(s), []raft.Peer{{ID: 1}})
+	defer n.Stop()
+	stabilize(t, n, s)
+	require.NoError(t, n.Campaign(context.Background()))
+	stabilize(t, n, s)
+	require.Equal(t, cmds, proposeAll(t, n, s, cmds...))
+}
+
+// restartSingleNode restarts the single-node group from the storage, checks
+// that the expected commands are committed again, and that the group can make
+// progress.
+func restartSingleNode(t *testing.T, s *Storage, wantCommitted []string) {
+	t.Helper()
+	n := raft.RestartNode(newTestConfig(s))
+	defer n.Stop()
+	require.Equal(t, wantCommitted, stabilize(t, n, s))
+	require.NoError(t, n.Campaign(context.Background()))
+	stabilize(t, n, s)
+	require.Equal(t, []string{"after-restart"}, proposeAll(t, n, s, "after-restart"))
+
+	st := n.Status()
+	require.Equal(t, raft.StateLeader, st.RaftState)
+	last, err := s.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, last, st.Commit)
+}
+
+// activeSegment returns the path of the last WAL segment.
+func activeSegment(t *testing.T, s *Storage) string {
+	seqs, err := listSegments(s.walDir())
+	require.NoError(t, err)
+	return filepath.Join(s.walDir(), segmentName(seqs[len(seqs)-1]))
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	return fi.Size()
+}
+
+// crashDuringAppend simulates a crash while an unsynced append of entries is
+// in flight. The damage function is then applied to the bytes written by the
+// append. Returns the storage reopened after the crash.
+func crashDuringAppend(t *testing.T, s *Storage, damage func(t *testing.T, path string, off, size int64)) *Storage {
+	t.Helper()
+	path := activeSegment(t, s)
+	synced := fileSize(t, path)
+	last, err := s.LastIndex()
+	require.NoError(t, err)
+	hs, _, err := s.InitialState()
+	require.NoError(t, err)
+
+	var ents []pb.Entry
+	for i := uint64(1); i <= 3; i++ {
+		ents = append(ents, pb.Entry{Index: last + i, Term: hs.Term, Data: []byte("lost")})
+	}
+	require.NoError(t, s.Save(pb.HardState{}, ents, pb.Snapshot{}, false /* sync */))
+	damage(t, path, synced, fileSize(t, path)-synced)
+
+	// The storage is abandoned without closing it, as would happen in a crash.
+	reopened, err := Open(s.dir, &Options{Logger: discardLogger})
+	require.NoError(t, err)
+	return reopened
+}
+
+func TestRecoveryTornWrite(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		damage func(t *testing.T, path string, off, size int64)
+	}{{
+		name: "truncated-header",
+		damage: func(t *testing.T, path string, off, size int64) {
+			require.NoError(t, os.Truncate(path, off+recordHeaderSize/2))
+		},
+	}, {
+		name: "truncated-payload",
+		damage: func(t *testing.T, path string, off, size int64) {
+			require.NoError(t, os.Truncate(path, off+size-1))
+		},
+	}, {
+		name: "zeroed-tail",
+		damage: func(t *testing.T, path string, off, size int64) {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			require.NoError(t, err)
+			defer f.Close()
+			_, err = f.WriteAt(make([]byte, size), off)
+			require.NoError(t, err)
+		},
+	}, {
+		name: "corrupted-last-record",
+		damage: func(t *testing.T, path string, off, size int64) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			require.NoError(t, err)
+			defer f.Close()
+			b := make([]byte, 1)
+			_, err = f.ReadAt(b, off+size-1)
+			require.NoError(t, err)
+			b[0] ^= 0xff
+			_, err = f.WriteAt(b, off+size-1)
+			require.NoError(t, err)
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStorage(t, t.TempDir(), 0)
+			cmds := []string{"a", "b", "c"}
+			startSingleNode(t, s, cmds...)
+			wantLast, err := s.LastIndex()
+			require.NoError(t, err)
+			wantHS, _, err := s.InitialState()
+			require.NoError(t, err)
+
+			s = crashDuringAppend(t, s, tt.damage)
+			defer s.Close()
+			hs, _, err := s.InitialState()
+			require.NoError(t, err)
+			require.Equal(t, wantHS, hs)
+			last, err := s.LastIndex()
+			require.NoError(t, err)
+			// All entries of a damaged append may be lost, but none of the synced
+			// ones.
+			require.GreaterOrEqual(t, last, wantLast)
+			require.LessOrEqual(t, last, wantLast+3)
+			if tt.name == "truncated-header" {
+				require.Equal(t, wantLast, last)
+			}
+
+			restartSingleNode(t, s, cmds)
+		})
+	}
+}
+
+// TestRecoveryAfterCompaction restarts a node whose log was compacted and
+// whose WAL has been split into multiple segments.
+func TestRecoveryAfterCompaction(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStorage(t, dir, 256)
+	var cmds []string
+	for i := 0; i < 30; i++ {
+		cmds = append(cmds, fmt.Sprintf("cmd-%d", i))
+	}
+	startSingleNode(t, s, cmds...)
+	require.Greater(t, len(s.wal.segs), 2)
+
+	hs, _, err := s.InitialState()
+	require.NoError(t, err)
+	_, err = s.CreateSnapshot(hs.Commit-10, &pb.ConfState{Voters: []uint64{1}}, []byte("snap"))
+	require.NoError(t, err)
+	require.NoError(t, s.Compact(hs.Commit-10))
+	s = crashDuringAppend(t, s, func(t *testing.T, path string, off, size int64) {
+		require.NoError(t, os.Truncate(path, off+size/2))
+	})
+	defer s.Close()
+
+	snap, err := s.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, []byte("snap"), snap.Data)
+	// Only the commands after the snapshot are replayed.
+	restartSingleNode(t, s, cmds[len(cmds)-10:])
+}
+
+// TestRecoveryCorruption checks that damage to the WAL that can't be the
+// result of a torn write is reported.
+func TestRecoveryCorruption(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	startSingleNode(t, s, "a", "b", "c")
+	require.NoError(t, s.Close())
+
+	path := activeSegment(t, s)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, recordHeaderSize)
+	require.NoError(t, err)
+	b[0] ^= 0xff
+	_, err = f.WriteAt(b, recordHeaderSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = Open(s.dir, &Options{Logger: discardLogger})
+	require.True(t, errors.Is(err, ErrCorrupt), "%v", err)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestorage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// snapshotName returns the name of the file holding the snapshot at the given
+// log position.
+func snapshotName(term, index uint64) string {
+	return fmt.Sprintf("%016x-%016x.snap", term, index)
+}
+
+// saveSnapshot durably writes the snapshot into dir. The file consists of a
+// 4-byte checksum followed by the marshaled snapshot.
+func saveSnapshot(dir string, snap pb.Snapshot) error {
+	data, err := snap.Marshal()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint32(buf, crc32.Checksum(data, crcTable))
+	buf = append(buf, data...)
+
+	path := filepath.Join(dir, snapshotName(snap.Metadata.Term, snap.Metadata.Index))
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// loadSnapshot reads the snapshot at the given log position from dir.
+func loadSnapshot(dir string, term, index uint64) (pb.Snapshot, error) {
+	path := filepath.Join(dir, snapshotName(term, index))
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return pb.Snapshot{}, err
+	}
+	if len(buf) < 4 || binary.LittleEndian.Uint32(buf) != crc32.Checksum(buf[4:], crcTable) {
+		return pb.Snapshot{}, fmt.Errorf("%w: bad checksum in snapshot %s", ErrCorrupt, path)
+	}
+	var snap pb.Snapshot
+	if err := snap.Unmarshal(buf[4:]); err != nil {
+		return pb.Snapshot{}, fmt.Errorf("%w: snapshot %s: %v", ErrCorrupt, path, err)
+	}
+	return snap, nil
+}
+
+// removeSnapshotsExcept removes all snapshot files from dir other than the one
+// at the given log position, including leftovers of interrupted writes.
+func removeSnapshotsExcept(dir string, term, index uint64) error {
+	names, err := readDirNames(dir)
+	if err != nil {
+		return err
+	}
+	keep := snapshotName(term, index)
+	var removed bool
+	for _, name := range names {
+		if name == keep || !(strings.HasSuffix(name, ".snap") || strings.HasSuffix(name, ".snap.tmp")) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		removed = true
+	}
+	if removed {
+		return syncDir(dir)
+	}
+	return nil
+}
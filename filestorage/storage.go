@@ -0,0 +1,645 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package filestorage provides a durable implementation of raft.Storage backed by
+files in a local directory.
+
+The directory holds a write-ahead log (WAL) split into segment files, and the
+most recent snapshot:
+
+	<dir>/wal/0000000000000000.wal
+	<dir>/wal/0000000000000001.wal
+	<dir>/snap/0000000000000005-000000000000002a.snap
+
+Every WAL record is checksummed. When the storage is opened, an incomplete or
+damaged record at the end of the last segment is considered the result of a
+crash during an unsynced write and is truncated away; damage anywhere else is
+reported as ErrCorrupt. Once a segment reaches Options.SegmentSize, a new one
+is started. Segments are removed after all of the log entries in them have been
+compacted away with Compact.
+
+The Storage keeps the uncompacted part of the log in memory, so reads never
+touch the disk. Writes can be driven directly from a Ready:
+
+	if err := s.Save(rd.HardState, rd.Entries, rd.Snapshot, rd.MustSync); err != nil {
+		// handle error
+	}
+
+or, when Config.AsyncStorageWrites is used, from the MsgStorageAppend messages
+handed to the local append thread:
+
+	if err := s.StorageAppend(m); err != nil {
+		// handle error
+	}
+	// deliver m.Responses
+*/
+package filestorage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// ErrCorrupt is returned by Open when the on-disk state is damaged in a way
+// that can't be attributed to an interrupted write.
+var ErrCorrupt = errors.New("filestorage: corrupt data")
+
+// defaultSegmentSize is the default target size of a WAL segment.
+const defaultSegmentSize = 64 << 20
+
+// Options configures a Storage.
+type Options struct {
+	// SegmentSize is the size in bytes after which the active WAL segment is
+	// closed and a new one is started. Zero means 64 MiB.
+	SegmentSize int64
+	// Logger is used to report recovery actions. If nil, a logger writing to
+	// stderr is used.
+	Logger raft.Logger
+}
+
+// Storage implements the raft.Storage interface backed by files in a
+// directory. All mutating methods make their changes durable before
+// returning, with the exception of Save when called with sync set to false.
+type Storage struct {
+	// Protects access to all fields. Most methods of Storage are run on the
+	// raft goroutine, but the mutating methods are typically run on an
+	// application goroutine.
+	mu sync.Mutex
+
+	dir string
+	wal *wal
+
+	hardState pb.HardState
+	snapshot  pb.Snapshot
+	// ents[0] is a dummy entry identifying the last compacted log position.
+	// ents[i] has raft log position i+ents[0].Index.
+	ents []pb.Entry
+}
+
+// Open opens the Storage in the given directory, creating it if it doesn't
+// exist, and recovers the state persisted in it. opts may be nil.
+func Open(dir string, opts *Options) (*Storage, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	segmentSize := opts.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = &raft.DefaultLogger{Logger: log.New(os.Stderr, "raft", log.LstdFlags)}
+	}
+	s := &Storage{
+		dir:  dir,
+		ents: make([]pb.Entry, 1),
+	}
+	for _, d := range []string{s.walDir(), s.snapDir()} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, err
+		}
+		if err := removeTemporaryFiles(d); err != nil {
+			return nil, err
+		}
+	}
+
+	w, recs, err := openWAL(s.walDir(), segmentSize, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = w
+	if err := s.replay(recs); err != nil {
+		_ = w.close()
+		return nil, err
+	}
+	if len(w.segs) == 0 {
+		if err := w.cut(0); err != nil {
+			_ = w.close()
+			return nil, err
+		}
+	}
+	md := s.snapshot.Metadata
+	if err := removeSnapshotsExcept(s.snapDir(), md.Term, md.Index); err != nil {
+		_ = w.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Storage) walDir() string  { return filepath.Join(s.dir, "wal") }
+func (s *Storage) snapDir() string { return filepath.Join(s.dir, "snap") }
+
+// replay reconstructs the in-memory state from the records of all WAL
+// segments.
+//
+// Older segments are removed once the entries in them are compacted, so the
+// first remaining segment generally doesn't start at the beginning of the log.
+// The log is therefore rebuilt on top of the most recent compaction point, and
+// entries below it are skipped.
+func (s *Storage) replay(segs [][]record) error {
+	var base pb.Entry
+	var snapMeta *pb.SnapshotMetadata
+	for _, recs := range segs {
+		for _, rec := range recs {
+			switch rec.typ {
+			case recCompact:
+				var e pb.Entry
+				if err := e.Unmarshal(rec.data); err != nil {
+					return fmt.Errorf("%w: %v", ErrCorrupt, err)
+				}
+				if e.Index > base.Index {
+					base = e
+				}
+			case recSnapshot, recApplySnapshot:
+				var md pb.SnapshotMetadata
+				if err := md.Unmarshal(rec.data); err != nil {
+					return fmt.Errorf("%w: %v", ErrCorrupt, err)
+				}
+				snapMeta = &md
+				if rec.typ == recApplySnapshot && md.Index > base.Index {
+					base = pb.Entry{Index: md.Index, Term: md.Term}
+				}
+			}
+		}
+	}
+	if snapMeta != nil {
+		snap, err := loadSnapshot(s.snapDir(), snapMeta.Term, snapMeta.Index)
+		if err != nil {
+			return err
+		}
+		s.snapshot = snap
+	}
+
+	s.ents = []pb.Entry{base}
+	for i, recs := range segs {
+		var maxIndex uint64
+		for _, rec := range recs {
+			switch rec.typ {
+			case recEntry:
+				var e pb.Entry
+				if err := e.Unmarshal(rec.data); err != nil {
+					return fmt.Errorf("%w: %v", ErrCorrupt, err)
+				}
+				if e.Index > maxIndex {
+					maxIndex = e.Index
+				}
+				if e.Index <= base.Index {
+					continue
+				}
+				if err := s.checkAppend(e.Index); err != nil {
+					return fmt.Errorf("%w: %v", ErrCorrupt, err)
+				}
+				s.ents = append(s.ents[:e.Index-base.Index], e)
+			case recHardState:
+				if err := s.hardState.Unmarshal(rec.data); err != nil {
+					return fmt.Errorf("%w: %v", ErrCorrupt, err)
+				}
+			case recApplySnapshot:
+				s.ents = []pb.Entry{base}
+			case recSnapshot, recCompact:
+			default:
+				return fmt.Errorf("%w: unknown record type %d", ErrCorrupt, rec.typ)
+			}
+		}
+		s.wal.segs[i].maxIndex = maxIndex
+	}
+	return nil
+}
+
+// InitialState implements the raft.Storage interface.
+func (s *Storage) InitialState() (pb.HardState, pb.ConfState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hardState, s.snapshot.Metadata.ConfState, nil
+}
+
+// Entries implements the raft.Storage interface.
+func (s *Storage) Entries(lo, hi, maxSize uint64) ([]pb.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset := s.ents[0].Index
+	if lo <= offset {
+		return nil, raft.ErrCompacted
+	}
+	if hi > s.lastIndex()+1 {
+		return nil, fmt.Errorf("entries' hi(%d) is out of bound lastindex(%d)", hi, s.lastIndex())
+	}
+	// only contains dummy entries.
+	if len(s.ents) == 1 {
+		return nil, raft.ErrUnavailable
+	}
+
+	ents := s.ents[lo-offset : hi-offset]
+	var size uint64
+	for i := range ents {
+		size += uint64(ents[i].Size())
+		if i > 0 && size > maxSize {
+			ents = ents[:i]
+			break
+		}
+	}
+	// NB: use the full slice expression to limit what the caller can do with the
+	// returned slice, see raft.MemoryStorage.
+	return ents[:len(ents):len(ents)], nil
+}
+
+// Term implements the raft.Storage interface.
+func (s *Storage) Term(i uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset := s.ents[0].Index
+	if i < offset {
+		return 0, raft.ErrCompacted
+	}
+	if int(i-offset) >= len(s.ents) {
+		return 0, raft.ErrUnavailable
+	}
+	return s.ents[i-offset].Term, nil
+}
+
+var _ raft.TermBoundaryStorage = (*Storage)(nil)
+
+// TermBoundaries implements the raft.TermBoundaryStorage interface.
+func (s *Storage) TermBoundaries() ([]pb.TermBoundary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bs []pb.TermBoundary
+	for i := range s.ents {
+		if n := len(bs); n == 0 || bs[n-1].Term != s.ents[i].Term {
+			bs = append(bs, pb.TermBoundary{Term: s.ents[i].Term, Index: s.ents[i].Index})
+		}
+	}
+	return bs, nil
+}
+
+// LastIndex implements the raft.Storage interface.
+func (s *Storage) LastIndex() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastIndex(), nil
+}
+
+func (s *Storage) lastIndex() uint64 {
+	return s.ents[0].Index + uint64(len(s.ents)) - 1
+}
+
+// FirstIndex implements the raft.Storage interface.
+func (s *Storage) FirstIndex() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ents[0].Index + 1, nil
+}
+
+// Snapshot implements the raft.Storage interface.
+func (s *Storage) Snapshot() (pb.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot, nil
+}
+
+// Save persists the given HardState, log entries and snapshot, any of which
+// may be empty. The snapshot is applied first, followed by the entries and
+// the HardState, which matches the order in which they must be applied when
+// handling a Ready. The changes are durable on return if sync is true, which
+// should be set to Ready.MustSync. A non-empty snapshot is always synced.
+func (s *Storage) Save(st pb.HardState, entries []pb.Entry, snap pb.Snapshot, sync bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !raft.IsEmptySnap(snap) {
+		if err := s.applySnapshot(snap); err != nil {
+			return err
+		}
+	}
+	if err := s.append(entries); err != nil {
+		return err
+	}
+	if !raft.IsEmptyHardState(st) {
+		if err := s.setHardState(st); err != nil {
+			return err
+		}
+	}
+	if sync || !raft.IsEmptySnap(snap) {
+		if err := s.wal.sync(); err != nil {
+			return err
+		}
+	}
+	if !raft.IsEmptySnap(snap) {
+		if err := s.releaseSnapshot(snap); err != nil {
+			return err
+		}
+	}
+	return s.maybeCut()
+}
+
+// StorageAppend durably persists the HardState, log entries and snapshot
+// carried by a MsgStorageAppend message. Once it returns, the responses
+// attached to the message can be delivered.
+func (s *Storage) StorageAppend(m pb.Message) error {
+	if m.Type != pb.MsgStorageAppend {
+		return fmt.Errorf("unexpected message type %s", m.Type)
+	}
+	st := pb.HardState{Term: m.Term, Vote: m.Vote, Commit: m.Commit}
+	var snap pb.Snapshot
+	if m.Snapshot != nil {
+		snap = *m.Snapshot
+	}
+	return s.Save(st, m.Entries, snap, true)
+}
+
+// SetHardState durably saves the current HardState.
+func (s *Storage) SetHardState(st pb.HardState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.setHardState(st); err != nil {
+		return err
+	}
+	if err := s.wal.sync(); err != nil {
+		return err
+	}
+	return s.maybeCut()
+}
+
+func (s *Storage) setHardState(st pb.HardState) error {
+	data, err := st.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.wal.write(record{typ: recHardState, data: data}); err != nil {
+		return err
+	}
+	s.hardState = st
+	return nil
+}
+
+// Append durably appends the new entries to storage, truncating any
+// conflicting entries.
+func (s *Storage) Append(entries []pb.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(entries); err != nil {
+		return err
+	}
+	if err := s.wal.sync(); err != nil {
+		return err
+	}
+	return s.maybeCut()
+}
+
+func (s *Storage) append(entries []pb.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	first := s.ents[0].Index + 1
+	last := entries[0].Index + uint64(len(entries)) - 1
+	// shortcut if there is no new entry.
+	if last < first {
+		return nil
+	}
+	// truncate compacted entries
+	if first > entries[0].Index {
+		entries = entries[first-entries[0].Index:]
+	}
+	if err := s.checkAppend(entries[0].Index); err != nil {
+		return err
+	}
+
+	recs := make([]record, len(entries))
+	for i := range entries {
+		data, err := entries[i].Marshal()
+		if err != nil {
+			return err
+		}
+		recs[i] = record{typ: recEntry, data: data}
+	}
+	if err := s.wal.write(recs...); err != nil {
+		return err
+	}
+	s.wal.noteAppend(last)
+
+	offset := entries[0].Index - s.ents[0].Index
+	// NB: full slice expression protects s.ents at index >= offset from
+	// rewrites, as they may still be referenced from outside Storage.
+	s.ents = append(s.ents[:offset:offset], entries...)
+	return nil
+}
+
+// checkAppend returns an error if an entry at the given index can't be
+// appended to the log without leaving a gap.
+func (s *Storage) checkAppend(index uint64) error {
+	if index > s.lastIndex()+1 {
+		return fmt.Errorf("missing log entry [last: %d, append at: %d]", s.lastIndex(), index)
+	}
+	return nil
+}
+
+// ApplySnapshot durably overwrites the contents of this Storage with those of
+// the given snapshot.
+func (s *Storage) ApplySnapshot(snap pb.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.applySnapshot(snap); err != nil {
+		return err
+	}
+	if err := s.wal.sync(); err != nil {
+		return err
+	}
+	if err := s.releaseSnapshot(snap); err != nil {
+		return err
+	}
+	return s.maybeCut()
+}
+
+// releaseSnapshot removes the files made obsolete by applying the given
+// snapshot. The snapshot must be durable.
+func (s *Storage) releaseSnapshot(snap pb.Snapshot) error {
+	if err := removeSnapshotsExcept(s.snapDir(), snap.Metadata.Term, snap.Metadata.Index); err != nil {
+		return err
+	}
+	return s.wal.releaseTo(snap.Metadata.Index)
+}
+
+func (s *Storage) applySnapshot(snap pb.Snapshot) error {
+	if s.snapshot.Metadata.Index >= snap.Metadata.Index {
+		return raft.ErrSnapOutOfDate
+	}
+	// The snapshot file must be durable before the WAL record referencing it
+	// is written. Older snapshot files are only removed once the record is
+	// synced.
+	if err := saveSnapshot(s.snapDir(), snap); err != nil {
+		return err
+	}
+	data, err := snap.Metadata.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.wal.write(record{typ: recApplySnapshot, data: data}); err != nil {
+		return err
+	}
+	s.wal.discardEntries()
+	s.snapshot = snap
+	s.ents = []pb.Entry{{Term: snap.Metadata.Term, Index: snap.Metadata.Index}}
+	return nil
+}
+
+// CreateSnapshot durably makes a snapshot which can be retrieved with
+// Snapshot() and can be used to reconstruct the state at that point. If any
+// configuration changes have been made since the last compaction, the result
+// of the last ApplyConfChange must be passed in.
+func (s *Storage) CreateSnapshot(i uint64, cs *pb.ConfState, data []byte) (pb.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i <= s.snapshot.Metadata.Index {
+		return pb.Snapshot{}, raft.ErrSnapOutOfDate
+	}
+	offset := s.ents[0].Index
+	if i < offset {
+		return pb.Snapshot{}, raft.ErrCompacted
+	}
+	if i > s.lastIndex() {
+		return pb.Snapshot{}, fmt.Errorf("snapshot %d is out of bound lastindex(%d)", i, s.lastIndex())
+	}
+
+	snap := pb.Snapshot{Data: data}
+	snap.Metadata.Index = i
+	snap.Metadata.Term = s.ents[i-offset].Term
+	if cs != nil {
+		snap.Metadata.ConfState = *cs
+	} else {
+		snap.Metadata.ConfState = s.snapshot.Metadata.ConfState
+	}
+	if err := saveSnapshot(s.snapDir(), snap); err != nil {
+		return pb.Snapshot{}, err
+	}
+	md, err := snap.Metadata.Marshal()
+	if err != nil {
+		return pb.Snapshot{}, err
+	}
+	if err := s.wal.write(record{typ: recSnapshot, data: md}); err != nil {
+		return pb.Snapshot{}, err
+	}
+	if err := s.wal.sync(); err != nil {
+		return pb.Snapshot{}, err
+	}
+	s.snapshot = snap
+	if err := removeSnapshotsExcept(s.snapDir(), snap.Metadata.Term, snap.Metadata.Index); err != nil {
+		return pb.Snapshot{}, err
+	}
+	return snap, s.maybeCut()
+}
+
+// Compact durably discards all log entries prior to compactIndex, and removes
+// the WAL segments that are no longer needed. It is the application's
+// responsibility to not attempt to compact an index greater than
+// raftLog.applied.
+func (s *Storage) Compact(compactIndex uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset := s.ents[0].Index
+	if compactIndex <= offset {
+		return raft.ErrCompacted
+	}
+	if compactIndex > s.lastIndex() {
+		return fmt.Errorf("compact %d is out of bound lastindex(%d)", compactIndex, s.lastIndex())
+	}
+
+	i := compactIndex - offset
+	base := pb.Entry{Index: s.ents[i].Index, Term: s.ents[i].Term}
+	data, err := base.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.wal.write(record{typ: recCompact, data: data}); err != nil {
+		return err
+	}
+	if err := s.wal.sync(); err != nil {
+		return err
+	}
+	// NB: allocate a new slice instead of reusing the old s.ents. Entries in
+	// s.ents are immutable, and can be referenced from outside Storage through
+	// slices returned by s.Entries().
+	ents := make([]pb.Entry, 1, uint64(len(s.ents))-i)
+	ents[0] = base
+	ents = append(ents, s.ents[i+1:]...)
+	s.ents = ents
+	return s.wal.releaseTo(compactIndex)
+}
+
+// maybeCut starts a new WAL segment if the active one is full. The new segment
+// begins with the state that would otherwise only be found in the segments
+// preceding it.
+func (s *Storage) maybeCut() error {
+	if !s.wal.full() {
+		return nil
+	}
+	var preamble []record
+	if !raft.IsEmptyHardState(s.hardState) {
+		data, err := s.hardState.Marshal()
+		if err != nil {
+			return err
+		}
+		preamble = append(preamble, record{typ: recHardState, data: data})
+	}
+	if !raft.IsEmptySnap(s.snapshot) {
+		data, err := s.snapshot.Metadata.Marshal()
+		if err != nil {
+			return err
+		}
+		preamble = append(preamble, record{typ: recSnapshot, data: data})
+	}
+	data, err := s.ents[0].Marshal()
+	if err != nil {
+		return err
+	}
+	preamble = append(preamble, record{typ: recCompact, data: data})
+	return s.wal.cut(s.lastIndex(), preamble...)
+}
+
+// Sync makes all previously saved state durable.
+func (s *Storage) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wal.sync()
+}
+
+// Close syncs and closes the Storage.
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wal.close()
+}
+
+// removeTemporaryFiles removes leftovers of interrupted file creations.
+func removeTemporaryFiles(dir string) error {
+	names, err := readDirNames(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, ".tmp") {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestorage
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+var discardLogger = &raft.DefaultLogger{Logger: log.New(io.Discard, "", 0)}
+
+func openTestStorage(t *testing.T, dir string, segmentSize int64) *Storage {
+	t.Helper()
+	s, err := Open(dir, &Options{SegmentSize: segmentSize, Logger: discardLogger})
+	require.NoError(t, err)
+	return s
+}
+
+// reopen closes the storage and opens it again from the same directory.
+func reopen(t *testing.T, s *Storage) *Storage {
+	t.Helper()
+	segmentSize := s.wal.segmentSize
+	require.NoError(t, s.Close())
+	return openTestStorage(t, s.dir, segmentSize)
+}
+
+func entries(index uint64, terms ...uint64) []pb.Entry {
+	var ents []pb.Entry
+	for i, term := range terms {
+		ents = append(ents, pb.Entry{Index: index + uint64(i), Term: term, Data: []byte(fmt.Sprintf("%d", index+uint64(i)))})
+	}
+	return ents
+}
+
+// requireState checks that the storage holds exactly the given HardState, log
+// entries and compaction point.
+func requireState(t *testing.T, s *Storage, st pb.HardState, first uint64, ents []pb.Entry) {
+	t.Helper()
+	hs, _, err := s.InitialState()
+	require.NoError(t, err)
+	require.Equal(t, st, hs)
+	fi, err := s.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, first, fi)
+	li, err := s.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, first+uint64(len(ents))-1, li)
+	if len(ents) > 0 {
+		got, err := s.Entries(first, li+1, math.MaxUint64)
+		require.NoError(t, err)
+		require.Equal(t, ents, got)
+	}
+}
+
+func TestStorageAppend(t *testing.T) {
+	for _, tt := range []struct {
+		ents  []pb.Entry
+		wents []pb.Entry
+	}{
+		{entries(4, 4, 5), entries(4, 4, 5)},
+		{entries(4, 4, 6, 6), entries(4, 4, 6, 6)},
+		{entries(4, 4, 5, 5), entries(4, 4, 5, 5)},
+		// Overwrite compacted entries.
+		{entries(2, 3, 3, 5), entries(4, 5)},
+		// Truncate incoming entries, truncate the existing entries and append.
+		{entries(3, 3, 3, 5), entries(4, 3, 5)},
+		// Truncate the existing entries and append.
+		{entries(5, 5), entries(4, 4, 5)},
+		// Direct append.
+		{entries(6, 5), entries(4, 4, 5, 5)},
+	} {
+		t.Run("", func(t *testing.T) {
+			s := openTestStorage(t, t.TempDir(), 0)
+			defer func() { s.Close() }()
+			require.NoError(t, s.ApplySnapshot(pb.Snapshot{Metadata: pb.SnapshotMetadata{Index: 3, Term: 3}}))
+			require.NoError(t, s.Append(entries(4, 4, 5)))
+
+			require.NoError(t, s.Append(tt.ents))
+			requireState(t, s, pb.HardState{}, 4, tt.wents)
+			s = reopen(t, s)
+			requireState(t, s, pb.HardState{}, 4, tt.wents)
+		})
+	}
+}
+
+func TestStorageAppendGap(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+	require.NoError(t, s.Append(entries(1, 1, 1)))
+	require.Error(t, s.Append(entries(4, 1)))
+	s = reopen(t, s)
+	requireState(t, s, pb.HardState{}, 1, entries(1, 1, 1))
+}
+
+func TestStorageTermAndEntries(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+	require.NoError(t, s.Append(entries(1, 1, 2, 3, 4, 5)))
+	require.NoError(t, s.Compact(2))
+
+	_, err := s.Term(1)
+	require.Equal(t, raft.ErrCompacted, err)
+	term, err := s.Term(2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), term)
+	_, err = s.Term(6)
+	require.Equal(t, raft.ErrUnavailable, err)
+
+	_, err = s.Entries(2, 4, math.MaxUint64)
+	require.Equal(t, raft.ErrCompacted, err)
+	ents := entries(3, 3, 4, 5)
+	got, err := s.Entries(3, 6, 0)
+	require.NoError(t, err)
+	require.Equal(t, ents[:1], got)
+	got, err = s.Entries(3, 6, uint64(ents[0].Size()+ents[1].Size()))
+	require.NoError(t, err)
+	require.Equal(t, ents[:2], got)
+	got, err = s.Entries(3, 6, math.MaxUint64)
+	require.NoError(t, err)
+	require.Equal(t, ents, got)
+}
+
+func TestStorageTermBoundaries(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+
+	bs, err := s.TermBoundaries()
+	require.NoError(t, err)
+	require.Equal(t, []pb.TermBoundary{{Term: 0, Index: 0}}, bs)
+
+	require.NoError(t, s.Append(entries(1, 1, 1, 2, 2, 2, 4, 5, 5)))
+	require.NoError(t, s.Compact(4))
+	want := []pb.TermBoundary{{Term: 2, Index: 4}, {Term: 4, Index: 6}, {Term: 5, Index: 7}}
+	bs, err = s.TermBoundaries()
+	require.NoError(t, err)
+	require.Equal(t, want, bs)
+
+	s = reopen(t, s)
+	bs, err = s.TermBoundaries()
+	require.NoError(t, err)
+	require.Equal(t, want, bs)
+}
+
+func TestStorageCompact(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+	require.NoError(t, s.Append(entries(1, 1, 1, 2, 2, 3)))
+	require.NoError(t, s.SetHardState(pb.HardState{Term: 3, Vote: 1, Commit: 4}))
+
+	require.Equal(t, raft.ErrCompacted, s.Compact(0))
+	require.Error(t, s.Compact(6))
+	require.NoError(t, s.Compact(3))
+	require.Equal(t, raft.ErrCompacted, s.Compact(3))
+
+	want := pb.HardState{Term: 3, Vote: 1, Commit: 4}
+	requireState(t, s, want, 4, entries(4, 2, 3))
+	s = reopen(t, s)
+	requireState(t, s, want, 4, entries(4, 2, 3))
+	term, err := s.Term(3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), term)
+}
+
+func TestStorageSnapshots(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+	require.NoError(t, s.Append(entries(1, 1, 1, 2, 2, 3)))
+	cs := &pb.ConfState{Voters: []uint64{1, 2, 3}}
+
+	snap, err := s.CreateSnapshot(3, cs, []byte("data"))
+	require.NoError(t, err)
+	require.Equal(t, pb.SnapshotMetadata{ConfState: *cs, Index: 3, Term: 2}, snap.Metadata)
+	_, err = s.CreateSnapshot(3, cs, nil)
+	require.Equal(t, raft.ErrSnapOutOfDate, err)
+	// Creating a snapshot doesn't affect the log.
+	requireState(t, s, pb.HardState{}, 1, entries(1, 1, 1, 2, 2, 3))
+
+	s = reopen(t, s)
+	got, err := s.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, snap, got)
+	_, gotCS, err := s.InitialState()
+	require.NoError(t, err)
+	require.Equal(t, *cs, gotCS)
+	requireState(t, s, pb.HardState{}, 1, entries(1, 1, 1, 2, 2, 3))
+
+	// Applying a snapshot discards the log.
+	applied := pb.Snapshot{
+		Data:     []byte("applied"),
+		Metadata: pb.SnapshotMetadata{ConfState: pb.ConfState{Voters: []uint64{1, 2}}, Index: 10, Term: 4},
+	}
+	require.Equal(t, raft.ErrSnapOutOfDate, s.ApplySnapshot(snap))
+	require.NoError(t, s.ApplySnapshot(applied))
+	require.NoError(t, s.Append(entries(11, 4)))
+	requireState(t, s, pb.HardState{}, 11, entries(11, 4))
+
+	s = reopen(t, s)
+	got, err = s.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, applied, got)
+	requireState(t, s, pb.HardState{}, 11, entries(11, 4))
+	// Only the latest snapshot file is retained.
+	names, err := readDirNames(s.snapDir())
+	require.NoError(t, err)
+	require.Equal(t, []string{snapshotName(4, 10)}, names)
+}
+
+func TestStorageSave(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 0)
+	defer func() { s.Close() }()
+	snap := pb.Snapshot{Metadata: pb.SnapshotMetadata{ConfState: pb.ConfState{Voters: []uint64{1}}, Index: 5, Term: 2}}
+	st := pb.HardState{Term: 3, Vote: 1, Commit: 6}
+	require.NoError(t, s.Save(st, entries(6, 3, 3), snap, true))
+	requireState(t, s, st, 6, entries(6, 3, 3))
+
+	m := pb.Message{
+		Type:    pb.MsgStorageAppend,
+		Term:    3,
+		Vote:    1,
+		Commit:  8,
+		Entries: entries(8, 3),
+	}
+	require.NoError(t, s.StorageAppend(m))
+	st.Commit = 8
+	requireState(t, s, st, 6, entries(6, 3, 3, 3))
+
+	s = reopen(t, s)
+	requireState(t, s, st, 6, entries(6, 3, 3, 3))
+	got, err := s.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, snap, got)
+}
+
+// TestStorageSegments checks that the WAL is split into segments, and that the
+// segments are removed once the entries in them are compacted.
+func TestStorageSegments(t *testing.T) {
+	s := openTestStorage(t, t.TempDir(), 512)
+	defer func() { s.Close() }()
+	st := pb.HardState{Term: 1, Vote: 1}
+	for i := uint64(1); i <= 100; i++ {
+		st.Commit = i - 1
+		require.NoError(t, s.Save(st, entries(i, 1), pb.Snapshot{}, true))
+	}
+	require.Greater(t, len(s.wal.segs), 5)
+	segs := len(s.wal.segs)
+
+	require.NoError(t, s.Compact(50))
+	require.Less(t, len(s.wal.segs), segs)
+	seqs, err := listSegments(s.walDir())
+	require.NoError(t, err)
+	require.Len(t, seqs, len(s.wal.segs))
+	var want []pb.Entry
+	for i := uint64(51); i <= 100; i++ {
+		want = append(want, entries(i, 1)...)
+	}
+	requireState(t, s, st, 51, want)
+
+	s = reopen(t, s)
+	requireState(t, s, st, 51, want)
+	term, err := s.Term(50)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), term)
+
+	// Compacting everything removes all but the active segment.
+	require.NoError(t, s.Compact(100))
+	require.Len(t, s.wal.segs, 1)
+	s = reopen(t, s)
+	requireState(t, s, st, 101, nil)
+}
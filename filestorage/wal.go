@@ -0,0 +1,381 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestorage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.etcd.io/raft/v3"
+)
+
+// recordType identifies the payload of a WAL record.
+type recordType uint8
+
+const (
+	// recEntry carries a single marshaled pb.Entry. An entry at index i
+	// truncates all entries at indices >= i written before it.
+	recEntry recordType = iota + 1
+	// recHardState carries a marshaled pb.HardState.
+	recHardState
+	// recSnapshot carries the marshaled pb.SnapshotMetadata of a snapshot file
+	// that was created by CreateSnapshot. It does not affect the log.
+	recSnapshot
+	// recApplySnapshot carries the marshaled pb.SnapshotMetadata of a snapshot
+	// file that was applied by ApplySnapshot. It discards all log entries
+	// written before it.
+	recApplySnapshot
+	// recCompact carries a marshaled pb.Entry (without payload) identifying the
+	// last compacted log position. All entries up to and including it are no
+	// longer needed.
+	recCompact
+)
+
+// recordHeaderSize is the size of the fixed record header: a 4-byte payload
+// length, a 4-byte checksum, and a 1-byte record type.
+const recordHeaderSize = 9
+
+// maxRecordSize bounds the payload length read from a record header, so that
+// a corrupted length can't cause a huge allocation.
+const maxRecordSize = 1 << 30
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// record is a single decoded WAL record.
+type record struct {
+	typ  recordType
+	data []byte
+}
+
+func (r record) checksum() uint32 {
+	crc := crc32.Update(0, crcTable, []byte{byte(r.typ)})
+	return crc32.Update(crc, crcTable, r.data)
+}
+
+// encode appends the encoding of the record to buf.
+func (r record) encode(buf []byte) []byte {
+	var hdr [recordHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(r.data)))
+	binary.LittleEndian.PutUint32(hdr[4:8], r.checksum())
+	hdr[8] = byte(r.typ)
+	buf = append(buf, hdr[:]...)
+	return append(buf, r.data...)
+}
+
+// errTornRecord is returned by readRecords when the segment ends with an
+// incomplete or damaged record, which is the expected outcome of a crash in the
+// middle of an unsynced write.
+var errTornRecord = errors.New("torn record at the end of segment")
+
+// readRecords decodes all records from r. It returns the decoded records and
+// the size of the valid prefix. If the input ends with an incomplete record, or
+// with a record that fails the checksum and is not followed by any data, the
+// records preceding it are returned along with errTornRecord. A checksum
+// failure in the middle of the input results in ErrCorrupt.
+func readRecords(r io.Reader) ([]record, int64, error) {
+	br := bufio.NewReader(r)
+	var recs []record
+	var off int64
+	for {
+		var hdr [recordHeaderSize]byte
+		n, err := io.ReadFull(br, hdr[:])
+		if err == io.EOF {
+			return recs, off, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return recs, off, errTornRecord
+		} else if err != nil {
+			return nil, 0, err
+		}
+		size := binary.LittleEndian.Uint32(hdr[0:4])
+		crc := binary.LittleEndian.Uint32(hdr[4:8])
+		rec := record{typ: recordType(hdr[8])}
+		if size == 0 && crc == 0 && rec.typ == 0 {
+			// A zeroed header is what a file system may leave behind when the
+			// file was extended but the data never made it to disk.
+			return recs, off, errTornRecord
+		}
+		if size > maxRecordSize {
+			return recs, off, tornOrCorrupt(br)
+		}
+		rec.data = make([]byte, size)
+		if _, err := io.ReadFull(br, rec.data); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return recs, off, errTornRecord
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if rec.checksum() != crc {
+			return recs, off, tornOrCorrupt(br)
+		}
+		recs = append(recs, rec)
+		off += int64(n) + int64(size)
+	}
+}
+
+// tornOrCorrupt decides how to treat a damaged record. If nothing follows it,
+// the damage is attributed to an interrupted write; otherwise the segment is
+// corrupt.
+func tornOrCorrupt(br *bufio.Reader) error {
+	if _, err := br.Peek(1); err == io.EOF {
+		return errTornRecord
+	}
+	return ErrCorrupt
+}
+
+// segment describes a WAL segment file.
+type segment struct {
+	seq uint64
+	// maxIndex is an upper bound on the indices of the log entries written to
+	// the segment. It is used to decide when the segment can be removed.
+	maxIndex uint64
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%016x.wal", seq)
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, ".wal") {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(name, ".wal"), 16, 64)
+	return seq, err == nil
+}
+
+// wal is a write-ahead log split into a sequence of segment files. Records are
+// only ever appended to the last segment. Once it grows beyond the configured
+// size, a new segment is started, and the old ones are removed when the log
+// entries they contain have been compacted away.
+type wal struct {
+	dir         string
+	segmentSize int64
+
+	segs []segment // sorted by seq, the last one is the active segment
+	f    *os.File  // the active segment
+	size int64     // size of the active segment
+	buf  []byte
+}
+
+// listSegments returns the sequence numbers of the segment files in dir, in
+// ascending order.
+func listSegments(dir string) ([]uint64, error) {
+	names, err := readDirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, name := range names {
+		if seq, ok := parseSegmentName(name); ok {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// openWAL reads all segments in dir and returns the records found in them, in
+// order, along with the wal positioned for appending to the last segment. A
+// torn write at the end of the last segment is truncated away. The returned
+// segments have their maxIndex unset; the caller is expected to fill it in.
+func openWAL(dir string, segmentSize int64, logger raft.Logger) (*wal, [][]record, error) {
+	seqs, err := listSegments(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &wal{dir: dir, segmentSize: segmentSize}
+	var recs [][]record
+	for i, seq := range seqs {
+		path := filepath.Join(dir, segmentName(seq))
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		segRecs, valid, err := readRecords(f)
+		last := i == len(seqs)-1
+		if err == errTornRecord && last {
+			logger.Warningf("truncating torn write at offset %d of WAL segment %s", valid, path)
+			if err = f.Truncate(valid); err == nil {
+				err = f.Sync()
+			}
+		} else if err == errTornRecord {
+			err = fmt.Errorf("%w: incomplete record in segment %s", ErrCorrupt, path)
+		} else if err == ErrCorrupt {
+			err = fmt.Errorf("%w: bad checksum in segment %s", ErrCorrupt, path)
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		recs = append(recs, segRecs)
+		w.segs = append(w.segs, segment{seq: seq})
+		if !last {
+			if err := f.Close(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if _, err := f.Seek(valid, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		w.f, w.size = f, valid
+	}
+	return w, recs, nil
+}
+
+// write appends the given records to the active segment. The data is handed to
+// the operating system, but is only durable after a call to sync.
+func (w *wal) write(recs ...record) error {
+	w.buf = w.buf[:0]
+	for _, r := range recs {
+		w.buf = r.encode(w.buf)
+	}
+	n, err := w.f.Write(w.buf)
+	w.size += int64(n)
+	return err
+}
+
+func (w *wal) sync() error {
+	return w.f.Sync()
+}
+
+// full returns true if the active segment has reached the target size.
+func (w *wal) full() bool {
+	return w.size >= w.segmentSize
+}
+
+// cut syncs and closes the active segment, and starts a new one beginning with
+// the given records. The preamble must carry all the state that is needed to
+// replay the log in the absence of the preceding segments. lastIndex is the
+// index of the last entry in the log at the time of the cut.
+func (w *wal) cut(lastIndex uint64, preamble ...record) error {
+	var seq uint64
+	if n := len(w.segs); n > 0 {
+		seq = w.segs[n-1].seq + 1
+		if err := w.f.Sync(); err != nil {
+			return err
+		}
+		if err := w.f.Close(); err != nil {
+			return err
+		}
+		w.f = nil
+	}
+	// Write the new segment under a temporary name and rename it into place
+	// once the preamble is durable, so that a crash never leaves behind a
+	// segment without one.
+	path := filepath.Join(w.dir, segmentName(seq))
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w.f, w.size = f, 0
+	if err := w.write(preamble...); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+	w.segs = append(w.segs, segment{seq: seq, maxIndex: lastIndex})
+	return nil
+}
+
+// noteAppend records that entries up to the given index were written to the
+// active segment.
+func (w *wal) noteAppend(index uint64) {
+	if s := &w.segs[len(w.segs)-1]; index > s.maxIndex {
+		s.maxIndex = index
+	}
+}
+
+// discardEntries records that none of the entries written to the WAL so far
+// are needed any longer, which is the case after a snapshot was applied.
+func (w *wal) discardEntries() {
+	for i := range w.segs {
+		w.segs[i].maxIndex = 0
+	}
+}
+
+// releaseTo removes the segments that only contain entries at indices up to
+// and including the given compacted index. The active segment is never
+// removed.
+func (w *wal) releaseTo(index uint64) error {
+	var n int
+	for n < len(w.segs)-1 && w.segs[n].maxIndex <= index {
+		n++
+	}
+	for _, s := range w.segs[:n] {
+		if err := os.Remove(filepath.Join(w.dir, segmentName(s.seq))); err != nil {
+			return err
+		}
+	}
+	w.segs = append([]segment(nil), w.segs[n:]...)
+	if n > 0 {
+		return syncDir(w.dir)
+	}
+	return nil
+}
+
+func (w *wal) close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+// syncDir fsyncs the given directory, which makes file creations, renames and
+// removals in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
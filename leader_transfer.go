@@ -0,0 +1,213 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"fmt"
+	"slices"
+
+	"go.etcd.io/raft/v3/tracker"
+)
+
+// LeaderTransferOutcome describes how a leadership transfer ended.
+type LeaderTransferOutcome uint8
+
+const (
+	// LeaderTransferSucceeded means that the transfer target was elected
+	// leader.
+	LeaderTransferSucceeded LeaderTransferOutcome = iota
+	// LeaderTransferTimeout means that the target was caught up and told to
+	// campaign, but the leader did not step down within an election timeout.
+	LeaderTransferTimeout
+	// LeaderTransferTargetLagging means that the target did not catch up with
+	// the leader's log within an election timeout, so it was never told to
+	// campaign.
+	LeaderTransferTargetLagging
+	// LeaderTransferTargetRemoved means that the target was removed from the
+	// voters by a configuration change.
+	LeaderTransferTargetRemoved
+	// LeaderTransferSuperseded means that a transfer to another target was
+	// requested while this one was in progress.
+	LeaderTransferSuperseded
+	// LeaderTransferNoTarget means that there was no voter eligible for the
+	// transfer: the requested target is a learner or a witness, or no target
+	// could be picked automatically.
+	LeaderTransferNoTarget
+	// LeaderTransferOtherLeader means that the leader stepped down during the
+	// transfer, and a node other than the target was elected.
+	LeaderTransferOtherLeader
+)
+
+var leaderTransferOutcomeNames = [...]string{
+	LeaderTransferSucceeded:     "succeeded",
+	LeaderTransferTimeout:       "timeout",
+	LeaderTransferTargetLagging: "target lagging",
+	LeaderTransferTargetRemoved: "target removed",
+	LeaderTransferSuperseded:    "superseded",
+	LeaderTransferNoTarget:      "no target",
+	LeaderTransferOtherLeader:   "other leader elected",
+}
+
+func (o LeaderTransferOutcome) String() string {
+	if int(o) < len(leaderTransferOutcomeNames) {
+		return leaderTransferOutcomeNames[o]
+	}
+	return fmt.Sprintf("LeaderTransferOutcome(%d)", uint8(o))
+}
+
+// LeaderTransferResult reports the outcome of a leadership transfer requested
+// via RawNode.TransferLeader or Node.TransferLeadership. It is delivered in
+// Ready.LeaderTransfers on the node that was the leader when the transfer was
+// requested. A request made on a follower is forwarded to the leader and
+// produces no result on the follower.
+//
+// Every request stepped on the leader gets a result. A request to transfer the
+// leadership to the leader itself succeeds right away, and a request for the
+// target of the transfer in progress gets the outcome of that transfer.
+type LeaderTransferResult struct {
+	// Target is the ID of the transfer target. It is None if no target could
+	// be picked automatically.
+	Target uint64
+	// Term is the term of the leader that carried out the transfer.
+	Term uint64
+	// Outcome tells whether the transfer succeeded, and if not, why it was
+	// aborted.
+	Outcome LeaderTransferOutcome
+}
+
+func (r LeaderTransferResult) String() string {
+	return fmt.Sprintf("transfer to %x at term %d: %s", r.Target, r.Term, r.Outcome)
+}
+
+// pickLeadTransferee returns the target of a leadership transfer requested
+// without one. Only recently active voters other than the leader itself are
+// considered, and witnesses are never picked. The first of them in the
+// configured preference list wins; otherwise it is the one with the highest
+// priority, then the most up-to-date one, with ties broken by the lowest ID.
+// Returns None if there is no such voter.
+func (r *raft) pickLeadTransferee() uint64 {
+	var best uint64
+	var bestPr *tracker.Progress
+	bestRank := len(r.leadTransferPreference)
+	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
+		if id == r.id || pr.IsLearner || pr.IsWitness || !pr.RecentActive {
+			return
+		}
+		rank := slices.Index(r.leadTransferPreference, id)
+		if rank < 0 {
+			rank = len(r.leadTransferPreference)
+		}
+		// Visit goes in the order of IDs, so only a strictly better voter
+		// replaces the current pick.
+		if best == None || rank < bestRank {
+			best, bestPr, bestRank = id, pr, rank
+			return
+		}
+		if rank > bestRank {
+			return
+		}
+		if prio, bestPrio := r.trk.Priority(id), r.trk.Priority(best); prio > bestPrio ||
+			prio == bestPrio && pr.Match > bestPr.Match {
+			best, bestPr = id, pr
+		}
+	})
+	return best
+}
+
+// startLeaderTransfer starts transferring leadership to the given voter.
+func (r *raft) startLeaderTransfer(to uint64, pr *tracker.Progress) {
+	r.logger.Infof("%x [term %d] starts to transfer leadership to %x", r.id, r.Term, to)
+	// Transfer leadership should be finished in one electionTimeout, so reset r.electionElapsed.
+	r.electionElapsed = 0
+	r.leadTransferee = to
+	r.leadTransferTerm = r.Term
+	r.leadTransferDups = 0
+	traceStartLeaderTransfer(r, to)
+	if pr.Match == r.raftLog.lastIndex() {
+		r.sendTimeoutNow(to)
+		r.logger.Infof("%x sends MsgTimeoutNow to %x immediately as %x already has up-to-date log", r.id, to, to)
+	} else {
+		r.sendAppend(to)
+	}
+}
+
+// abortLeaderTransfer aborts the leadership transfer in progress, if any, and
+// reports the given outcome for it.
+func (r *raft) abortLeaderTransfer(outcome LeaderTransferOutcome) {
+	if r.leadTransferee == None {
+		return
+	}
+	r.finishLeaderTransfers(LeaderTransferResult{Target: r.leadTransferee, Term: r.leadTransferTerm, Outcome: outcome})
+	r.leadTransferee = None
+}
+
+// leaderTransferTimedOut aborts the leadership transfer in progress when it
+// has not completed within an election timeout.
+func (r *raft) leaderTransferTimedOut() {
+	// Proposals are dropped while the transfer is in progress, so the target
+	// was told to campaign iff it caught up with the log.
+	outcome := LeaderTransferTimeout
+	if pr := r.trk.Progress[r.leadTransferee]; pr == nil || pr.Match < r.raftLog.lastIndex() {
+		outcome = LeaderTransferTargetLagging
+	}
+	r.logger.Infof("%x [term %d] aborts transferring leadership to %x: %s", r.id, r.Term, r.leadTransferee, outcome)
+	r.abortLeaderTransfer(outcome)
+}
+
+// stepDownDuringLeaderTransfer is called when the leader steps down while a
+// leadership transfer is in progress. This is the expected result of a
+// transfer, but whether it succeeded is only known once the new leader is.
+func (r *raft) stepDownDuringLeaderTransfer() {
+	if r.leadTransferee == None {
+		return
+	}
+	r.leadTransferStepDown = r.leadTransferee
+	r.leadTransferee = None
+}
+
+// maybeFinishLeaderTransfer reports the outcome of the leadership transfer
+// that was in progress when this node stepped down, once the new leader is
+// known.
+func (r *raft) maybeFinishLeaderTransfer() {
+	if r.leadTransferStepDown == None || r.lead == None {
+		return
+	}
+	res := LeaderTransferResult{Target: r.leadTransferStepDown, Term: r.leadTransferTerm, Outcome: LeaderTransferSucceeded}
+	if r.lead != res.Target {
+		res.Outcome = LeaderTransferOtherLeader
+	}
+	r.leadTransferStepDown = None
+	r.finishLeaderTransfers(res)
+}
+
+// finishLeaderTransfers reports the outcome of the leadership transfer that
+// was in progress, for the request that started it and each of its duplicates.
+func (r *raft) finishLeaderTransfers(res LeaderTransferResult) {
+	for ; r.leadTransferDups > 0; r.leadTransferDups-- {
+		r.finishLeaderTransfer(res)
+	}
+	r.finishLeaderTransfer(res)
+}
+
+func (r *raft) finishLeaderTransfer(res LeaderTransferResult) {
+	r.leadTransferResults = append(r.leadTransferResults, res)
+	traceFinishLeaderTransfer(r, res)
+}
+
+// dropLeaderTransfer reports a leadership transfer that could not be started
+// because the target is not eligible.
+func (r *raft) dropLeaderTransfer(to uint64) {
+	r.finishLeaderTransfer(LeaderTransferResult{Target: to, Term: r.Term, Outcome: LeaderTransferNoTarget})
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func TestPickLeadTransferee(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		prefer   []uint64
+		match    map[uint64]uint64
+		inactive []uint64
+		want     uint64
+	}{{
+		name:  "most up-to-date",
+		match: map[uint64]uint64{2: 5, 3: 7, 4: 6},
+		want:  3,
+	}, {
+		name:  "lowest id among equals",
+		match: map[uint64]uint64{2: 7, 3: 7, 4: 6},
+		want:  2,
+	}, {
+		name:   "preferred",
+		prefer: []uint64{4, 3},
+		match:  map[uint64]uint64{2: 7, 3: 6, 4: 5},
+		want:   4,
+	}, {
+		name:     "preferred inactive",
+		prefer:   []uint64{4, 3},
+		match:    map[uint64]uint64{2: 7, 3: 6, 4: 5},
+		inactive: []uint64{4},
+		want:     3,
+	}, {
+		name:   "preference ignores leader and witness",
+		prefer: []uint64{1, 5},
+		match:  map[uint64]uint64{2: 5, 3: 7, 4: 6, 5: 7},
+		want:   3,
+	}, {
+		name:     "none active",
+		match:    map[uint64]uint64{2: 7, 3: 7, 4: 7},
+		inactive: []uint64{2, 3, 4},
+		want:     None,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3, 4), withWitnesses(5), withLearners(6)))
+			cfg.LeaderTransferPreference = tt.prefer
+			r := newRaft(cfg)
+			r.becomeCandidate()
+			r.becomeLeader()
+			for id, pr := range r.trk.Progress {
+				pr.RecentActive = true
+				pr.Match = tt.match[id]
+			}
+			for _, id := range tt.inactive {
+				r.trk.Progress[id].RecentActive = false
+			}
+			require.Equal(t, tt.want, r.pickLeadTransferee())
+		})
+	}
+}
+
+func TestLeaderTransferResults(t *testing.T) {
+	nt := newNetwork(nil, nil, nil)
+	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgHup})
+	lead := nt.peers[1].(*raft)
+
+	// A transfer without a target picks the most up-to-date voter, and its
+	// success is reported once the old leader learns about the new one.
+	nt.send(pb.Message{From: None, To: 1, Type: pb.MsgTransferLeader})
+	checkLeaderTransferState(t, lead, StateFollower, 2)
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 2, Term: 1, Outcome: LeaderTransferSucceeded},
+	}, lead.leadTransferResults)
+
+	// A transfer is superseded by a transfer to another target.
+	lead = nt.peers[2].(*raft)
+	nt.isolate(3)
+	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{}}})
+	nt.send(pb.Message{From: 3, To: 2, Type: pb.MsgTransferLeader})
+	require.Equal(t, uint64(3), lead.leadTransferee)
+	nt.send(pb.Message{From: 1, To: 2, Type: pb.MsgTransferLeader})
+	checkLeaderTransferState(t, lead, StateFollower, 1)
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 3, Term: 2, Outcome: LeaderTransferSuperseded},
+		{Target: 1, Term: 2, Outcome: LeaderTransferSucceeded},
+	}, lead.leadTransferResults)
+
+	// If another node is elected while the transfer is in progress, this is
+	// reported once the new leader is known.
+	lead = nt.peers[1].(*raft)
+	nt.send(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader})
+	require.Equal(t, uint64(3), lead.leadTransferee)
+	nt.send(pb.Message{From: 2, To: 2, Type: pb.MsgHup})
+	checkLeaderTransferState(t, lead, StateFollower, 2)
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 2, Term: 1, Outcome: LeaderTransferSucceeded},
+		{Target: 3, Term: 3, Outcome: LeaderTransferOtherLeader},
+	}, lead.leadTransferResults)
+}
+
+// TestLeaderTransferResultsSelfAndDuplicate checks that a transfer to the
+// leader itself succeeds right away, and that a duplicate request for the
+// transfer in progress gets its outcome.
+func TestLeaderTransferResultsSelfAndDuplicate(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	r.becomeCandidate()
+	r.becomeLeader()
+
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, None, r.leadTransferee)
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 1, Term: 1, Outcome: LeaderTransferSucceeded},
+	}, r.leadTransferResults)
+	r.leadTransferResults = nil
+
+	// Follower 2 is lagging, so the transfer times out.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgTransferLeader}))
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, uint64(2), r.leadTransferee)
+	require.Empty(t, r.leadTransferResults)
+	for i := 0; i < r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.Equal(t, None, r.leadTransferee)
+	res := LeaderTransferResult{Target: 2, Term: 1, Outcome: LeaderTransferTargetLagging}
+	require.Equal(t, []LeaderTransferResult{res, res}, r.leadTransferResults)
+
+	// The duplicates are not carried over to the next transfer.
+	r.leadTransferResults = nil
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader}))
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 3, Term: 1, Outcome: LeaderTransferSuperseded},
+	}, r.leadTransferResults)
+}
+
+func TestLeaderTransferToWitness(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2), withWitnesses(3)))
+	r.becomeCandidate()
+	r.becomeLeader()
+
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, None, r.leadTransferee)
+	// Follower 2 hasn't been heard from, so no target can be picked either.
+	r.trk.Progress[2].RecentActive = false
+	require.NoError(t, r.Step(pb.Message{From: None, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, None, r.leadTransferee)
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 3, Term: 1, Outcome: LeaderTransferNoTarget},
+		{Target: None, Term: 1, Outcome: LeaderTransferNoTarget},
+	}, r.leadTransferResults)
+}
+
+func TestRawNodeLeaderTransferReady(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2))
+	rn := newTestRawNode(1, 10, 1, s)
+	require.NoError(t, rn.Campaign())
+	rn.raft.becomeLeader()
+	for rn.HasReady() {
+		rd := rn.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		rn.Advance(rd)
+	}
+
+	// Follower 2 never responds, so it doesn't catch up in time.
+	rn.TransferLeader(2)
+	for i := 0; i < 10; i++ {
+		rn.Tick()
+	}
+	require.True(t, rn.HasReady())
+	rd := rn.Ready()
+	require.Equal(t, []LeaderTransferResult{
+		{Target: 2, Term: 1, Outcome: LeaderTransferTargetLagging},
+	}, rd.LeaderTransfers)
+	rn.Advance(rd)
+	require.Empty(t, rn.raft.leadTransferResults)
+}
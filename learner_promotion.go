@@ -0,0 +1,139 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"fmt"
+	"slices"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+	"go.etcd.io/raft/v3/tracker"
+)
+
+// LearnerPromotionOutcome describes how an automatic learner promotion ended.
+type LearnerPromotionOutcome uint8
+
+const (
+	// LearnerPromotionSucceeded means that the conf change promoting the
+	// learner was applied, and the learner is now a voter.
+	LearnerPromotionSucceeded LearnerPromotionOutcome = iota
+	// LearnerPromotionRejected means that the conf change promoting the
+	// learner was applied, but the learner is not a voter, e.g. because the
+	// application cancelled the change. Unless the application also removes
+	// the mark for automatic promotion, the leader proposes the promotion
+	// again.
+	LearnerPromotionRejected
+	// LearnerPromotionAborted means that the leader stepped down before the
+	// conf change promoting the learner was applied. The promotion may still
+	// take effect if the next leader commits the conf change; otherwise, the
+	// next leader promotes the learner again.
+	LearnerPromotionAborted
+)
+
+var learnerPromotionOutcomeNames = [...]string{
+	LearnerPromotionSucceeded: "succeeded",
+	LearnerPromotionRejected:  "rejected",
+	LearnerPromotionAborted:   "aborted",
+}
+
+func (o LearnerPromotionOutcome) String() string {
+	if int(o) < len(learnerPromotionOutcomeNames) {
+		return learnerPromotionOutcomeNames[o]
+	}
+	return fmt.Sprintf("LearnerPromotionOutcome(%d)", uint8(o))
+}
+
+// LearnerPromotionResult reports the outcome of the automatic promotion of a
+// learner marked with raftpb.ConfChangeAddLearnerNodeAutoPromote. It is
+// delivered in Ready.LearnerPromotions on the leader that proposed the
+// promotion.
+type LearnerPromotionResult struct {
+	// ID is the ID of the learner.
+	ID uint64
+	// Index is the index of the conf change entry promoting the learner.
+	Index uint64
+	// Outcome tells whether the learner was promoted.
+	Outcome LearnerPromotionOutcome
+}
+
+func (r LearnerPromotionResult) String() string {
+	return fmt.Sprintf("promotion of %x at index %d: %s", r.ID, r.Index, r.Outcome)
+}
+
+// maybePromoteLearners proposes a conf change promoting the learners marked
+// for automatic promotion that have caught up with the log, i.e. are being
+// replicated to and miss at most Config.AutoPromoteMaxLag committed entries.
+// All such learners are promoted at once, using joint consensus if there is
+// more than one of them. Nothing is proposed while another conf change is
+// pending, since the leader checks again once it is applied.
+func (r *raft) maybePromoteLearners() {
+	if r.state != StateLeader || len(r.trk.LearnersAutoPromote) == 0 || r.promotionIndex != 0 {
+		return
+	}
+	// Mirror the checks of conf change proposals in stepLeader, which would
+	// otherwise turn the proposal into an empty entry.
+	if r.pendingConfIndex > r.raftLog.applied || len(r.trk.Voters[1]) > 0 || r.leadTransferee != None {
+		return
+	}
+	var ids []uint64
+	for id := range r.trk.LearnersAutoPromote {
+		pr := r.trk.Progress[id]
+		if pr.State == tracker.StateReplicate && pr.Match+r.autoPromoteMaxLag >= r.raftLog.committed {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	slices.Sort(ids)
+	var cc pb.ConfChangeV2
+	for _, id := range ids {
+		cc.Changes = append(cc.Changes, pb.ConfChangeSingle{Type: pb.ConfChangeAddNode, NodeID: id})
+	}
+	m, err := confChangeToMsg(cc)
+	if err != nil {
+		panic(err)
+	}
+	if err := r.Step(m); err != nil {
+		r.logger.Debugf("%x [term %d] not promoting learners %v: %v", r.id, r.Term, ids, err)
+		return
+	}
+	r.logger.Infof("%x [term %d] proposed promoting learners %v at index %d", r.id, r.Term, ids, r.pendingConfIndex)
+	r.promotionIndex = r.pendingConfIndex
+	r.promotionIDs = ids
+}
+
+// finishLearnerPromotion reports the outcome of the learner promotion once
+// its conf change has been applied.
+func (r *raft) finishLearnerPromotion() {
+	for _, id := range r.promotionIDs {
+		res := LearnerPromotionResult{ID: id, Index: r.promotionIndex, Outcome: LearnerPromotionSucceeded}
+		if _, ok := r.trk.Voters[0][id]; !ok {
+			res.Outcome = LearnerPromotionRejected
+		}
+		r.promotionResults = append(r.promotionResults, res)
+	}
+	r.promotionIndex, r.promotionIDs = 0, nil
+}
+
+// abortLearnerPromotion reports the learner promotion in progress, if any, as
+// aborted. It is called when the leader steps down.
+func (r *raft) abortLearnerPromotion() {
+	for _, id := range r.promotionIDs {
+		r.promotionResults = append(r.promotionResults,
+			LearnerPromotionResult{ID: id, Index: r.promotionIndex, Outcome: LearnerPromotionAborted})
+	}
+	r.promotionIndex, r.promotionIDs = 0, nil
+}
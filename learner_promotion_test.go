@@ -0,0 +1,157 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// stabilizePromotions processes the Ready structs of the given RawNode,
+// cancelling the conf changes for which cancel returns true, and returns the
+// reported learner promotions.
+func stabilizePromotions(
+	t *testing.T, rn *RawNode, s *MemoryStorage, cancel func(pb.ConfChangeV2) bool,
+) []LearnerPromotionResult {
+	var res []LearnerPromotionResult
+	for rn.HasReady() {
+		rd := rn.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		for _, e := range rd.CommittedEntries {
+			if e.Type != pb.EntryConfChangeV2 {
+				continue
+			}
+			var cc pb.ConfChangeV2
+			require.NoError(t, cc.Unmarshal(e.Data))
+			if cancel != nil && cancel(cc) {
+				for i := range cc.Changes {
+					cc.Changes[i].NodeID = 0
+				}
+			}
+			rn.ApplyConfChange(cc)
+		}
+		res = append(res, rd.LearnerPromotions...)
+		rn.Advance(rd)
+	}
+	return res
+}
+
+// ackAppend acknowledges the given log index on behalf of the given peer.
+func ackAppend(t *testing.T, rn *RawNode, from, index uint64) {
+	t.Helper()
+	require.NoError(t, rn.Step(pb.Message{From: from, To: 1, Term: rn.raft.Term, Type: pb.MsgAppResp, Index: index}))
+}
+
+func TestLearnerAutoPromotion(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withLearners(3), withLearnersAutoPromote(3))
+	rn := newTestRawNode(1, 10, 1, s)
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	require.NoError(t, rn.Propose([]byte("foo")))
+	stabilizePromotions(t, rn, s, nil)
+	ackAppend(t, rn, 2, rn.raft.raftLog.lastIndex())
+	require.Empty(t, stabilizePromotions(t, rn, s, nil))
+
+	// The learner is not promoted while it lags behind the commit index.
+	ackAppend(t, rn, 3, 1)
+	require.Empty(t, stabilizePromotions(t, rn, s, nil))
+	require.Zero(t, rn.raft.promotionIndex)
+
+	// Once it catches up, the leader proposes promoting it, and reports the
+	// outcome when the conf change is applied.
+	ackAppend(t, rn, 3, rn.raft.raftLog.lastIndex())
+	index := rn.raft.promotionIndex
+	require.Equal(t, rn.raft.raftLog.lastIndex(), index)
+	require.Empty(t, stabilizePromotions(t, rn, s, nil))
+	ackAppend(t, rn, 2, index)
+	require.Equal(t, []LearnerPromotionResult{
+		{ID: 3, Index: index, Outcome: LearnerPromotionSucceeded},
+	}, stabilizePromotions(t, rn, s, nil))
+	require.Equal(t, []uint64{1, 2, 3}, rn.raft.trk.VoterNodes())
+	require.Nil(t, rn.raft.trk.LearnersAutoPromote)
+}
+
+func TestLearnerAutoPromotionMaxLag(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withLearners(3), withLearnersAutoPromote(3))
+	rn := newTestRawNode(1, 10, 1, s, func(c *Config) { c.AutoPromoteMaxLag = 2 })
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rn.Propose([]byte("foo")))
+	}
+	stabilizePromotions(t, rn, s, nil)
+	ackAppend(t, rn, 2, rn.raft.raftLog.lastIndex())
+	stabilizePromotions(t, rn, s, nil)
+	committed := rn.raft.raftLog.committed
+	require.Equal(t, uint64(4), committed)
+	ackAppend(t, rn, 3, committed-3)
+	require.Empty(t, stabilizePromotions(t, rn, s, nil))
+	require.Zero(t, rn.raft.promotionIndex)
+	ackAppend(t, rn, 3, committed-2)
+	require.NotZero(t, rn.raft.promotionIndex)
+}
+
+func TestLearnerAutoPromotionRejected(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withLearners(3), withLearnersAutoPromote(3))
+	rn := newTestRawNode(1, 10, 1, s)
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	cancel := func(cc pb.ConfChangeV2) bool {
+		return cc.Changes[0].Type == pb.ConfChangeAddNode
+	}
+	stabilizePromotions(t, rn, s, cancel)
+	ackAppend(t, rn, 2, rn.raft.raftLog.lastIndex())
+	stabilizePromotions(t, rn, s, cancel)
+
+	ackAppend(t, rn, 3, rn.raft.raftLog.lastIndex())
+	index := rn.raft.promotionIndex
+	stabilizePromotions(t, rn, s, cancel)
+	ackAppend(t, rn, 2, index)
+	require.Equal(t, []LearnerPromotionResult{
+		{ID: 3, Index: index, Outcome: LearnerPromotionRejected},
+	}, stabilizePromotions(t, rn, s, cancel))
+	require.Equal(t, []uint64{3}, rn.raft.trk.LearnerNodes())
+
+	// The learner is still marked, so the leader tries again once it catches
+	// up.
+	require.Zero(t, rn.raft.promotionIndex)
+	ackAppend(t, rn, 3, rn.raft.raftLog.lastIndex())
+	require.Equal(t, rn.raft.raftLog.lastIndex(), rn.raft.promotionIndex)
+	require.Greater(t, rn.raft.promotionIndex, index)
+}
+
+func TestLearnerAutoPromotionAborted(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withLearners(3), withLearnersAutoPromote(3))
+	rn := newTestRawNode(1, 10, 1, s)
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	stabilizePromotions(t, rn, s, nil)
+	ackAppend(t, rn, 2, rn.raft.raftLog.lastIndex())
+	stabilizePromotions(t, rn, s, nil)
+
+	ackAppend(t, rn, 3, rn.raft.raftLog.lastIndex())
+	index := rn.raft.promotionIndex
+	require.NotZero(t, index)
+	stabilizePromotions(t, rn, s, nil)
+
+	rn.raft.becomeFollower(2, 2)
+	require.Equal(t, []LearnerPromotionResult{
+		{ID: 3, Index: index, Outcome: LearnerPromotionAborted},
+	}, stabilizePromotions(t, rn, s, nil))
+	require.Zero(t, rn.raft.promotionIndex)
+}
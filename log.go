@@ -27,6 +27,9 @@ type raftLog struct {
 	// unstable contains all unstable entries and snapshot.
 	// they will be saved into storage.
 	unstable unstable
+	// terms indexes the terms of the log entries, both stable and unstable, so
+	// that looking them up does not need to access Storage.
+	terms termIndex
 
 	// committed is the highest log position that is known to be in
 	// stable storage on a quorum of nodes.
@@ -59,6 +62,20 @@ type raftLog struct {
 	// applyingEntsPaused is true when entry application has been paused until
 	// enough progress is acknowledged.
 	applyingEntsPaused bool
+
+	// maxApplyLag limits the number of entries that can be handed out for
+	// application ahead of the application's apply progress, see
+	// Config.MaxApplyLag. Zero means no limit.
+	maxApplyLag uint64
+	// reportedApplied is the highest log position that the application
+	// reported as applied to its state machine via reportApplyProgress. It can
+	// be ahead of applied, which is only updated once the application
+	// acknowledges the Ready or MsgStorageApply that handed out the entries.
+	// Invariant: reportedApplied <= applying
+	reportedApplied uint64
+	// applyQueueDepth is the number of entries that the application reported
+	// as queued for application at reportedApplied.
+	applyQueueDepth uint64
 }
 
 // newLog returns log using the given storage and default options. It
@@ -86,12 +103,14 @@ func newLogWithSize(storage Storage, logger Logger, maxApplyingEntsSize entryEnc
 			offsetInProgress: lastIndex + 1,
 			logger:           logger,
 		},
+		terms:               newTermIndex(storage, firstIndex-1, lastIndex),
 		maxApplyingEntsSize: maxApplyingEntsSize,
 
 		// Initialize our committed and applied pointers to the time of the last compaction.
-		committed: firstIndex - 1,
-		applying:  firstIndex - 1,
-		applied:   firstIndex - 1,
+		committed:       firstIndex - 1,
+		applying:        firstIndex - 1,
+		applied:         firstIndex - 1,
+		reportedApplied: firstIndex - 1,
 
 		logger: logger,
 	}
@@ -136,6 +155,7 @@ func (l *raftLog) append(ents ...pb.Entry) uint64 {
 		l.logger.Panicf("after(%d) is out of range [committed(%d)]", after, l.committed)
 	}
 	l.unstable.truncateAndAppend(ents)
+	l.terms.truncateAndAppend(ents)
 	return l.lastIndex()
 }
 
@@ -178,6 +198,14 @@ func (l *raftLog) findConflict(ents []pb.Entry) uint64 {
 // an unsuccessful append to a follower, and ultimately restore the steady flow
 // of appends.
 func (l *raftLog) findConflictByTerm(index uint64, term uint64) (uint64, uint64) {
+	// Look up the terms in the index, which takes O(log terms). Fall back to
+	// scanning the log if the index does not cover the given index.
+	if first := l.firstIndex(); index+1 >= first && index <= l.lastIndex() {
+		l.terms.compact(first - 1)
+		if index, term, ok := l.terms.findConflictByTerm(index, term); ok {
+			return index, term
+		}
+	}
 	for ; index > 0; index-- {
 		// If there is an error (likely ErrCompacted or ErrUnavailable), we don't
 		// know whether it's a match or not, so assume a possible match and return
@@ -191,6 +219,56 @@ func (l *raftLog) findConflictByTerm(index uint64, term uint64) (uint64, uint64)
 	return 0, 0
 }
 
+// termBoundaries returns up to limit last term boundaries of the (after, index]
+// interval of the log, in increasing index order. Each boundary is a term, and
+// the first index in the interval at which the log has this term.
+//
+// This function is used by a follower to describe its divergent log tail when
+// rejecting an append, see findConflictByTermBoundaries.
+func (l *raftLog) termBoundaries(after, index uint64, limit int) []pb.TermBoundary {
+	return l.terms.boundaries(after, index, limit)
+}
+
+// findConflictByTermBoundaries returns a best guess on where this log ends
+// matching another log, given the term boundaries of the other log's tail up to
+// the given index, as returned by termBoundaries.
+//
+// If the logs have the same term at some index covered by the boundaries, the
+// returned value is the largest such index. By the Log Matching property, the
+// logs match up to this index. Otherwise, the logs do not match at any of the
+// covered indices, and the returned value is the index preceding the first
+// boundary.
+//
+// This function is used by a leader to find where its log matches a follower's
+// log in one round trip, even if the follower has many short divergent terms.
+func (l *raftLog) findConflictByTermBoundaries(bs []pb.TermBoundary, index uint64) uint64 {
+	for i := len(bs) - 1; i >= 0; i-- {
+		lo, hi := bs[i].Index, index
+		if i+1 < len(bs) {
+			hi = bs[i+1].Index - 1
+		}
+		hi = min(hi, l.lastIndex())
+		if hi < lo {
+			continue
+		}
+		// Find the last index in [lo, hi] at which this log has a term not
+		// exceeding the other log's term at this index.
+		guess, term := l.findConflictByTerm(hi, bs[i].Term)
+		if guess < lo {
+			continue
+		} else if term == bs[i].Term || term == 0 {
+			// The logs match at guess, or we can't tell since its term is unknown.
+			return guess
+		}
+		// Otherwise, this log has terms below bs[i].Term at [lo, guess], and
+		// above it at (guess, hi], so the logs do not match in [lo, hi].
+	}
+	if len(bs) == 0 {
+		return index
+	}
+	return bs[0].Index - 1
+}
+
 // nextUnstableEnts returns all entries that are available to be written to the
 // local stable log and are not already in-progress.
 func (l *raftLog) nextUnstableEnts() []pb.Entry {
@@ -267,9 +345,40 @@ func (l *raftLog) maxAppliableIndex(allowUnstable bool) uint64 {
 	if !allowUnstable {
 		hi = min(hi, l.unstable.offset-1)
 	}
+	if l.maxApplyLag > 0 {
+		hi = min(hi, l.applyLagLimit())
+	}
 	return hi
 }
 
+// appliedIndex returns the highest log position known to be applied to the
+// state machine, either acknowledged or reported by the application.
+func (l *raftLog) appliedIndex() uint64 {
+	return max(l.applied, l.reportedApplied)
+}
+
+// applyLag returns the number of entries that were handed out for application
+// but are not yet applied. If the application reported a larger queue depth,
+// the queue depth is returned instead.
+func (l *raftLog) applyLag() uint64 {
+	return max(l.applying-l.appliedIndex(), l.applyQueueDepth)
+}
+
+// applyLagLimit returns the maximum index that can be handed out for
+// application without exceeding maxApplyLag.
+func (l *raftLog) applyLagLimit() uint64 {
+	if lag := l.applyLag(); lag < l.maxApplyLag {
+		return l.applying + l.maxApplyLag - lag
+	}
+	return l.applying
+}
+
+// applyThrottled returns true if committed entries are held back because the
+// apply lag has reached maxApplyLag.
+func (l *raftLog) applyThrottled() bool {
+	return l.maxApplyLag > 0 && l.applyLagLimit() < l.committed
+}
+
 // nextUnstableSnapshot returns the snapshot, if present, that is available to
 // be applied to the local storage and is not already in-progress.
 func (l *raftLog) nextUnstableSnapshot() *pb.Snapshot {
@@ -333,6 +442,11 @@ func (l *raftLog) appliedTo(i uint64, size entryEncodingSize) {
 	}
 	l.applied = i
 	l.applying = max(l.applying, i)
+	if i > l.reportedApplied {
+		// The entries up to i are no longer queued.
+		l.applyQueueDepth -= min(l.applyQueueDepth, i-l.reportedApplied)
+		l.reportedApplied = i
+	}
 	if l.applyingEntsSize > size {
 		l.applyingEntsSize -= size
 	} else {
@@ -346,6 +460,9 @@ func (l *raftLog) acceptApplying(i uint64, size entryEncodingSize, allowUnstable
 	if l.committed < i {
 		l.logger.Panicf("applying(%d) is out of range [prevApplying(%d), committed(%d)]", i, l.applying, l.committed)
 	}
+	// NB: the maximum appliable index depends on applying when the apply lag is
+	// limited, so it must be computed before applying is updated.
+	maxIndex := l.maxAppliableIndex(allowUnstable)
 	l.applying = i
 	l.applyingEntsSize += size
 	// Determine whether to pause entry application until some progress is
@@ -359,7 +476,21 @@ func (l *raftLog) acceptApplying(i uint64, size entryEncodingSize, allowUnstable
 	//    not equal, then the returned entries slice must have been truncated to
 	//    adhere to the memory limit.
 	l.applyingEntsPaused = l.applyingEntsSize >= l.maxApplyingEntsSize ||
-		i < l.maxAppliableIndex(allowUnstable)
+		i < maxIndex
+}
+
+// reportApplyProgress records the apply progress reported by the application:
+// the highest log position applied to the state machine, and the number of
+// entries queued for application. Reports for positions that were not handed
+// out for application are clamped.
+func (l *raftLog) reportApplyProgress(applied, queueDepth uint64) {
+	applied = min(applied, l.applying)
+	if applied < l.reportedApplied {
+		// A stale report.
+		return
+	}
+	l.reportedApplied = applied
+	l.applyQueueDepth = queueDepth
 }
 
 func (l *raftLog) stableTo(id entryID) { l.unstable.stableTo(id) }
@@ -393,13 +524,21 @@ func (l *raftLog) term(i uint64) (uint64, error) {
 	// The valid term range is [firstIndex-1, lastIndex]. Even though the entry at
 	// firstIndex-1 is compacted away, its term is available for matching purposes
 	// when doing log appends.
-	if i+1 < l.firstIndex() {
+	first := l.firstIndex()
+	if i+1 < first {
 		return 0, ErrCompacted
 	}
 	if i > l.lastIndex() {
 		return 0, ErrUnavailable
 	}
 
+	// Storage may have been compacted since the last lookup. The index needs to
+	// know about it, so that it does not return the terms of compacted entries.
+	l.terms.compact(first - 1)
+	if t, ok := l.terms.term(i); ok {
+		return t, nil
+	}
+
 	t, err := l.storage.Term(i)
 	if err == nil {
 		return t, nil
@@ -465,6 +604,7 @@ func (l *raftLog) restore(s pb.Snapshot) {
 	l.logger.Infof("log [%s] starts to restore snapshot [index: %d, term: %d]", l, s.Metadata.Index, s.Metadata.Term)
 	l.committed = s.Metadata.Index
 	l.unstable.restore(s)
+	l.terms.reset(entryID{term: s.Metadata.Term, index: s.Metadata.Index})
 }
 
 // scan visits all log entries in the [lo, hi) range, returning them via the
@@ -0,0 +1,180 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"slices"
+	"sort"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// TermBoundaryStorage is an optional extension of LogStorage, which supplies
+// the term boundaries of the log cheaply, e.g. from an index maintained by a
+// durable storage. If the LogStorage implements it, raft uses it to seed the
+// in-memory index of log terms at startup. Otherwise, raft finds the term
+// boundaries with a binary search using LogStorage.Term.
+type TermBoundaryStorage interface {
+	LogStorage
+	// TermBoundaries returns the index of the first entry of each run of entries
+	// with the same term in [FirstIndex()-1, LastIndex()], in increasing index
+	// order. The first boundary is at FirstIndex()-1, and has the term of the
+	// entry at this index. The caller owns the returned slice.
+	TermBoundaries() ([]pb.TermBoundary, error)
+}
+
+// termIndex is an in-memory index of the terms of a contiguous span of the log
+// entries. It only stores the first index of each term, so it is small, and
+// finding the term of an entry in the span takes O(log terms).
+type termIndex struct {
+	// runs contains the first index of each run of entries with the same term,
+	// in increasing order of indices and terms. runs[0].Index is the first
+	// index of the span, which is not necessarily the first index of its term.
+	runs []pb.TermBoundary
+	// last is the last index of the span.
+	last uint64
+}
+
+// newTermIndex returns the index of the terms of the entries in [first, last]
+// in the given storage. Returns an empty index if the storage doesn't have the
+// terms of these entries.
+func newTermIndex(storage LogStorage, first, last uint64) termIndex {
+	if ts, ok := storage.(TermBoundaryStorage); ok {
+		if runs, err := ts.TermBoundaries(); err == nil && len(runs) != 0 &&
+			runs[0].Index == first && runs[len(runs)-1].Index <= last {
+			return termIndex{runs: runs, last: last}
+		}
+	}
+	var runs []pb.TermBoundary
+	for index := last; index+1 > first; {
+		term, err := storage.Term(index)
+		if err != nil {
+			return termIndex{}
+		}
+		// Find the first index in [first, index] with this term. The log terms
+		// never decrease, so the indices with this term are contiguous.
+		lo, hi := first, index
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			if t, err := storage.Term(mid); err == nil && t == term {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		runs = append(runs, pb.TermBoundary{Term: term, Index: lo})
+		if lo == 0 {
+			break
+		}
+		index = lo - 1
+	}
+	slices.Reverse(runs)
+	return termIndex{runs: runs, last: last}
+}
+
+// run returns the position of the run containing the given index, or -1 if the
+// index is below the span.
+func (t *termIndex) run(index uint64) int {
+	return sort.Search(len(t.runs), func(i int) bool {
+		return t.runs[i].Index > index
+	}) - 1
+}
+
+// term returns the term of the entry at the given index, or false if the index
+// is outside the span.
+func (t *termIndex) term(index uint64) (uint64, bool) {
+	if len(t.runs) == 0 || index > t.last {
+		return 0, false
+	}
+	i := t.run(index)
+	if i < 0 {
+		return 0, false
+	}
+	return t.runs[i].Term, true
+}
+
+// findConflictByTerm returns the max index <= the given one, such that the term
+// at this index is <= the given term or unknown, and the term at this index or 0
+// if it is unknown. See raftLog.findConflictByTerm. Returns false if the given
+// index is outside the span.
+func (t *termIndex) findConflictByTerm(index, term uint64) (uint64, uint64, bool) {
+	if _, ok := t.term(index); !ok {
+		return 0, 0, false
+	}
+	i := t.run(index)
+	// The terms of the runs increase, so find the last run with a term <= term.
+	if j := sort.Search(i+1, func(j int) bool { return t.runs[j].Term > term }) - 1; j == i {
+		return index, t.runs[j].Term, true
+	} else if j >= 0 {
+		return t.runs[j+1].Index - 1, t.runs[j].Term, true
+	}
+	// All the terms in the span up to the index are above the given term, and
+	// the term preceding the span is unknown.
+	if first := t.runs[0].Index; first > 0 {
+		return first - 1, 0, true
+	}
+	return 0, 0, true
+}
+
+// boundaries returns up to limit last term boundaries of the (after, index]
+// interval of the span, in increasing index order. The interval must be within
+// the span.
+func (t *termIndex) boundaries(after, index uint64, limit int) []pb.TermBoundary {
+	var bs []pb.TermBoundary
+	for i := t.run(index); i >= 0 && index > after && len(bs) < limit; i-- {
+		b := t.runs[i]
+		b.Index = max(b.Index, after+1)
+		bs = append(bs, b)
+		index = b.Index - 1
+	}
+	slices.Reverse(bs)
+	return bs
+}
+
+// truncateAndAppend truncates the span to the index preceding the first of the
+// given entries, and appends the entries to it.
+func (t *termIndex) truncateAndAppend(ents []pb.Entry) {
+	if len(ents) == 0 {
+		return
+	}
+	if after := ents[0].Index - 1; len(t.runs) != 0 && after < t.last {
+		t.runs = t.runs[:t.run(after)+1]
+		t.last = after
+	}
+	for i := range ents {
+		if n := len(t.runs); n == 0 || t.runs[n-1].Term != ents[i].Term {
+			t.runs = append(t.runs, pb.TermBoundary{Term: ents[i].Term, Index: ents[i].Index})
+		}
+	}
+	t.last = ents[len(ents)-1].Index
+}
+
+// compact removes the entries below the given index from the span.
+func (t *termIndex) compact(index uint64) {
+	if len(t.runs) == 0 || index <= t.runs[0].Index {
+		return
+	} else if index > t.last {
+		*t = termIndex{}
+		return
+	}
+	t.runs = t.runs[t.run(index):]
+	t.runs[0].Index = index
+}
+
+// reset makes the span consist of the single entry with the given ID.
+func (t *termIndex) reset(id entryID) {
+	t.runs = append(t.runs[:0], pb.TermBoundary{Term: id.term, Index: id.index})
+	t.last = id.index
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// termBoundaryStorage is a MemoryStorage which supplies the term boundaries.
+type termBoundaryStorage struct {
+	*MemoryStorage
+	bs []pb.TermBoundary
+}
+
+func (s termBoundaryStorage) TermBoundaries() ([]pb.TermBoundary, error) {
+	return s.bs, nil
+}
+
+func TestNewTermIndex(t *testing.T) {
+	ents := index(10).terms(3, 3, 3, 4, 5, 5, 7, 7, 7, 8)
+	want := termIndex{
+		runs: []pb.TermBoundary{{Term: 3, Index: 10}, {Term: 4, Index: 13}, {Term: 5, Index: 14},
+			{Term: 7, Index: 16}, {Term: 8, Index: 19}},
+		last: 19,
+	}
+	st := NewMemoryStorage()
+	require.NoError(t, st.ApplySnapshot(pb.Snapshot{Metadata: pb.SnapshotMetadata{
+		Index: ents[0].Index,
+		Term:  ents[0].Term,
+	}}))
+	require.NoError(t, st.Append(ents[1:]))
+
+	st.callStats = inMemStorageCallStats{}
+	require.Equal(t, want, newTermIndex(st, 10, 19))
+	// The terms are found by a binary search rather than a scan.
+	require.Less(t, st.callStats.term, 4*len(want.runs))
+
+	st.callStats = inMemStorageCallStats{}
+	require.Equal(t, want, newTermIndex(termBoundaryStorage{MemoryStorage: st, bs: want.runs}, 10, 19))
+	require.Zero(t, st.callStats.term)
+	// The boundaries not matching the log are ignored.
+	require.Equal(t, want, newTermIndex(termBoundaryStorage{MemoryStorage: st, bs: want.runs[1:]}, 10, 19))
+}
+
+// TestTermIndex checks the termIndex against the terms of a log after random
+// appends and compactions.
+func TestTermIndex(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(seed))
+			terms := []uint64{0} // terms[i] is the term at index i
+			first := uint64(0)   // the first index of the span
+			ti := termIndex{runs: []pb.TermBoundary{{Term: 0, Index: 0}}}
+
+			for step := 0; step < 50; step++ {
+				switch last := uint64(len(terms) - 1); {
+				case rnd.Intn(4) == 0 && last > first:
+					first += uint64(rnd.Intn(int(last-first))) + 1
+					ti.compact(first)
+				default:
+					after := first + uint64(rnd.Intn(int(last-first)+1))
+					term := terms[after]
+					var ents []pb.Entry
+					for i, n := uint64(1), rnd.Intn(10)+1; i <= uint64(n); i++ {
+						term += uint64(rnd.Intn(2))
+						ents = append(ents, pb.Entry{Index: after + i, Term: term})
+					}
+					ti.truncateAndAppend(ents)
+					terms = terms[:after+1]
+					for _, e := range ents {
+						terms = append(terms, e.Term)
+					}
+				}
+
+				last := uint64(len(terms) - 1)
+				require.Equal(t, last, ti.last)
+				if first > 0 {
+					_, ok := ti.term(first - 1)
+					require.False(t, ok)
+				}
+				_, ok := ti.term(last + 1)
+				require.False(t, ok)
+				for i := first; i <= last; i++ {
+					term, ok := ti.term(i)
+					require.True(t, ok)
+					require.Equal(t, terms[i], term)
+
+					for term := uint64(0); term <= terms[last]+1; term++ {
+						// Find the expected conflict the slow way. If there is none in
+						// the span, it is at first-1 with an unknown term.
+						wantIndex, wantTerm := i, uint64(0)
+						for ; wantIndex >= first; wantIndex-- {
+							if terms[wantIndex] <= term {
+								wantTerm = terms[wantIndex]
+								break
+							}
+						}
+						index, gotTerm, ok := ti.findConflictByTerm(i, term)
+						require.True(t, ok)
+						require.Equal(t, wantIndex, index, "index %d, term %d", i, term)
+						require.Equal(t, wantTerm, gotTerm, "index %d, term %d", i, term)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRaftLogTermFromIndex checks that the raftLog looks up the terms of the
+// stable entries without accessing Storage.
+func TestRaftLogTermFromIndex(t *testing.T) {
+	st := NewMemoryStorage()
+	require.NoError(t, st.Append(index(1).terms(1, 1, 2, 2, 2, 3, 5, 5)))
+	l := newLog(st, raftLogger)
+	l.append(index(8).terms(6, 6)...)
+
+	st.callStats = inMemStorageCallStats{}
+	for i, want := range []uint64{0, 1, 1, 2, 2, 2, 3, 5, 6, 6} {
+		require.Equal(t, want, mustTerm(l.term(uint64(i))))
+	}
+	index, term := l.findConflictByTerm(9, 4)
+	require.Equal(t, uint64(6), index)
+	require.Equal(t, uint64(3), term)
+	require.Zero(t, st.callStats.term)
+
+	// The index learns about the compaction of Storage.
+	require.NoError(t, st.Compact(4))
+	_, err := l.term(2)
+	require.Equal(t, ErrCompacted, err)
+	require.Equal(t, uint64(2), mustTerm(l.term(4)))
+	index, term = l.findConflictByTerm(5, 1)
+	require.Equal(t, uint64(3), index)
+	require.Zero(t, term)
+	require.Zero(t, st.callStats.term)
+
+	// Restoring a snapshot resets the index.
+	l.restore(pb.Snapshot{Metadata: pb.SnapshotMetadata{Index: 20, Term: 7}})
+	require.Equal(t, termIndex{runs: []pb.TermBoundary{{Term: 7, Index: 20}}, last: 20}, l.terms)
+}
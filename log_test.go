@@ -105,6 +105,96 @@ func TestFindConflictByTerm(t *testing.T) {
 	}
 }
 
+func TestTermBoundaries(t *testing.T) {
+	ents := index(10).terms(3, 3, 3, 4, 5, 5, 7, 7, 7, 8)
+	for _, tt := range []struct {
+		after uint64
+		index uint64
+		limit int
+		want  []pb.TermBoundary
+	}{
+		{after: 19, index: 19, limit: 8, want: nil},
+		{after: 18, index: 19, limit: 8, want: []pb.TermBoundary{{Term: 8, Index: 19}}},
+		{after: 10, index: 19, limit: 8, want: []pb.TermBoundary{
+			{Term: 3, Index: 11}, {Term: 4, Index: 13}, {Term: 5, Index: 14},
+			{Term: 7, Index: 16}, {Term: 8, Index: 19},
+		}},
+		{after: 13, index: 18, limit: 8, want: []pb.TermBoundary{
+			{Term: 5, Index: 14}, {Term: 7, Index: 16},
+		}},
+		{after: 10, index: 19, limit: 2, want: []pb.TermBoundary{
+			{Term: 7, Index: 16}, {Term: 8, Index: 19},
+		}},
+		// The entries below the snapshot are not covered.
+		{after: 0, index: 13, limit: 8, want: []pb.TermBoundary{
+			{Term: 3, Index: 10}, {Term: 4, Index: 13},
+		}},
+	} {
+		t.Run("", func(t *testing.T) {
+			st := NewMemoryStorage()
+			st.ApplySnapshot(pb.Snapshot{Metadata: pb.SnapshotMetadata{
+				Index: ents[0].Index,
+				Term:  ents[0].Term,
+			}})
+			l := newLog(st, raftLogger)
+			l.append(ents[1:]...)
+			require.Equal(t, tt.want, l.termBoundaries(tt.after, tt.index, tt.limit))
+		})
+	}
+}
+
+func TestFindConflictByTermBoundaries(t *testing.T) {
+	for _, tt := range []struct {
+		ents   []pb.Entry // ents[0] contains the (index, term) of the snapshot
+		bounds []pb.TermBoundary
+		index  uint64
+		want   uint64
+	}{
+		// No boundaries, nothing is known beyond the index.
+		{ents: index(0).terms(0, 1, 1, 2, 2), index: 3, want: 3},
+		// The terms match at the last index.
+		{ents: index(0).terms(0, 1, 1, 2, 2), bounds: []pb.TermBoundary{{Term: 2, Index: 3}}, index: 4, want: 4},
+		// The terms match in the last run of the other log, up to where the run
+		// of this log ends.
+		{ents: index(0).terms(0, 1, 1, 2, 2, 5), bounds: []pb.TermBoundary{{Term: 2, Index: 3}}, index: 6, want: 4},
+		// Many short divergent terms in the other log, with a match in the first.
+		{
+			ents:   index(0).terms(0, 1, 1, 2, 2, 9, 9, 9, 9),
+			bounds: []pb.TermBoundary{{Term: 2, Index: 3}, {Term: 3, Index: 5}, {Term: 4, Index: 6}, {Term: 5, Index: 7}},
+			index:  8, want: 4,
+		},
+		// The terms of the other log are larger in the covered interval.
+		{
+			ents:   index(0).terms(0, 1, 1, 2, 2, 2, 2),
+			bounds: []pb.TermBoundary{{Term: 3, Index: 4}, {Term: 4, Index: 5}},
+			index:  6, want: 3,
+		},
+		// The terms of the other log are smaller in the covered interval.
+		{
+			ents:   index(0).terms(0, 1, 1, 5, 5, 5, 5),
+			bounds: []pb.TermBoundary{{Term: 2, Index: 4}, {Term: 3, Index: 5}},
+			index:  6, want: 3,
+		},
+		// The other log is longer than this one.
+		{ents: index(0).terms(0, 1, 1, 2), bounds: []pb.TermBoundary{{Term: 2, Index: 3}}, index: 8, want: 3},
+		// The terms in the covered interval are compacted, so the match is
+		// unknown.
+		{ents: index(10).terms(3, 3, 3), bounds: []pb.TermBoundary{{Term: 2, Index: 5}}, index: 8, want: 8},
+	} {
+		t.Run("", func(t *testing.T) {
+			st := NewMemoryStorage()
+			require.NotEmpty(t, tt.ents)
+			st.ApplySnapshot(pb.Snapshot{Metadata: pb.SnapshotMetadata{
+				Index: tt.ents[0].Index,
+				Term:  tt.ents[0].Term,
+			}})
+			l := newLog(st, raftLogger)
+			l.append(tt.ents[1:]...)
+			require.Equal(t, tt.want, l.findConflictByTermBoundaries(tt.bounds, tt.index))
+		})
+	}
+}
+
 func TestIsUpToDate(t *testing.T) {
 	previousEnts := index(1).terms(1, 2, 3)
 	raftLog := newLog(NewMemoryStorage(), raftLogger)
@@ -568,6 +658,63 @@ func TestAppliedTo(t *testing.T) {
 	}
 }
 
+// TestApplyLag checks that the committed entries handed out for application
+// are limited by the apply lag, taking the apply progress reported by the
+// application into account.
+func TestApplyLag(t *testing.T) {
+	snap := pb.Snapshot{
+		Metadata: pb.SnapshotMetadata{Term: 1, Index: 3},
+	}
+	ents := index(4).terms(1, 1, 1, 1, 1, 1)
+	tests := []struct {
+		applied    uint64
+		applying   uint64
+		reported   uint64 // 0 means no report
+		queueDepth uint64
+
+		wents      []pb.Entry
+		wthrottled bool
+	}{
+		{applied: 3, applying: 3, wents: ents[:3], wthrottled: true},
+		{applied: 3, applying: 4, wents: ents[1:3], wthrottled: true},
+		{applied: 3, applying: 6, wents: nil, wthrottled: true},
+		{applied: 5, applying: 6, wents: ents[3:5], wthrottled: true},
+		{applied: 6, applying: 6, wents: ents[3:6]},
+		{applied: 7, applying: 7, wents: ents[4:6]},
+		// The reported apply progress allows handing out more entries.
+		{applied: 3, applying: 6, reported: 4, wents: ents[3:4], wthrottled: true},
+		{applied: 3, applying: 6, reported: 6, wents: ents[3:6]},
+		// The report is clamped to the applying index.
+		{applied: 3, applying: 4, reported: 9, wents: ents[1:4], wthrottled: true},
+		// The queue depth counts towards the lag.
+		{applied: 3, applying: 6, reported: 6, queueDepth: 2, wents: ents[3:4], wthrottled: true},
+		{applied: 3, applying: 6, reported: 6, queueDepth: 3, wents: nil, wthrottled: true},
+		{applied: 3, applying: 6, reported: 5, queueDepth: 5, wents: nil, wthrottled: true},
+		// The queue depth shrinks as entries are acknowledged.
+		{applied: 6, applying: 6, reported: 4, queueDepth: 2, wents: ents[3:6]},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			storage := NewMemoryStorage()
+			require.NoError(t, storage.ApplySnapshot(snap))
+			require.NoError(t, storage.Append(ents))
+
+			raftLog := newLog(storage, raftLogger)
+			raftLog.maxApplyLag = 3
+			raftLog.maybeCommit(entryID{term: 1, index: 9})
+			raftLog.acceptApplying(tt.applying, 0 /* size */, false /* allowUnstable */)
+			if tt.reported != 0 {
+				raftLog.reportApplyProgress(tt.reported, tt.queueDepth)
+			}
+			raftLog.appliedTo(tt.applied, 0 /* size */)
+
+			require.Equal(t, tt.wents, raftLog.nextCommittedEnts(false /* allowUnstable */))
+			require.Equal(t, tt.wents != nil, raftLog.hasNextCommittedEnts(false /* allowUnstable */))
+			require.Equal(t, tt.wthrottled, raftLog.applyThrottled())
+		})
+	}
+}
+
 // TestNextUnstableEnts ensures unstableEntries returns the unstable part of the
 // entries correctly.
 func TestNextUnstableEnts(t *testing.T) {
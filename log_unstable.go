@@ -46,6 +46,10 @@ type unstable struct {
 	// Invariant: offset <= offsetInProgress
 	offsetInProgress uint64
 
+	// onTruncate, if set, is called when the entries at indices >= the given
+	// index are removed from the log, and replaced by different entries.
+	onTruncate func(index uint64)
+
 	logger Logger
 }
 
@@ -201,6 +205,7 @@ func (u *unstable) truncateAndAppend(ents []pb.Entry) {
 		u.entries = append(u.entries, ents...)
 	case fromIndex <= u.offset:
 		u.logger.Infof("replace the unstable entries from index %d", fromIndex)
+		u.truncated(fromIndex)
 		// The log is being truncated to before our current offset
 		// portion, so set the offset and replace the entries.
 		u.entries = ents
@@ -209,6 +214,7 @@ func (u *unstable) truncateAndAppend(ents []pb.Entry) {
 	default:
 		// Truncate to fromIndex (exclusive), and append the new entries.
 		u.logger.Infof("truncate the unstable entries before index %d", fromIndex)
+		u.truncated(fromIndex)
 		keep := u.slice(u.offset, fromIndex) // NB: appending to this slice is safe,
 		u.entries = append(keep, ents...)    // and will reallocate/copy it
 		// Only in-progress entries before fromIndex are still considered to be
@@ -217,6 +223,14 @@ func (u *unstable) truncateAndAppend(ents []pb.Entry) {
 	}
 }
 
+// truncated notifies about the entries at indices >= the given index being
+// removed from the log.
+func (u *unstable) truncated(index uint64) {
+	if u.onTruncate != nil {
+		u.onTruncate(index)
+	}
+}
+
 // slice returns the entries from the unstable log with indexes in the range
 // [lo, hi). The entire range must be stored in the unstable log or the method
 // will panic. The returned slice can be appended to, but the entries in it must
@@ -0,0 +1,208 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"sync"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// Metrics receives the events that occur in the raft state machine, see
+// Config.Metrics. The methods are called synchronously while the raft state
+// machine is stepped or ticked, so they must be cheap and must not call back
+// into the raft node.
+//
+// Implementations should embed NoopMetrics to remain compatible with the
+// events added in the future.
+type Metrics interface {
+	// ElectionStarted is called when the node campaigns to become the leader in
+	// the given term.
+	ElectionStarted(term uint64)
+	// ElectionWon is called when the node wins the election in the given term.
+	ElectionWon(term uint64)
+	// ElectionLost is called when the node loses the vote in the given term.
+	ElectionLost(term uint64)
+	// PreVoteStarted is called when the node starts a pre-vote for the given
+	// term. See Config.PreVote.
+	PreVoteStarted(term uint64)
+	// PreVoteWon is called when the pre-vote for the given term succeeds, and
+	// the node proceeds to the election.
+	PreVoteWon(term uint64)
+	// PreVoteLost is called when the pre-vote for the given term fails.
+	PreVoteLost(term uint64)
+	// TermChanged is called when the term of the node changes.
+	TermChanged(from, to uint64)
+	// MessageSent is called for every message sent to another node.
+	MessageSent(typ pb.MessageType)
+	// MessageReceived is called for every message received from another node.
+	MessageReceived(typ pb.MessageType)
+	// ProposalDropped is called when a proposal is dropped with
+	// ErrProposalDropped.
+	ProposalDropped(reason ProposalDropReason)
+	// UncommittedSizeRejected is called when the leader rejects the entries of
+	// the given payload size, because the uncommitted log would exceed
+	// Config.MaxUncommittedEntriesSize.
+	UncommittedSizeRejected(size uint64)
+	// SnapshotSent is called when the leader starts sending the snapshot at the
+	// given index to the given follower.
+	SnapshotSent(to, index uint64)
+	// SnapshotApplied is called when the node restores the snapshot at the
+	// given index received from the leader.
+	SnapshotApplied(index uint64)
+	// ProbeToReplicate is called when the leader moves the given follower from
+	// StateProbe to StateReplicate, i.e. the follower's log is found to match
+	// the leader's. See tracker.Progress.
+	ProbeToReplicate(id uint64)
+}
+
+// NoopMetrics is a Metrics implementation which ignores all events. It is used
+// if Config.Metrics is not set.
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) ElectionStarted(uint64)             {}
+func (NoopMetrics) ElectionWon(uint64)                 {}
+func (NoopMetrics) ElectionLost(uint64)                {}
+func (NoopMetrics) PreVoteStarted(uint64)              {}
+func (NoopMetrics) PreVoteWon(uint64)                  {}
+func (NoopMetrics) PreVoteLost(uint64)                 {}
+func (NoopMetrics) TermChanged(uint64, uint64)         {}
+func (NoopMetrics) MessageSent(pb.MessageType)         {}
+func (NoopMetrics) MessageReceived(pb.MessageType)     {}
+func (NoopMetrics) ProposalDropped(ProposalDropReason) {}
+func (NoopMetrics) UncommittedSizeRejected(uint64)     {}
+func (NoopMetrics) SnapshotSent(uint64, uint64)        {}
+func (NoopMetrics) SnapshotApplied(uint64)             {}
+func (NoopMetrics) ProbeToReplicate(uint64)            {}
+
+// MetricsCounts holds the number of events received by CounterMetrics.
+type MetricsCounts struct {
+	ElectionsStarted uint64
+	ElectionsWon     uint64
+	ElectionsLost    uint64
+	PreVotesStarted  uint64
+	PreVotesWon      uint64
+	PreVotesLost     uint64
+	TermChanges      uint64
+
+	MessagesSent     map[pb.MessageType]uint64
+	MessagesReceived map[pb.MessageType]uint64
+	ProposalsDropped map[ProposalDropReason]uint64
+
+	UncommittedSizeRejections uint64
+	SnapshotsSent             uint64
+	SnapshotsApplied          uint64
+	ProbeToReplicate          uint64
+}
+
+// CounterMetrics is a Metrics implementation which counts the events in memory.
+// It is safe for concurrent use, so the counts can be read while the node is
+// running.
+type CounterMetrics struct {
+	mu     sync.Mutex
+	counts MetricsCounts
+}
+
+var _ Metrics = (*CounterMetrics)(nil)
+
+// Counts returns a copy of the current counts.
+func (m *CounterMetrics) Counts() MetricsCounts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.counts
+	c.MessagesSent = cloneCounts(c.MessagesSent)
+	c.MessagesReceived = cloneCounts(c.MessagesReceived)
+	c.ProposalsDropped = cloneCounts(c.ProposalsDropped)
+	return c
+}
+
+func cloneCounts[K comparable](m map[K]uint64) map[K]uint64 {
+	c := make(map[K]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (m *CounterMetrics) inc(f func(c *MetricsCounts)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&m.counts)
+}
+
+func incKey[K comparable](m *map[K]uint64, k K) {
+	if *m == nil {
+		*m = map[K]uint64{}
+	}
+	(*m)[k]++
+}
+
+func (m *CounterMetrics) ElectionStarted(uint64) {
+	m.inc(func(c *MetricsCounts) { c.ElectionsStarted++ })
+}
+
+func (m *CounterMetrics) ElectionWon(uint64) {
+	m.inc(func(c *MetricsCounts) { c.ElectionsWon++ })
+}
+
+func (m *CounterMetrics) ElectionLost(uint64) {
+	m.inc(func(c *MetricsCounts) { c.ElectionsLost++ })
+}
+
+func (m *CounterMetrics) PreVoteStarted(uint64) {
+	m.inc(func(c *MetricsCounts) { c.PreVotesStarted++ })
+}
+
+func (m *CounterMetrics) PreVoteWon(uint64) {
+	m.inc(func(c *MetricsCounts) { c.PreVotesWon++ })
+}
+
+func (m *CounterMetrics) PreVoteLost(uint64) {
+	m.inc(func(c *MetricsCounts) { c.PreVotesLost++ })
+}
+
+func (m *CounterMetrics) TermChanged(uint64, uint64) {
+	m.inc(func(c *MetricsCounts) { c.TermChanges++ })
+}
+
+func (m *CounterMetrics) MessageSent(typ pb.MessageType) {
+	m.inc(func(c *MetricsCounts) { incKey(&c.MessagesSent, typ) })
+}
+
+func (m *CounterMetrics) MessageReceived(typ pb.MessageType) {
+	m.inc(func(c *MetricsCounts) { incKey(&c.MessagesReceived, typ) })
+}
+
+func (m *CounterMetrics) ProposalDropped(reason ProposalDropReason) {
+	m.inc(func(c *MetricsCounts) { incKey(&c.ProposalsDropped, reason) })
+}
+
+func (m *CounterMetrics) UncommittedSizeRejected(uint64) {
+	m.inc(func(c *MetricsCounts) { c.UncommittedSizeRejections++ })
+}
+
+func (m *CounterMetrics) SnapshotSent(uint64, uint64) {
+	m.inc(func(c *MetricsCounts) { c.SnapshotsSent++ })
+}
+
+func (m *CounterMetrics) SnapshotApplied(uint64) {
+	m.inc(func(c *MetricsCounts) { c.SnapshotsApplied++ })
+}
+
+func (m *CounterMetrics) ProbeToReplicate(uint64) {
+	m.inc(func(c *MetricsCounts) { c.ProbeToReplicate++ })
+}
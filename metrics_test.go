@@ -0,0 +1,144 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func TestMetricsElection(t *testing.T) {
+	metrics := map[uint64]*CounterMetrics{}
+	nt := newNetworkWithConfig(func(c *Config) {
+		preVoteConfig(c)
+		metrics[c.ID] = &CounterMetrics{}
+		c.Metrics = metrics[c.ID]
+	}, nil, nil, nil)
+	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgHup})
+	require.Equal(t, StateLeader, nt.peers[1].(*raft).state)
+
+	c := metrics[1].Counts()
+	require.Equal(t, uint64(1), c.PreVotesStarted)
+	require.Equal(t, uint64(1), c.PreVotesWon)
+	require.Zero(t, c.PreVotesLost)
+	require.Equal(t, uint64(1), c.ElectionsStarted)
+	require.Equal(t, uint64(1), c.ElectionsWon)
+	require.Zero(t, c.ElectionsLost)
+	require.Equal(t, uint64(1), c.TermChanges)
+	require.Equal(t, uint64(2), c.MessagesSent[pb.MsgPreVote])
+	require.Equal(t, uint64(2), c.MessagesSent[pb.MsgVote])
+	require.Equal(t, uint64(2), c.MessagesReceived[pb.MsgPreVoteResp])
+	require.Equal(t, uint64(2), c.MessagesReceived[pb.MsgVoteResp])
+	// The followers are probed after the election, and then replicated to.
+	require.Equal(t, uint64(2), c.ProbeToReplicate)
+
+	c = metrics[2].Counts()
+	require.Zero(t, c.ElectionsStarted)
+	require.Equal(t, uint64(1), c.TermChanges)
+	require.Equal(t, uint64(1), c.MessagesReceived[pb.MsgPreVote])
+	require.Equal(t, uint64(1), c.MessagesSent[pb.MsgPreVoteResp])
+	require.Equal(t, uint64(1), c.MessagesReceived[pb.MsgVote])
+	require.Equal(t, uint64(1), c.MessagesSent[pb.MsgVoteResp])
+	require.NotZero(t, c.MessagesReceived[pb.MsgApp])
+	require.Equal(t, c.MessagesReceived[pb.MsgApp], c.MessagesSent[pb.MsgAppResp])
+}
+
+func TestMetricsElectionLost(t *testing.T) {
+	for _, preVote := range []bool{false, true} {
+		t.Run("", func(t *testing.T) {
+			metrics := &CounterMetrics{}
+			r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), func(c *Config) {
+				c.PreVote = preVote
+				c.Metrics = metrics
+			})
+
+			require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgHup}))
+			respType := pb.MsgVoteResp
+			if preVote {
+				respType = pb.MsgPreVoteResp
+			}
+			for _, id := range []uint64{2, 3} {
+				require.NoError(t, r.Step(pb.Message{From: id, To: 1, Term: r.Term, Type: respType, Reject: true}))
+			}
+			require.Equal(t, StateFollower, r.state)
+
+			c := metrics.Counts()
+			if preVote {
+				require.Equal(t, uint64(1), c.PreVotesStarted)
+				require.Equal(t, uint64(1), c.PreVotesLost)
+				require.Zero(t, c.ElectionsStarted)
+				require.Zero(t, c.TermChanges)
+			} else {
+				require.Equal(t, uint64(1), c.ElectionsStarted)
+				require.Equal(t, uint64(1), c.ElectionsLost)
+				require.Zero(t, c.PreVotesStarted)
+				require.Equal(t, uint64(1), c.TermChanges)
+			}
+		})
+	}
+}
+
+func TestMetricsProposalDropped(t *testing.T) {
+	metrics := map[uint64]*CounterMetrics{}
+	nt := newNetworkWithConfig(func(c *Config) {
+		c.DisableProposalForwarding = c.ID == 3
+		c.MaxUncommittedEntriesSize = 10
+		metrics[c.ID] = &CounterMetrics{}
+		c.Metrics = metrics[c.ID]
+	}, nil, nil, nil)
+	prop := func(id uint64, data string) error {
+		return nt.peers[id].Step(pb.Message{From: id, To: id, Type: pb.MsgProp, Entries: []pb.Entry{{Data: []byte(data)}}})
+	}
+	require.ErrorIs(t, prop(2, "foo"), ErrProposalDropped)
+	require.Equal(t, map[ProposalDropReason]uint64{ProposalDropNoLeader: 1}, metrics[2].Counts().ProposalsDropped)
+
+	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgHup})
+	require.ErrorIs(t, prop(3, "foo"), ErrProposalDropped)
+	require.Equal(t, map[ProposalDropReason]uint64{ProposalDropForwardingDisabled: 1}, metrics[3].Counts().ProposalsDropped)
+
+	// The first uncommitted proposal is accepted regardless of its size.
+	require.NoError(t, prop(1, "foo"))
+	require.ErrorIs(t, prop(1, "very large proposal"), ErrProposalDropped)
+	c := metrics[1].Counts()
+	require.Equal(t, map[ProposalDropReason]uint64{ProposalDropUncommittedSizeLimit: 1}, c.ProposalsDropped)
+	require.Equal(t, uint64(1), c.UncommittedSizeRejections)
+
+	// The leadership transfer to an isolated node never completes.
+	nt.isolate(3)
+	nt.send(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader})
+	require.ErrorIs(t, prop(1, "foo"), ErrProposalDropped)
+	require.Equal(t, uint64(1), metrics[1].Counts().ProposalsDropped[ProposalDropLeaderTransfer])
+}
+
+func TestMetricsSnapshot(t *testing.T) {
+	metrics := &CounterMetrics{}
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	r := newTestRaft(1, 10, 1, storage, func(c *Config) { c.Metrics = metrics })
+	r.becomeCandidate()
+	r.becomeLeader()
+	sendSnapshot(t, r, 2)
+	require.Equal(t, uint64(1), metrics.Counts().SnapshotsSent)
+
+	metrics = &CounterMetrics{}
+	r = newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)), func(c *Config) { c.Metrics = metrics })
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 2, Type: pb.MsgSnap, Snapshot: &testingSnap}))
+	c := metrics.Counts()
+	require.Equal(t, uint64(1), c.SnapshotsApplied)
+	require.Equal(t, uint64(1), c.MessagesReceived[pb.MsgSnap])
+}
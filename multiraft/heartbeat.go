@@ -0,0 +1,99 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiraft
+
+import (
+	"sort"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// Heartbeat carries the fields of a MsgHeartbeat or MsgHeartbeatResp message of
+// a group.
+type Heartbeat struct {
+	GroupID uint64
+	Term    uint64
+	Commit  uint64
+	Index   uint64
+	// SupportedCodecs is the bitmask of the entry codecs advertised in a
+	// MsgHeartbeatResp, see raft.Config.EntryCodecs.
+	SupportedCodecs uint64
+}
+
+func (hb Heartbeat) message(typ pb.MessageType, from, to uint64) pb.Message {
+	return pb.Message{
+		Type:   typ,
+		From:   from,
+		To:     to,
+		Term:   hb.Term,
+		Commit: hb.Commit,
+		Index:  hb.Index,
+
+		SupportedCodecs: hb.SupportedCodecs,
+	}
+}
+
+// HeartbeatBatch carries the heartbeats and heartbeat responses of all groups
+// from one store to another.
+type HeartbeatBatch struct {
+	From, To   uint64
+	Heartbeats []Heartbeat
+	Responses  []Heartbeat
+}
+
+// heartbeatBatcher coalesces the heartbeats and heartbeat responses of multiple
+// groups by their destination store.
+type heartbeatBatcher struct {
+	byStore map[uint64]*HeartbeatBatch
+}
+
+// add adds the message of the given group to the batch of its destination
+// store. Returns false if the message can't be batched, in which case it has to
+// be sent on its own. This is the case for all messages other than heartbeats
+// and heartbeat responses, and for the ones carrying a context, which is used
+// by the ReadOnlySafe read requests.
+func (b *heartbeatBatcher) add(groupID uint64, m pb.Message) bool {
+	if m.Type != pb.MsgHeartbeat && m.Type != pb.MsgHeartbeatResp || len(m.Context) != 0 {
+		return false
+	}
+	if b.byStore == nil {
+		b.byStore = map[uint64]*HeartbeatBatch{}
+	}
+	batch, ok := b.byStore[m.To]
+	if !ok {
+		batch = &HeartbeatBatch{From: m.From, To: m.To}
+		b.byStore[m.To] = batch
+	}
+	hb := Heartbeat{GroupID: groupID, Term: m.Term, Commit: m.Commit, Index: m.Index, SupportedCodecs: m.SupportedCodecs}
+	if m.Type == pb.MsgHeartbeat {
+		batch.Heartbeats = append(batch.Heartbeats, hb)
+	} else {
+		batch.Responses = append(batch.Responses, hb)
+	}
+	return true
+}
+
+// batches returns the batches ordered by the destination store ID.
+func (b *heartbeatBatcher) batches() []HeartbeatBatch {
+	if len(b.byStore) == 0 {
+		return nil
+	}
+	res := make([]HeartbeatBatch, 0, len(b.byStore))
+	for _, batch := range b.byStore {
+		res = append(res, *batch)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].To < res[j].To })
+	return res
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package multiraft hosts many raft groups in a single process, and batches the
+work and the messages of these groups.
+
+A MultiRaft corresponds to a store: a process (or a disk) holding one replica of
+each of the groups added to it. Within every group, the raft ID of a replica is
+the ID of the store that holds it, so that the messages of all groups between a
+pair of stores can be batched together.
+
+Groups send a heartbeat to each follower on every heartbeat tick, which, with
+thousands of groups per store, adds up to a lot of messages. MultiRaft coalesces
+the heartbeats and heartbeat responses of all groups addressed to the same store
+into a single HeartbeatBatch, and fans them back out to the individual groups
+when a batch is received with StepHeartbeats.
+
+All groups are driven by a single loop similar to the one of a RawNode:
+
+	for {
+		// Tick, Step, StepHeartbeats, Propose, ...
+		if mr.HasReady() {
+			rd := mr.Ready()
+			for _, g := range rd.Groups {
+				// persist g.HardState, g.Entries, g.Snapshot of group g.GroupID
+			}
+			// send rd.Messages and rd.Heartbeats
+			for _, g := range rd.Groups {
+				// apply g.CommittedEntries of group g.GroupID
+			}
+			mr.Advance(rd)
+		}
+	}
+
+Only the groups that were touched since they were last processed are checked
+for updates, so idle groups cost nothing beyond their ticks.
+
+MultiRaft is not safe for concurrent use.
+*/
+package multiraft
+
+import (
+	"errors"
+	"fmt"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// ErrGroupNotFound is returned when the group a request is addressed to is not
+// hosted by the MultiRaft.
+var ErrGroupNotFound = errors.New("multiraft: group not found")
+
+// ErrGroupExists is returned by AddGroup when the group is already hosted by
+// the MultiRaft.
+var ErrGroupExists = errors.New("multiraft: group already exists")
+
+// GroupMessage is a raft message addressed to the replica of a group.
+type GroupMessage struct {
+	GroupID uint64
+	Message pb.Message
+}
+
+// GroupReady is the Ready of a group.
+type GroupReady struct {
+	GroupID uint64
+	// Ready is the Ready of the group. Its Messages are always empty: they are
+	// returned in the Messages and Heartbeats of the combined Ready instead.
+	raft.Ready
+}
+
+// Ready combines the updates of all groups that have any.
+type Ready struct {
+	// Groups contains the Ready of each group that has updates, in the order of
+	// group IDs.
+	Groups []GroupReady
+	// Messages are the outgoing messages of all groups, other than the
+	// heartbeats returned in Heartbeats. Like the messages of a raft.Ready, they
+	// must be sent after the updates of all groups have been persisted.
+	Messages []GroupMessage
+	// Heartbeats contains a batch of heartbeats and heartbeat responses for each
+	// store that the groups have any for, in the order of store IDs. They are
+	// sent under the same rules as Messages.
+	Heartbeats []HeartbeatBatch
+}
+
+// MultiRaft hosts a replica of many raft groups.
+type MultiRaft struct {
+	id     uint64
+	groups map[uint64]*raft.RawNode
+	sched  scheduler
+}
+
+// New returns a MultiRaft for the store with the given ID, initially hosting no
+// groups.
+func New(id uint64) *MultiRaft {
+	if id == raft.None {
+		panic("cannot use none as id")
+	}
+	return &MultiRaft{id: id, groups: map[uint64]*raft.RawNode{}}
+}
+
+// AddGroup starts hosting the replica of the given group, configured by cfg.
+// The cfg.ID must be the ID of this store. Asynchronous storage writes are not
+// supported.
+func (mr *MultiRaft) AddGroup(groupID uint64, cfg *raft.Config) error {
+	if _, ok := mr.groups[groupID]; ok {
+		return ErrGroupExists
+	}
+	if cfg.ID != mr.id {
+		return fmt.Errorf("replica ID %d of group %d does not match store ID %d", cfg.ID, groupID, mr.id)
+	}
+	if cfg.AsyncStorageWrites {
+		return errors.New("asynchronous storage writes are not supported")
+	}
+	rn, err := raft.NewRawNode(cfg)
+	if err != nil {
+		return err
+	}
+	mr.groups[groupID] = rn
+	mr.sched.add(groupID)
+	return nil
+}
+
+// RemoveGroup stops hosting the replica of the given group. The updates of the
+// group returned in a Ready are ignored by the subsequent Advance.
+func (mr *MultiRaft) RemoveGroup(groupID uint64) {
+	delete(mr.groups, groupID)
+}
+
+// NumGroups returns the number of hosted groups.
+func (mr *MultiRaft) NumGroups() int {
+	return len(mr.groups)
+}
+
+// WithRawNode calls f with the RawNode of the given group, and schedules the
+// group for processing afterwards. The RawNode must not be retained, and its
+// Ready and Advance methods must not be used.
+func (mr *MultiRaft) WithRawNode(groupID uint64, f func(rn *raft.RawNode) error) error {
+	rn, ok := mr.groups[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	mr.sched.add(groupID)
+	return f(rn)
+}
+
+// Tick advances the internal logical clock of all groups by a single tick. Only
+// the groups which have work to do as a result, e.g. heartbeats to send or an
+// election to start, are scheduled for processing.
+func (mr *MultiRaft) Tick() {
+	for id, rn := range mr.groups {
+		rn.Tick()
+		if rn.HasReady() {
+			mr.sched.add(id)
+		}
+	}
+}
+
+// Campaign causes the replica of the given group to transition to candidate
+// state and start campaigning to become leader.
+func (mr *MultiRaft) Campaign(groupID uint64) error {
+	return mr.WithRawNode(groupID, (*raft.RawNode).Campaign)
+}
+
+// Propose proposes data be appended to the log of the given group.
+func (mr *MultiRaft) Propose(groupID uint64, data []byte) error {
+	return mr.WithRawNode(groupID, func(rn *raft.RawNode) error {
+		return rn.Propose(data)
+	})
+}
+
+// ProposeConfChange proposes a config change to the given group.
+func (mr *MultiRaft) ProposeConfChange(groupID uint64, cc pb.ConfChangeI) error {
+	return mr.WithRawNode(groupID, func(rn *raft.RawNode) error {
+		return rn.ProposeConfChange(cc)
+	})
+}
+
+// ApplyConfChange applies a config change to the replica of the given group.
+// See raft.RawNode.ApplyConfChange.
+func (mr *MultiRaft) ApplyConfChange(groupID uint64, cc pb.ConfChangeI) (*pb.ConfState, error) {
+	var cs *pb.ConfState
+	err := mr.WithRawNode(groupID, func(rn *raft.RawNode) error {
+		cs = rn.ApplyConfChange(cc)
+		return nil
+	})
+	return cs, err
+}
+
+// Step advances the state machine of a group using the given message.
+func (mr *MultiRaft) Step(m GroupMessage) error {
+	return mr.WithRawNode(m.GroupID, func(rn *raft.RawNode) error {
+		return rn.Step(m.Message)
+	})
+}
+
+// HasReady returns true if any of the groups scheduled for processing has a
+// Ready to handle.
+func (mr *MultiRaft) HasReady() bool {
+	for _, id := range mr.sched.ids() {
+		if rn, ok := mr.groups[id]; ok && rn.HasReady() {
+			return true
+		}
+		// Nothing to do for this group until it is touched again.
+		mr.sched.remove(id)
+	}
+	return false
+}
+
+// Ready returns the outstanding updates of all groups scheduled for
+// processing. The caller must handle the updates as described in the package
+// documentation, and call Advance before calling Ready again.
+func (mr *MultiRaft) Ready() Ready {
+	var rd Ready
+	var hbs heartbeatBatcher
+	for _, id := range mr.sched.ids() {
+		rn, ok := mr.groups[id]
+		if !ok || !rn.HasReady() {
+			mr.sched.remove(id)
+			continue
+		}
+		grd := rn.Ready()
+		for _, m := range grd.Messages {
+			if !hbs.add(id, m) {
+				rd.Messages = append(rd.Messages, GroupMessage{GroupID: id, Message: m})
+			}
+		}
+		grd.Messages = nil
+		rd.Groups = append(rd.Groups, GroupReady{GroupID: id, Ready: grd})
+	}
+	rd.Heartbeats = hbs.batches()
+	return rd
+}
+
+// Advance notifies the groups that the application has handled the given
+// Ready. The groups stay scheduled, since handling the Ready may have given
+// them more work to do.
+func (mr *MultiRaft) Advance(rd Ready) {
+	for _, g := range rd.Groups {
+		if rn, ok := mr.groups[g.GroupID]; ok {
+			rn.Advance(g.Ready)
+		}
+	}
+}
+
+// StepHeartbeats fans out a batch of heartbeats and heartbeat responses
+// received from another store to the groups they are addressed to. The
+// messages addressed to the groups that are not hosted here are dropped, as
+// are the ones the groups fail to step.
+func (mr *MultiRaft) StepHeartbeats(b HeartbeatBatch) {
+	if b.To != mr.id {
+		return
+	}
+	step := func(typ pb.MessageType, hbs []Heartbeat) {
+		for _, hb := range hbs {
+			rn, ok := mr.groups[hb.GroupID]
+			if !ok {
+				continue
+			}
+			mr.sched.add(hb.GroupID)
+			_ = rn.Step(hb.message(typ, b.From, b.To))
+		}
+	}
+	step(pb.MsgHeartbeat, b.Heartbeats)
+	step(pb.MsgHeartbeatResp, b.Responses)
+}
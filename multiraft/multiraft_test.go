@@ -0,0 +1,350 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiraft
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+var discardLogger = &raft.DefaultLogger{Logger: log.New(io.Discard, "", 0)}
+
+// testCluster is a set of stores hosting the same groups, connected by an
+// in-memory network.
+type testCluster struct {
+	stores   map[uint64]*MultiRaft
+	storages map[uint64]map[uint64]*raft.MemoryStorage // by store and group
+	// Messages in flight, by destination store.
+	msgs       map[uint64][]GroupMessage
+	heartbeats map[uint64][]HeartbeatBatch
+}
+
+// newTestCluster returns a cluster of the given number of stores, each hosting
+// a replica of the given number of groups. The options modify the config of
+// every replica.
+func newTestCluster(t *testing.T, stores, groups int, opts ...func(*raft.Config)) *testCluster {
+	c := &testCluster{
+		stores:     map[uint64]*MultiRaft{},
+		storages:   map[uint64]map[uint64]*raft.MemoryStorage{},
+		msgs:       map[uint64][]GroupMessage{},
+		heartbeats: map[uint64][]HeartbeatBatch{},
+	}
+	var peers []uint64
+	for id := uint64(1); id <= uint64(stores); id++ {
+		peers = append(peers, id)
+	}
+	for _, id := range peers {
+		mr := New(id)
+		c.stores[id] = mr
+		c.storages[id] = map[uint64]*raft.MemoryStorage{}
+		for g := uint64(1); g <= uint64(groups); g++ {
+			s := raft.NewMemoryStorage()
+			require.NoError(t, s.ApplySnapshot(pb.Snapshot{Metadata: pb.SnapshotMetadata{
+				Index: 1, Term: 1, ConfState: pb.ConfState{Voters: peers},
+			}}))
+			c.storages[id][g] = s
+			cfg := &raft.Config{
+				ID:              id,
+				ElectionTick:    10,
+				HeartbeatTick:   1,
+				Storage:         s,
+				MaxSizePerMsg:   math.MaxUint64,
+				MaxInflightMsgs: 256,
+				Logger:          discardLogger,
+			}
+			for _, opt := range opts {
+				opt(cfg)
+			}
+			require.NoError(t, mr.AddGroup(g, cfg))
+		}
+	}
+	return c
+}
+
+// handleReady handles the Ready of the given store, if it has any, and queues
+// its messages on the network. Returns the handled Ready.
+func (c *testCluster) handleReady(t *testing.T, id uint64) Ready {
+	mr := c.stores[id]
+	if !mr.HasReady() {
+		return Ready{}
+	}
+	rd := mr.Ready()
+	for _, g := range rd.Groups {
+		s := c.storages[id][g.GroupID]
+		if !raft.IsEmptySnap(g.Snapshot) {
+			require.NoError(t, s.ApplySnapshot(g.Snapshot))
+		}
+		if !raft.IsEmptyHardState(g.HardState) {
+			require.NoError(t, s.SetHardState(g.HardState))
+		}
+		require.NoError(t, s.Append(g.Entries))
+	}
+	for _, m := range rd.Messages {
+		c.msgs[m.Message.To] = append(c.msgs[m.Message.To], m)
+	}
+	for _, b := range rd.Heartbeats {
+		c.heartbeats[b.To] = append(c.heartbeats[b.To], b)
+	}
+	mr.Advance(rd)
+	return rd
+}
+
+// deliver delivers the messages in flight to the given store.
+func (c *testCluster) deliver(id uint64) {
+	mr := c.stores[id]
+	for _, m := range c.msgs[id] {
+		_ = mr.Step(m)
+	}
+	for _, b := range c.heartbeats[id] {
+		mr.StepHeartbeats(b)
+	}
+	delete(c.msgs, id)
+	delete(c.heartbeats, id)
+}
+
+// stabilize handles the Ready and delivers the messages of all stores until
+// there is nothing left to do.
+func (c *testCluster) stabilize(t *testing.T) {
+	for done := false; !done; {
+		done = true
+		for id := uint64(1); id <= uint64(len(c.stores)); id++ {
+			if rd := c.handleReady(t, id); len(rd.Groups) > 0 {
+				done = false
+			}
+		}
+		for id := uint64(1); id <= uint64(len(c.stores)); id++ {
+			if len(c.msgs[id])+len(c.heartbeats[id]) > 0 {
+				done = false
+			}
+			c.deliver(id)
+		}
+	}
+}
+
+func (c *testCluster) campaign(t *testing.T, id uint64) {
+	for g := range c.storages[id] {
+		require.NoError(t, c.stores[id].Campaign(g))
+	}
+	c.stabilize(t)
+}
+
+func (c *testCluster) status(t *testing.T, id, groupID uint64) raft.Status {
+	var st raft.Status
+	require.NoError(t, c.stores[id].WithRawNode(groupID, func(rn *raft.RawNode) error {
+		st = rn.Status()
+		return nil
+	}))
+	return st
+}
+
+func TestMultiRaftAddGroup(t *testing.T) {
+	mr := New(1)
+	cfg := &raft.Config{
+		ID:              2,
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		Storage:         raft.NewMemoryStorage(),
+		MaxInflightMsgs: 256,
+		Logger:          discardLogger,
+	}
+	require.Error(t, mr.AddGroup(1, cfg))
+	cfg.ID = 1
+	require.NoError(t, mr.AddGroup(1, cfg))
+	require.Equal(t, ErrGroupExists, mr.AddGroup(1, cfg))
+	require.Equal(t, 1, mr.NumGroups())
+
+	require.Equal(t, ErrGroupNotFound, mr.Propose(2, []byte("foo")))
+	require.Equal(t, ErrGroupNotFound, mr.Step(GroupMessage{GroupID: 2}))
+	mr.RemoveGroup(1)
+	require.Zero(t, mr.NumGroups())
+	require.Equal(t, ErrGroupNotFound, mr.Campaign(1))
+}
+
+func TestMultiRaftReplication(t *testing.T) {
+	const groups = 5
+	c := newTestCluster(t, 3, groups)
+	c.campaign(t, 1)
+	for g := uint64(1); g <= groups; g++ {
+		require.Equal(t, raft.StateLeader, c.status(t, 1, g).RaftState)
+		require.NoError(t, c.stores[1].Propose(g, []byte("foo")))
+	}
+	c.stabilize(t)
+
+	for id := uint64(1); id <= 3; id++ {
+		for g := uint64(1); g <= groups; g++ {
+			st := c.status(t, id, g)
+			require.Equal(t, uint64(1), st.Lead)
+			require.Equal(t, uint64(3), st.Commit)
+			ents, err := c.storages[id][g].Entries(3, 4, math.MaxUint64)
+			require.NoError(t, err)
+			require.Equal(t, []byte("foo"), ents[0].Data)
+		}
+	}
+}
+
+// TestMultiRaftCoalescedHeartbeats checks that the heartbeats of all groups
+// between a pair of stores are sent in a single batch, and that the responses
+// are batched the same way.
+func TestMultiRaftCoalescedHeartbeats(t *testing.T) {
+	const groups = 10
+	c := newTestCluster(t, 3, groups)
+	c.campaign(t, 1)
+
+	c.stores[1].Tick()
+	rd := c.handleReady(t, 1)
+	require.Empty(t, rd.Messages)
+	require.Len(t, rd.Heartbeats, 2)
+	for i, b := range rd.Heartbeats {
+		require.Equal(t, uint64(1), b.From)
+		require.Equal(t, uint64(i+2), b.To)
+		require.Len(t, b.Heartbeats, groups)
+		require.Empty(t, b.Responses)
+		for g, hb := range b.Heartbeats {
+			require.Equal(t, uint64(g+1), hb.GroupID)
+			require.Equal(t, uint64(1), hb.Term)
+			require.Equal(t, uint64(2), hb.Commit)
+		}
+	}
+
+	for _, id := range []uint64{2, 3} {
+		c.deliver(id)
+		rd := c.handleReady(t, id)
+		require.Empty(t, rd.Messages)
+		require.Len(t, rd.Heartbeats, 1)
+		b := rd.Heartbeats[0]
+		require.Equal(t, HeartbeatBatch{From: id, To: 1}, HeartbeatBatch{From: b.From, To: b.To})
+		require.Empty(t, b.Heartbeats)
+		require.Len(t, b.Responses, groups)
+	}
+	c.stabilize(t)
+	for g := uint64(1); g <= groups; g++ {
+		for id, pr := range c.status(t, 1, g).Progress {
+			require.True(t, pr.RecentActive, "group %d store %d", g, id)
+		}
+	}
+}
+
+// TestMultiRaftCodecsNegotiated checks that the codecs advertised in the
+// coalesced heartbeat responses reach the leaders, which then compress the
+// entries.
+func TestMultiRaftCodecsNegotiated(t *testing.T) {
+	const groups = 3
+	c := newTestCluster(t, 2, groups, func(cfg *raft.Config) {
+		cfg.EntryCodecs = []raft.Codec{raft.FlateCodec{}}
+	})
+	c.campaign(t, 1)
+
+	c.stores[1].Tick()
+	c.handleReady(t, 1)
+	c.deliver(2)
+	rd := c.handleReady(t, 2)
+	require.Len(t, rd.Heartbeats, 1)
+	require.Len(t, rd.Heartbeats[0].Responses, groups)
+	for _, hb := range rd.Heartbeats[0].Responses {
+		require.Equal(t, uint64(1<<raft.FlateCodecID), hb.SupportedCodecs)
+	}
+	c.stabilize(t)
+
+	for g := uint64(1); g <= groups; g++ {
+		require.Equal(t, uint64(1<<raft.FlateCodecID), c.status(t, 1, g).Progress[2].Codecs)
+		require.NoError(t, c.stores[1].Propose(g, bytes.Repeat([]byte("a"), 1000)))
+	}
+	rd = c.handleReady(t, 1)
+	require.Len(t, rd.Messages, groups)
+	for _, m := range rd.Messages {
+		require.Equal(t, pb.MsgApp, m.Message.Type)
+		require.Equal(t, uint32(raft.FlateCodecID), m.Message.EntriesCodec)
+	}
+	c.stabilize(t)
+	for g := uint64(1); g <= groups; g++ {
+		require.Equal(t, c.status(t, 1, g).Commit, c.status(t, 2, g).Commit)
+	}
+}
+
+// TestMultiRaftHeartbeatWithContext checks that the heartbeats carrying a read
+// request context are not batched.
+func TestMultiRaftHeartbeatWithContext(t *testing.T) {
+	c := newTestCluster(t, 3, 2)
+	c.campaign(t, 1)
+
+	require.NoError(t, c.stores[1].WithRawNode(2, func(rn *raft.RawNode) error {
+		rn.ReadIndex([]byte("ctx"))
+		return nil
+	}))
+	rd := c.handleReady(t, 1)
+	require.Empty(t, rd.Heartbeats)
+	require.Len(t, rd.Messages, 2)
+	for _, m := range rd.Messages {
+		require.Equal(t, uint64(2), m.GroupID)
+		require.Equal(t, pb.MsgHeartbeat, m.Message.Type)
+		require.Equal(t, []byte("ctx"), m.Message.Context)
+	}
+	for _, id := range []uint64{2, 3} {
+		c.deliver(id)
+		rd := c.handleReady(t, id)
+		require.Empty(t, rd.Heartbeats)
+		require.Len(t, rd.Messages, 1)
+		require.Equal(t, pb.MsgHeartbeatResp, rd.Messages[0].Message.Type)
+	}
+	c.deliver(1)
+	rd = c.handleReady(t, 1)
+	require.Len(t, rd.Groups, 1)
+	require.Equal(t, []raft.ReadState{{Index: 2, RequestCtx: []byte("ctx")}}, rd.Groups[0].ReadStates)
+}
+
+// TestMultiRaftScheduler checks that only the groups that were touched are
+// processed.
+func TestMultiRaftScheduler(t *testing.T) {
+	c := newTestCluster(t, 3, 10)
+	c.campaign(t, 1)
+	for id := uint64(1); id <= 3; id++ {
+		require.False(t, c.stores[id].HasReady())
+		require.Empty(t, c.stores[id].sched.ids())
+	}
+
+	require.NoError(t, c.stores[1].Propose(7, []byte("foo")))
+	require.Equal(t, []uint64{7}, c.stores[1].sched.ids())
+	rd := c.handleReady(t, 1)
+	require.Len(t, rd.Groups, 1)
+	require.Equal(t, uint64(7), rd.Groups[0].GroupID)
+	require.Len(t, rd.Messages, 2)
+
+	c.deliver(2)
+	require.Equal(t, []uint64{7}, c.stores[2].sched.ids())
+	c.stabilize(t)
+	require.Equal(t, uint64(3), c.status(t, 3, 7).Commit)
+	require.Equal(t, uint64(2), c.status(t, 3, 6).Commit)
+
+	// The followers have nothing to do on a tick, but the leader has
+	// heartbeats to send.
+	c.stores[2].Tick()
+	require.Empty(t, c.stores[2].sched.ids())
+	c.stores[1].Tick()
+	require.Len(t, c.stores[1].sched.ids(), 10)
+	c.stabilize(t)
+
+	// Removed groups are skipped, even if they were scheduled.
+	require.NoError(t, c.stores[1].Propose(3, []byte("foo")))
+	c.stores[1].RemoveGroup(3)
+	require.False(t, c.stores[1].HasReady())
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiraft
+
+import "slices"
+
+// scheduler tracks the groups that need to be checked for updates: the ones
+// that were stepped, or otherwise touched since they last had nothing to do,
+// and the ones that got work to do from a tick.
+type scheduler struct {
+	pending map[uint64]struct{}
+}
+
+// add schedules the given group.
+func (s *scheduler) add(groupID uint64) {
+	if s.pending == nil {
+		s.pending = map[uint64]struct{}{}
+	}
+	s.pending[groupID] = struct{}{}
+}
+
+// remove unschedules the given group.
+func (s *scheduler) remove(groupID uint64) {
+	delete(s.pending, groupID)
+}
+
+// ids returns the scheduled groups in the order of their IDs.
+func (s *scheduler) ids() []uint64 {
+	ids := make([]uint64, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
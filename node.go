@@ -71,6 +71,14 @@ type Ready struct {
 	// The returned is only valid for the request that requested to read.
 	ReadStates []ReadState
 
+	// LeaderTransfers reports the outcomes of leadership transfers requested
+	// while this node was the leader, in the order in which they ended.
+	LeaderTransfers []LeaderTransferResult
+
+	// LearnerPromotions reports the outcomes of the automatic promotions of
+	// learners proposed while this node was the leader.
+	LearnerPromotions []LearnerPromotionResult
+
 	// Entries specifies entries to be saved to stable storage BEFORE
 	// Messages are sent.
 	//
@@ -93,6 +101,9 @@ type Ready struct {
 	// If async storage writes are enabled, this field does not need to be acted
 	// on immediately. It will be reflected in a MsgStorageApply message in the
 	// Messages slice.
+	//
+	// On a witness, the normal entries have no payloads, and must not be applied
+	// to the state machine. Only the configuration changes are applied.
 	CommittedEntries []pb.Entry
 
 	// Messages specifies outbound messages.
@@ -137,7 +148,14 @@ type Node interface {
 	Campaign(ctx context.Context) error
 	// Propose proposes that data be appended to the log. Note that proposals can be lost without
 	// notice, therefore it is user's job to ensure proposal retries.
+	// If the proposal is dropped by the node, the returned error is a
+	// *ProposalDroppedError describing why.
 	Propose(ctx context.Context, data []byte) error
+	// ProposeWithTracking proposes that data be appended to the log, and returns
+	// a handle which is notified when the proposal is committed and applied, or
+	// fails. Only the leader can track proposals. See
+	// RawNode.ProposeWithTracking.
+	ProposeWithTracking(ctx context.Context, data []byte) (*ProposalHandle, error)
 	// ProposeConfChange proposes a configuration change. Like any proposal, the
 	// configuration change may be dropped with or without an error being
 	// returned. In particular, configuration changes are dropped unless the
@@ -187,6 +205,9 @@ type Node interface {
 	ApplyConfChange(cc pb.ConfChangeI) *pb.ConfState
 
 	// TransferLeadership attempts to transfer leadership to the given transferee.
+	// If the transferee is None, the leader picks the target itself; this only
+	// works when called on the leader. The outcome of the transfer is reported
+	// in Ready.LeaderTransfers of the leader.
 	TransferLeadership(ctx context.Context, lead, transferee uint64)
 
 	// ForgetLeader forgets a follower's current leader, changing it to None. It
@@ -215,6 +236,12 @@ type Node interface {
 	// leader to be elected without the old leader knowing.
 	ForgetLeader(ctx context.Context) error
 
+	// Unquiesce wakes up a quiesced node, see Config.Quiescence. A follower also
+	// asks the leader to wake up. This should be called on a quiesced follower
+	// when the application suspects that the leader has failed, since the
+	// follower doesn't campaign while quiesced.
+	Unquiesce(ctx context.Context) error
+
 	// ReadIndex request a read state. The read state will be set in the ready.
 	// Read state has a read index. Once the application advances further than the read
 	// index, any linearizable read requests issued before the read request can be
@@ -222,11 +249,18 @@ type Node interface {
 	// Note that request can be lost without notice, therefore it is user's job
 	// to ensure read index retries.
 	ReadIndex(ctx context.Context, rctx []byte) error
+	// ReadIndexWithWaiter issues a linearizable read, and returns a waiter
+	// which is notified when the read can be served from the local state
+	// machine, or when the read fails. See RawNode.ReadIndexWithWaiter.
+	ReadIndexWithWaiter(ctx context.Context) (*ReadIndexWaiter, error)
 
 	// Status returns the current status of the raft state machine.
 	Status() Status
 	// ReportUnreachable reports the given node is not reachable for the last send.
 	ReportUnreachable(id uint64)
+	// ReportApplyProgress reports the progress of the application's apply
+	// thread, see RawNode.ReportApplyProgress.
+	ReportApplyProgress(applied, queueDepth uint64)
 	// ReportSnapshot reports the status of the sent snapshot. The id is the raft ID of the follower
 	// who is meant to receive the snapshot, and the status is SnapshotFinish or SnapshotFailure.
 	// Calling ReportSnapshot with SnapshotFinish is a no-op. But, any failure in applying a
@@ -291,6 +325,20 @@ func RestartNode(c *Config) Node {
 type msgWithResult struct {
 	m      pb.Message
 	result chan error
+	// handle, if set, tracks the proposal. See ProposeWithTracking.
+	handle *ProposalHandle
+}
+
+// readIndexRequest is a read passed to ReadIndexWithWaiter.
+type readIndexRequest struct {
+	waiter *ReadIndexWaiter
+	result chan error
+}
+
+// applyProgress is the apply progress passed to ReportApplyProgress.
+type applyProgress struct {
+	applied    uint64
+	queueDepth uint64
 }
 
 // node is the canonical implementation of the Node interface
@@ -299,6 +347,8 @@ type node struct {
 	recvc      chan pb.Message
 	confc      chan pb.ConfChangeV2
 	confstatec chan pb.ConfState
+	applyc     chan applyProgress
+	readc      chan readIndexRequest
 	readyc     chan Ready
 	advancec   chan struct{}
 	tickc      chan struct{}
@@ -315,6 +365,8 @@ func newNode(rn *RawNode) node {
 		recvc:      make(chan pb.Message),
 		confc:      make(chan pb.ConfChangeV2),
 		confstatec: make(chan pb.ConfState),
+		applyc:     make(chan applyProgress),
+		readc:      make(chan readIndexRequest),
 		readyc:     make(chan Ready),
 		advancec:   make(chan struct{}),
 		// make tickc a buffered chan, so raft node can buffer some ticks when the node
@@ -386,7 +438,12 @@ func (n *node) run() {
 		case pm := <-propc:
 			m := pm.m
 			m.From = r.id
-			err := r.Step(m)
+			var err error
+			if pm.handle != nil {
+				err = r.proposeTracked(m, pm.handle)
+			} else {
+				err = r.Step(m)
+			}
 			if pm.result != nil {
 				pm.result <- err
 				close(pm.result)
@@ -397,6 +454,11 @@ func (n *node) run() {
 				break
 			}
 			r.Step(m)
+		case p := <-n.applyc:
+			r.reportApplyProgress(p.applied, p.queueDepth)
+		case req := <-n.readc:
+			req.result <- r.readIndexWithWaiter(req.waiter)
+			close(req.result)
 		case cc := <-n.confc:
 			_, okBefore := r.trk.Progress[r.id]
 			cs := r.applyConfChange(cc)
@@ -470,6 +532,18 @@ func (n *node) Propose(ctx context.Context, data []byte) error {
 	return n.stepWait(ctx, pb.Message{Type: pb.MsgProp, Entries: []pb.Entry{{Data: data}}})
 }
 
+func (n *node) ProposeWithTracking(ctx context.Context, data []byte) (*ProposalHandle, error) {
+	pm := msgWithResult{
+		m:      pb.Message{Type: pb.MsgProp, Entries: []pb.Entry{{Data: data}}},
+		result: make(chan error, 1),
+		handle: newProposalHandle(),
+	}
+	if err := n.propose(ctx, pm); err != nil {
+		return nil, err
+	}
+	return pm.handle, nil
+}
+
 func (n *node) Step(ctx context.Context, m pb.Message) error {
 	// Ignore unexpected local messages receiving over network.
 	if IsLocalMsg(m.Type) && !IsLocalMsgTarget(m.From) {
@@ -516,14 +590,19 @@ func (n *node) stepWithWaitOption(ctx context.Context, m pb.Message, wait bool)
 			return ErrStopped
 		}
 	}
-	ch := n.propc
 	pm := msgWithResult{m: m}
 	if wait {
 		pm.result = make(chan error, 1)
 	}
+	return n.propose(ctx, pm)
+}
+
+// propose sends the proposal to the node, and waits for the result if
+// pm.result is set.
+func (n *node) propose(ctx context.Context, pm msgWithResult) error {
 	select {
-	case ch <- pm:
-		if !wait {
+	case n.propc <- pm:
+		if pm.result == nil {
 			return nil
 		}
 	case <-ctx.Done():
@@ -583,6 +662,13 @@ func (n *node) ReportUnreachable(id uint64) {
 	}
 }
 
+func (n *node) ReportApplyProgress(applied, queueDepth uint64) {
+	select {
+	case n.applyc <- applyProgress{applied: applied, queueDepth: queueDepth}:
+	case <-n.done:
+	}
+}
+
 func (n *node) ReportSnapshot(id uint64, status SnapshotStatus) {
 	rej := status == SnapshotFailure
 
@@ -605,6 +691,25 @@ func (n *node) ForgetLeader(ctx context.Context) error {
 	return n.step(ctx, pb.Message{Type: pb.MsgForgetLeader})
 }
 
+func (n *node) Unquiesce(ctx context.Context) error {
+	return n.step(ctx, pb.Message{Type: pb.MsgUnquiesce})
+}
+
 func (n *node) ReadIndex(ctx context.Context, rctx []byte) error {
 	return n.step(ctx, pb.Message{Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: rctx}}})
 }
+
+func (n *node) ReadIndexWithWaiter(ctx context.Context) (*ReadIndexWaiter, error) {
+	req := readIndexRequest{waiter: newReadIndexWaiter(), result: make(chan error, 1)}
+	select {
+	case n.readc <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.done:
+		return nil, ErrStopped
+	}
+	if err := <-req.result; err != nil {
+		return nil, err
+	}
+	return req.waiter, nil
+}
@@ -16,8 +16,12 @@ package raft
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
+
+	pb "go.etcd.io/raft/v3/raftpb"
 )
 
 func BenchmarkOneNode(b *testing.B) {
@@ -49,3 +53,43 @@ func BenchmarkOneNode(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkReadIndex issues bursts of ReadOnlySafe read requests on the leader
+// of a three node group, and reports the number of heartbeats sent per read
+// with and without BatchReadIndex.
+func BenchmarkReadIndex(b *testing.B) {
+	const burst = 100
+	for _, batch := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batch=%t", batch), func(b *testing.B) {
+			nt := newNetworkWithConfig(func(c *Config) {
+				c.BatchReadIndex = batch
+			}, nil, nil, nil)
+			nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgHup})
+			r := nt.peers[1].(*raft)
+
+			var heartbeats int
+			nt.msgHook = func(m pb.Message) bool {
+				if m.Type == pb.MsgHeartbeat {
+					heartbeats++
+				}
+				return true
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i += burst {
+				n := min(burst, b.N-i)
+				for j := 0; j < n; j++ {
+					ctx := []byte(strconv.Itoa(i + j))
+					_ = r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: ctx}}})
+				}
+				nt.send(nt.filter(r.readMessages())...)
+				if len(r.readStates) != n {
+					b.Fatalf("got %d read states, want %d", len(r.readStates), n)
+				}
+				r.readStates = nil
+			}
+			b.ReportMetric(float64(heartbeats)/float64(b.N), "heartbeats/op")
+		})
+	}
+}
@@ -426,6 +426,38 @@ func TestNodeProposeWaitDropped(t *testing.T) {
 	require.Empty(t, msgs)
 }
 
+// TestNodeProposeDropped checks that Propose returns a ProposalDroppedError if
+// the proposal is dropped.
+func TestNodeProposeDropped(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	cfg := newTestConfig(1, 10, 1, s)
+	cfg.MaxUncommittedEntriesSize = 4
+	rn, err := NewRawNode(cfg)
+	require.NoError(t, err)
+	n := newNode(rn)
+	go n.run()
+	defer n.Stop()
+	require.NoError(t, n.Campaign(context.Background()))
+	for {
+		rd := <-n.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		n.Advance()
+		if rd.SoftState != nil && rd.SoftState.Lead == 1 {
+			break
+		}
+	}
+
+	// The entries are not committed until the next Ready is handled, so the
+	// second proposal exceeds the uncommitted size limit.
+	ctx := context.Background()
+	require.NoError(t, n.Propose(ctx, []byte("foo")))
+	err = n.Propose(ctx, []byte("bar"))
+	require.ErrorIs(t, err, ErrProposalDropped)
+	var pErr *ProposalDroppedError
+	require.ErrorAs(t, err, &pErr)
+	require.Equal(t, ProposalDroppedError{Reason: ProposalDropUncommittedSizeLimit, Lead: 1, Term: 1}, *pErr)
+}
+
 // TestNodeTick ensures that node.Tick() will increase the
 // elapsed of the underlying raft state machine.
 func TestNodeTick(t *testing.T) {
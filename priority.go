@@ -0,0 +1,96 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"bytes"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// priorityTransfer is the context of a MsgTransferLeader sent by a follower
+// which asks the leader to transfer the leadership to it because it has a
+// higher priority.
+var priorityTransfer = []byte("PriorityTransfer")
+
+// priorityOf returns the election priority of the given node. The priority is
+// taken from the membership config. For this node, Config.Priority is used if
+// the membership config doesn't assign one.
+func (r *raft) priorityOf(id uint64) uint64 {
+	if prio := r.trk.Priority(id); prio != 0 || id != r.id {
+		return prio
+	}
+	return r.priority
+}
+
+// priorityElectionDelay returns the number of ticks that this node waits in
+// addition to the randomized election timeout before campaigning. Nodes with
+// a lower priority than the highest one among the voters wait longer, so that
+// the higher priority nodes are likely to win elections. The delay is at most
+// one election timeout, so that the lower priority nodes still campaign if
+// the higher priority ones are down.
+func (r *raft) priorityElectionDelay() int {
+	prio := r.priorityOf(r.id)
+	maxPrio := r.trk.MaxPriority()
+	if prio >= maxPrio {
+		return 0
+	}
+	return int(float64(r.electionTimeout) * float64(maxPrio-prio) / float64(maxPrio))
+}
+
+// maybeRequestLeadership asks the leader to transfer the leadership to this
+// node, if it has a higher priority than the leader in the membership config
+// and appears to be caught up. The request is repeated at most once per
+// election timeout. The leader checks the priorities again, and only carries
+// out the transfer once this node has caught up, so acting on a stale config
+// or log here is safe.
+func (r *raft) maybeRequestLeadership() {
+	if r.lead == None || r.lead == r.id || !r.promotable() {
+		return
+	}
+	if r.trk.Priority(r.id) <= r.trk.Priority(r.lead) {
+		return
+	}
+	if r.raftLog.committed < r.raftLog.lastIndex() {
+		return
+	}
+	if r.ticks < r.nextLeadershipRequest {
+		return
+	}
+	r.nextLeadershipRequest = r.ticks + uint64(r.electionTimeout)
+	r.logger.Infof("%x [term %d] has a higher priority than leader %x, requests leadership transfer",
+		r.id, r.Term, r.lead)
+	r.send(pb.Message{To: r.lead, Type: pb.MsgTransferLeader, Context: priorityTransfer})
+}
+
+// ignoreLeadershipRequest returns true if the leader should ignore the given
+// MsgTransferLeader sent by a follower because of its priority.
+func (r *raft) ignoreLeadershipRequest(m pb.Message) bool {
+	if !bytes.Equal(m.Context, priorityTransfer) {
+		return false
+	}
+	if r.trk.Priority(m.From) <= r.trk.Priority(r.id) {
+		r.logger.Infof("%x [term %d] ignores leadership request from %x: priority %d is not higher than %d",
+			r.id, r.Term, m.From, r.trk.Priority(m.From), r.trk.Priority(r.id))
+		return true
+	}
+	// Don't interfere with transfers requested by the application.
+	if r.leadTransferee != None && r.leadTransferee != m.From {
+		r.logger.Infof("%x [term %d] ignores leadership request from %x: transfer to %x is in progress",
+			r.id, r.Term, m.From, r.leadTransferee)
+		return true
+	}
+	return false
+}
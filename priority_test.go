@@ -0,0 +1,135 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func TestPriorityElectionDelay(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		priority   uint64 // Config.Priority
+		priorities map[uint64]uint64
+		want       int
+	}{
+		{name: "no priorities", want: 0},
+		{name: "highest", priorities: map[uint64]uint64{1: 4, 2: 4, 3: 2}, want: 0},
+		{name: "lower", priorities: map[uint64]uint64{1: 1, 2: 4}, want: 7},
+		{name: "none", priorities: map[uint64]uint64{2: 4}, want: 10},
+		{name: "config", priority: 2, priorities: map[uint64]uint64{2: 4}, want: 5},
+		{name: "config overridden", priority: 4, priorities: map[uint64]uint64{1: 1, 2: 4}, want: 7},
+		// Learners don't take part in elections, so their priorities don't
+		// delay the voters.
+		{name: "learner", priorities: map[uint64]uint64{1: 1, 4: 5}, want: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3), withLearners(4)))
+			cfg.Priority = tt.priority
+			r := newRaft(cfg)
+			r.trk.Priorities = tt.priorities
+			require.Equal(t, tt.want, r.priorityElectionDelay())
+
+			for i := 0; i < 100; i++ {
+				r.resetRandomizedElectionTimeout()
+				require.GreaterOrEqual(t, r.randomizedElectionTimeout, 10+tt.want)
+				require.Less(t, r.randomizedElectionTimeout, 20+tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityLeadershipRequest(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3, 4)))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.trk.Priorities = map[uint64]uint64{1: 2, 2: 1, 3: 3, 4: 4}
+	request := func(from uint64) pb.Message {
+		return pb.Message{From: from, To: 1, Term: r.Term, Type: pb.MsgTransferLeader, Context: priorityTransfer}
+	}
+
+	// A request from a node with a lower priority is ignored, e.g. if it acted
+	// on a stale config.
+	require.NoError(t, r.Step(request(2)))
+	require.Equal(t, None, r.leadTransferee)
+	// A node with a higher priority gets the leadership.
+	require.NoError(t, r.Step(request(3)))
+	require.Equal(t, uint64(3), r.leadTransferee)
+	// A request doesn't supersede a transfer in progress.
+	require.NoError(t, r.Step(request(4)))
+	require.Equal(t, uint64(3), r.leadTransferee)
+	// A transfer requested by the application does.
+	require.NoError(t, r.Step(pb.Message{From: 4, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, uint64(4), r.leadTransferee)
+}
+
+func TestPriorityLeadershipRequestFollower(t *testing.T) {
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	r.becomeFollower(1, 1)
+	heartbeat := pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgHeartbeat}
+	requests := func() int {
+		var n int
+		for _, m := range r.readMessages() {
+			if m.Type == pb.MsgTransferLeader {
+				require.Equal(t, uint64(1), m.To)
+				require.Equal(t, priorityTransfer, m.Context)
+				n++
+			}
+		}
+		return n
+	}
+
+	// Without a higher priority than the leader, nothing is requested.
+	require.NoError(t, r.Step(heartbeat))
+	require.Zero(t, requests())
+	r.trk.Priorities = map[uint64]uint64{1: 1, 2: 1}
+	require.NoError(t, r.Step(heartbeat))
+	require.Zero(t, requests())
+
+	// With a higher priority, the request is sent at most once per election
+	// timeout.
+	r.trk.Priorities = map[uint64]uint64{1: 1, 2: 2}
+	require.NoError(t, r.Step(heartbeat))
+	require.Equal(t, 1, requests())
+	for i := 0; i < r.electionTimeout-1; i++ {
+		r.tick()
+		require.NoError(t, r.Step(heartbeat))
+		require.Zero(t, requests())
+	}
+	r.tick()
+	require.NoError(t, r.Step(heartbeat))
+	require.Equal(t, 1, requests())
+}
+
+func TestPickLeadTransfereePriority(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3, 4)))
+	r.becomeCandidate()
+	r.becomeLeader()
+	for id, pr := range r.trk.Progress {
+		pr.RecentActive = true
+		pr.Match = map[uint64]uint64{2: 7, 3: 5, 4: 6}[id]
+	}
+	require.Equal(t, uint64(2), r.pickLeadTransferee())
+	// A higher priority wins over a more up-to-date log.
+	r.trk.Priorities = map[uint64]uint64{3: 1, 4: 1}
+	require.Equal(t, uint64(4), r.pickLeadTransferee())
+	// The preference list wins over the priority.
+	r.leadTransferPreference = []uint64{2}
+	require.Equal(t, uint64(2), r.pickLeadTransferee())
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"context"
+	"errors"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+var (
+	// ErrProposalOverwritten is returned for a tracked proposal whose entry was
+	// removed from the log before being committed, because it was overwritten by
+	// the entries of another leader.
+	ErrProposalOverwritten = errors.New("raft: proposal overwritten")
+	// ErrProposalTimeout is returned for a tracked proposal which has not been
+	// committed within Config.ProposalTrackingTimeout ticks. The proposal may
+	// still be committed later.
+	ErrProposalTimeout = errors.New("raft: proposal timed out")
+	// ErrProposalOutcomeUnknown is returned for a tracked proposal which was
+	// not known to be committed when the log was replaced by a snapshot. The
+	// proposal may or may not be included in the snapshot.
+	ErrProposalOutcomeUnknown = errors.New("raft: proposal outcome unknown")
+)
+
+// ProposalHandle tracks a proposal made with RawNode.ProposeWithTracking or
+// Node.ProposeWithTracking. It is notified when the proposal's entry is
+// committed and when it is applied, or when the proposal fails.
+//
+// A proposal is considered committed when its entry is returned in the
+// CommittedEntries of a Ready, and applied when the application has reported
+// applying it, i.e. called Advance (or, with asynchronous storage writes,
+// stepped the MsgStorageApplyResp) after that.
+type ProposalHandle struct {
+	index, term uint64
+	// deadline is the tick at which the proposal times out if it has not been
+	// committed by then. Zero if there is no deadline.
+	deadline uint64
+
+	committed chan struct{}
+	applied   chan struct{}
+	err       error
+}
+
+func newProposalHandle() *ProposalHandle {
+	return &ProposalHandle{
+		committed: make(chan struct{}),
+		applied:   make(chan struct{}),
+	}
+}
+
+// Index returns the log index of the proposal's entry.
+func (h *ProposalHandle) Index() uint64 { return h.index }
+
+// Term returns the term of the proposal's entry.
+func (h *ProposalHandle) Term() uint64 { return h.term }
+
+// Committed returns a channel which is closed when the proposal is committed,
+// or when it fails.
+func (h *ProposalHandle) Committed() <-chan struct{} { return h.committed }
+
+// Applied returns a channel which is closed when the proposal is applied, or
+// when it fails. Applied is closed after Committed.
+func (h *ProposalHandle) Applied() <-chan struct{} { return h.applied }
+
+// Err returns the reason why the proposal has failed, or nil if it has not.
+// It must only be called after the Committed or Applied channel is closed.
+func (h *ProposalHandle) Err() error { return h.err }
+
+// Wait blocks until the proposal is applied or fails, and returns the error
+// if it fails. Returns ctx.Err() if the context is done first.
+func (h *ProposalHandle) Wait(ctx context.Context) error {
+	select {
+	case <-h.applied:
+		return h.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *ProposalHandle) isCommitted() bool {
+	select {
+	case <-h.committed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *ProposalHandle) fail(err error) {
+	h.err = err
+	close(h.committed)
+	close(h.applied)
+}
+
+// proposalTracker holds the tracked proposals which have not been applied or
+// failed yet, ordered by index.
+type proposalTracker struct {
+	pending []*ProposalHandle
+	// timeout is the number of ticks after which an uncommitted proposal fails.
+	// Zero means no timeout.
+	timeout uint64
+}
+
+// track starts tracking the proposal with the given entry ID, proposed at the
+// given tick.
+func (t *proposalTracker) track(h *ProposalHandle, id entryID, now uint64) {
+	if n := len(t.pending); n > 0 && t.pending[n-1].index >= id.index {
+		panic("tracked proposals must have increasing indices")
+	}
+	h.index, h.term = id.index, id.term
+	if t.timeout > 0 {
+		h.deadline = now + t.timeout
+	}
+	t.pending = append(t.pending, h)
+}
+
+// commit notifies the proposals whose entries are committed. The entries must
+// be contiguous.
+func (t *proposalTracker) commit(ents []pb.Entry) {
+	if len(ents) == 0 {
+		return
+	}
+	first, last := ents[0].Index, ents[len(ents)-1].Index
+	kept := t.pending[:0]
+	for _, h := range t.pending {
+		if h.index < first || h.index > last || h.isCommitted() {
+			kept = append(kept, h)
+		} else if ents[h.index-first].Term != h.term {
+			// NB: this should not happen because the overwritten entries are
+			// reported when the log is truncated.
+			h.fail(ErrProposalOverwritten)
+		} else {
+			close(h.committed)
+			kept = append(kept, h)
+		}
+	}
+	t.pending = kept
+}
+
+// applied notifies the committed proposals applied up to the given index.
+func (t *proposalTracker) applied(index uint64) {
+	var n int
+	for n < len(t.pending) && t.pending[n].index <= index && t.pending[n].isCommitted() {
+		close(t.pending[n].applied)
+		n++
+	}
+	t.pending = t.pending[n:]
+}
+
+// truncate fails the proposals whose entries at indices >= the given one have
+// been removed from the log.
+func (t *proposalTracker) truncate(index uint64) {
+	n := len(t.pending)
+	for n > 0 && t.pending[n-1].index >= index {
+		n--
+		t.pending[n].fail(ErrProposalOverwritten)
+	}
+	t.pending = t.pending[:n]
+}
+
+// restore fails the uncommitted proposals when the log is replaced by a
+// snapshot at the given index.
+func (t *proposalTracker) restore(index uint64) {
+	t.truncate(index + 1)
+	kept := t.pending[:0]
+	for _, h := range t.pending {
+		if h.isCommitted() {
+			kept = append(kept, h)
+		} else {
+			h.fail(ErrProposalOutcomeUnknown)
+		}
+	}
+	t.pending = kept
+}
+
+// expire fails the uncommitted proposals whose deadline has passed.
+func (t *proposalTracker) expire(now uint64) {
+	if t.timeout == 0 {
+		return
+	}
+	kept := t.pending[:0]
+	for _, h := range t.pending {
+		if h.isCommitted() || now < h.deadline {
+			kept = append(kept, h)
+		} else {
+			h.fail(ErrProposalTimeout)
+		}
+	}
+	t.pending = kept
+}
+
+// proposeTracked proposes the entry in the given MsgProp, and starts tracking
+// it with the given handle. Only the leader can track proposals, since the
+// index of a proposal forwarded to the leader is not known.
+func (r *raft) proposeTracked(m pb.Message, h *ProposalHandle) error {
+	if len(m.Entries) != 1 {
+		r.logger.Panicf("%x tracked MsgProp must have one entry, got %d", r.id, len(m.Entries))
+	}
+	if r.state != StateLeader {
+		return r.dropProposal(ProposalDropNotLeader)
+	}
+	if err := r.Step(m); err != nil {
+		return err
+	}
+	r.proposals.track(h, r.raftLog.lastEntryID(), r.ticks)
+	return nil
+}
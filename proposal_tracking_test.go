@@ -0,0 +1,177 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func requireOpen(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatal("channel is closed")
+	default:
+	}
+}
+
+func requireClosed(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	default:
+		t.Fatal("channel is open")
+	}
+}
+
+func TestProposeWithTracking(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	// Handle the leader's empty entry.
+	rd := rn.Ready()
+	require.NoError(t, s.Append(rd.Entries))
+	rn.Advance(rd)
+
+	h, err := rn.ProposeWithTracking([]byte("foo"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), h.Index())
+	require.Equal(t, uint64(1), h.Term())
+	requireOpen(t, h.Committed())
+
+	// The entry is committed once it is persisted.
+	rd = rn.Ready()
+	require.NoError(t, s.Append(rd.Entries))
+	rn.Advance(rd)
+	requireOpen(t, h.Committed())
+
+	rd = rn.Ready()
+	require.Len(t, rd.CommittedEntries, 1)
+	requireClosed(t, h.Committed())
+	requireOpen(t, h.Applied())
+	rn.Advance(rd)
+	requireClosed(t, h.Applied())
+	require.NoError(t, h.Err())
+	require.NoError(t, h.Wait(context.Background()))
+	require.Empty(t, rn.raft.proposals.pending)
+}
+
+func TestProposeWithTrackingNotLeader(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	rn.raft.becomeFollower(2, 2)
+	_, err := rn.ProposeWithTracking([]byte("foo"))
+	var pErr *ProposalDroppedError
+	require.ErrorAs(t, err, &pErr)
+	require.Equal(t, ProposalDroppedError{Reason: ProposalDropNotLeader, Lead: 2, Term: 2}, *pErr)
+	require.Empty(t, rn.raft.proposals.pending)
+}
+
+func TestProposeWithTrackingOverwritten(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	h1, err := rn.ProposeWithTracking([]byte("foo"))
+	require.NoError(t, err)
+	h2, err := rn.ProposeWithTracking([]byte("bar"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, []uint64{h1.Index(), h2.Index()})
+
+	// A new leader overwrites the second entry.
+	r := rn.raft
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: 2, Type: pb.MsgApp,
+		Index: 2, LogTerm: 1, Entries: []pb.Entry{{Index: 3, Term: 2}}, Commit: 2}))
+	requireClosed(t, h2.Committed())
+	requireClosed(t, h2.Applied())
+	require.ErrorIs(t, h2.Err(), ErrProposalOverwritten)
+	requireOpen(t, h1.Committed())
+
+	rd := rn.Ready()
+	require.Equal(t, uint64(2), rd.CommittedEntries[len(rd.CommittedEntries)-1].Index)
+	requireClosed(t, h1.Committed())
+	require.NoError(t, h1.Err())
+}
+
+func TestProposeWithTrackingTimeout(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), func(c *Config) {
+		c.ProposalTrackingTimeout = 3
+	})
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	h, err := rn.ProposeWithTracking([]byte("foo"))
+	require.NoError(t, err)
+	rn.Tick()
+	rn.Tick()
+	requireOpen(t, h.Committed())
+	rn.Tick()
+	requireClosed(t, h.Applied())
+	require.ErrorIs(t, h.Err(), ErrProposalTimeout)
+	require.Empty(t, rn.raft.proposals.pending)
+}
+
+func TestProposeWithTrackingSnapshot(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	h, err := rn.ProposeWithTracking([]byte("foo"))
+	require.NoError(t, err)
+
+	// The node loses leadership and receives a snapshot from the new leader.
+	snap := pb.Snapshot{Metadata: pb.SnapshotMetadata{
+		Index: 5, Term: 2, ConfState: pb.ConfState{Voters: []uint64{1, 2}},
+	}}
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 2, Type: pb.MsgSnap, Snapshot: &snap}))
+	requireClosed(t, h.Applied())
+	require.ErrorIs(t, h.Err(), ErrProposalOutcomeUnknown)
+}
+
+func TestNodeProposeWithTracking(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	n := newNode(rn)
+	go n.run()
+	defer n.Stop()
+	require.NoError(t, n.Campaign(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var h *ProposalHandle
+	var applied <-chan struct{} // nil until the proposal is made
+	for {
+		select {
+		case rd := <-n.Ready():
+			require.NoError(t, s.Append(rd.Entries))
+			n.Advance()
+			if h == nil && rd.SoftState != nil && rd.SoftState.Lead == 1 {
+				var err error
+				h, err = n.ProposeWithTracking(ctx, []byte("foo"))
+				require.NoError(t, err)
+				applied = h.Applied()
+			}
+		case <-applied:
+			require.NoError(t, h.Err())
+			require.Equal(t, uint64(1), h.Term())
+			return
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+}
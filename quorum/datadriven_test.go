@@ -28,7 +28,8 @@ import (
 // CommittedIndex or "vote" to verify a VoteResult. The underlying configuration
 // and inputs are specified via the arguments 'cfg' and 'cfgj' (for the majority
 // config and, optionally, majority config joint to the first one) and 'idx'
-// (for CommittedIndex) and 'votes' (for VoteResult).
+// (for CommittedIndex) and 'votes' (for VoteResult). If 'witnesses' are
+// specified, "committed" checks CommittedIndexWithWitnesses instead.
 //
 // Internally, the harness runs some additional checks on each test case for
 // which it is known that the result shouldn't change. For example,
@@ -41,6 +42,8 @@ func TestDataDriven(t *testing.T) {
 			// be empty) and the second one is used iff joint is true.
 			var joint bool
 			var ids, idsj []uint64
+			// The voters that are witnesses.
+			var witnesses map[uint64]struct{}
 			// The committed indexes for the nodes in the config in the order in
 			// which they appear in (ids,idsj), without repetition. An underscore
 			// denotes an omission (i.e. no information for this voter); this is
@@ -75,6 +78,13 @@ func TestDataDriven(t *testing.T) {
 							arg.Scan(t, i, &n)
 							idsj = append(idsj, n)
 						}
+					case "witnesses":
+						var n uint64
+						arg.Scan(t, i, &n)
+						if witnesses == nil {
+							witnesses = map[uint64]struct{}{}
+						}
+						witnesses[n] = struct{}{}
 					case "idx":
 						var n uint64
 						// Register placeholders as zeroes.
@@ -162,9 +172,27 @@ func TestDataDriven(t *testing.T) {
 			case "committed":
 				l := makeLookuper(idxs, ids, idsj)
 
-				// Branch based on whether this is a majority or joint quorum
-				// test case.
-				if !joint {
+				// Branch based on whether this is a witness, majority or joint
+				// quorum test case.
+				if witnesses != nil {
+					cc := JointConfig([2]MajorityConfig{c, cj})
+					fmt.Fprint(&buf, cc.Describe(l))
+					idx := cc.CommittedIndexWithWitnesses(l, witnesses)
+					if !joint {
+						if aIdx := c.CommittedIndexWithWitnesses(l, witnesses); aIdx != idx {
+							fmt.Fprintf(&buf, "%s <-- via majority quorum\n", aIdx)
+						}
+					}
+					// Interchanging the majorities shouldn't make a difference. If it does, print.
+					if aIdx := JointConfig([2]MajorityConfig{cj, c}).CommittedIndexWithWitnesses(l, witnesses); aIdx != idx {
+						fmt.Fprintf(&buf, "%s <-- via symmetry\n", aIdx)
+					}
+					// Witnesses can only lower the committed index.
+					if aIdx := cc.CommittedIndex(l); aIdx < idx {
+						fmt.Fprintf(&buf, "%s <-- without witnesses\n", aIdx)
+					}
+					fmt.Fprintf(&buf, "%s\n", idx)
+				} else if !joint {
 					idx := c.CommittedIndex(l)
 					fmt.Fprint(&buf, c.Describe(l))
 					// These alternative computations should return the same
@@ -55,6 +55,17 @@ func (c JointConfig) CommittedIndex(l AckedIndexer) Index {
 	return idx1
 }
 
+// CommittedIndexWithWitnesses returns the largest committed index for the given
+// joint quorum, see MajorityConfig.CommittedIndexWithWitnesses.
+func (c JointConfig) CommittedIndexWithWitnesses(l AckedIndexer, witnesses map[uint64]struct{}) Index {
+	idx0 := c[0].CommittedIndexWithWitnesses(l, witnesses)
+	idx1 := c[1].CommittedIndexWithWitnesses(l, witnesses)
+	if idx0 < idx1 {
+		return idx0
+	}
+	return idx1
+}
+
 // VoteResult takes a mapping of voters to yes/no (true/false) votes and returns
 // a result indicating whether the vote is pending, lost, or won. A joint quorum
 // requires both majority quorums to vote in favor.
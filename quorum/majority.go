@@ -160,6 +160,29 @@ func (c MajorityConfig) CommittedIndex(l AckedIndexer) Index {
 	return Index(srt[pos])
 }
 
+// CommittedIndexWithWitnesses is like CommittedIndex, but additionally
+// requires the index to be acked by at least one voter that is not a witness.
+// Witnesses don't store the payloads of log entries, so an index acked only
+// by witnesses is not durable. This happens e.g. when the witnesses form a
+// quorum and ack entries before the leader's own append is durable.
+func (c MajorityConfig) CommittedIndexWithWitnesses(l AckedIndexer, witnesses map[uint64]struct{}) Index {
+	idx := c.CommittedIndex(l)
+	if len(c) == 0 || len(witnesses) == 0 {
+		return idx
+	}
+	// The largest index acked by a non-witness voter.
+	var stored Index
+	for id := range c {
+		if _, ok := witnesses[id]; ok {
+			continue
+		}
+		if i, ok := l.AckedIndex(id); ok && i > stored {
+			stored = i
+		}
+	}
+	return min(idx, stored)
+}
+
 // VoteResult takes a mapping of voters to yes/no (true/false) votes and returns
 // a result indicating whether the vote is pending (i.e. neither a quorum of
 // yes/no has been reached), won (a quorum of yes has been reached), or lost (a
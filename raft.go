@@ -44,6 +44,10 @@ const (
 	LocalApplyThread uint64 = math.MaxUint64 - 1
 )
 
+// maxRejectTermBoundaries is the maximum number of term boundaries a follower
+// includes in a MsgAppResp rejection.
+const maxRejectTermBoundaries = 8
+
 // Possible values for StateType.
 const (
 	StateFollower StateType = iota
@@ -82,9 +86,84 @@ const (
 const noLimit = math.MaxUint64
 
 // ErrProposalDropped is returned when the proposal is ignored by some cases,
-// so that the proposer can be notified and fail fast.
+// so that the proposer can be notified and fail fast. The returned error is
+// usually a *ProposalDroppedError wrapping it, so it should be checked for with
+// errors.Is.
 var ErrProposalDropped = errors.New("raft proposal dropped")
 
+// ProposalDroppedError is returned when a proposal is dropped, and describes
+// why. It wraps ErrProposalDropped, so errors.Is(err, ErrProposalDropped) can be
+// used to check for dropped proposals.
+//
+// The proposer can use it to decide whether to redirect the proposal to the
+// leader, retry it, or back off.
+type ProposalDroppedError struct {
+	// Reason is the reason why the proposal was dropped.
+	Reason ProposalDropReason
+	// Lead is the leader known to the node at the time, or None if unknown.
+	Lead uint64
+	// Term is the term of the node at the time.
+	Term uint64
+}
+
+func (e *ProposalDroppedError) Error() string {
+	return fmt.Sprintf("%v: %s (lead: %x, term: %d)", ErrProposalDropped, e.Reason, e.Lead, e.Term)
+}
+
+// Unwrap returns ErrProposalDropped.
+func (e *ProposalDroppedError) Unwrap() error {
+	return ErrProposalDropped
+}
+
+// ProposalDropReason describes why a proposal was dropped.
+type ProposalDropReason uint8
+
+const (
+	// ProposalDropNoLeader means that there is no known leader to handle the
+	// proposal, e.g. an election is in progress.
+	ProposalDropNoLeader ProposalDropReason = iota + 1
+	// ProposalDropForwardingDisabled means that the node is a follower, and
+	// Config.DisableProposalForwarding is set.
+	ProposalDropForwardingDisabled
+	// ProposalDropLeaderTransfer means that the leader is transferring the
+	// leadership to another node.
+	ProposalDropLeaderTransfer
+	// ProposalDropUncommittedSizeLimit means that appending the proposal would
+	// exceed Config.MaxUncommittedEntriesSize.
+	ProposalDropUncommittedSizeLimit
+	// ProposalDropNotInConfig means that the leader has been removed from the
+	// configuration.
+	ProposalDropNotInConfig
+	// ProposalDropNotLeader means that the proposal can only be handled by the
+	// leader, and the node is not the leader. This is the case for tracked
+	// proposals, see RawNode.ProposeWithTracking.
+	ProposalDropNotLeader
+	// ProposalDropApplyBehind means that the application of committed entries
+	// is lagging too far behind, see Config.MaxProposalApplyLag.
+	ProposalDropApplyBehind
+)
+
+func (r ProposalDropReason) String() string {
+	switch r {
+	case ProposalDropNoLeader:
+		return "no leader"
+	case ProposalDropForwardingDisabled:
+		return "forwarding disabled"
+	case ProposalDropLeaderTransfer:
+		return "leader transfer in progress"
+	case ProposalDropUncommittedSizeLimit:
+		return "uncommitted size limit exceeded"
+	case ProposalDropNotInConfig:
+		return "leader not in configuration"
+	case ProposalDropNotLeader:
+		return "not leader"
+	case ProposalDropApplyBehind:
+		return "apply too far behind"
+	default:
+		return fmt.Sprintf("ProposalDropReason(%d)", uint8(r))
+	}
+}
+
 // lockedRand is a small wrapper around rand.Rand to provide
 // synchronization among multiple raft groups. Only the methods needed
 // by the code are exposed (e.g. Intn).
@@ -142,6 +221,11 @@ type Config struct {
 	// Storage when it needs. raft reads out the previous state and configuration
 	// out of storage when restarting.
 	Storage Storage
+	// LogStorage and StateStorage can be set instead of Storage, to read the log
+	// entries from one backend, and the durable state and snapshots from
+	// another. Both must be set in this case, see NewSplitStorage.
+	LogStorage   LogStorage
+	StateStorage StateStorage
 	// Applied is the last applied index. It should only be set when restarting
 	// raft. raft will not return entries to the application smaller or equal to
 	// Applied. If Applied is unset when restarting, raft might return previous
@@ -197,11 +281,29 @@ type Config struct {
 	// Ready structs to encompass all outstanding entries in unacknowledged
 	// MsgStorageApply messages when AsyncStorageWrites is enabled.
 	MaxCommittedSizePerReady uint64
+	// MaxApplyLag limits the number of committed entries that can be handed out
+	// for application, in Ready.CommittedEntries or MsgStorageApply messages,
+	// ahead of the application's apply progress. The progress is acknowledged
+	// by Advance or the MsgStorageApply responses, and can be reported earlier
+	// with RawNode.ReportApplyProgress. If the application reports a queue
+	// depth, it counts towards the lag as well. Note: 0 for no limit.
+	//
+	// This provides backpressure for an application that applies entries
+	// asynchronously, e.g. with AsyncStorageWrites, and allows it to bound the
+	// memory used by its apply queue.
+	MaxApplyLag uint64
 	// MaxUncommittedEntriesSize limits the aggregate byte size of the
 	// uncommitted entries that may be appended to a leader's log. Once this
 	// limit is exceeded, proposals will begin to return ErrProposalDropped
 	// errors. Note: 0 for no limit.
 	MaxUncommittedEntriesSize uint64
+	// MaxProposalApplyLag limits the number of committed entries that are not
+	// yet applied by the local application. Once this limit is reached, the
+	// node drops new proposals with ProposalDropApplyBehind until the
+	// application catches up. On the leader, this includes the proposals
+	// forwarded by followers. See MaxApplyLag for how the apply progress is
+	// tracked. Note: 0 for no limit.
+	MaxProposalApplyLag uint64
 	// MaxInflightMsgs limits the max number of in-flight append messages during
 	// optimistic replication phase. The application transportation layer usually
 	// has its own sending buffer over TCP/UDP. Setting MaxInflightMsgs to avoid
@@ -218,6 +320,37 @@ type Config struct {
 	// throughput limit of 10 MB/s for this group. With RTT of 400ms, this drops
 	// to 2.5 MB/s. See Little's law to understand the maths behind.
 	MaxInflightBytes uint64
+	// MaxAppendBytesPerTick limits the rate at which the leader sends the
+	// payloads of log entries to each follower, in bytes per tick. It prevents
+	// a follower catching up on a long log from saturating the network link,
+	// e.g. in WAN deployments. Heartbeats and snapshots are not subject to the
+	// limit. Ignored if zero.
+	//
+	// The limit is enforced with a token bucket which is refilled on every
+	// tick, and whose capacity is MaxAppendBurstBytes. A single append message
+	// can exceed the available budget, in which case the subsequent messages
+	// are delayed until the excess is paid back.
+	MaxAppendBytesPerTick uint64
+	// MaxAppendBurstBytes is the maximum number of bytes that the leader can
+	// send to a follower at once after a period of inactivity, when
+	// MaxAppendBytesPerTick is set. Defaults to MaxAppendBytesPerTick if
+	// lower.
+	MaxAppendBurstBytes uint64
+
+	// EntryCodecs lists the codecs that can be used to compress the log
+	// entries in MsgApp messages, in the order of preference. The node
+	// advertises the codecs in its responses to the leader, and a leader
+	// compresses the entries sent to a follower with the first of its codecs
+	// that the follower supports. Followers which don't advertise any codecs,
+	// such as the ones running an older version, receive uncompressed entries.
+	// Compression is disabled if empty.
+	//
+	// The compressed size of the entries counts towards MaxSizePerMsg,
+	// MaxInflightBytes and MaxAppendBytesPerTick.
+	EntryCodecs []Codec
+	// EntryCompressionThreshold is the minimum encoded size of the entries in
+	// a MsgApp for them to be compressed. Ignored if EntryCodecs is empty.
+	EntryCompressionThreshold uint64
 
 	// CheckQuorum specifies if the leader should check quorum activity. Leader
 	// steps down when quorum is not active for an electionTimeout.
@@ -238,8 +371,35 @@ type Config struct {
 	// If the clock drift is unbounded, leader might keep the lease longer than it
 	// should (clock can move backward/pause without any bound). ReadIndex is not safe
 	// in that case.
-	// CheckQuorum MUST be enabled if ReadOnlyOption is ReadOnlyLeaseBased.
+	// CheckQuorum or LeaseTicks MUST be enabled if ReadOnlyOption is
+	// ReadOnlyLeaseBased. With LeaseTicks, the lease is explicitly supported by
+	// a quorum, and reads fall back to ReadOnlySafe while it is not valid.
 	ReadOnlyOption ReadOnlyOption
+	// BatchReadIndex coalesces the read only requests that require a quorum
+	// confirmation. At most one confirmation round is outstanding at a time:
+	// requests arriving while it is in flight are confirmed together by the
+	// next round, which starts when the outstanding one completes or on the
+	// next heartbeat. Each request still gets its own ReadState with the
+	// commit index at the time it was received.
+	BatchReadIndex bool
+
+	// LeaseTicks enables leader leases, and is the number of ticks for which a
+	// follower promises not to vote for another candidate after acknowledging
+	// a leader's heartbeat. The leader holds a lease while a quorum of voters
+	// supports it, in which case ReadOnlyLeaseBased reads are served without
+	// contacting the followers. Zero disables leases. Must not exceed
+	// ElectionTick.
+	//
+	// Leases delay elections after a leader failure by up to LeaseTicks. A
+	// (re)started peer does not vote for the first LeaseTicks ticks, as it
+	// doesn't remember the promises it made before the restart. Leadership
+	// transfers bypass the promises, which is safe since the leader stops
+	// using its lease when it starts a transfer.
+	LeaseTicks int
+	// MaxClockSkewTicks is the maximum number of ticks by which the clock of a
+	// follower may run ahead of the leader's during LeaseTicks. The leader
+	// shortens the lease by this amount. Must be less than LeaseTicks.
+	MaxClockSkewTicks int
 
 	// Logger is the logger used for raft log. For multinode which can host
 	// multiple raft group, each raft group can have its own logger
@@ -284,6 +444,80 @@ type Config struct {
 	// https://github.com/etcd-io/raft/issues/83
 	StepDownOnRemoval bool
 
+	// Quiescence lets an idle group stop exchanging heartbeats. Once all
+	// followers have caught up with the leader's log and commit index, the
+	// leader sends them MsgQuiesce on its next heartbeat timeout instead of
+	// heartbeats, and stops its own timers. Quiesced followers don't campaign.
+	//
+	// A quiesced peer wakes up on any message other than the responses to the
+	// leader's messages, on a proposal, a conf change, ReportUnreachable, or an
+	// explicit Unquiesce call. A woken up leader sends heartbeats right away,
+	// and a woken up follower asks the leader to wake up too. Since a quiesced
+	// follower can't detect a leader failure on its own, the application must
+	// call Unquiesce on it when it suspects that the leader is down.
+	Quiescence bool
+
+	// SnapshotSource, if set, makes the leader stream snapshots to followers
+	// in chunks read from it, instead of sending the snapshot returned by
+	// Storage.Snapshot in a single MsgSnap. The metadata of the snapshot is
+	// sent along with each chunk. The follower acknowledges the chunks it has
+	// received, and an interrupted transfer resumes from the last acknowledged
+	// chunk. Followers must have SnapshotSink set.
+	SnapshotSource SnapshotSource
+	// SnapshotSink receives the chunks of the snapshots streamed by the leader,
+	// see SnapshotSource. If nil, the streamed snapshots are rejected.
+	SnapshotSink SnapshotSink
+	// SnapshotChunkSize is the maximum size in bytes of a snapshot chunk. Zero
+	// means 1 MiB.
+	SnapshotChunkSize uint64
+	// MaxInflightSnapshotChunks limits the number of unacknowledged snapshot
+	// chunks in flight to a follower. Zero means 4.
+	MaxInflightSnapshotChunks int
+
+	// ProposalTrackingTimeout is the number of ticks after which a proposal
+	// tracked with ProposeWithTracking fails with ErrProposalTimeout, if it
+	// has not been committed by then. Zero means no timeout.
+	ProposalTrackingTimeout int
+	// ReadIndexTimeout is the number of ticks after which a read made with
+	// ReadIndexWithWaiter fails with ErrReadIndexTimeout, if its read index has
+	// not been received by then. Zero means ElectionTick.
+	ReadIndexTimeout int
+
+	// Priority is the election priority of this node, used if the membership
+	// config doesn't assign one to it (see raftpb.ConfChangeSetPriority). A
+	// node with a lower priority than the highest one among the voters waits
+	// up to one election timeout longer before campaigning. Zero is the
+	// lowest priority.
+	//
+	// Only the priorities in the membership config, which all nodes agree on,
+	// make a follower ask the leader to transfer the leadership to it.
+	Priority uint64
+
+	// LeaderTransferPreference lists the voters in the order in which they are
+	// preferred as the target of a leadership transfer requested without one
+	// (see RawNode.TransferLeader). Voters not in the list are only picked if
+	// none of the listed ones is eligible.
+	LeaderTransferPreference []uint64
+
+	// AutoPromoteMaxLag is the number of committed log entries that a learner
+	// marked for automatic promotion (see
+	// raftpb.ConfChangeAddLearnerNodeAutoPromote) may be missing when the
+	// leader promotes it to a voter. With zero, the learner must have all the
+	// committed entries.
+	AutoPromoteMaxLag uint64
+
+	// Metrics receives the events occurring in the raft state machine, such as
+	// elections, messages, and dropped proposals. If nil, the events are
+	// ignored.
+	Metrics Metrics
+
+	// Rand, if set, is the source of randomness for the randomized election
+	// timeouts, and is only used from the goroutine driving the raft node. It
+	// can be a seeded *math/rand.Rand to make the behavior of the node
+	// reproducible, e.g. in simulations. If nil, a cryptographically secure
+	// source shared by all nodes is used.
+	Rand interface{ Intn(n int) int }
+
 	// raft state tracer
 	TraceLogger TraceLogger
 }
@@ -304,8 +538,17 @@ func (c *Config) validate() error {
 		return errors.New("election tick must be greater than heartbeat tick")
 	}
 
-	if c.Storage == nil {
+	switch {
+	case c.Storage != nil && (c.LogStorage != nil || c.StateStorage != nil):
+		return errors.New("cannot set both storage and log/state storage")
+	case c.Storage != nil:
+	case c.LogStorage == nil && c.StateStorage == nil:
 		return errors.New("storage cannot be nil")
+	case c.LogStorage == nil || c.StateStorage == nil:
+		return errors.New("log storage and state storage must be set together")
+	default:
+		c.Storage = NewSplitStorage(c.LogStorage, c.StateStorage)
+		c.LogStorage, c.StateStorage = nil, nil
 	}
 
 	if c.MaxUncommittedEntriesSize == 0 {
@@ -331,8 +574,42 @@ func (c *Config) validate() error {
 		c.Logger = getLogger()
 	}
 
-	if c.ReadOnlyOption == ReadOnlyLeaseBased && !c.CheckQuorum {
-		return errors.New("CheckQuorum must be enabled when ReadOnlyOption is ReadOnlyLeaseBased")
+	if c.Metrics == nil {
+		c.Metrics = NoopMetrics{}
+	}
+
+	if c.ProposalTrackingTimeout < 0 {
+		return errors.New("proposal tracking timeout must not be negative")
+	}
+
+	if c.ReadIndexTimeout < 0 {
+		return errors.New("read index timeout must not be negative")
+	} else if c.ReadIndexTimeout == 0 {
+		c.ReadIndexTimeout = c.ElectionTick
+	}
+
+	if err := validateCodecs(c.EntryCodecs); err != nil {
+		return err
+	}
+
+	if c.SnapshotChunkSize == 0 {
+		c.SnapshotChunkSize = defaultSnapshotChunkSize
+	}
+	if c.MaxInflightSnapshotChunks < 0 {
+		return errors.New("max inflight snapshot chunks must not be negative")
+	} else if c.MaxInflightSnapshotChunks == 0 {
+		c.MaxInflightSnapshotChunks = defaultMaxInflightSnapshotChunks
+	}
+
+	if c.LeaseTicks < 0 || c.LeaseTicks > c.ElectionTick {
+		return errors.New("lease ticks must be between 0 and election tick")
+	}
+	if c.MaxClockSkewTicks < 0 || (c.LeaseTicks > 0 && c.MaxClockSkewTicks >= c.LeaseTicks) {
+		return errors.New("max clock skew ticks must be non-negative and less than lease ticks")
+	}
+
+	if c.ReadOnlyOption == ReadOnlyLeaseBased && !c.CheckQuorum && c.LeaseTicks == 0 {
+		return errors.New("CheckQuorum or LeaseTicks must be enabled when ReadOnlyOption is ReadOnlyLeaseBased")
 	}
 
 	return nil
@@ -351,6 +628,16 @@ type raft struct {
 
 	maxMsgSize         entryEncodingSize
 	maxUncommittedSize entryPayloadSize
+	// maxProposalApplyLag is Config.MaxProposalApplyLag.
+	maxProposalApplyLag uint64
+
+	// codecs is Config.EntryCodecs, and supportedCodecs is their bitmask.
+	codecs                    []Codec
+	supportedCodecs           uint64
+	entryCompressionThreshold uint64
+	// compressionRatio is the ratio of the uncompressed to compressed size of
+	// the entries in the last compressed MsgApp.
+	compressionRatio float64
 
 	trk tracker.ProgressTracker
 
@@ -358,6 +645,8 @@ type raft struct {
 
 	// isLearner is true if the local raft node is a learner.
 	isLearner bool
+	// isWitness is true if the local raft node is a witness.
+	isWitness bool
 
 	// msgs contains the list of messages that should be sent out immediately to
 	// other nodes.
@@ -381,6 +670,37 @@ type raft struct {
 	// leadTransferee is id of the leader transfer target when its value is not zero.
 	// Follow the procedure defined in raft thesis 3.10.
 	leadTransferee uint64
+	// leadTransferTerm is the term in which the leadership transfer to
+	// leadTransferee (or leadTransferStepDown) was started.
+	leadTransferTerm uint64
+	// leadTransferStepDown is the target of the leadership transfer that was in
+	// progress when this node stepped down. Its outcome is reported once the
+	// new leader is known.
+	leadTransferStepDown uint64
+	// leadTransferDups is the number of requests for the same target received
+	// while the transfer to leadTransferee (or leadTransferStepDown) was in
+	// progress. Each of them gets the outcome of the transfer.
+	leadTransferDups int
+	// leadTransferPreference is the order in which voters are picked as the
+	// target of a leadership transfer requested without one.
+	leadTransferPreference []uint64
+	// leadTransferResults contains the outcomes of leadership transfers that
+	// have not been delivered in a Ready yet.
+	leadTransferResults []LeaderTransferResult
+	// priority is the election priority of this node, see Config.Priority.
+	priority uint64
+	// nextLeadershipRequest is the tick from which this node may again ask the
+	// leader to transfer the leadership to it because of its priority.
+	nextLeadershipRequest uint64
+	// autoPromoteMaxLag is Config.AutoPromoteMaxLag.
+	autoPromoteMaxLag uint64
+	// promotionIndex is the index of the conf change entry that this leader
+	// proposed to promote the learners in promotionIDs, or zero.
+	promotionIndex uint64
+	promotionIDs   []uint64
+	// promotionResults contains the outcomes of learner promotions that have
+	// not been delivered in a Ready yet.
+	promotionResults []LearnerPromotionResult
 	// Only one conf change may be pending (in the log, but not yet
 	// applied) at a time. This is enforced via pendingConfIndex, which
 	// is set to a value >= the log index of the latest pending
@@ -408,6 +728,22 @@ type raft struct {
 	// only leader keeps heartbeatElapsed.
 	heartbeatElapsed int
 
+	// ticks is the number of ticks since this raft instance was created. It is
+	// the local clock used for leader leases.
+	ticks uint64
+	// leaseTicks and maxClockSkewTicks are Config.LeaseTicks and
+	// Config.MaxClockSkewTicks, see there for details.
+	leaseTicks        uint64
+	maxClockSkewTicks uint64
+	// leaseSupportExpiry is the time (in ticks) until which this peer has
+	// promised not to vote for another candidate, by acknowledging a leader's
+	// heartbeat. Leadership transfers are exempt from the promise.
+	leaseSupportExpiry uint64
+	// leaseRevoked is set by the leader once it has sent MsgTimeoutNow in its
+	// term. From then on, the lease is never used again, since the transfer
+	// target may win an election regardless of the promises of the followers.
+	leaseRevoked bool
+
 	checkQuorum bool
 	preVote     bool
 
@@ -419,6 +755,20 @@ type raft struct {
 	randomizedElectionTimeout int
 	disableProposalForwarding bool
 	stepDownOnRemoval         bool
+	quiescence                bool
+
+	snapSource SnapshotSource
+	snapSink   SnapshotSink
+	// snapChunkSize and maxInflightSnapChunks are Config.SnapshotChunkSize and
+	// Config.MaxInflightSnapshotChunks.
+	snapChunkSize         uint64
+	maxInflightSnapChunks uint64
+	// snapRecv is the snapshot that the follower is receiving from the leader.
+	snapRecv snapshotTransfer
+
+	// quiesced is true while the peer is quiesced, see Config.Quiescence. It
+	// stops the election and heartbeat timers.
+	quiesced bool
 
 	tick func()
 	step stepFunc
@@ -432,6 +782,13 @@ type raft struct {
 	pendingReadIndexMessages []pb.Message
 
 	traceLogger TraceLogger
+	metrics     Metrics
+	rand        interface{ Intn(n int) int }
+
+	// proposals tracks the proposals made with ProposeWithTracking.
+	proposals proposalTracker
+	// readWaiters tracks the reads made with ReadIndexWithWaiter.
+	readWaiters readIndexWaiters
 }
 
 func newRaft(c *Config) *raft {
@@ -439,6 +796,7 @@ func newRaft(c *Config) *raft {
 		panic(err.Error())
 	}
 	raftlog := newLogWithSize(c.Storage, c.Logger, entryEncodingSize(c.MaxCommittedSizePerReady))
+	raftlog.maxApplyLag = c.MaxApplyLag
 	hs, cs, err := c.Storage.InitialState()
 	if err != nil {
 		panic(err) // TODO(bdarnell)
@@ -451,18 +809,45 @@ func newRaft(c *Config) *raft {
 		raftLog:                     raftlog,
 		maxMsgSize:                  entryEncodingSize(c.MaxSizePerMsg),
 		maxUncommittedSize:          entryPayloadSize(c.MaxUncommittedEntriesSize),
+		maxProposalApplyLag:         c.MaxProposalApplyLag,
 		trk:                         tracker.MakeProgressTracker(c.MaxInflightMsgs, c.MaxInflightBytes),
 		electionTimeout:             c.ElectionTick,
 		heartbeatTimeout:            c.HeartbeatTick,
 		logger:                      c.Logger,
 		checkQuorum:                 c.CheckQuorum,
 		preVote:                     c.PreVote,
-		readOnly:                    newReadOnly(c.ReadOnlyOption),
+		readOnly:                    newReadOnly(c.ReadOnlyOption, c.BatchReadIndex),
 		disableProposalForwarding:   c.DisableProposalForwarding,
 		disableConfChangeValidation: c.DisableConfChangeValidation,
 		stepDownOnRemoval:           c.StepDownOnRemoval,
-		traceLogger:                 c.TraceLogger,
-	}
+		quiescence:                  c.Quiescence,
+		snapSource:                  c.SnapshotSource,
+		snapSink:                    c.SnapshotSink,
+		snapChunkSize:               c.SnapshotChunkSize,
+		maxInflightSnapChunks:       uint64(c.MaxInflightSnapshotChunks),
+		leaseTicks:                  uint64(c.LeaseTicks),
+		maxClockSkewTicks:           uint64(c.MaxClockSkewTicks),
+		// The peer could have supported a lease before a restart, so it must
+		// not vote until any such lease expires.
+		leaseSupportExpiry: uint64(c.LeaseTicks),
+		traceLogger:        c.TraceLogger,
+		metrics:            c.Metrics,
+		rand:               c.Rand,
+		proposals:          proposalTracker{timeout: uint64(c.ProposalTrackingTimeout)},
+		readWaiters:        readIndexWaiters{timeout: uint64(c.ReadIndexTimeout)},
+
+		leadTransferPreference: c.LeaderTransferPreference,
+		priority:               c.Priority,
+		autoPromoteMaxLag:      c.AutoPromoteMaxLag,
+
+		codecs:                    c.EntryCodecs,
+		supportedCodecs:           codecMask(c.EntryCodecs),
+		entryCompressionThreshold: c.EntryCompressionThreshold,
+		compressionRatio:          1,
+	}
+	raftlog.unstable.onTruncate = r.proposals.truncate
+	r.trk.MaxAppendBytesPerTick = c.MaxAppendBytesPerTick
+	r.trk.MaxAppendBurstBytes = c.MaxAppendBurstBytes
 
 	traceInitState(r)
 
@@ -513,6 +898,9 @@ func (r *raft) send(m pb.Message) {
 	if m.From == None {
 		m.From = r.id
 	}
+	if m.To != r.id && !IsLocalMsg(m.Type) {
+		r.metrics.MessageSent(m.Type)
+	}
 	if m.Type == pb.MsgVote || m.Type == pb.MsgVoteResp || m.Type == pb.MsgPreVote || m.Type == pb.MsgPreVoteResp {
 		if m.Term == 0 {
 			// All {pre-,}campaign messages need to have the term set when
@@ -541,6 +929,9 @@ func (r *raft) send(m pb.Message) {
 			m.Term = r.Term
 		}
 	}
+	if m.Type == pb.MsgAppResp || m.Type == pb.MsgHeartbeatResp {
+		m.SupportedCodecs = r.supportedCodecs
+	}
 	if m.Type == pb.MsgAppResp || m.Type == pb.MsgVoteResp || m.Type == pb.MsgPreVoteResp {
 		// If async storage writes are enabled, messages added to the msgs slice
 		// are allowed to be sent out before unstable state (e.g. log entry
@@ -628,14 +1019,22 @@ func (r *raft) maybeSendAppend(to uint64, sendIfEmpty bool) bool {
 	}
 
 	var ents []pb.Entry
+	maxSize := r.maxMsgSize
+	codec := r.appendCodec(pr)
 	// In a throttled StateReplicate only send empty MsgApp, to ensure progress.
 	// Otherwise, if we had a full Inflights and all inflight messages were in
 	// fact dropped, replication to that follower would stall. Instead, an empty
 	// MsgApp will eventually reach the follower (heartbeats responses prompt the
 	// leader to send an append), allowing it to be acked or rejected, both of
 	// which will clear out Inflights.
+	//
+	// Similarly, only send empty MsgApp if the follower has exhausted its rate
+	// limit. The budget is replenished on ticks, see tickAppendRate.
 	if pr.State != tracker.StateReplicate || !pr.Inflights.Full() {
-		ents, err = r.raftLog.entries(pr.Next, r.maxMsgSize)
+		var ok bool
+		if maxSize, ok = r.appendBudget(pr); ok {
+			ents, err = r.raftLog.entries(pr.Next, r.compressedFetchSize(codec, maxSize))
+		}
 	}
 	if len(ents) == 0 && !sendIfEmpty {
 		return false
@@ -645,20 +1044,74 @@ func (r *raft) maybeSendAppend(to uint64, sendIfEmpty bool) bool {
 		return r.maybeSendSnapshot(to, pr)
 	}
 
-	// Send the actual MsgApp otherwise, and update the progress accordingly.
-	r.send(pb.Message{
+	// Witnesses only need the log positions of the entries to vote and ack, so
+	// the payloads of normal entries are not sent to them.
+	if pr.IsWitness {
+		ents = witnessEntries(ents)
+	}
+	m := pb.Message{
 		To:      to,
 		Type:    pb.MsgApp,
 		Index:   prevIndex,
 		LogTerm: prevTerm,
 		Entries: ents,
 		Commit:  r.raftLog.committed,
-	})
-	pr.SentEntries(len(ents), uint64(payloadsSize(ents)))
+	}
+	// The compressed entries are accounted for with their size on the wire.
+	size := uint64(payloadsSize(ents))
+	if codec != nil && len(ents) > 0 {
+		if cents, csize, ok := r.compressAppend(&m, codec, maxSize); ok {
+			ents, size = cents, csize
+		} else {
+			// The entries were fetched to fit into maxSize once compressed. Cut
+			// them down to fit uncompressed.
+			ents = limitSize(ents, maxSize)
+			m.Entries, size = ents, uint64(payloadsSize(ents))
+		}
+	}
+	if pr.RateLimit != nil {
+		pr.RateLimit.Take(size)
+	}
+	// Send the actual MsgApp otherwise, and update the progress accordingly.
+	r.send(m)
+	pr.SentEntries(len(ents), size)
 	pr.SentCommit(r.raftLog.committed)
 	return true
 }
 
+// appendBudget returns the max size of the entries that can be sent to the
+// follower in a MsgApp. Returns false if the follower has exhausted its rate
+// limit, and no entries can be sent.
+func (r *raft) appendBudget(pr *tracker.Progress) (entryEncodingSize, bool) {
+	if pr.RateLimit == nil {
+		return r.maxMsgSize, true
+	}
+	available := pr.RateLimit.Available()
+	if available == 0 {
+		return 0, false
+	}
+	// NB: at least one entry is returned, even if it exceeds the budget.
+	return min(r.maxMsgSize, entryEncodingSize(available)), true
+}
+
+// tickAppendRate replenishes the rate limits of the followers, and resumes
+// replication to the followers which had exhausted them.
+func (r *raft) tickAppendRate() {
+	if r.trk.MaxAppendBytesPerTick == 0 {
+		return
+	}
+	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
+		if id == r.id || pr.RateLimit == nil {
+			return
+		}
+		throttled := pr.RateLimit.Available() == 0
+		pr.RateLimit.Tick()
+		if throttled && pr.RateLimit.Available() > 0 {
+			r.maybeSendAppend(id, false /* sendIfEmpty */)
+		}
+	})
+}
+
 // maybeSendSnapshot fetches a snapshot from Storage, and sends it to the given
 // node. Returns true iff the snapshot message has been emitted successfully.
 func (r *raft) maybeSendSnapshot(to uint64, pr *tracker.Progress) bool {
@@ -666,6 +1119,9 @@ func (r *raft) maybeSendSnapshot(to uint64, pr *tracker.Progress) bool {
 		r.logger.Debugf("ignore sending snapshot to %x since it is not recently active", to)
 		return false
 	}
+	if r.snapSource != nil {
+		return r.startSnapshotStream(to, pr)
+	}
 
 	snapshot, err := r.raftLog.snapshot()
 	if err != nil {
@@ -683,8 +1139,13 @@ func (r *raft) maybeSendSnapshot(to uint64, pr *tracker.Progress) bool {
 		r.id, r.raftLog.firstIndex(), r.raftLog.committed, sindex, sterm, to, pr)
 	pr.BecomeSnapshot(sindex)
 	r.logger.Debugf("%x paused sending replication messages to %x [%s]", r.id, to, pr)
+	if pr.IsWitness {
+		// Witnesses don't hold the state machine, only its metadata.
+		snapshot.Data = nil
+	}
 
 	r.send(pb.Message{To: to, Type: pb.MsgSnap, Snapshot: &snapshot})
+	r.metrics.SnapshotSent(to, sindex)
 	return true
 }
 
@@ -698,9 +1159,16 @@ func (r *raft) sendHeartbeat(to uint64, ctx []byte) {
 	// The leader MUST NOT forward the follower's commit to
 	// an unmatched index.
 	commit := min(pr.Match, r.raftLog.committed)
+	// With leases enabled, the heartbeat carries the time at which it was sent,
+	// and the follower echoes it back when it promises to support the lease.
+	var sent uint64
+	if r.leaseTicks > 0 {
+		sent = r.ticks
+	}
 	r.send(pb.Message{
 		To:      to,
 		Type:    pb.MsgHeartbeat,
+		Index:   sent,
 		Commit:  commit,
 		Context: ctx,
 	})
@@ -720,12 +1188,7 @@ func (r *raft) bcastAppend() {
 
 // bcastHeartbeat sends RPC, without entries to all the peers.
 func (r *raft) bcastHeartbeat() {
-	lastCtx := r.readOnly.lastPendingRequestCtx()
-	if len(lastCtx) == 0 {
-		r.bcastHeartbeatWithCtx(nil)
-	} else {
-		r.bcastHeartbeatWithCtx([]byte(lastCtx))
-	}
+	r.bcastHeartbeatWithCtx(r.readOnly.startRound())
 }
 
 func (r *raft) bcastHeartbeatWithCtx(ctx []byte) {
@@ -741,6 +1204,11 @@ func (r *raft) appliedTo(index uint64, size entryEncodingSize) {
 	oldApplied := r.raftLog.applied
 	newApplied := max(index, oldApplied)
 	r.raftLog.appliedTo(newApplied, size)
+	r.proposals.applied(newApplied)
+	r.readWaiters.applied(newApplied)
+	if r.promotionIndex != 0 && newApplied >= r.promotionIndex {
+		r.finishLearnerPromotion()
+	}
 
 	if r.trk.Config.AutoLeave && newApplied >= r.pendingConfIndex && r.state == StateLeader {
 		// If the current (and most recent, at least for this leader's term)
@@ -764,6 +1232,7 @@ func (r *raft) appliedTo(index uint64, size entryEncodingSize) {
 			r.logger.Infof("initiating automatic transition out of joint configuration %s", r.trk.Config)
 		}
 	}
+	r.maybePromoteLearners()
 }
 
 func (r *raft) appliedSnap(snap *pb.Snapshot) {
@@ -783,6 +1252,7 @@ func (r *raft) maybeCommit() bool {
 
 func (r *raft) reset(term uint64) {
 	if r.Term != term {
+		r.metrics.TermChanged(r.Term, term)
 		r.Term = term
 		r.Vote = None
 	}
@@ -792,7 +1262,10 @@ func (r *raft) reset(term uint64) {
 	r.heartbeatElapsed = 0
 	r.resetRandomizedElectionTimeout()
 
-	r.abortLeaderTransfer()
+	r.stepDownDuringLeaderTransfer()
+	r.abortLearnerPromotion()
+	r.leaseRevoked = false
+	r.quiesced = false
 
 	r.trk.ResetVotes()
 	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
@@ -800,7 +1273,9 @@ func (r *raft) reset(term uint64) {
 			Match:     0,
 			Next:      r.raftLog.lastIndex() + 1,
 			Inflights: tracker.NewInflights(r.trk.MaxInflight, r.trk.MaxInflightBytes),
+			RateLimit: r.trk.NewRateLimit(),
 			IsLearner: pr.IsLearner,
+			IsWitness: pr.IsWitness,
 		}
 		if id == r.id {
 			pr.Match = r.raftLog.lastIndex()
@@ -809,7 +1284,15 @@ func (r *raft) reset(term uint64) {
 
 	r.pendingConfIndex = 0
 	r.uncommittedSize = 0
-	r.readOnly = newReadOnly(r.readOnly.option)
+	r.readOnly = newReadOnly(r.readOnly.option, r.readOnly.batch)
+	r.readWaiters.failUnresolved(ErrReadIndexLeaderChanged)
+}
+
+// dropProposal reports a proposal dropped for the given reason, and returns
+// the error to be returned to the proposer.
+func (r *raft) dropProposal(reason ProposalDropReason) error {
+	r.metrics.ProposalDropped(reason)
+	return &ProposalDroppedError{Reason: reason, Lead: r.lead, Term: r.Term}
 }
 
 func (r *raft) appendEntry(es ...pb.Entry) (accepted bool) {
@@ -820,6 +1303,7 @@ func (r *raft) appendEntry(es ...pb.Entry) (accepted bool) {
 	}
 	// Track the size of this uncommitted proposal.
 	if !r.increaseUncommittedSize(es) {
+		r.metrics.UncommittedSizeRejected(uint64(payloadsSize(es)))
 		r.logger.Warningf(
 			"%x appending new entries to log would exceed uncommitted entry size limit; dropping proposal",
 			r.id,
@@ -848,6 +1332,10 @@ func (r *raft) appendEntry(es ...pb.Entry) (accepted bool) {
 
 // tickElection is run by followers and candidates after r.electionTimeout.
 func (r *raft) tickElection() {
+	r.ticks++
+	if r.quiesced {
+		return
+	}
 	r.electionElapsed++
 
 	if r.promotable() && r.pastElectionTimeout() {
@@ -860,6 +1348,10 @@ func (r *raft) tickElection() {
 
 // tickHeartbeat is run by leaders to send a MsgBeat after r.heartbeatTimeout.
 func (r *raft) tickHeartbeat() {
+	r.ticks++
+	if r.quiesced {
+		return
+	}
 	r.heartbeatElapsed++
 	r.electionElapsed++
 
@@ -872,16 +1364,20 @@ func (r *raft) tickHeartbeat() {
 		}
 		// If current leader cannot transfer leadership in electionTimeout, it becomes leader again.
 		if r.state == StateLeader && r.leadTransferee != None {
-			r.abortLeaderTransfer()
+			r.leaderTransferTimedOut()
 		}
 	}
 
 	if r.state != StateLeader {
 		return
 	}
+	r.tickAppendRate()
 
 	if r.heartbeatElapsed >= r.heartbeatTimeout {
 		r.heartbeatElapsed = 0
+		if r.maybeQuiesce() {
+			return
+		}
 		if err := r.Step(pb.Message{From: r.id, Type: pb.MsgBeat}); err != nil {
 			r.logger.Debugf("error occurred during checking sending heartbeat: %v", err)
 		}
@@ -949,6 +1445,8 @@ func (r *raft) becomeLeader() {
 	// The leader always has RecentActive == true; MsgCheckQuorum makes sure to
 	// preserve this.
 	pr.RecentActive = true
+	// The leader trivially supports its own lease.
+	pr.LeaseExpiry = math.MaxUint64
 
 	// Conservatively set the pendingConfIndex to the last index in the
 	// log. There may or may not be a pending config change, but it's
@@ -984,6 +1482,11 @@ func (r *raft) hup(t CampaignType) {
 		r.logger.Warningf("%x cannot campaign at term %d since there are still pending configuration changes to apply", r.id, r.Term)
 		return
 	}
+	if t != campaignTransfer && r.supportingLease() {
+		r.logger.Infof("%x cannot campaign at term %d since it supports a leader lease (remaining ticks: %d)",
+			r.id, r.Term, r.leaseSupportExpiry-r.ticks)
+		return
+	}
 
 	r.logger.Infof("%x is starting a new election at term %d", r.id, r.Term)
 	r.campaign(t)
@@ -1035,10 +1538,12 @@ func (r *raft) campaign(t CampaignType) {
 		voteMsg = pb.MsgPreVote
 		// PreVote RPCs are sent for the next term before we've incremented r.Term.
 		term = r.Term + 1
+		r.metrics.PreVoteStarted(term)
 	} else {
 		r.becomeCandidate()
 		voteMsg = pb.MsgVote
 		term = r.Term
+		r.metrics.ElectionStarted(term)
 	}
 	var ids []uint64
 	{
@@ -1084,6 +1589,12 @@ func (r *raft) poll(id uint64, t pb.MessageType, v bool) (granted int, rejected
 
 func (r *raft) Step(m pb.Message) error {
 	traceReceiveMessage(r, &m)
+	// The new leader may become known on any of the paths below, including
+	// the ones that return early.
+	defer r.maybeFinishLeaderTransfer()
+	if m.From != None && m.From != r.id && !IsLocalMsg(m.Type) {
+		r.metrics.MessageReceived(m.Type)
+	}
 
 	// Handle the message term, which may result in our stepping down to a follower.
 	switch {
@@ -1100,6 +1611,19 @@ func (r *raft) Step(m pb.Message) error {
 				// TODO(pav-kv): it should be ok to simply print the %+v of the lastEntryID.
 				r.logger.Infof("%x [logterm: %d, index: %d, vote: %x] ignored %s from %x [logterm: %d, index: %d] at term %d: lease is not expired (remaining ticks: %d)",
 					r.id, last.term, last.index, r.Vote, m.Type, m.From, m.LogTerm, m.Index, r.Term, r.electionTimeout-r.electionElapsed)
+				if r.quiesced {
+					// The candidate may have detected a leader failure. Restart the
+					// election timer, and check whether the leader is still there.
+					r.unquiesce(true /* notifyLeader */)
+				}
+				return nil
+			}
+			if !force && r.supportingLease() {
+				// The peer has promised to support the lease of a leader, and must
+				// not vote for anyone else until the promise expires.
+				last := r.raftLog.lastEntryID()
+				r.logger.Infof("%x [logterm: %d, index: %d, vote: %x] ignored %s from %x [logterm: %d, index: %d] at term %d: supporting leader lease (remaining ticks: %d)",
+					r.id, last.term, last.index, r.Vote, m.Type, m.From, m.LogTerm, m.Index, r.Term, r.leaseSupportExpiry-r.ticks)
 				return nil
 			}
 		}
@@ -1115,7 +1639,7 @@ func (r *raft) Step(m pb.Message) error {
 		default:
 			r.logger.Infof("%x [term: %d] received a %s message with higher term from %x [term: %d]",
 				r.id, r.Term, m.Type, m.From, m.Term)
-			if m.Type == pb.MsgApp || m.Type == pb.MsgHeartbeat || m.Type == pb.MsgSnap {
+			if m.Type == pb.MsgApp || m.Type == pb.MsgHeartbeat || m.Type == pb.MsgSnap || m.Type == pb.MsgSnapChunk || m.Type == pb.MsgQuiesce {
 				r.becomeFollower(m.Term, m.From)
 			} else {
 				r.becomeFollower(m.Term, None)
@@ -1178,7 +1702,16 @@ func (r *raft) Step(m pb.Message) error {
 		return nil
 	}
 
+	if r.quiesced && wakesUp(m) {
+		r.unquiesce(m.From != r.lead /* notifyLeader */)
+	}
+
 	switch m.Type {
+	case pb.MsgUnquiesce:
+		// The node has been woken up above, if it was quiesced. There is nothing
+		// else to do, and the message must not reach the per-state step
+		// functions since it doesn't come from a peer when stepped locally.
+
 	case pb.MsgHup:
 		if r.preVote {
 			r.hup(campaignPreElection)
@@ -1291,11 +1824,15 @@ func stepLeader(r *raft, m pb.Message) error {
 			// If we are not currently a member of the range (i.e. this node
 			// was removed from the configuration while serving as leader),
 			// drop any new proposals.
-			return ErrProposalDropped
+			return r.dropProposal(ProposalDropNotInConfig)
 		}
 		if r.leadTransferee != None {
 			r.logger.Debugf("%x [term %d] transfer leadership to %x is in progress; dropping proposal", r.id, r.Term, r.leadTransferee)
-			return ErrProposalDropped
+			return r.dropProposal(ProposalDropLeaderTransfer)
+		}
+		if r.applyBehind() {
+			r.logger.Debugf("%x [term %d] apply is too far behind; dropping proposal", r.id, r.Term)
+			return r.dropProposal(ProposalDropApplyBehind)
 		}
 
 		for i := range m.Entries {
@@ -1339,7 +1876,7 @@ func stepLeader(r *raft, m pb.Message) error {
 		}
 
 		if !r.appendEntry(m.Entries...) {
-			return ErrProposalDropped
+			return r.dropProposal(ProposalDropUncommittedSizeLimit)
 		}
 		r.bcastAppend()
 		return nil
@@ -1364,6 +1901,15 @@ func stepLeader(r *raft, m pb.Message) error {
 		return nil
 	case pb.MsgForgetLeader:
 		return nil // noop on leader
+	case pb.MsgTransferLeader:
+		if m.From == None {
+			if m.From = r.pickLeadTransferee(); m.From == None {
+				r.logger.Infof("%x [term %d] found no voter to transfer leadership to", r.id, r.Term)
+				r.dropLeaderTransfer(None)
+				return nil
+			}
+			r.logger.Infof("%x [term %d] picked %x as the leadership transfer target", r.id, r.Term, m.From)
+		}
 	}
 
 	// All other message types require a progress for m.From (pr).
@@ -1378,6 +1924,7 @@ func stepLeader(r *raft, m pb.Message) error {
 		// an MsgAppResp to acknowledge the appended entries in the last Ready.
 
 		pr.RecentActive = true
+		pr.Codecs = m.SupportedCodecs
 
 		if m.Reject {
 			// RejectHint is the suggested next base entry for appending (i.e.
@@ -1500,6 +2047,16 @@ func stepLeader(r *raft, m pb.Message) error {
 				//    log.
 				nextProbeIdx, _ = r.raftLog.findConflictByTerm(m.RejectHint, m.LogTerm)
 			}
+			if len(m.TermBoundaries) != 0 {
+				// The follower also described the terms of its log tail up to
+				// RejectHint. If there are many short divergent terms, probing
+				// once per term would still take many round trips. Instead, look
+				// for the last index at which the terms of both logs are the same,
+				// and probe there directly. By the Log Matching property, the
+				// probe succeeds.
+				nextProbeIdx = min(nextProbeIdx,
+					r.raftLog.findConflictByTermBoundaries(m.TermBoundaries, m.RejectHint))
+			}
 			if pr.MaybeDecrTo(m.Index, nextProbeIdx) {
 				r.logger.Debugf("%x decreased progress of %x to [%s]", r.id, m.From, pr)
 				if pr.State == tracker.StateReplicate {
@@ -1520,6 +2077,7 @@ func stepLeader(r *raft, m pb.Message) error {
 				switch {
 				case pr.State == tracker.StateProbe:
 					pr.BecomeReplicate()
+					r.metrics.ProbeToReplicate(m.From)
 				case pr.State == tracker.StateSnapshot && pr.Match+1 >= r.raftLog.firstIndex():
 					// Note that we don't take into account PendingSnapshot to
 					// enter this branch. No matter at which index a snapshot
@@ -1566,11 +2124,21 @@ func stepLeader(r *raft, m pb.Message) error {
 					r.logger.Infof("%x sent MsgTimeoutNow to %x after received MsgAppResp", r.id, m.From)
 					r.sendTimeoutNow(m.From)
 				}
+				// A learner marked for automatic promotion may have caught up.
+				if pr.IsLearner {
+					r.maybePromoteLearners()
+				}
 			}
 		}
 	case pb.MsgHeartbeatResp:
 		pr.RecentActive = true
 		pr.MsgAppFlowPaused = false
+		pr.Codecs = m.SupportedCodecs
+		if r.leaseTicks > 0 && m.Index != 0 && m.Index <= r.ticks {
+			// The follower promised to support the lease for leaseTicks of its
+			// own clock, starting no earlier than the heartbeat was sent.
+			pr.LeaseExpiry = max(pr.LeaseExpiry, m.Index+r.leaseTicks-r.maxClockSkewTicks)
+		}
 
 		// NB: if the follower is paused (full Inflights), this will still send an
 		// empty append, allowing it to recover from situations in which all the
@@ -1588,8 +2156,21 @@ func stepLeader(r *raft, m pb.Message) error {
 		if pr.Match < r.raftLog.lastIndex() || pr.State == tracker.StateProbe {
 			r.sendAppend(m.From)
 		}
+		// Similarly, resume a streamed snapshot transfer. If the follower hasn't
+		// acknowledged any chunk for an election timeout, the chunks in flight
+		// were likely dropped, so send them again from the last acknowledged one.
+		if pr.State == tracker.StateSnapshot && pr.SnapshotSize > 0 {
+			if pr.SnapshotSent > pr.SnapshotAcked && r.ticks-pr.SnapshotAckTick >= uint64(r.electionTimeout) {
+				r.logger.Debugf("%x resending snapshot chunks to %x [%s]", r.id, m.From, pr)
+				pr.ResendSnapshotChunks()
+				pr.SnapshotAckTick = r.ticks
+			}
+			r.sendSnapshotChunks(m.From, pr)
+		}
 
-		if r.readOnly.option != ReadOnlySafe || len(m.Context) == 0 {
+		// NB: ReadOnlyLeaseBased requests are also acked here if they were
+		// issued while the lease was not valid.
+		if len(m.Context) == 0 {
 			return nil
 		}
 
@@ -1603,6 +2184,15 @@ func stepLeader(r *raft, m pb.Message) error {
 				r.send(resp)
 			}
 		}
+		// Confirm the requests batched while the completed round was in flight.
+		if r.readOnly.batch && !r.readOnly.roundInflight() {
+			if ctx := r.readOnly.startRound(); ctx != nil {
+				r.bcastHeartbeatWithCtx(ctx)
+			}
+		}
+	case pb.MsgSnapChunkResp:
+		pr.RecentActive = true
+		r.handleSnapshotChunkResp(m, pr)
 	case pb.MsgSnapStatus:
 		if pr.State != tracker.StateSnapshot {
 			return nil
@@ -1631,6 +2221,15 @@ func stepLeader(r *raft, m pb.Message) error {
 	case pb.MsgTransferLeader:
 		if pr.IsLearner {
 			r.logger.Debugf("%x is learner. Ignored transferring leadership", r.id)
+			r.dropLeaderTransfer(m.From)
+			return nil
+		}
+		if pr.IsWitness {
+			r.logger.Debugf("%x is witness. Ignored transferring leadership", r.id)
+			r.dropLeaderTransfer(m.From)
+			return nil
+		}
+		if r.ignoreLeadershipRequest(m) {
 			return nil
 		}
 		leadTransferee := m.From
@@ -1639,26 +2238,20 @@ func stepLeader(r *raft, m pb.Message) error {
 			if lastLeadTransferee == leadTransferee {
 				r.logger.Infof("%x [term %d] transfer leadership to %x is in progress, ignores request to same node %x",
 					r.id, r.Term, leadTransferee, leadTransferee)
+				// The request gets the outcome of the transfer in progress.
+				r.leadTransferDups++
 				return nil
 			}
-			r.abortLeaderTransfer()
+			r.abortLeaderTransfer(LeaderTransferSuperseded)
 			r.logger.Infof("%x [term %d] abort previous transferring leadership to %x", r.id, r.Term, lastLeadTransferee)
 		}
 		if leadTransferee == r.id {
 			r.logger.Debugf("%x is already leader. Ignored transferring leadership to self", r.id)
+			r.finishLeaderTransfer(LeaderTransferResult{Target: r.id, Term: r.Term, Outcome: LeaderTransferSucceeded})
 			return nil
 		}
 		// Transfer leadership to third party.
-		r.logger.Infof("%x [term %d] starts to transfer leadership to %x", r.id, r.Term, leadTransferee)
-		// Transfer leadership should be finished in one electionTimeout, so reset r.electionElapsed.
-		r.electionElapsed = 0
-		r.leadTransferee = leadTransferee
-		if pr.Match == r.raftLog.lastIndex() {
-			r.sendTimeoutNow(leadTransferee)
-			r.logger.Infof("%x sends MsgTimeoutNow to %x immediately as %x already has up-to-date log", r.id, leadTransferee, leadTransferee)
-		} else {
-			r.sendAppend(leadTransferee)
-		}
+		r.startLeaderTransfer(leadTransferee, pr)
 	}
 	return nil
 }
@@ -1678,28 +2271,41 @@ func stepCandidate(r *raft, m pb.Message) error {
 	switch m.Type {
 	case pb.MsgProp:
 		r.logger.Infof("%x no leader at term %d; dropping proposal", r.id, r.Term)
-		return ErrProposalDropped
+		return r.dropProposal(ProposalDropNoLeader)
 	case pb.MsgApp:
 		r.becomeFollower(m.Term, m.From) // always m.Term == r.Term
 		r.handleAppendEntries(m)
 	case pb.MsgHeartbeat:
 		r.becomeFollower(m.Term, m.From) // always m.Term == r.Term
 		r.handleHeartbeat(m)
+	case pb.MsgQuiesce:
+		r.becomeFollower(m.Term, m.From) // always m.Term == r.Term
+		r.handleQuiesce(m)
 	case pb.MsgSnap:
 		r.becomeFollower(m.Term, m.From) // always m.Term == r.Term
 		r.handleSnapshot(m)
+	case pb.MsgSnapChunk:
+		r.becomeFollower(m.Term, m.From) // always m.Term == r.Term
+		r.handleSnapshotChunk(m)
 	case myVoteRespType:
 		gr, rj, res := r.poll(m.From, m.Type, !m.Reject)
 		r.logger.Infof("%x has received %d %s votes and %d vote rejections", r.id, gr, m.Type, rj)
 		switch res {
 		case quorum.VoteWon:
 			if r.state == StatePreCandidate {
+				r.metrics.PreVoteWon(r.Term + 1)
 				r.campaign(campaignElection)
 			} else {
+				r.metrics.ElectionWon(r.Term)
 				r.becomeLeader()
 				r.bcastAppend()
 			}
 		case quorum.VoteLost:
+			if r.state == StatePreCandidate {
+				r.metrics.PreVoteLost(r.Term + 1)
+			} else {
+				r.metrics.ElectionLost(r.Term)
+			}
 			// pb.MsgPreVoteResp contains future term of pre-candidate
 			// m.Term > r.Term; reuse r.Term
 			r.becomeFollower(r.Term, None)
@@ -1715,10 +2321,13 @@ func stepFollower(r *raft, m pb.Message) error {
 	case pb.MsgProp:
 		if r.lead == None {
 			r.logger.Infof("%x no leader at term %d; dropping proposal", r.id, r.Term)
-			return ErrProposalDropped
+			return r.dropProposal(ProposalDropNoLeader)
 		} else if r.disableProposalForwarding {
 			r.logger.Infof("%x not forwarding to leader %x at term %d; dropping proposal", r.id, r.lead, r.Term)
-			return ErrProposalDropped
+			return r.dropProposal(ProposalDropForwardingDisabled)
+		} else if r.applyBehind() {
+			r.logger.Debugf("%x [term %d] apply is too far behind; dropping proposal", r.id, r.Term)
+			return r.dropProposal(ProposalDropApplyBehind)
 		}
 		m.To = r.lead
 		r.send(m)
@@ -1730,15 +2339,29 @@ func stepFollower(r *raft, m pb.Message) error {
 		r.electionElapsed = 0
 		r.lead = m.From
 		r.handleHeartbeat(m)
+		r.maybeRequestLeadership()
+	case pb.MsgQuiesce:
+		r.electionElapsed = 0
+		r.lead = m.From
+		r.handleQuiesce(m)
 	case pb.MsgSnap:
 		r.electionElapsed = 0
 		r.lead = m.From
 		r.handleSnapshot(m)
+	case pb.MsgSnapChunk:
+		r.electionElapsed = 0
+		r.lead = m.From
+		r.handleSnapshotChunk(m)
 	case pb.MsgTransferLeader:
 		if r.lead == None {
 			r.logger.Infof("%x no leader at term %d; dropping leader transfer msg", r.id, r.Term)
 			return nil
 		}
+		if m.From == None {
+			// Only the leader can pick the target.
+			r.logger.Infof("%x is not the leader at term %d; dropping leader transfer msg without a target", r.id, r.Term)
+			return nil
+		}
 		m.To = r.lead
 		r.send(m)
 	case pb.MsgForgetLeader:
@@ -1768,7 +2391,7 @@ func stepFollower(r *raft, m pb.Message) error {
 			r.logger.Errorf("%x invalid format of MsgReadIndexResp from %x, entries count: %d", r.id, m.From, len(m.Entries))
 			return nil
 		}
-		r.readStates = append(r.readStates, ReadState{Index: m.Index, RequestCtx: m.Entries[0].Data})
+		r.addReadState(ReadState{Index: m.Index, RequestCtx: m.Entries[0].Data})
 	}
 	return nil
 }
@@ -1784,6 +2407,15 @@ func logSliceFromMsgApp(m *pb.Message) logSlice {
 }
 
 func (r *raft) handleAppendEntries(m pb.Message) {
+	if m.EntriesCodec != 0 {
+		ents, err := r.decompressEntries(m)
+		if err != nil {
+			r.logger.Errorf("%x dropping MsgApp from %x with entries compressed by codec %d: %v",
+				r.id, m.From, m.EntriesCodec, err)
+			return
+		}
+		m.Entries, m.EntriesCodec, m.CompressedEntries = ents, 0, nil
+	}
 	// TODO(pav-kv): construct logSlice up the stack next to receiving the
 	// message, and validate it before taking any action (e.g. bumping term).
 	a := logSliceFromMsgApp(&m)
@@ -1817,19 +2449,102 @@ func (r *raft) handleAppendEntries(m pb.Message) {
 	// LogTerm in this response in any case, so we don't verify it here.
 	hintIndex := min(m.Index, r.raftLog.lastIndex())
 	hintIndex, hintTerm := r.raftLog.findConflictByTerm(hintIndex, m.LogTerm)
+	// Additionally, describe the terms of the uncommitted log tail up to the hint,
+	// so that the leader can find the match in one round trip. Leaders that don't
+	// know about this field fall back to using the hint.
 	r.send(pb.Message{
-		To:         m.From,
-		Type:       pb.MsgAppResp,
-		Index:      m.Index,
-		Reject:     true,
-		RejectHint: hintIndex,
-		LogTerm:    hintTerm,
+		To:             m.From,
+		Type:           pb.MsgAppResp,
+		Index:          m.Index,
+		Reject:         true,
+		RejectHint:     hintIndex,
+		LogTerm:        hintTerm,
+		TermBoundaries: r.raftLog.termBoundaries(r.raftLog.committed, hintIndex, maxRejectTermBoundaries),
 	})
 }
 
 func (r *raft) handleHeartbeat(m pb.Message) {
 	r.raftLog.commitTo(m.Commit)
-	r.send(pb.Message{To: m.From, Type: pb.MsgHeartbeatResp, Context: m.Context})
+	resp := pb.Message{To: m.From, Type: pb.MsgHeartbeatResp, Context: m.Context}
+	if r.leaseTicks > 0 && m.Index != 0 {
+		// The leader asks for lease support. Promise not to vote for anyone else
+		// for leaseTicks, and let the leader know which heartbeat this is for.
+		r.leaseSupportExpiry = max(r.leaseSupportExpiry, r.ticks+r.leaseTicks)
+		resp.Index = m.Index
+	}
+	r.send(resp)
+}
+
+// handleQuiesce quiesces the follower if its log matches the leader's.
+// Otherwise, the follower asks the leader to wake up and catch it up.
+func (r *raft) handleQuiesce(m pb.Message) {
+	if r.raftLog.lastIndex() != m.Index || !r.raftLog.matchTerm(entryID{term: m.LogTerm, index: m.Index}) {
+		r.logger.Debugf("%x [lastindex: %d] can't quiesce at [logterm: %d, index: %d]",
+			r.id, r.raftLog.lastIndex(), m.LogTerm, m.Index)
+		r.send(pb.Message{To: m.From, Type: pb.MsgUnquiesce})
+		return
+	}
+	r.raftLog.commitTo(m.Commit)
+	r.quiesced = true
+	r.logger.Debugf("%x quiesced at term %d", r.id, r.Term)
+}
+
+// maybeQuiesce quiesces the leader and its followers if all of them have caught
+// up with the leader's log, and there is nothing else in progress. Returns true
+// if the leader has quiesced.
+func (r *raft) maybeQuiesce() bool {
+	if !r.quiescence || r.state != StateLeader || r.leadTransferee != None {
+		return false
+	}
+	last := r.raftLog.lastEntryID()
+	if r.raftLog.committed != last.index || len(r.readOnly.readIndexQueue) != 0 || len(r.pendingReadIndexMessages) != 0 {
+		return false
+	}
+	caughtUp := true
+	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
+		caughtUp = caughtUp && pr.Match == last.index
+	})
+	if !caughtUp {
+		return false
+	}
+	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
+		if id == r.id {
+			return
+		}
+		r.send(pb.Message{To: id, Type: pb.MsgQuiesce, Index: last.index, LogTerm: last.term, Commit: r.raftLog.committed})
+		pr.SentCommit(r.raftLog.committed)
+	})
+	r.quiesced = true
+	r.logger.Debugf("%x quiesced at term %d", r.id, r.Term)
+	return true
+}
+
+// wakesUp returns true if the given message wakes up a quiesced peer. The
+// responses to the leader's messages don't, since they are the leftovers of
+// the traffic that preceded the quiescence. Neither do the local storage
+// messages.
+func wakesUp(m pb.Message) bool {
+	switch m.Type {
+	case pb.MsgQuiesce, pb.MsgAppResp, pb.MsgHeartbeatResp:
+		return false
+	}
+	return !IsLocalMsgTarget(m.From)
+}
+
+// unquiesce wakes up the quiesced peer, and restarts its timers. The leader
+// sends heartbeats right away to wake up its followers. A follower notifies
+// its leader if requested, so that the leader resumes sending heartbeats.
+func (r *raft) unquiesce(notifyLeader bool) {
+	r.quiesced = false
+	r.electionElapsed = 0
+	r.heartbeatElapsed = 0
+	r.logger.Debugf("%x woke up at term %d", r.id, r.Term)
+	switch {
+	case r.state == StateLeader:
+		r.bcastHeartbeat()
+	case notifyLeader && r.lead != None:
+		r.send(pb.Message{To: r.lead, Type: pb.MsgUnquiesce})
+	}
 }
 
 func (r *raft) handleSnapshot(m pb.Message) {
@@ -1843,6 +2558,7 @@ func (r *raft) handleSnapshot(m pb.Message) {
 	if r.restore(s) {
 		r.logger.Infof("%x [commit: %d] restored snapshot [index: %d, term: %d]",
 			r.id, r.raftLog.committed, sindex, sterm)
+		r.metrics.SnapshotApplied(sindex)
 		r.send(pb.Message{To: m.From, Type: pb.MsgAppResp, Index: r.raftLog.lastIndex()})
 	} else {
 		r.logger.Infof("%x [commit: %d] ignored snapshot [index: %d, term: %d]",
@@ -1915,9 +2631,12 @@ func (r *raft) restore(s pb.Snapshot) bool {
 	}
 
 	r.raftLog.restore(s)
+	r.proposals.restore(s.Metadata.Index)
 
 	// Reset the configuration and add the (potentially updated) peers in anew.
-	r.trk = tracker.MakeProgressTracker(r.trk.MaxInflight, r.trk.MaxInflightBytes)
+	prev := r.trk
+	r.trk = tracker.MakeProgressTracker(prev.MaxInflight, prev.MaxInflightBytes)
+	r.trk.MaxAppendBytesPerTick, r.trk.MaxAppendBurstBytes = prev.MaxAppendBytesPerTick, prev.MaxAppendBurstBytes
 	cfg, trk, err := confchange.Restore(confchange.Changer{
 		Tracker:   r.trk,
 		LastIndex: r.raftLog.lastIndex(),
@@ -1941,10 +2660,13 @@ func (r *raft) restore(s pb.Snapshot) bool {
 // which is true when its own id is in progress list.
 func (r *raft) promotable() bool {
 	pr := r.trk.Progress[r.id]
-	return pr != nil && !pr.IsLearner && !r.raftLog.hasNextOrInProgressSnapshot()
+	return pr != nil && !pr.IsLearner && !pr.IsWitness && !r.raftLog.hasNextOrInProgressSnapshot()
 }
 
 func (r *raft) applyConfChange(cc pb.ConfChangeV2) pb.ConfState {
+	if r.quiesced {
+		r.unquiesce(false /* notifyLeader */)
+	}
 	cfg, trk, err := func() (tracker.Config, tracker.ProgressMap, error) {
 		changer := confchange.Changer{
 			Tracker:   r.trk,
@@ -1985,9 +2707,11 @@ func (r *raft) switchToConfig(cfg tracker.Config, trk tracker.ProgressMap) pb.Co
 	// Update whether the node itself is a learner, resetting to false when the
 	// node is removed.
 	r.isLearner = ok && pr.IsLearner
+	r.isWitness = ok && pr.IsWitness
 
-	if (!ok || r.isLearner) && r.state == StateLeader {
-		// This node is leader and was removed or demoted, step down if requested.
+	if (!ok || r.isLearner || r.isWitness) && r.state == StateLeader {
+		// This node is leader and was removed, demoted, or turned into a witness,
+		// step down if requested.
 		//
 		// We prevent demotions at the time writing but hypothetically we handle
 		// them the same way as removing the leader.
@@ -2024,7 +2748,7 @@ func (r *raft) switchToConfig(cfg tracker.Config, trk tracker.ProgressMap) pb.Co
 	}
 	// If the leadTransferee was removed or demoted, abort the leadership transfer.
 	if _, tOK := r.trk.Config.Voters.IDs()[r.leadTransferee]; !tOK && r.leadTransferee != 0 {
-		r.abortLeaderTransfer()
+		r.abortLeaderTransfer(LeaderTransferTargetRemoved)
 	}
 
 	return cs
@@ -2047,15 +2771,34 @@ func (r *raft) pastElectionTimeout() bool {
 }
 
 func (r *raft) resetRandomizedElectionTimeout() {
-	r.randomizedElectionTimeout = r.electionTimeout + globalRand.Intn(r.electionTimeout)
+	rnd := r.rand
+	if rnd == nil {
+		rnd = globalRand
+	}
+	r.randomizedElectionTimeout = r.electionTimeout + rnd.Intn(r.electionTimeout) + r.priorityElectionDelay()
 }
 
 func (r *raft) sendTimeoutNow(to uint64) {
+	// The transfer target campaigns regardless of the lease promises, so the
+	// lease can't be trusted for the rest of the term.
+	r.leaseRevoked = true
 	r.send(pb.Message{To: to, Type: pb.MsgTimeoutNow})
 }
 
-func (r *raft) abortLeaderTransfer() {
-	r.leadTransferee = None
+// supportingLease returns true if this peer has promised to support a leader
+// lease which has not expired yet.
+func (r *raft) supportingLease() bool {
+	return r.ticks < r.leaseSupportExpiry
+}
+
+// leaseExpiry returns the time (in ticks) until which the leader holds a lease
+// supported by a quorum of voters. Returns 0 if leases are disabled, this peer
+// is not the leader, or the lease can't be used due to a leadership transfer.
+func (r *raft) leaseExpiry() uint64 {
+	if r.leaseTicks == 0 || r.state != StateLeader || r.leadTransferee != None || r.leaseRevoked {
+		return 0
+	}
+	return r.trk.LeaseExpiry()
 }
 
 // committedEntryInCurrentTerm return true if the peer has committed an entry in its term.
@@ -2069,7 +2812,7 @@ func (r *raft) committedEntryInCurrentTerm() bool {
 // itself, a blank value will be returned.
 func (r *raft) responseToReadIndexReq(req pb.Message, readIndex uint64) pb.Message {
 	if req.From == None || req.From == r.id {
-		r.readStates = append(r.readStates, ReadState{
+		r.addReadState(ReadState{
 			Index:      readIndex,
 			RequestCtx: req.Entries[0].Data,
 		})
@@ -2143,13 +2886,25 @@ func sendMsgReadIndexResponse(r *raft, m pb.Message) {
 	// thinking: use an internally defined context instead of the user given context.
 	// We can express this in terms of the term and index instead of a user-supplied value.
 	// This would allow multiple reads to piggyback on the same message.
-	switch r.readOnly.option {
+	option := r.readOnly.option
+	if option == ReadOnlyLeaseBased && r.leaseTicks > 0 && r.ticks >= r.leaseExpiry() {
+		// The lease is not valid, so confirm the leadership with a quorum.
+		r.logger.Debugf("%x [term %d] leader lease is not valid; falling back to quorum read", r.id, r.Term)
+		option = ReadOnlySafe
+	}
+	switch option {
 	// If more than the local vote is needed, go through a full broadcast.
 	case ReadOnlySafe:
 		r.readOnly.addRequest(r.raftLog.committed, m)
 		// The local node automatically acks the request.
 		r.readOnly.recvAck(r.id, m.Entries[0].Data)
-		r.bcastHeartbeatWithCtx(m.Entries[0].Data)
+		if !r.readOnly.batch {
+			r.bcastHeartbeatWithCtx(m.Entries[0].Data)
+		} else if !r.readOnly.roundInflight() {
+			r.bcastHeartbeatWithCtx(r.readOnly.startRound())
+		}
+		// Otherwise, the request is confirmed by the round that starts when
+		// the outstanding one completes.
 	case ReadOnlyLeaseBased:
 		if resp := r.responseToReadIndexReq(m, r.raftLog.committed); resp.To != None {
 			r.send(resp)
@@ -0,0 +1,230 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// leaseConfig enables leader leases of 5 ticks, of which 1 tick is reserved for
+// clock skew.
+func leaseConfig(c *Config) {
+	c.ReadOnlyOption = ReadOnlyLeaseBased
+	c.LeaseTicks = 5
+	c.MaxClockSkewTicks = 1
+}
+
+func TestLeaseConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		lease, skew int
+		readOnly    ReadOnlyOption
+		wantErr     bool
+	}{
+		{lease: 0, skew: 0, readOnly: ReadOnlySafe},
+		{lease: 5, skew: 0, readOnly: ReadOnlyLeaseBased},
+		{lease: 10, skew: 9, readOnly: ReadOnlyLeaseBased},
+		{lease: 0, skew: 0, readOnly: ReadOnlyLeaseBased, wantErr: true},
+		{lease: -1, skew: 0, readOnly: ReadOnlySafe, wantErr: true},
+		{lease: 11, skew: 0, readOnly: ReadOnlySafe, wantErr: true},
+		{lease: 5, skew: 5, readOnly: ReadOnlySafe, wantErr: true},
+		{lease: 5, skew: -1, readOnly: ReadOnlySafe, wantErr: true},
+	} {
+		cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1)))
+		cfg.LeaseTicks, cfg.MaxClockSkewTicks = tt.lease, tt.skew
+		cfg.ReadOnlyOption = tt.readOnly
+		err := cfg.validate()
+		require.Equal(t, tt.wantErr, err != nil, "%+v: %v", tt, err)
+	}
+}
+
+// TestLeaseExpiry checks that the leader's lease is supported by a quorum of
+// the followers' heartbeat responses, and shortened by the clock skew.
+func TestLeaseExpiry(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), leaseConfig)
+	r.becomeCandidate()
+	r.becomeLeader()
+	require.Zero(t, r.leaseExpiry())
+
+	r.tick()
+	r.tick()
+	msgs := r.readMessages()
+	require.NotEmpty(t, msgs)
+	for _, m := range msgs {
+		if m.Type == pb.MsgHeartbeat {
+			require.NotZero(t, m.Index)
+			require.LessOrEqual(t, m.Index, r.ticks)
+		}
+	}
+
+	// A single follower's support forms a quorum with the leader.
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: 1}))
+	require.Equal(t, uint64(5), r.leaseExpiry())
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: 2}))
+	require.Equal(t, uint64(6), r.leaseExpiry())
+	// Stale responses don't shorten the lease, and responses from the future
+	// are ignored.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: 1}))
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: 100}))
+	require.Equal(t, uint64(6), r.leaseExpiry())
+
+	st := getBasicStatus(r)
+	require.Equal(t, LeaseStatus{Now: 2, Expiry: 6, SupportExpiry: 5}, st.Lease)
+	require.True(t, st.Lease.Valid())
+
+	for r.ticks < 6 {
+		r.tick()
+	}
+	require.False(t, getBasicStatus(r).Lease.Valid())
+}
+
+// TestLeaseRead checks that reads are served locally only while the lease is
+// valid.
+func TestLeaseRead(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), leaseConfig)
+	r.becomeCandidate()
+	r.becomeLeader()
+	// Commit the entry of the leader's term.
+	for _, id := range []uint64{2, 3} {
+		require.NoError(t, r.Step(pb.Message{From: id, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: r.raftLog.lastIndex()}))
+	}
+	r.advanceMessagesAfterAppend()
+	r.tick()
+	r.readMessages()
+
+	read := func(ctx string) {
+		require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: []byte(ctx)}}}))
+	}
+	// Without lease support, the read goes through a quorum.
+	read("a")
+	require.Empty(t, r.readStates)
+	msgs := r.readMessages()
+	require.Len(t, msgs, 2)
+	for _, m := range msgs {
+		require.Equal(t, pb.MsgHeartbeat, m.Type)
+		require.Equal(t, []byte("a"), m.Context)
+	}
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: msgs[0].Index, Context: []byte("a")}))
+	require.Len(t, r.readStates, 1)
+	r.readStates = nil
+
+	// The acked heartbeat established the lease, so reads are served locally.
+	read("b")
+	require.Len(t, r.readStates, 1)
+	require.Empty(t, r.readMessages())
+	r.readStates = nil
+
+	for r.ticks < r.leaseExpiry() {
+		r.tick()
+	}
+	r.readMessages()
+	read("c")
+	require.Empty(t, r.readStates)
+	require.NotEmpty(t, r.readMessages())
+}
+
+// TestLeaseSupport checks that a follower doesn't vote for other candidates
+// while it supports a leader's lease, unless leadership is being transferred.
+func TestLeaseSupport(t *testing.T) {
+	vote := func(r *raft, ctx string) pb.Message {
+		return pb.Message{From: 3, To: r.id, Term: r.Term + 1, Type: pb.MsgVote, LogTerm: 1, Index: 10, Context: []byte(ctx)}
+	}
+
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), leaseConfig)
+	r.becomeFollower(1, 1)
+	// A fresh peer may have supported a lease before a restart.
+	require.NoError(t, r.Step(vote(r, "")))
+	require.Equal(t, uint64(1), r.Term)
+	require.Empty(t, r.readMessages())
+	for i := 0; i < 5; i++ {
+		r.tick()
+	}
+
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgHeartbeat, Index: 3}))
+	msgs := r.readMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.MsgHeartbeatResp, msgs[0].Type)
+	require.Equal(t, uint64(3), msgs[0].Index)
+	require.Equal(t, uint64(10), r.leaseSupportExpiry)
+
+	require.NoError(t, r.Step(vote(r, "")))
+	require.Equal(t, uint64(1), r.Term)
+	require.Empty(t, r.readMessages())
+	// The peer doesn't campaign on its own either.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 2, Type: pb.MsgHup}))
+	require.Equal(t, StateFollower, r.state)
+
+	// A leadership transfer overrides the promise.
+	require.NoError(t, r.Step(vote(r, string(campaignTransfer))))
+	require.Equal(t, uint64(2), r.Term)
+	require.Equal(t, uint64(3), r.Vote)
+
+	// Once the promise expires, the peer votes again.
+	r = newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), leaseConfig)
+	r.becomeFollower(1, 1)
+	for i := 0; i < 5; i++ {
+		r.tick()
+	}
+	require.NoError(t, r.Step(vote(r, "")))
+	require.Equal(t, uint64(2), r.Term)
+	require.Equal(t, uint64(3), r.Vote)
+}
+
+// TestLeaseTransferLeader checks that the leader stops using its lease once it
+// starts a leadership transfer, and doesn't use it for the rest of the term
+// once the transfer target was told to campaign, even if the transfer is
+// aborted.
+func TestLeaseTransferLeader(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), leaseConfig)
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.tick()
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: 1}))
+	require.NotZero(t, r.leaseExpiry())
+
+	// Follower 3 is not caught up, so the transfer waits for it.
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, uint64(3), r.leadTransferee)
+	require.Zero(t, r.leaseExpiry())
+	require.False(t, getBasicStatus(r).Lease.Valid())
+	// Aborting the transfer before MsgTimeoutNow was sent restores the lease.
+	r.abortLeaderTransfer(LeaderTransferTimeout)
+	require.NotZero(t, r.leaseExpiry())
+
+	// Follower 2 is caught up, so MsgTimeoutNow is sent immediately.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: r.raftLog.lastIndex()}))
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgTransferLeader}))
+	var sent bool
+	for _, m := range r.readMessages() {
+		sent = sent || m.Type == pb.MsgTimeoutNow
+	}
+	require.True(t, sent)
+	require.Zero(t, r.leaseExpiry())
+
+	// The transfer times out, but the lease is not restored, even with fresh
+	// support from the followers.
+	for i := 0; i < r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.Equal(t, StateLeader, r.state)
+	require.Equal(t, None, r.leadTransferee)
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Index: r.ticks}))
+	require.Zero(t, r.leaseExpiry())
+}
@@ -551,17 +551,18 @@ func TestFollowerCheckMsgApp(t *testing.T) {
 		wreject     bool
 		wrejectHint uint64
 		wlogterm    uint64
+		wbounds     []pb.TermBoundary
 	}{
 		// match with committed entries
-		{0, 0, 1, false, 0, 0},
-		{ents[0].Term, ents[0].Index, 1, false, 0, 0},
+		{0, 0, 1, false, 0, 0, nil},
+		{ents[0].Term, ents[0].Index, 1, false, 0, 0, nil},
 		// match with uncommitted entries
-		{ents[1].Term, ents[1].Index, 2, false, 0, 0},
+		{ents[1].Term, ents[1].Index, 2, false, 0, 0, nil},
 
 		// unmatch with existing entry
-		{ents[0].Term, ents[1].Index, ents[1].Index, true, 1, 1},
+		{ents[0].Term, ents[1].Index, ents[1].Index, true, 1, 1, nil},
 		// unexisting entry
-		{ents[1].Term + 1, ents[1].Index + 1, ents[1].Index + 1, true, 2, 2},
+		{ents[1].Term + 1, ents[1].Index + 1, ents[1].Index + 1, true, 2, 2, []pb.TermBoundary{{Term: 2, Index: 2}}},
 	}
 	for i, tt := range tests {
 		storage := newTestMemoryStorage(withPeers(1, 2, 3))
@@ -573,7 +574,7 @@ func TestFollowerCheckMsgApp(t *testing.T) {
 		r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgApp, Term: 2, LogTerm: tt.term, Index: tt.index})
 
 		assert.Equal(t, []pb.Message{
-			{From: 1, To: 2, Type: pb.MsgAppResp, Term: 2, Index: tt.windex, Reject: tt.wreject, RejectHint: tt.wrejectHint, LogTerm: tt.wlogterm},
+			{From: 1, To: 2, Type: pb.MsgAppResp, Term: 2, Index: tt.windex, Reject: tt.wreject, RejectHint: tt.wrejectHint, LogTerm: tt.wlogterm, TermBoundaries: tt.wbounds},
 		}, r.readMessages(), "#%d", i)
 	}
 }
@@ -0,0 +1,118 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// quiescenceConfig enables quiescence, along with CheckQuorum which a quiesced
+// leader must not step down for.
+func quiescenceConfig(c *Config) {
+	c.Quiescence = true
+	c.CheckQuorum = true
+}
+
+// TestQuiesceLaggingFollower checks that the leader doesn't quiesce while a
+// follower hasn't caught up with its log.
+func TestQuiesceLaggingFollower(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), quiescenceConfig)
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	last := r.raftLog.lastIndex()
+	r.trk.Progress[1].MaybeUpdate(last)
+	r.trk.Progress[2].MaybeUpdate(last)
+	require.True(t, r.maybeCommit())
+
+	r.tick()
+	require.False(t, r.quiesced)
+	for _, m := range r.readMessages() {
+		require.Equal(t, pb.MsgHeartbeat, m.Type)
+	}
+
+	r.trk.Progress[3].MaybeUpdate(last)
+	r.tick()
+	require.True(t, r.quiesced)
+	msgs := r.readMessages()
+	require.Len(t, msgs, 2)
+	for _, m := range msgs {
+		require.Equal(t, pb.MsgQuiesce, m.Type)
+		require.Equal(t, last, m.Index)
+		require.Equal(t, r.Term, m.LogTerm)
+		require.Equal(t, last, m.Commit)
+	}
+
+	// A quiesced leader doesn't send heartbeats, and doesn't step down due to
+	// CheckQuorum.
+	for i := 0; i < 2*r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.Empty(t, r.readMessages())
+	require.Equal(t, StateLeader, r.state)
+	require.True(t, r.quiesced)
+
+	// Late responses to the leader's messages don't wake it up.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp}))
+	require.True(t, r.quiesced)
+}
+
+// TestQuiesceFollowerLogMismatch checks that a follower whose log doesn't match
+// the leader's doesn't quiesce, and asks the leader to wake up.
+func TestQuiesceFollowerLogMismatch(t *testing.T) {
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), quiescenceConfig)
+	r.becomeFollower(1, 1)
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgQuiesce, Index: 1, LogTerm: 1, Commit: 1}))
+	require.False(t, r.quiesced)
+	require.Equal(t, []pb.Message{{From: 2, To: 1, Term: 1, Type: pb.MsgUnquiesce}}, r.readMessages())
+
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgApp, Entries: []pb.Entry{{Index: 1, Term: 1}}}))
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgQuiesce, Index: 1, LogTerm: 1, Commit: 1}))
+	require.True(t, r.quiesced)
+	require.Empty(t, r.readMessages())
+	require.Equal(t, uint64(1), r.raftLog.committed)
+
+	for i := 0; i < 2*r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.Equal(t, StateFollower, r.state)
+	require.True(t, r.quiesced)
+}
+
+// TestQuiesceFollowerVote checks that a quiesced follower that ignores a vote
+// request due to CheckQuorum wakes up, and checks in with the leader.
+func TestQuiesceFollowerVote(t *testing.T) {
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), quiescenceConfig)
+	r.becomeFollower(1, 1)
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 2, Term: 1, Type: pb.MsgQuiesce}))
+	require.True(t, r.quiesced)
+
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 2, Term: 2, Type: pb.MsgVote}))
+	require.False(t, r.quiesced)
+	require.Equal(t, uint64(1), r.Term)
+	require.Equal(t, []pb.Message{{From: 2, To: 1, Term: 1, Type: pb.MsgUnquiesce}}, r.readMessages())
+
+	// The follower campaigns after the election timeout, unless it hears from
+	// the leader.
+	for i := 0; i < 2*r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.NotEqual(t, StateFollower, r.state)
+}
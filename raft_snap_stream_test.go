@@ -0,0 +1,402 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+	"go.etcd.io/raft/v3/tracker"
+)
+
+// testSnapshotSource serves the data of a single snapshot.
+type testSnapshotSource struct {
+	meta pb.SnapshotMetadata
+	data []byte
+}
+
+func (s *testSnapshotSource) SnapshotInfo() (pb.SnapshotMetadata, uint64, error) {
+	return s.meta, uint64(len(s.data)), nil
+}
+
+func (s *testSnapshotSource) SnapshotChunk(meta pb.SnapshotMetadata, offset, size uint64) ([]byte, error) {
+	if meta.Index != s.meta.Index {
+		return nil, ErrSnapOutOfDate
+	}
+	return s.data[offset : offset+size], nil
+}
+
+// testSnapshotHistory serves the data of several snapshots, of which the last
+// one is the most recent.
+type testSnapshotHistory struct {
+	snaps []testSnapshotSource
+}
+
+func (h *testSnapshotHistory) SnapshotInfo() (pb.SnapshotMetadata, uint64, error) {
+	return h.snaps[len(h.snaps)-1].SnapshotInfo()
+}
+
+func (h *testSnapshotHistory) SnapshotChunk(meta pb.SnapshotMetadata, offset, size uint64) ([]byte, error) {
+	for i := range h.snaps {
+		if h.snaps[i].meta.Index == meta.Index {
+			return h.snaps[i].SnapshotChunk(meta, offset, size)
+		}
+	}
+	return nil, ErrSnapOutOfDate
+}
+
+// testSnapshotSink accumulates the data of the received snapshot.
+type testSnapshotSink struct {
+	data []byte
+	err  error
+}
+
+func (s *testSnapshotSink) WriteSnapshotChunk(_ pb.SnapshotMetadata, offset uint64, data []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.data = append(s.data[:offset], data...)
+	return nil
+}
+
+var testingSnapData = []byte("0123456789")
+
+// snapStreamConfig makes the leader stream the snapshots of the given source
+// in chunks of 4 bytes, with at most 2 chunks in flight.
+func snapStreamConfig(src SnapshotSource) func(*Config) {
+	return func(c *Config) {
+		c.SnapshotSource = src
+		c.SnapshotChunkSize = 4
+		c.MaxInflightSnapshotChunks = 2
+	}
+}
+
+// sendSnapshot forces the leader to send a snapshot to the given follower.
+func sendSnapshot(t *testing.T, r *raft, to uint64) {
+	t.Helper()
+	r.trk.Progress[to].Next = r.raftLog.firstIndex()
+	require.NoError(t, r.Step(pb.Message{From: to, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: r.trk.Progress[to].Next - 1, Reject: true}))
+}
+
+// requireSnapChunks checks that the messages are snapshot chunks at the given
+// offsets.
+func requireSnapChunks(t *testing.T, msgs []pb.Message, offsets ...uint64) {
+	t.Helper()
+	require.Len(t, msgs, len(offsets))
+	for i, m := range msgs {
+		require.Equal(t, pb.MsgSnapChunk, m.Type)
+		require.Equal(t, offsets[i], m.Index)
+		require.Equal(t, uint64(len(testingSnapData)), m.RejectHint)
+		require.Equal(t, testingSnap.Metadata, m.Snapshot.Metadata)
+		end := min(offsets[i]+4, uint64(len(testingSnapData)))
+		require.Equal(t, testingSnapData[offsets[i]:end], m.Snapshot.Data)
+	}
+}
+
+func snapChunkResp(r *raft, offset uint64) pb.Message {
+	return pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgSnapChunkResp, Index: offset, RejectHint: testingSnap.Metadata.Index}
+}
+
+// TestSnapshotStreamWindow checks that the leader limits the number of chunks
+// in flight, and sends more of them as they are acknowledged.
+func TestSnapshotStreamWindow(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	r := newTestRaft(1, 10, 1, storage, snapStreamConfig(&testSnapshotSource{meta: testingSnap.Metadata, data: testingSnapData}))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	sendSnapshot(t, r, 2)
+	pr := r.trk.Progress[2]
+	require.Equal(t, tracker.StateSnapshot, pr.State)
+	require.Equal(t, testingSnap.Metadata.Index, pr.PendingSnapshot)
+	requireSnapChunks(t, r.readMessages(), 0, 4)
+	require.Equal(t, uint64(8), pr.SnapshotSent)
+
+	require.NoError(t, r.Step(snapChunkResp(r, 4)))
+	requireSnapChunks(t, r.readMessages(), 8)
+	require.NoError(t, r.Step(snapChunkResp(r, 8)))
+	require.NoError(t, r.Step(snapChunkResp(r, 10)))
+	require.Empty(t, r.readMessages())
+	require.Equal(t, uint64(10), pr.SnapshotAcked)
+	require.Equal(t, uint64(3), pr.SnapshotChunksAcked)
+
+	// The follower acknowledges the restored snapshot like a regular one.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: testingSnap.Metadata.Index}))
+	require.Equal(t, tracker.StateReplicate, pr.State)
+	require.Equal(t, testingSnap.Metadata.Index, pr.Match)
+	require.Zero(t, pr.SnapshotSize)
+}
+
+// TestSnapshotStreamResume checks that the leader resumes the transfer from
+// the offset acknowledged by the follower.
+func TestSnapshotStreamResume(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	r := newTestRaft(1, 10, 1, storage, snapStreamConfig(&testSnapshotSource{meta: testingSnap.Metadata, data: testingSnapData}))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	sendSnapshot(t, r, 2)
+	pr := r.trk.Progress[2]
+	requireSnapChunks(t, r.readMessages(), 0, 4)
+
+	// The chunks in flight are lost. The transfer is resumed from the last
+	// acknowledged offset upon a heartbeat response after an election timeout.
+	for i := 0; i < r.electionTimeout; i++ {
+		r.tick()
+	}
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp}))
+	requireSnapChunks(t, r.readMessages(), 0, 4)
+
+	// The follower has received only the first chunk.
+	require.NoError(t, r.Step(snapChunkResp(r, 4)))
+	requireSnapChunks(t, r.readMessages(), 8)
+	// A duplicate acknowledgement doesn't rewind the transfer.
+	require.NoError(t, r.Step(snapChunkResp(r, 4)))
+	require.Empty(t, r.readMessages())
+	// The follower has restarted and lost the data.
+	require.NoError(t, r.Step(snapChunkResp(r, 0)))
+	requireSnapChunks(t, r.readMessages(), 0, 4)
+	require.Equal(t, uint64(8), pr.SnapshotSent)
+	require.Equal(t, uint64(0), pr.SnapshotAcked)
+
+	// Responses to another snapshot are ignored.
+	resp := snapChunkResp(r, 8)
+	resp.RejectHint = 5
+	require.NoError(t, r.Step(resp))
+	require.Empty(t, r.readMessages())
+	require.Equal(t, uint64(0), pr.SnapshotAcked)
+}
+
+// TestSnapshotStreamHeartbeatResp checks that the heartbeat responses received
+// while the chunks are in flight don't make the leader send them again.
+func TestSnapshotStreamHeartbeatResp(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	r := newTestRaft(1, 10, 1, storage, snapStreamConfig(&testSnapshotSource{meta: testingSnap.Metadata, data: testingSnapData}))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	sendSnapshot(t, r, 2)
+	pr := r.trk.Progress[2]
+	requireSnapChunks(t, r.readMessages(), 0, 4)
+
+	for i := 0; i < r.electionTimeout-1; i++ {
+		r.tick()
+		r.readMessages()
+		require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp}))
+		require.Empty(t, r.readMessages())
+	}
+	require.Equal(t, uint64(8), pr.SnapshotSent)
+
+	// The acknowledgement restarts the retransmit timeout.
+	require.NoError(t, r.Step(snapChunkResp(r, 4)))
+	requireSnapChunks(t, r.readMessages(), 8)
+	r.tick()
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp}))
+	require.Empty(t, r.readMessages())
+}
+
+// TestSnapshotStreamReject checks that the leader aborts the transfer if the
+// follower rejects it.
+func TestSnapshotStreamReject(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	r := newTestRaft(1, 10, 1, storage, snapStreamConfig(&testSnapshotSource{meta: testingSnap.Metadata, data: testingSnapData}))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	sendSnapshot(t, r, 2)
+	pr := r.trk.Progress[2]
+	r.readMessages()
+
+	resp := snapChunkResp(r, 0)
+	resp.Reject = true
+	require.NoError(t, r.Step(resp))
+	require.Equal(t, tracker.StateProbe, pr.State)
+	require.Zero(t, pr.PendingSnapshot)
+	require.True(t, pr.MsgAppFlowPaused)
+	require.Empty(t, r.readMessages())
+}
+
+// TestSnapshotStreamNewerSnapshot checks that a follower keeps receiving the
+// snapshot it was sent, while the leader streams a more recent snapshot to
+// another follower.
+func TestSnapshotStreamNewerSnapshot(t *testing.T) {
+	snap := pb.SnapshotMetadata{Index: 11, Term: 11, ConfState: pb.ConfState{Voters: []uint64{1, 2, 3}}}
+	newSnap := pb.SnapshotMetadata{Index: 12, Term: 11, ConfState: snap.ConfState}
+	src := &testSnapshotHistory{snaps: []testSnapshotSource{{meta: snap, data: testingSnapData}}}
+	storage := newTestMemoryStorage(withPeers(1, 2, 3))
+	require.NoError(t, storage.ApplySnapshot(pb.Snapshot{Metadata: snap}))
+	r := newTestRaft(1, 10, 1, storage, snapStreamConfig(src))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+
+	requireChunk := func(m pb.Message, to uint64, meta pb.SnapshotMetadata, offset uint64) {
+		t.Helper()
+		require.Equal(t, pb.MsgSnapChunk, m.Type)
+		require.Equal(t, to, m.To)
+		require.Equal(t, meta, m.Snapshot.Metadata)
+		require.Equal(t, offset, m.Index)
+	}
+	ack := func(id uint64, meta pb.SnapshotMetadata, offset uint64) {
+		t.Helper()
+		require.NoError(t, r.Step(pb.Message{From: id, To: 1, Term: r.Term, Type: pb.MsgSnapChunkResp, Index: offset, RejectHint: meta.Index}))
+	}
+
+	sendSnapshot(t, r, 2)
+	msgs := r.readMessages()
+	require.Len(t, msgs, 2)
+	requireChunk(msgs[0], 2, snap, 0)
+	requireChunk(msgs[1], 2, snap, 4)
+
+	// A more recent snapshot is streamed to follower 3.
+	src.snaps = append(src.snaps, testSnapshotSource{meta: newSnap, data: []byte("abcdefghijkl")})
+	sendSnapshot(t, r, 3)
+	msgs = r.readMessages()
+	require.Len(t, msgs, 2)
+	requireChunk(msgs[0], 3, newSnap, 0)
+	requireChunk(msgs[1], 3, newSnap, 4)
+
+	// Both transfers continue where they are.
+	ack(2, snap, 4)
+	msgs = r.readMessages()
+	require.Len(t, msgs, 1)
+	requireChunk(msgs[0], 2, snap, 8)
+	require.Equal(t, testingSnapData[8:], msgs[0].Snapshot.Data)
+	ack(3, newSnap, 4)
+	msgs = r.readMessages()
+	require.Len(t, msgs, 1)
+	requireChunk(msgs[0], 3, newSnap, 8)
+	require.Equal(t, []byte("ijkl"), msgs[0].Snapshot.Data)
+	require.Equal(t, snap.Index, r.trk.Progress[2].PendingSnapshot)
+	require.Equal(t, newSnap.Index, r.trk.Progress[3].PendingSnapshot)
+}
+
+func snapChunk(offset uint64) pb.Message {
+	end := min(offset+4, uint64(len(testingSnapData)))
+	return pb.Message{
+		From: 1, To: 2, Term: 1, Type: pb.MsgSnapChunk,
+		Index:      offset,
+		RejectHint: uint64(len(testingSnapData)),
+		Snapshot:   &pb.Snapshot{Metadata: testingSnap.Metadata, Data: testingSnapData[offset:end]},
+	}
+}
+
+// TestSnapshotStreamFollower checks that the follower assembles the snapshot
+// data from chunks, tells the leader where to resume from if the chunks arrive
+// out of order, and restores the snapshot once it is complete.
+func TestSnapshotStreamFollower(t *testing.T) {
+	sink := &testSnapshotSink{}
+	r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)), func(c *Config) { c.SnapshotSink = sink })
+	r.becomeFollower(1, 1)
+	requireResp := func(offset uint64) {
+		t.Helper()
+		msgs := r.readMessages()
+		require.Len(t, msgs, 1)
+		require.Equal(t, pb.MsgSnapChunkResp, msgs[0].Type)
+		require.Equal(t, offset, msgs[0].Index)
+		require.Equal(t, testingSnap.Metadata.Index, msgs[0].RejectHint)
+		require.False(t, msgs[0].Reject)
+	}
+
+	// The transfer must start from the beginning.
+	require.NoError(t, r.Step(snapChunk(4)))
+	requireResp(0)
+	require.NoError(t, r.Step(snapChunk(0)))
+	requireResp(4)
+	// A duplicate chunk.
+	require.NoError(t, r.Step(snapChunk(0)))
+	requireResp(4)
+	// A gap.
+	require.NoError(t, r.Step(snapChunk(8)))
+	requireResp(4)
+	require.NoError(t, r.Step(snapChunk(4)))
+	requireResp(8)
+	require.Equal(t, testingSnapData[:8], sink.data)
+
+	require.NoError(t, r.Step(snapChunk(8)))
+	require.Equal(t, testingSnapData, sink.data)
+	msgs := r.readMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.MsgAppResp, msgs[0].Type)
+	require.Equal(t, testingSnap.Metadata.Index, msgs[0].Index)
+	require.Equal(t, testingSnap.Metadata.Index, r.raftLog.committed)
+	snap := r.raftLog.nextUnstableSnapshot()
+	require.NotNil(t, snap)
+	require.Equal(t, testingSnap.Metadata, snap.Metadata)
+	require.Empty(t, snap.Data)
+
+	// Chunks of the restored snapshot are ignored.
+	require.NoError(t, r.Step(snapChunk(0)))
+	msgs = r.readMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.MsgAppResp, msgs[0].Type)
+}
+
+// TestSnapshotStreamFollowerReject checks that the follower rejects the
+// transfer if it can't write the chunks.
+func TestSnapshotStreamFollowerReject(t *testing.T) {
+	for _, sink := range []SnapshotSink{nil, &testSnapshotSink{err: errors.New("disk full")}} {
+		r := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)), func(c *Config) { c.SnapshotSink = sink })
+		r.becomeFollower(1, 1)
+		require.NoError(t, r.Step(snapChunk(0)))
+		msgs := r.readMessages()
+		require.Len(t, msgs, 1)
+		require.Equal(t, pb.MsgSnapChunkResp, msgs[0].Type)
+		require.True(t, msgs[0].Reject)
+	}
+}
+
+// TestSnapshotStream checks the transfer of a snapshot between the leader and
+// a follower that loses its progress in the middle of it.
+func TestSnapshotStream(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.ApplySnapshot(testingSnap))
+	leader := newTestRaft(1, 10, 1, storage, snapStreamConfig(&testSnapshotSource{meta: testingSnap.Metadata, data: testingSnapData}))
+	leader.becomeCandidate()
+	leader.becomeLeader()
+	leader.readMessages()
+	sendSnapshot(t, leader, 2)
+	sink := &testSnapshotSink{}
+	follower := newTestRaft(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)), func(c *Config) { c.SnapshotSink = sink })
+	follower.becomeFollower(1, 1)
+	deliver := func(from, to *raft) {
+		for _, m := range from.readMessages() {
+			require.NoError(t, to.Step(m))
+		}
+	}
+
+	deliver(leader, follower)
+	deliver(follower, leader)
+	// The follower restarts and forgets about the transfer.
+	follower.snapRecv = snapshotTransfer{}
+	for i := 0; i < 5; i++ {
+		deliver(leader, follower)
+		deliver(follower, leader)
+	}
+	require.Equal(t, testingSnapData, sink.data)
+	require.Equal(t, leader.raftLog.lastIndex(), follower.raftLog.lastIndex())
+	pr := leader.trk.Progress[2]
+	require.Equal(t, tracker.StateReplicate, pr.State)
+	require.Equal(t, leader.raftLog.lastIndex(), pr.Match)
+}
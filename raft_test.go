@@ -249,7 +249,7 @@ func TestUncommittedEntryLimit(t *testing.T) {
 	}
 
 	// Send one more proposal to r1. It should be rejected.
-	require.Equal(t, ErrProposalDropped, r.Step(propMsg))
+	require.ErrorIs(t, r.Step(propMsg), ErrProposalDropped)
 
 	// Read messages and reduce the uncommitted size as if we had committed
 	// these entries.
@@ -268,7 +268,7 @@ func TestUncommittedEntryLimit(t *testing.T) {
 	require.NoError(t, r.Step(propMsgLarge))
 
 	// Send one more proposal to r1. It should be rejected, again.
-	require.Equal(t, ErrProposalDropped, r.Step(propMsg))
+	require.ErrorIs(t, r.Step(propMsg), ErrProposalDropped)
 
 	// But we can always append an entry with no Data. This is used both for the
 	// leader's first empty entry and for auto-transitioning out of joint config
@@ -2110,6 +2110,57 @@ func TestReadOnlyForNewLeader(t *testing.T) {
 	require.Equal(t, wctx, rs.RequestCtx)
 }
 
+// TestReadOnlyBatchReadIndex ensures that with BatchReadIndex the read only
+// requests arriving while a confirmation round is in flight are confirmed
+// together by the next round, and still get individual read states.
+func TestReadOnlyBatchReadIndex(t *testing.T) {
+	nt := newNetworkWithConfig(func(c *Config) {
+		c.BatchReadIndex = true
+	}, nil, nil, nil)
+	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgHup})
+	r := nt.peers[1].(*raft)
+	require.Equal(t, StateLeader, r.state)
+
+	heartbeats := func(ctx string) {
+		t.Helper()
+		msgs := r.readMessages()
+		require.Len(t, msgs, 2)
+		for _, m := range msgs {
+			require.Equal(t, pb.MsgHeartbeat, m.Type)
+			require.Equal(t, ctx, string(m.Context))
+		}
+	}
+	readIndex := func(ctx string) {
+		require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: []byte(ctx)}}}))
+	}
+	ack := func(ctx string) {
+		require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgHeartbeatResp, Context: []byte(ctx)}))
+	}
+
+	// The first request starts a round.
+	readIndex("ctx1")
+	heartbeats("ctx1")
+	// The requests arriving while it is in flight do not.
+	readIndex("ctx2")
+	readIndex("ctx3")
+	require.Empty(t, r.readMessages())
+
+	// Completing the round releases the first request, and starts a round
+	// covering the rest.
+	ack("ctx1")
+	require.Equal(t, []ReadState{{Index: r.raftLog.committed, RequestCtx: []byte("ctx1")}}, r.readStates)
+	r.readStates = nil
+	heartbeats("ctx3")
+
+	ack("ctx3")
+	require.Equal(t, []ReadState{
+		{Index: r.raftLog.committed, RequestCtx: []byte("ctx2")},
+		{Index: r.raftLog.committed, RequestCtx: []byte("ctx3")},
+	}, r.readStates)
+	require.Empty(t, r.readMessages())
+	require.False(t, r.readOnly.roundInflight())
+}
+
 func TestLeaderAppResp(t *testing.T) {
 	// initial progress: match = 0; next = 3
 	tests := []struct {
@@ -3145,7 +3196,7 @@ func TestLeaderTransferIgnoreProposal(t *testing.T) {
 
 	nt.send(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{}}})
 	err := lead.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{}}})
-	require.Equal(t, ErrProposalDropped, err)
+	require.ErrorIs(t, err, ErrProposalDropped)
 
 	require.Equal(t, uint64(1), lead.trk.Progress[1].Match)
 }
@@ -3763,6 +3814,18 @@ func TestFastLogRejection(t *testing.T) {
 			nextAppendTerm:  2,
 			nextAppendIndex: 1,
 		},
+		// The leader and follower logs have interleaved terms, so that probing
+		// once per term would take a round trip for each index. The follower
+		// includes its term boundaries in the rejection, and the leader finds
+		// the match at index 1 immediately.
+		{
+			leaderLog:       index(1).terms(1, 2, 4, 6, 8, 10),
+			followerLog:     index(1).terms(1, 3, 5, 7, 9, 9),
+			rejectHintTerm:  9,
+			rejectHintIndex: 6,
+			nextAppendTerm:  1,
+			nextAppendIndex: 1,
+		},
 		// A case when a stale MsgApp from leader arrives after the corresponding
 		// log index got compacted.
 		// A stale (type=MsgApp,index=3,logTerm=3,entries=[(term=3,index=4)]) is
@@ -4099,8 +4162,10 @@ func SetRandomizedElectionTimeout(r *RawNode, v int) {
 	setRandomizedElectionTimeout(r.raft, v)
 }
 
-func newTestConfig(id uint64, election, heartbeat int, storage Storage) *Config {
-	return &Config{
+// newTestConfig returns the configuration of a raft peer used by the tests,
+// modified by the given functions in order.
+func newTestConfig(id uint64, election, heartbeat int, storage Storage, opts ...func(*Config)) *Config {
+	cfg := &Config{
 		ID:              id,
 		ElectionTick:    election,
 		HeartbeatTick:   heartbeat,
@@ -4108,6 +4173,10 @@ func newTestConfig(id uint64, election, heartbeat int, storage Storage) *Config
 		MaxSizePerMsg:   noLimit,
 		MaxInflightMsgs: 256,
 	}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return cfg
 }
 
 type testMemoryStorageOptions func(*MemoryStorage)
@@ -4124,6 +4193,23 @@ func withLearners(learners ...uint64) testMemoryStorageOptions {
 	}
 }
 
+// withWitnesses adds witnesses to the voters of the initial configuration.
+func withWitnesses(witnesses ...uint64) testMemoryStorageOptions {
+	return func(ms *MemoryStorage) {
+		cs := &ms.snapshot.Metadata.ConfState
+		cs.Voters = append(cs.Voters, witnesses...)
+		cs.Witnesses = witnesses
+	}
+}
+
+// withLearnersAutoPromote marks learners of the initial configuration for
+// automatic promotion.
+func withLearnersAutoPromote(learners ...uint64) testMemoryStorageOptions {
+	return func(ms *MemoryStorage) {
+		ms.snapshot.Metadata.ConfState.LearnersAutoPromote = learners
+	}
+}
+
 func newTestMemoryStorage(opts ...testMemoryStorageOptions) *MemoryStorage {
 	ms := NewMemoryStorage()
 	for _, o := range opts {
@@ -4132,8 +4218,8 @@ func newTestMemoryStorage(opts ...testMemoryStorageOptions) *MemoryStorage {
 	return ms
 }
 
-func newTestRaft(id uint64, election, heartbeat int, storage Storage) *raft {
-	return newRaft(newTestConfig(id, election, heartbeat, storage))
+func newTestRaft(id uint64, election, heartbeat int, storage Storage, opts ...func(*Config)) *raft {
+	return newRaft(newTestConfig(id, election, heartbeat, storage, opts...))
 }
 
 func newTestLearnerRaft(id uint64, election, heartbeat int, storage Storage) *raft {
@@ -4143,8 +4229,8 @@ func newTestLearnerRaft(id uint64, election, heartbeat int, storage Storage) *ra
 
 // newTestRawNode sets up a RawNode with the given peers. The configuration will
 // not be reflected in the Storage.
-func newTestRawNode(id uint64, election, heartbeat int, storage Storage) *RawNode {
-	cfg := newTestConfig(id, election, heartbeat, storage)
+func newTestRawNode(id uint64, election, heartbeat int, storage Storage, opts ...func(*Config)) *RawNode {
+	cfg := newTestConfig(id, election, heartbeat, storage, opts...)
 	rn, err := NewRawNode(cfg)
 	if err != nil {
 		panic(err)
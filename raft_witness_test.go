@@ -0,0 +1,160 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// TestWitnessNotPromotable checks that a witness never campaigns, and that the
+// leadership can't be transferred to it.
+func TestWitnessNotPromotable(t *testing.T) {
+	w := newTestRaft(3, 10, 1, newTestMemoryStorage(withPeers(1, 2), withWitnesses(3)))
+	require.False(t, w.promotable())
+	for i := 0; i < 2*w.electionTimeout; i++ {
+		w.tick()
+	}
+	require.Equal(t, StateFollower, w.state)
+	require.Zero(t, w.Term)
+
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2), withWitnesses(3)))
+	require.True(t, r.promotable())
+	r.becomeCandidate()
+	r.becomeLeader()
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgTransferLeader}))
+	require.Equal(t, None, r.leadTransferee)
+}
+
+// TestWitnessReplication checks that the leader sends the normal entries to
+// witnesses without payloads, and leaves its own log intact.
+func TestWitnessReplication(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2), withWitnesses(3)))
+	r.becomeCandidate()
+	r.becomeLeader()
+	for _, id := range []uint64{2, 3} {
+		pr := r.trk.Progress[id]
+		pr.MaybeUpdate(r.raftLog.lastIndex())
+		pr.BecomeReplicate()
+	}
+	r.readMessages()
+
+	cc := pb.ConfChange{Type: pb.ConfChangeAddLearnerNode, NodeID: 4}
+	ccData, err := cc.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{
+		{Data: []byte("foo")},
+		{Type: pb.EntryConfChange, Data: ccData},
+	}}))
+
+	msgs := r.readMessages()
+	require.Len(t, msgs, 2)
+	for _, m := range msgs {
+		require.Equal(t, pb.MsgApp, m.Type)
+		require.Len(t, m.Entries, 2)
+		require.Equal(t, ccData, m.Entries[1].Data)
+		if m.To == 3 {
+			require.Empty(t, m.Entries[0].Data)
+		} else {
+			require.Equal(t, []byte("foo"), m.Entries[0].Data)
+		}
+	}
+	ents, err := r.raftLog.entries(r.raftLog.lastIndex()-1, noLimit)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), ents[0].Data)
+}
+
+// TestWitnessSnapshot checks that the snapshots are sent to witnesses without
+// the state machine data.
+func TestWitnessSnapshot(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withWitnesses(3))
+	require.NoError(t, s.ApplySnapshot(pb.Snapshot{
+		Data: []byte("state"),
+		Metadata: pb.SnapshotMetadata{
+			Index:     11,
+			Term:      11,
+			ConfState: pb.ConfState{Voters: []uint64{1, 2, 3}, Witnesses: []uint64{3}},
+		},
+	}))
+	r := newTestRaft(1, 10, 1, s)
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+
+	for _, id := range []uint64{2, 3} {
+		pr := r.trk.Progress[id]
+		pr.RecentActive = true
+		pr.Next = r.raftLog.firstIndex() - 1
+		require.True(t, r.maybeSendSnapshot(id, pr))
+	}
+	msgs := r.readMessages()
+	require.Len(t, msgs, 2)
+	require.Equal(t, uint64(2), msgs[0].To)
+	require.Equal(t, []byte("state"), msgs[0].Snapshot.Data)
+	require.Equal(t, uint64(3), msgs[1].To)
+	require.Empty(t, msgs[1].Snapshot.Data)
+	require.Equal(t, []uint64{3}, msgs[1].Snapshot.Metadata.ConfState.Witnesses)
+}
+
+// TestWitnessCommit checks that the entries acknowledged only by witnesses are
+// not committed, even though the witnesses form a quorum: the leader commits
+// them once they are durable in its own log.
+func TestWitnessCommit(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1), withWitnesses(2, 3)))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	for _, id := range []uint64{2, 3} {
+		require.NoError(t, r.Step(pb.Message{From: id, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: r.raftLog.lastIndex()}))
+	}
+	r.readMessages()
+	li := r.raftLog.lastIndex()
+
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{Data: []byte("foo")}}}))
+	require.Equal(t, li+1, r.raftLog.lastIndex())
+	// The append to the leader's log is not durable yet.
+	r.msgs = nil
+	for _, id := range []uint64{2, 3} {
+		require.NoError(t, r.Step(pb.Message{From: id, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: li + 1}))
+	}
+	require.Equal(t, li, r.raftLog.committed)
+
+	r.advanceMessagesAfterAppend()
+	require.Equal(t, li+1, r.raftLog.committed)
+}
+
+// TestWitnessCommittedEntries checks that a witness gets the committed normal
+// entries without payloads, even if its log has them.
+func TestWitnessCommittedEntries(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2), withWitnesses(3))
+	cc := pb.ConfChange{Type: pb.ConfChangeAddLearnerNode, NodeID: 4}
+	ccData, err := cc.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]pb.Entry{
+		{Term: 1, Index: 1, Data: []byte("foo")},
+		{Term: 1, Index: 2, Type: pb.EntryConfChange, Data: ccData},
+	}))
+	require.NoError(t, s.SetHardState(pb.HardState{Term: 1, Commit: 2}))
+	rn, err := NewRawNode(newTestConfig(3, 10, 1, s))
+	require.NoError(t, err)
+
+	rd := rn.Ready()
+	require.Len(t, rd.CommittedEntries, 2)
+	require.Empty(t, rd.CommittedEntries[0].Data)
+	require.Equal(t, ccData, rd.CommittedEntries[1].Data)
+}
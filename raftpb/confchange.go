@@ -56,8 +56,9 @@ func MarshalConfChange(c ConfChangeI) (EntryType, []byte, error) {
 func (c ConfChange) AsV2() ConfChangeV2 {
 	return ConfChangeV2{
 		Changes: []ConfChangeSingle{{
-			Type:   c.Type,
-			NodeID: c.NodeID,
+			Type:     c.Type,
+			NodeID:   c.NodeID,
+			Priority: c.Priority,
 		}},
 		Context: c.Context,
 	}
@@ -116,8 +117,11 @@ func (c ConfChangeV2) LeaveJoint() bool {
 // slice of ConfChangeSingle. The supported operations are:
 // - vn: make n a voter,
 // - ln: make n a learner,
-// - rn: remove n, and
-// - un: update n.
+// - an: make n a learner that is promoted to a voter automatically,
+// - wn: make n a witness,
+// - rn: remove n,
+// - un: update n, and
+// - pn=k: set the priority of n to k.
 func ConfChangesFromString(s string) ([]ConfChangeSingle, error) {
 	var ccs []ConfChangeSingle
 	toks := strings.Split(strings.TrimSpace(s), " ")
@@ -134,14 +138,33 @@ func ConfChangesFromString(s string) ([]ConfChangeSingle, error) {
 			cc.Type = ConfChangeAddNode
 		case 'l':
 			cc.Type = ConfChangeAddLearnerNode
+		case 'a':
+			cc.Type = ConfChangeAddLearnerNodeAutoPromote
+		case 'w':
+			cc.Type = ConfChangeAddWitness
 		case 'r':
 			cc.Type = ConfChangeRemoveNode
 		case 'u':
 			cc.Type = ConfChangeUpdateNode
+		case 'p':
+			cc.Type = ConfChangeSetPriority
 		default:
 			return nil, fmt.Errorf("unknown input: %s", tok)
 		}
-		id, err := strconv.ParseUint(tok[1:], 10, 64)
+		idStr := tok[1:]
+		if cc.Type == ConfChangeSetPriority {
+			var prioStr string
+			var ok bool
+			if idStr, prioStr, ok = strings.Cut(idStr, "="); !ok {
+				return nil, fmt.Errorf("missing priority: %s", tok)
+			}
+			prio, err := strconv.ParseUint(prioStr, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			cc.Priority = prio
+		}
+		id, err := strconv.ParseUint(idStr, 10, 64)
 		if err != nil {
 			return nil, err
 		}
@@ -163,14 +186,23 @@ func ConfChangesToString(ccs []ConfChangeSingle) string {
 			buf.WriteByte('v')
 		case ConfChangeAddLearnerNode:
 			buf.WriteByte('l')
+		case ConfChangeAddLearnerNodeAutoPromote:
+			buf.WriteByte('a')
+		case ConfChangeAddWitness:
+			buf.WriteByte('w')
 		case ConfChangeRemoveNode:
 			buf.WriteByte('r')
 		case ConfChangeUpdateNode:
 			buf.WriteByte('u')
+		case ConfChangeSetPriority:
+			buf.WriteByte('p')
 		default:
 			buf.WriteString("unknown")
 		}
 		fmt.Fprintf(&buf, "%d", cc.NodeID)
+		if cc.Type == ConfChangeSetPriority {
+			fmt.Fprintf(&buf, "=%d", cc.Priority)
+		}
 	}
 	return buf.String()
 }
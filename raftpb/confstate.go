@@ -35,6 +35,11 @@ func (cs ConfState) Equivalent(cs2 ConfState) error {
 		s(&cs.Learners)
 		s(&cs.VotersOutgoing)
 		s(&cs.LearnersNext)
+		s(&cs.Witnesses)
+		s(&cs.LearnersAutoPromote)
+		if len(cs.Priorities) == 0 {
+			cs.Priorities = nil
+		}
 	}
 
 	if !reflect.DeepEqual(cs1, cs2) {
@@ -48,6 +48,12 @@ func TestConfState_Equivalent(t *testing.T) {
 		{ConfState{Voters: []uint64{1, 2, 3, 4}}, ConfState{Voters: []uint64{2, 1, 3}}, false},
 		// Sensitive to AutoLeave flag.
 		{ConfState{AutoLeave: true}, ConfState{}, false},
+		// Sensitive to priorities, but not to nil vs empty map.
+		{ConfState{Priorities: map[uint64]uint64{1: 2}}, ConfState{Priorities: map[uint64]uint64{1: 2}}, true},
+		{ConfState{Priorities: map[uint64]uint64{1: 2}}, ConfState{Priorities: map[uint64]uint64{1: 3}}, false},
+		{ConfState{Priorities: map[uint64]uint64{}}, ConfState{}, true},
+		{ConfState{Learners: []uint64{3, 4}, LearnersAutoPromote: []uint64{4, 3}}, ConfState{Learners: []uint64{3, 4}, LearnersAutoPromote: []uint64{3, 4}}, true},
+		{ConfState{Learners: []uint64{3, 4}, LearnersAutoPromote: []uint64{4}}, ConfState{Learners: []uint64{3, 4}}, false},
 	}
 
 	for _, tc := range testCases {
@@ -10,6 +10,7 @@ import (
 	math_bits "math/bits"
 
 	_ "github.com/gogo/protobuf/gogoproto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	proto "github.com/golang/protobuf/proto"
 )
 
@@ -96,6 +97,10 @@ const (
 	MsgStorageApply      MessageType = 21
 	MsgStorageApplyResp  MessageType = 22
 	MsgForgetLeader      MessageType = 23
+	MsgQuiesce           MessageType = 24
+	MsgUnquiesce         MessageType = 25
+	MsgSnapChunk         MessageType = 26
+	MsgSnapChunkResp     MessageType = 27
 )
 
 var MessageType_name = map[int32]string{
@@ -123,6 +128,10 @@ var MessageType_name = map[int32]string{
 	21: "MsgStorageApply",
 	22: "MsgStorageApplyResp",
 	23: "MsgForgetLeader",
+	24: "MsgQuiesce",
+	25: "MsgUnquiesce",
+	26: "MsgSnapChunk",
+	27: "MsgSnapChunkResp",
 }
 
 var MessageType_value = map[string]int32{
@@ -150,6 +159,10 @@ var MessageType_value = map[string]int32{
 	"MsgStorageApply":      21,
 	"MsgStorageApplyResp":  22,
 	"MsgForgetLeader":      23,
+	"MsgQuiesce":           24,
+	"MsgUnquiesce":         25,
+	"MsgSnapChunk":         26,
+	"MsgSnapChunkResp":     27,
 }
 
 func (x MessageType) Enum() *MessageType {
@@ -239,6 +252,19 @@ const (
 	ConfChangeRemoveNode     ConfChangeType = 1
 	ConfChangeUpdateNode     ConfChangeType = 2
 	ConfChangeAddLearnerNode ConfChangeType = 3
+	// ConfChangeAddWitness adds a voter that participates in elections and
+	// commit quorums, but receives log entries without their payloads and
+	// can never become the leader. A witness can be added from scratch or
+	// by converting a learner, but can't be turned into a voter or learner.
+	ConfChangeAddWitness ConfChangeType = 4
+	// ConfChangeSetPriority sets the election priority of a voter or learner
+	// to the value carried in the priority field of the change. Nodes with a
+	// higher priority are preferred as the leader. Priority 0 is the default.
+	ConfChangeSetPriority ConfChangeType = 5
+	// ConfChangeAddLearnerNodeAutoPromote adds a learner, or marks an existing
+	// one, to be promoted to a voter by the leader once it has caught up with
+	// the log (see Config.AutoPromoteMaxLag).
+	ConfChangeAddLearnerNodeAutoPromote ConfChangeType = 6
 )
 
 var ConfChangeType_name = map[int32]string{
@@ -246,13 +272,19 @@ var ConfChangeType_name = map[int32]string{
 	1: "ConfChangeRemoveNode",
 	2: "ConfChangeUpdateNode",
 	3: "ConfChangeAddLearnerNode",
+	4: "ConfChangeAddWitness",
+	5: "ConfChangeSetPriority",
+	6: "ConfChangeAddLearnerNodeAutoPromote",
 }
 
 var ConfChangeType_value = map[string]int32{
-	"ConfChangeAddNode":        0,
-	"ConfChangeRemoveNode":     1,
-	"ConfChangeUpdateNode":     2,
-	"ConfChangeAddLearnerNode": 3,
+	"ConfChangeAddNode":                   0,
+	"ConfChangeRemoveNode":                1,
+	"ConfChangeUpdateNode":                2,
+	"ConfChangeAddLearnerNode":            3,
+	"ConfChangeAddWitness":                4,
+	"ConfChangeSetPriority":               5,
+	"ConfChangeAddLearnerNodeAutoPromote": 6,
 }
 
 func (x ConfChangeType) Enum() *ConfChangeType {
@@ -424,14 +456,37 @@ type Message struct {
 	// message types. However, peer nodes running older binary versions may send a
 	// non-nil, empty value for the snapshot field of non-MsgSnap messages. Code
 	// should be prepared to handle such messages.
-	Snapshot   *Snapshot `protobuf:"bytes,9,opt,name=snapshot" json:"snapshot,omitempty"`
-	Reject     bool      `protobuf:"varint,10,opt,name=reject" json:"reject"`
-	RejectHint uint64    `protobuf:"varint,11,opt,name=rejectHint" json:"rejectHint"`
-	Context    []byte    `protobuf:"bytes,12,opt,name=context" json:"context,omitempty"`
+	Snapshot *Snapshot `protobuf:"bytes,9,opt,name=snapshot" json:"snapshot,omitempty"`
+	Reject   bool      `protobuf:"varint,10,opt,name=reject" json:"reject"`
+	// (type=MsgSnapChunk,index=1024,rejectHint=4096) carries the chunk of the
+	// snapshot data at byte offset 1024 out of the total of 4096 bytes. The chunk
+	// is in snapshot.data, along with the metadata of the snapshot.
+	// (type=MsgSnapChunkResp,index=2048,rejectHint=100) means the follower has
+	// received the first 2048 bytes of the data of the snapshot at log index 100.
+	RejectHint uint64 `protobuf:"varint,11,opt,name=rejectHint" json:"rejectHint"`
+	Context    []byte `protobuf:"bytes,12,opt,name=context" json:"context,omitempty"`
 	// responses are populated by a raft node to instruct storage threads on how
 	// to respond and who to respond to when the work associated with a message
 	// is complete. Populated for MsgStorageAppend and MsgStorageApply messages.
 	Responses []Message `protobuf:"bytes,14,rep,name=responses" json:"responses"`
+	// (type=MsgApp,entriesCodec=1) means that the entries of the message are
+	// not in the entries field, but compressed with the codec 1 in
+	// compressedEntries. The uncompressed data is the encoding of a Message
+	// which only has the entries field set. Leaders only compress the entries
+	// sent to the followers which advertise support for the codec in
+	// supportedCodecs.
+	EntriesCodec      uint32 `protobuf:"varint,15,opt,name=entriesCodec" json:"entriesCodec"`
+	CompressedEntries []byte `protobuf:"bytes,16,opt,name=compressedEntries" json:"compressedEntries,omitempty"`
+	// supportedCodecs is the bitmask of the codecs that the sender can use to
+	// decompress the entries of a MsgApp, with bit i set for the codec i. Set
+	// in MsgAppResp and MsgHeartbeatResp.
+	SupportedCodecs uint64 `protobuf:"varint,17,opt,name=supportedCodecs" json:"supportedCodecs"`
+	// (type=MsgAppResp,reject=true,rejectHint=9,termBoundaries=[(3,5),(4,8)])
+	// means the follower has entries with term 3 at indices 5-7, and with term 4
+	// at indices 8-9. The boundaries are in increasing index order, and cover
+	// the tail of the follower's log above its commit index, up to rejectHint.
+	// They allow the leader to find where the logs match in one round trip.
+	TermBoundaries []TermBoundary `protobuf:"bytes,18,rep,name=termBoundaries" json:"termBoundaries"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
@@ -467,6 +522,46 @@ func (m *Message) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Message proto.InternalMessageInfo
 
+// TermBoundary is the index at which a run of log entries with the given term
+// starts.
+type TermBoundary struct {
+	Term  uint64 `protobuf:"varint,1,opt,name=term" json:"term"`
+	Index uint64 `protobuf:"varint,2,opt,name=index" json:"index"`
+}
+
+func (m *TermBoundary) Reset()         { *m = TermBoundary{} }
+func (m *TermBoundary) String() string { return proto.CompactTextString(m) }
+func (*TermBoundary) ProtoMessage()    {}
+func (*TermBoundary) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b042552c306ae59b, []int{4}
+}
+func (m *TermBoundary) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TermBoundary) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TermBoundary.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TermBoundary) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TermBoundary.Merge(m, src)
+}
+func (m *TermBoundary) XXX_Size() int {
+	return m.Size()
+}
+func (m *TermBoundary) XXX_DiscardUnknown() {
+	xxx_messageInfo_TermBoundary.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TermBoundary proto.InternalMessageInfo
+
 type HardState struct {
 	Term   uint64 `protobuf:"varint,1,opt,name=term" json:"term"`
 	Vote   uint64 `protobuf:"varint,2,opt,name=vote" json:"vote"`
@@ -477,7 +572,7 @@ func (m *HardState) Reset()         { *m = HardState{} }
 func (m *HardState) String() string { return proto.CompactTextString(m) }
 func (*HardState) ProtoMessage()    {}
 func (*HardState) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b042552c306ae59b, []int{4}
+	return fileDescriptor_b042552c306ae59b, []int{5}
 }
 func (m *HardState) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -521,28 +616,33 @@ type ConfState struct {
 	// If set, the config is joint and Raft will automatically transition into
 	// the final config (i.e. remove the outgoing config) when this is safe.
 	AutoLeave bool `protobuf:"varint,5,opt,name=auto_leave,json=autoLeave" json:"auto_leave"`
+	// The voters (in either the incoming or the outgoing config) that are
+	// witnesses, i.e. don't store the payloads of the log entries.
+	Witnesses []uint64 `protobuf:"varint,6,rep,name=witnesses" json:"witnesses,omitempty"`
+	// The election priorities of the nodes (in either the incoming or the
+	// outgoing config) that have one. Nodes not in the map have priority 0.
+	Priorities map[uint64]uint64 `protobuf:"bytes,7,rep,name=priorities" json:"priorities,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// The learners that the leader promotes to voters once they have caught
+	// up with the log. This is a subset of the learners.
+	LearnersAutoPromote []uint64 `protobuf:"varint,8,rep,name=learners_auto_promote,json=learnersAutoPromote" json:"learners_auto_promote,omitempty"`
 }
 
 func (m *ConfState) Reset()         { *m = ConfState{} }
 func (m *ConfState) String() string { return proto.CompactTextString(m) }
 func (*ConfState) ProtoMessage()    {}
 func (*ConfState) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b042552c306ae59b, []int{5}
+	return fileDescriptor_b042552c306ae59b, []int{6}
 }
 func (m *ConfState) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ConfState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ConfState.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ConfState) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ConfState.Merge(m, src)
@@ -560,6 +660,8 @@ type ConfChange struct {
 	Type    ConfChangeType `protobuf:"varint,2,opt,name=type,enum=raftpb.ConfChangeType" json:"type"`
 	NodeID  uint64         `protobuf:"varint,3,opt,name=node_id,json=nodeId" json:"node_id"`
 	Context []byte         `protobuf:"bytes,4,opt,name=context" json:"context,omitempty"`
+	// The priority set by ConfChangeSetPriority.
+	Priority uint64 `protobuf:"varint,5,opt,name=priority" json:"priority"`
 	// NB: this is used only by etcd to thread through a unique identifier.
 	// Ideally it should really use the Context instead. No counterpart to
 	// this field exists in ConfChangeV2.
@@ -570,7 +672,7 @@ func (m *ConfChange) Reset()         { *m = ConfChange{} }
 func (m *ConfChange) String() string { return proto.CompactTextString(m) }
 func (*ConfChange) ProtoMessage()    {}
 func (*ConfChange) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b042552c306ae59b, []int{6}
+	return fileDescriptor_b042552c306ae59b, []int{7}
 }
 func (m *ConfChange) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -604,13 +706,15 @@ var xxx_messageInfo_ConfChange proto.InternalMessageInfo
 type ConfChangeSingle struct {
 	Type   ConfChangeType `protobuf:"varint,1,opt,name=type,enum=raftpb.ConfChangeType" json:"type"`
 	NodeID uint64         `protobuf:"varint,2,opt,name=node_id,json=nodeId" json:"node_id"`
+	// The priority set by ConfChangeSetPriority.
+	Priority uint64 `protobuf:"varint,3,opt,name=priority" json:"priority"`
 }
 
 func (m *ConfChangeSingle) Reset()         { *m = ConfChangeSingle{} }
 func (m *ConfChangeSingle) String() string { return proto.CompactTextString(m) }
 func (*ConfChangeSingle) ProtoMessage()    {}
 func (*ConfChangeSingle) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b042552c306ae59b, []int{7}
+	return fileDescriptor_b042552c306ae59b, []int{8}
 }
 func (m *ConfChangeSingle) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -681,7 +785,7 @@ func (m *ConfChangeV2) Reset()         { *m = ConfChangeV2{} }
 func (m *ConfChangeV2) String() string { return proto.CompactTextString(m) }
 func (*ConfChangeV2) ProtoMessage()    {}
 func (*ConfChangeV2) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b042552c306ae59b, []int{8}
+	return fileDescriptor_b042552c306ae59b, []int{9}
 }
 func (m *ConfChangeV2) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -719,8 +823,10 @@ func init() {
 	proto.RegisterType((*SnapshotMetadata)(nil), "raftpb.SnapshotMetadata")
 	proto.RegisterType((*Snapshot)(nil), "raftpb.Snapshot")
 	proto.RegisterType((*Message)(nil), "raftpb.Message")
+	proto.RegisterType((*TermBoundary)(nil), "raftpb.TermBoundary")
 	proto.RegisterType((*HardState)(nil), "raftpb.HardState")
 	proto.RegisterType((*ConfState)(nil), "raftpb.ConfState")
+	proto.RegisterMapType((map[uint64]uint64)(nil), "raftpb.ConfState.PrioritiesEntry")
 	proto.RegisterType((*ConfChange)(nil), "raftpb.ConfChange")
 	proto.RegisterType((*ConfChangeSingle)(nil), "raftpb.ConfChangeSingle")
 	proto.RegisterType((*ConfChangeV2)(nil), "raftpb.ConfChangeV2")
@@ -729,76 +835,93 @@ func init() {
 func init() { proto.RegisterFile("raft.proto", fileDescriptor_b042552c306ae59b) }
 
 var fileDescriptor_b042552c306ae59b = []byte{
-	// 1102 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0xcb, 0x6e, 0x23, 0x45,
-	0x14, 0xed, 0x6e, 0x77, 0xfc, 0xb8, 0x76, 0x9c, 0x4a, 0xc5, 0x33, 0xd3, 0x8a, 0x22, 0x8f, 0xf1,
-	0x0c, 0x1a, 0x2b, 0x68, 0x02, 0x32, 0x12, 0x42, 0xec, 0xf2, 0x18, 0x94, 0xa0, 0x38, 0x0c, 0x4e,
-	0x26, 0x0b, 0x24, 0x14, 0x55, 0xdc, 0x95, 0x4e, 0x83, 0x5d, 0xd5, 0xaa, 0x2e, 0x87, 0x64, 0x83,
-	0x10, 0x5f, 0xc0, 0x92, 0x0d, 0x5b, 0x3e, 0x80, 0x8f, 0x40, 0x59, 0x66, 0xc9, 0x6a, 0xc4, 0x24,
-	0x7f, 0xc0, 0x17, 0xa0, 0xaa, 0xae, 0x7e, 0xd8, 0x89, 0x66, 0xc1, 0xae, 0xea, 0xdc, 0x53, 0xf7,
-	0x9e, 0x7b, 0x6e, 0x57, 0x35, 0x80, 0x20, 0x67, 0x72, 0x23, 0x12, 0x5c, 0x72, 0x5c, 0x56, 0xeb,
-	0xe8, 0x74, 0xb5, 0x15, 0xf0, 0x80, 0x6b, 0xe8, 0x63, 0xb5, 0x4a, 0xa2, 0xdd, 0x9f, 0x60, 0xe1,
-	0x15, 0x93, 0xe2, 0x0a, 0x7b, 0xe0, 0x1e, 0x51, 0x31, 0xf1, 0x9c, 0x8e, 0xdd, 0x73, 0xb7, 0xdc,
-	0xeb, 0xb7, 0x4f, 0xad, 0xa1, 0x46, 0xf0, 0x2a, 0x2c, 0xec, 0x31, 0x9f, 0x5e, 0x7a, 0xa5, 0x42,
-	0x28, 0x81, 0xf0, 0x47, 0xe0, 0x1e, 0x5d, 0x45, 0xd4, 0xb3, 0x3b, 0x76, 0xaf, 0xd9, 0x5f, 0xde,
-	0x48, 0x6a, 0x6d, 0xe8, 0x94, 0x2a, 0x90, 0x25, 0xba, 0x8a, 0x28, 0xc6, 0xe0, 0xee, 0x10, 0x49,
-	0x3c, 0xb7, 0x63, 0xf7, 0x1a, 0x43, 0xbd, 0xee, 0xfe, 0x6c, 0x03, 0x3a, 0x64, 0x24, 0x8a, 0xcf,
-	0xb9, 0x1c, 0x50, 0x49, 0x7c, 0x22, 0x09, 0xfe, 0x0c, 0x60, 0xc4, 0xd9, 0xd9, 0x49, 0x2c, 0x89,
-	0x4c, 0x72, 0xd7, 0xf3, 0xdc, 0xdb, 0x9c, 0x9d, 0x1d, 0xaa, 0x80, 0xc9, 0x5d, 0x1b, 0xa5, 0x80,
-	0x52, 0x1a, 0x6a, 0xa5, 0xc5, 0x26, 0x12, 0x48, 0xf5, 0x27, 0x55, 0x7f, 0xc5, 0x26, 0x34, 0xd2,
-	0xfd, 0x16, 0xaa, 0xa9, 0x02, 0x25, 0x51, 0x29, 0xd0, 0x35, 0x1b, 0x43, 0xbd, 0xc6, 0x5f, 0x40,
-	0x75, 0x62, 0x94, 0xe9, 0xc4, 0xf5, 0xbe, 0x97, 0x6a, 0x99, 0x57, 0x6e, 0xf2, 0x66, 0xfc, 0xee,
-	0xbf, 0x25, 0xa8, 0x0c, 0x68, 0x1c, 0x93, 0x80, 0xe2, 0x97, 0xe0, 0xca, 0xdc, 0xab, 0x95, 0x34,
-	0x87, 0x09, 0x17, 0xdd, 0x52, 0x34, 0xdc, 0x02, 0x47, 0xf2, 0x99, 0x4e, 0x1c, 0xc9, 0x55, 0x1b,
-	0x67, 0x82, 0xcf, 0xb5, 0xa1, 0x90, 0xac, 0x41, 0x77, 0xbe, 0x41, 0xdc, 0x86, 0xca, 0x98, 0x07,
-	0x7a, 0xba, 0x0b, 0x85, 0x60, 0x0a, 0xe6, 0xb6, 0x95, 0xef, 0xdb, 0xf6, 0x12, 0x2a, 0x94, 0x49,
-	0x11, 0xd2, 0xd8, 0xab, 0x74, 0x4a, 0xbd, 0x7a, 0x7f, 0x71, 0x66, 0xc6, 0x69, 0x2a, 0xc3, 0xc1,
-	0x6b, 0x50, 0x1e, 0xf1, 0xc9, 0x24, 0x94, 0x5e, 0xb5, 0x90, 0xcb, 0x60, 0x4a, 0xe2, 0x05, 0x97,
-	0xd4, 0x5b, 0x2c, 0x4a, 0x54, 0x08, 0xee, 0x43, 0x35, 0x36, 0x5e, 0x7a, 0x35, 0xed, 0x31, 0x9a,
-	0xf7, 0x58, 0xf3, 0xed, 0x61, 0xc6, 0x53, 0xb5, 0x04, 0xfd, 0x9e, 0x8e, 0xa4, 0x07, 0x1d, 0xbb,
-	0x57, 0x4d, 0x6b, 0x25, 0x18, 0x7e, 0x0e, 0x90, 0xac, 0x76, 0x43, 0x26, 0xbd, 0x7a, 0xa1, 0x62,
-	0x01, 0x57, 0xd6, 0x8c, 0x38, 0x93, 0xf4, 0x52, 0x7a, 0x0d, 0x35, 0x72, 0x53, 0x24, 0x05, 0xf1,
-	0xa7, 0x50, 0x13, 0x34, 0x8e, 0x38, 0x8b, 0x69, 0xec, 0x35, 0xb5, 0x01, 0x4b, 0x73, 0x83, 0x4b,
-	0x3f, 0xc3, 0x8c, 0xd7, 0xfd, 0x0e, 0x6a, 0xbb, 0x44, 0xf8, 0xc9, 0x37, 0x99, 0x8e, 0xc5, 0xbe,
-	0x37, 0x96, 0xd4, 0x0d, 0xe7, 0x9e, 0x1b, 0xb9, 0x8b, 0xa5, 0xfb, 0x2e, 0x76, 0xff, 0xb4, 0xa1,
-	0x96, 0x5d, 0x02, 0xfc, 0x18, 0xca, 0xea, 0x8c, 0x88, 0x3d, 0xbb, 0x53, 0xea, 0xb9, 0x43, 0xb3,
-	0xc3, 0xab, 0x50, 0x1d, 0x53, 0x22, 0x98, 0x8a, 0x38, 0x3a, 0x92, 0xed, 0xf1, 0x0b, 0x58, 0x4a,
-	0x58, 0x27, 0x7c, 0x2a, 0x03, 0x1e, 0xb2, 0xc0, 0x2b, 0x69, 0x4a, 0x33, 0x81, 0xbf, 0x36, 0x28,
-	0x7e, 0x06, 0x8b, 0xe9, 0xa1, 0x13, 0xa6, 0x4c, 0x72, 0x35, 0xad, 0x91, 0x82, 0x07, 0xca, 0xa3,
-	0x67, 0x00, 0x64, 0x2a, 0xf9, 0xc9, 0x98, 0x92, 0x0b, 0xaa, 0xbf, 0xb0, 0x74, 0x16, 0x35, 0x85,
-	0xef, 0x2b, 0xb8, 0xfb, 0xbb, 0x0d, 0xa0, 0x44, 0x6f, 0x9f, 0x13, 0x16, 0x50, 0xfc, 0x89, 0xb9,
-	0x0b, 0x8e, 0xbe, 0x0b, 0x8f, 0x8b, 0x77, 0x3b, 0x61, 0xdc, 0xbb, 0x0e, 0x2f, 0xa0, 0xc2, 0xb8,
-	0x4f, 0x4f, 0x42, 0xdf, 0x98, 0xd2, 0x54, 0xc1, 0xdb, 0xb7, 0x4f, 0xcb, 0x07, 0xdc, 0xa7, 0x7b,
-	0x3b, 0xc3, 0xb2, 0x0a, 0xef, 0xf9, 0xd8, 0xcb, 0x47, 0x9a, 0x3c, 0x34, 0xd9, 0x30, 0x57, 0xc1,
-	0x09, 0x7d, 0x33, 0x08, 0x30, 0xa7, 0x9d, 0xbd, 0x9d, 0xa1, 0x13, 0xfa, 0xdd, 0x09, 0xa0, 0xbc,
-	0xf8, 0x61, 0xc8, 0x82, 0x71, 0x2e, 0xd2, 0xfe, 0x3f, 0x22, 0x9d, 0xf7, 0x89, 0xec, 0xfe, 0x61,
-	0x43, 0x23, 0xcf, 0x73, 0xdc, 0xc7, 0x5b, 0x00, 0x52, 0x10, 0x16, 0x87, 0x32, 0xe4, 0xcc, 0x54,
-	0x5c, 0x7b, 0xa0, 0x62, 0xc6, 0x49, 0x3f, 0xe6, 0xfc, 0x14, 0xfe, 0x1c, 0x2a, 0x23, 0xcd, 0x4a,
-	0x26, 0x5e, 0x78, 0xa7, 0xe6, 0x5b, 0x4b, 0xaf, 0xad, 0xa1, 0x17, 0x3d, 0x2b, 0xcd, 0x78, 0xb6,
-	0xbe, 0x0b, 0xb5, 0xec, 0x31, 0xc7, 0x4b, 0x50, 0xd7, 0x9b, 0x03, 0x2e, 0x26, 0x64, 0x8c, 0x2c,
-	0xbc, 0x02, 0x4b, 0x1a, 0xc8, 0xf3, 0x23, 0x1b, 0x3f, 0x82, 0xe5, 0x39, 0xf0, 0xb8, 0x8f, 0x9c,
-	0xf5, 0xbf, 0x4a, 0x50, 0x2f, 0xbc, 0x75, 0x18, 0xa0, 0x3c, 0x88, 0x83, 0xdd, 0x69, 0x84, 0x2c,
-	0x5c, 0x87, 0xca, 0x20, 0x0e, 0xb6, 0x28, 0x91, 0xc8, 0x36, 0x9b, 0xd7, 0x82, 0x47, 0xc8, 0x31,
-	0xac, 0xcd, 0x28, 0x42, 0x25, 0xdc, 0x04, 0x48, 0xd6, 0x43, 0x1a, 0x47, 0xc8, 0x35, 0xc4, 0x63,
-	0x2e, 0x29, 0x5a, 0x50, 0xda, 0xcc, 0x46, 0x47, 0xcb, 0x26, 0xaa, 0x5e, 0x0f, 0x54, 0xc1, 0x08,
-	0x1a, 0xaa, 0x18, 0x25, 0x42, 0x9e, 0xaa, 0x2a, 0x55, 0xdc, 0x02, 0x54, 0x44, 0xf4, 0xa1, 0x1a,
-	0xc6, 0xd0, 0x1c, 0xc4, 0xc1, 0x1b, 0x26, 0x28, 0x19, 0x9d, 0x93, 0xd3, 0x31, 0x45, 0x80, 0x97,
-	0x61, 0xd1, 0x24, 0x52, 0x37, 0x6e, 0x1a, 0xa3, 0xba, 0xa1, 0x6d, 0x9f, 0xd3, 0xd1, 0x0f, 0xdf,
-	0x4c, 0xb9, 0x98, 0x4e, 0x50, 0x43, 0xb5, 0x3d, 0x88, 0x03, 0x3d, 0xa0, 0x33, 0x2a, 0xf6, 0x29,
-	0xf1, 0xa9, 0x40, 0x8b, 0xe6, 0xf4, 0x51, 0x38, 0xa1, 0x7c, 0x2a, 0x0f, 0xf8, 0x8f, 0xa8, 0x69,
-	0xc4, 0x0c, 0x29, 0xf1, 0xf5, 0x4f, 0x14, 0x2d, 0x19, 0x31, 0x19, 0xa2, 0xc5, 0x20, 0xd3, 0xef,
-	0x6b, 0x41, 0x75, 0x8b, 0xcb, 0xa6, 0xaa, 0xd9, 0x6b, 0x0e, 0x36, 0x27, 0x0f, 0x25, 0x17, 0x24,
-	0xa0, 0x9b, 0x51, 0x44, 0x99, 0x8f, 0x56, 0xb0, 0x07, 0xad, 0x79, 0x54, 0xf3, 0x5b, 0x6a, 0x62,
-	0x33, 0x91, 0xf1, 0x15, 0x7a, 0x84, 0x9f, 0xc0, 0xca, 0x1c, 0xa8, 0xd9, 0x8f, 0x0d, 0xfb, 0x4b,
-	0x2e, 0x02, 0x2a, 0x4d, 0x47, 0x4f, 0xd6, 0x7f, 0xb1, 0xa1, 0xf5, 0xd0, 0x17, 0x89, 0xd7, 0xc0,
-	0x7b, 0x08, 0xdf, 0x9c, 0x4a, 0x8e, 0x2c, 0xfc, 0x21, 0x7c, 0xf0, 0x50, 0xf4, 0x2b, 0x1e, 0x32,
-	0xb9, 0x37, 0x89, 0xc6, 0xe1, 0x28, 0x54, 0xd3, 0x7f, 0x1f, 0xed, 0xd5, 0xa5, 0xa1, 0x39, 0xeb,
-	0x57, 0xd0, 0x9c, 0xbd, 0x87, 0xca, 0xff, 0x1c, 0xd9, 0xf4, 0x7d, 0x75, 0xe3, 0x90, 0xa5, 0xac,
-	0xc8, 0xe1, 0x21, 0x9d, 0xf0, 0x0b, 0xaa, 0x23, 0xf6, 0x6c, 0xe4, 0x4d, 0xe4, 0x13, 0x99, 0x44,
-	0x9c, 0xd9, 0x46, 0x36, 0x7d, 0x7f, 0x3f, 0x79, 0xee, 0x74, 0xb4, 0xb4, 0xf5, 0xfc, 0xfa, 0x5d,
-	0xdb, 0xba, 0x79, 0xd7, 0xb6, 0xae, 0x6f, 0xdb, 0xf6, 0xcd, 0x6d, 0xdb, 0xfe, 0xe7, 0xb6, 0x6d,
-	0xff, 0x7a, 0xd7, 0xb6, 0x7e, 0xbb, 0x6b, 0x5b, 0x37, 0x77, 0x6d, 0xeb, 0xef, 0xbb, 0xb6, 0xf5,
-	0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x67, 0x2b, 0x47, 0x0c, 0x83, 0x09, 0x00, 0x00,
+	// 1371 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0x4d, 0x6f, 0xdb, 0xc6,
+	0x16, 0x15, 0x29, 0xea, 0xeb, 0x4a, 0x96, 0xc6, 0xd7, 0x72, 0xc2, 0xf8, 0x19, 0x8a, 0xa2, 0xe4,
+	0x21, 0x82, 0x1f, 0xe2, 0x57, 0xa8, 0x40, 0x11, 0x04, 0xe8, 0x42, 0xb6, 0x53, 0xd8, 0x45, 0xec,
+	0x3a, 0xb2, 0x93, 0x02, 0x05, 0x0a, 0x83, 0x11, 0xc7, 0x34, 0x1b, 0x89, 0xc3, 0x92, 0x23, 0x27,
+	0xda, 0x14, 0x45, 0xd7, 0x05, 0x9a, 0x65, 0xb6, 0xdd, 0xf4, 0x6f, 0x74, 0xd3, 0x45, 0x96, 0xd9,
+	0xb5, 0xab, 0xa0, 0xb1, 0xff, 0x48, 0x31, 0xc3, 0xe1, 0x87, 0x24, 0x27, 0x8b, 0xee, 0x66, 0xce,
+	0x39, 0x73, 0xe7, 0xdc, 0x3b, 0x77, 0x86, 0x04, 0x08, 0xac, 0x53, 0xbe, 0xe9, 0x07, 0x8c, 0x33,
+	0x2c, 0x8a, 0xb1, 0xff, 0x6c, 0xad, 0xe9, 0x30, 0x87, 0x49, 0xe8, 0xff, 0x62, 0x14, 0xb1, 0x9d,
+	0x1f, 0xa0, 0xf0, 0xd0, 0xe3, 0xc1, 0x14, 0x4d, 0x30, 0x8e, 0x69, 0x30, 0x36, 0xf5, 0xb6, 0xd6,
+	0x35, 0xb6, 0x8c, 0x37, 0xef, 0x6e, 0xe6, 0x06, 0x12, 0xc1, 0x35, 0x28, 0xec, 0x79, 0x36, 0x7d,
+	0x69, 0xe6, 0x33, 0x54, 0x04, 0xe1, 0xff, 0xc0, 0x38, 0x9e, 0xfa, 0xd4, 0xd4, 0xda, 0x5a, 0xb7,
+	0xde, 0x5b, 0xde, 0x8c, 0xf6, 0xda, 0x94, 0x21, 0x05, 0x91, 0x04, 0x9a, 0xfa, 0x14, 0x11, 0x8c,
+	0x1d, 0x8b, 0x5b, 0xa6, 0xd1, 0xd6, 0xba, 0xb5, 0x81, 0x1c, 0x77, 0x7e, 0xd4, 0x80, 0x1c, 0x79,
+	0x96, 0x1f, 0x9e, 0x31, 0xbe, 0x4f, 0xb9, 0x65, 0x5b, 0xdc, 0xc2, 0xcf, 0x00, 0x86, 0xcc, 0x3b,
+	0x3d, 0x09, 0xb9, 0xc5, 0xa3, 0xd8, 0xd5, 0x34, 0xf6, 0x36, 0xf3, 0x4e, 0x8f, 0x04, 0xa1, 0x62,
+	0x57, 0x86, 0x31, 0x20, 0x9c, 0xba, 0xd2, 0x69, 0x36, 0x89, 0x08, 0x12, 0xf9, 0x71, 0x91, 0x5f,
+	0x36, 0x09, 0x89, 0x74, 0xbe, 0x81, 0x72, 0xec, 0x40, 0x58, 0x14, 0x0e, 0xe4, 0x9e, 0xb5, 0x81,
+	0x1c, 0xe3, 0x03, 0x28, 0x8f, 0x95, 0x33, 0x19, 0xb8, 0xda, 0x33, 0x63, 0x2f, 0xf3, 0xce, 0x55,
+	0xdc, 0x44, 0xdf, 0xf9, 0xa3, 0x00, 0xa5, 0x7d, 0x1a, 0x86, 0x96, 0x43, 0xf1, 0x1e, 0x18, 0x3c,
+	0xad, 0xd5, 0x4a, 0x1c, 0x43, 0xd1, 0xd9, 0x6a, 0x09, 0x19, 0x36, 0x41, 0xe7, 0x6c, 0x26, 0x13,
+	0x9d, 0x33, 0x91, 0xc6, 0x69, 0xc0, 0xe6, 0xd2, 0x10, 0x48, 0x92, 0xa0, 0x31, 0x9f, 0x20, 0xb6,
+	0xa0, 0x34, 0x62, 0x8e, 0x3c, 0xdd, 0x42, 0x86, 0x8c, 0xc1, 0xb4, 0x6c, 0xc5, 0xc5, 0xb2, 0xdd,
+	0x83, 0x12, 0xf5, 0x78, 0xe0, 0xd2, 0xd0, 0x2c, 0xb5, 0xf3, 0xdd, 0x6a, 0x6f, 0x69, 0xe6, 0x8c,
+	0xe3, 0x50, 0x4a, 0x83, 0xeb, 0x50, 0x1c, 0xb2, 0xf1, 0xd8, 0xe5, 0x66, 0x39, 0x13, 0x4b, 0x61,
+	0xc2, 0xe2, 0x39, 0xe3, 0xd4, 0x5c, 0xca, 0x5a, 0x14, 0x08, 0xf6, 0xa0, 0x1c, 0xaa, 0x5a, 0x9a,
+	0x15, 0x59, 0x63, 0x32, 0x5f, 0x63, 0xa9, 0xd7, 0x06, 0x89, 0x4e, 0xec, 0x15, 0xd0, 0xef, 0xe8,
+	0x90, 0x9b, 0xd0, 0xd6, 0xba, 0xe5, 0x78, 0xaf, 0x08, 0xc3, 0x3b, 0x00, 0xd1, 0x68, 0xd7, 0xf5,
+	0xb8, 0x59, 0xcd, 0xec, 0x98, 0xc1, 0x45, 0x69, 0x86, 0xcc, 0xe3, 0xf4, 0x25, 0x37, 0x6b, 0xe2,
+	0xc8, 0xd5, 0x26, 0x31, 0x88, 0x9f, 0x42, 0x25, 0xa0, 0xa1, 0xcf, 0xbc, 0x90, 0x86, 0x66, 0x5d,
+	0x16, 0xa0, 0x31, 0x77, 0x70, 0x71, 0x1b, 0x26, 0x3a, 0xec, 0x42, 0x4d, 0xd5, 0x63, 0x9b, 0xd9,
+	0x74, 0x68, 0x36, 0xda, 0x5a, 0x77, 0x49, 0xc9, 0x66, 0x18, 0xec, 0xc1, 0xf2, 0x90, 0x8d, 0xfd,
+	0x80, 0x86, 0x21, 0xb5, 0x1f, 0xaa, 0x3a, 0x93, 0x8c, 0x91, 0x45, 0x1a, 0x37, 0xa1, 0x11, 0x4e,
+	0x7c, 0x9f, 0x05, 0x9c, 0xda, 0x32, 0x4a, 0x68, 0x2e, 0x67, 0xb2, 0x9b, 0x27, 0x71, 0x0b, 0xea,
+	0xa2, 0x0b, 0xb6, 0xd8, 0xc4, 0xb3, 0x2d, 0xb9, 0x01, 0xca, 0x3c, 0x9a, 0x71, 0x1e, 0xc7, 0x29,
+	0x1b, 0x9f, 0xe7, 0xdc, 0x8a, 0xce, 0x0e, 0xd4, 0xb2, 0xaa, 0xa4, 0xd7, 0xb4, 0x85, 0x5e, 0xfb,
+	0xc8, 0x15, 0xec, 0x7c, 0x0b, 0x95, 0x5d, 0x2b, 0xb0, 0xa3, 0xbb, 0xfa, 0xe1, 0x10, 0x71, 0x97,
+	0xe8, 0x0b, 0x5d, 0x92, 0x76, 0x57, 0x7e, 0xb1, 0xbb, 0x3a, 0x3f, 0xe7, 0xa1, 0x92, 0x3c, 0x0e,
+	0x78, 0x0d, 0x8a, 0x62, 0x4d, 0x10, 0x9a, 0x5a, 0x3b, 0xdf, 0x35, 0x06, 0x6a, 0x86, 0x6b, 0x50,
+	0x1e, 0x51, 0x2b, 0xf0, 0x04, 0xa3, 0x4b, 0x26, 0x99, 0xe3, 0x5d, 0x68, 0x44, 0xaa, 0x13, 0x36,
+	0xe1, 0x0e, 0x73, 0x3d, 0xc7, 0xcc, 0x4b, 0x49, 0x3d, 0x82, 0xbf, 0x52, 0x28, 0xde, 0x86, 0xa5,
+	0x78, 0xd1, 0x89, 0x27, 0x9a, 0xc7, 0x90, 0xb2, 0x5a, 0x0c, 0x1e, 0x88, 0xde, 0xb9, 0x0d, 0x60,
+	0x4d, 0x38, 0x3b, 0x19, 0x51, 0xeb, 0x9c, 0x9a, 0x85, 0x4c, 0x8f, 0x56, 0x04, 0xfe, 0x48, 0xc0,
+	0xb8, 0x0e, 0x95, 0x17, 0x2e, 0xf7, 0xc4, 0x09, 0x87, 0x66, 0x51, 0x46, 0x49, 0x01, 0xec, 0x03,
+	0xf8, 0x81, 0xcb, 0x02, 0x97, 0xa7, 0x17, 0xf0, 0xd6, 0xc2, 0x43, 0xb8, 0x79, 0x98, 0x68, 0xe4,
+	0xa5, 0x1c, 0x64, 0x16, 0x61, 0x0f, 0x56, 0x13, 0xab, 0xd2, 0x8e, 0x1f, 0xb0, 0xb1, 0x28, 0x6f,
+	0x59, 0x6e, 0xb6, 0x12, 0x93, 0xfd, 0x09, 0x67, 0x87, 0x11, 0xb5, 0xf6, 0x39, 0x34, 0xe6, 0x42,
+	0x22, 0x81, 0xfc, 0x73, 0x3a, 0x8d, 0x4e, 0x6b, 0x20, 0x86, 0xd8, 0x84, 0xc2, 0xb9, 0x35, 0x9a,
+	0xa8, 0x73, 0x1a, 0x44, 0x93, 0x07, 0xfa, 0x7d, 0xed, 0x81, 0xf1, 0xfa, 0xd7, 0x9b, 0x5a, 0xe7,
+	0x77, 0x0d, 0x40, 0x58, 0xdc, 0x3e, 0xb3, 0x3c, 0x87, 0xe2, 0x27, 0xea, 0xf5, 0xd3, 0xe5, 0xeb,
+	0x77, 0x2d, 0x9b, 0x44, 0xa4, 0x58, 0x78, 0x00, 0xef, 0x42, 0xc9, 0x63, 0x36, 0x3d, 0x71, 0x6d,
+	0x75, 0xdc, 0x75, 0x41, 0x5e, 0xbc, 0xbb, 0x59, 0x3c, 0x60, 0x36, 0xdd, 0xdb, 0x19, 0x14, 0x05,
+	0xbd, 0x67, 0xa3, 0x99, 0x5e, 0xe2, 0xe8, 0xd3, 0x12, 0x4f, 0xb1, 0x0d, 0x65, 0x55, 0x8a, 0xe9,
+	0xcc, 0xd3, 0x97, 0xa0, 0xb8, 0x06, 0xba, 0x6b, 0xab, 0x26, 0x04, 0x15, 0x5f, 0xdf, 0xdb, 0x19,
+	0xe8, 0xae, 0xdd, 0xf9, 0x45, 0x03, 0x92, 0xfa, 0x3b, 0x72, 0x3d, 0x67, 0x94, 0xe6, 0xa1, 0xfd,
+	0x9b, 0x3c, 0xf4, 0x8f, 0xe6, 0x91, 0x75, 0x9b, 0xbf, 0xca, 0x6d, 0xe7, 0x37, 0x0d, 0x6a, 0xe9,
+	0x4e, 0x4f, 0x7b, 0xb8, 0x05, 0xc0, 0x03, 0xcb, 0x0b, 0x5d, 0xee, 0x32, 0x4f, 0x79, 0x5a, 0xbf,
+	0xc2, 0x53, 0xa2, 0x89, 0xdf, 0xc0, 0x74, 0x15, 0xde, 0x87, 0xd2, 0x50, 0xaa, 0xa2, 0x0b, 0x91,
+	0xf9, 0xbc, 0xcd, 0x27, 0x1f, 0xbf, 0xf6, 0x4a, 0x9e, 0x2d, 0x7c, 0x7e, 0xa6, 0xf0, 0x1b, 0xbb,
+	0x50, 0x49, 0xfe, 0x01, 0xb0, 0x01, 0x55, 0x39, 0x39, 0x60, 0xc1, 0xd8, 0x1a, 0x91, 0x1c, 0xae,
+	0x40, 0x43, 0x02, 0x69, 0x7c, 0xa2, 0xe1, 0x2a, 0x2c, 0xcf, 0x81, 0x4f, 0x7b, 0x44, 0xdf, 0x78,
+	0x65, 0x40, 0x35, 0xf3, 0x89, 0x44, 0x80, 0xe2, 0x7e, 0xe8, 0xec, 0x4e, 0x7c, 0x92, 0xc3, 0x2a,
+	0x94, 0xf6, 0x43, 0x67, 0x8b, 0x5a, 0x9c, 0x68, 0x6a, 0x72, 0x18, 0x30, 0x9f, 0xe8, 0x4a, 0xd5,
+	0xf7, 0x7d, 0x92, 0xc7, 0x3a, 0x40, 0x34, 0x1e, 0xd0, 0xd0, 0x27, 0x86, 0x12, 0x3e, 0x65, 0x9c,
+	0x92, 0x82, 0xf0, 0xa6, 0x26, 0x92, 0x2d, 0x2a, 0x56, 0x7c, 0x74, 0x48, 0x09, 0x09, 0xd4, 0xc4,
+	0x66, 0xd4, 0x0a, 0xf8, 0x33, 0xb1, 0x4b, 0x19, 0x9b, 0x40, 0xb2, 0x88, 0x5c, 0x54, 0x41, 0x84,
+	0xfa, 0x7e, 0xe8, 0x3c, 0xf1, 0x02, 0x6a, 0x0d, 0xcf, 0xac, 0x67, 0x23, 0x4a, 0x00, 0x97, 0x61,
+	0x49, 0x05, 0x12, 0x97, 0x74, 0x12, 0x92, 0xaa, 0x92, 0x6d, 0x9f, 0xd1, 0xe1, 0xf3, 0xc7, 0x13,
+	0x16, 0x4c, 0xc6, 0xa4, 0x26, 0xd2, 0xde, 0x0f, 0x1d, 0x79, 0x40, 0xa7, 0x34, 0x78, 0x44, 0x2d,
+	0x9b, 0x06, 0x64, 0x49, 0xad, 0x3e, 0x76, 0xc7, 0x94, 0x4d, 0xf8, 0x01, 0x7b, 0x41, 0xea, 0xca,
+	0xcc, 0x80, 0x5a, 0xb6, 0xfc, 0xf7, 0x22, 0x0d, 0x65, 0x26, 0x41, 0xa4, 0x19, 0xa2, 0xf2, 0x3d,
+	0x0c, 0xa8, 0x4c, 0x71, 0x59, 0xed, 0xaa, 0xe6, 0x52, 0x83, 0x6a, 0xe5, 0x11, 0x67, 0x81, 0xe5,
+	0xd0, 0xbe, 0xef, 0x53, 0xcf, 0x26, 0x2b, 0x68, 0x42, 0x73, 0x1e, 0x95, 0xfa, 0xa6, 0x38, 0xb1,
+	0x19, 0x66, 0x34, 0x25, 0xab, 0x78, 0x1d, 0x56, 0xe6, 0x40, 0xa9, 0xbe, 0xa6, 0xd4, 0x5f, 0xb0,
+	0xc0, 0xa1, 0x5c, 0x65, 0x74, 0x5d, 0xd9, 0x7a, 0x3c, 0x71, 0x69, 0x38, 0xa4, 0xc4, 0x54, 0xe9,
+	0x3c, 0xf1, 0xbe, 0x57, 0xc8, 0x0d, 0x85, 0x88, 0x8a, 0x6d, 0x9f, 0x4d, 0xbc, 0xe7, 0x64, 0x2d,
+	0xb6, 0x19, 0x23, 0x32, 0xfc, 0x7f, 0x36, 0x7e, 0xd2, 0xa0, 0x79, 0x55, 0x6f, 0xe3, 0x3a, 0x98,
+	0x57, 0xe1, 0xe2, 0x69, 0x23, 0x39, 0xfc, 0x2f, 0xdc, 0xba, 0x8a, 0xfd, 0x92, 0xb9, 0x1e, 0xdf,
+	0x1b, 0xfb, 0x23, 0x77, 0xe8, 0x8a, 0x3e, 0xfa, 0x98, 0xec, 0xe1, 0x4b, 0x25, 0xd3, 0x37, 0xfe,
+	0xd4, 0xa0, 0x3e, 0x7b, 0xe9, 0xc5, 0x51, 0xa6, 0x48, 0xdf, 0xb6, 0xc5, 0xf5, 0x26, 0x39, 0x51,
+	0xd5, 0x14, 0x1e, 0xd0, 0x31, 0x3b, 0xa7, 0x92, 0xd1, 0x66, 0x99, 0x27, 0xbe, 0x6d, 0xf1, 0x88,
+	0xd1, 0x67, 0x33, 0xe9, 0xdb, 0xf6, 0xa3, 0xe8, 0x95, 0x96, 0x6c, 0x7e, 0x76, 0x5d, 0xdf, 0xb6,
+	0xbf, 0x8e, 0x3e, 0x18, 0xc4, 0xc0, 0x1b, 0xb0, 0x9a, 0xb9, 0xb4, 0x94, 0xab, 0x67, 0x7c, 0x4a,
+	0x0a, 0x78, 0x17, 0x6e, 0x7f, 0x28, 0x64, 0xe6, 0xed, 0x27, 0xc5, 0xad, 0x3b, 0x6f, 0xde, 0xb7,
+	0x72, 0x6f, 0xdf, 0xb7, 0x72, 0x6f, 0x2e, 0x5a, 0xda, 0xdb, 0x8b, 0x96, 0xf6, 0xf7, 0x45, 0x4b,
+	0x7b, 0x75, 0xd9, 0xca, 0xbd, 0xbe, 0x6c, 0xe5, 0xde, 0x5e, 0xb6, 0x72, 0x7f, 0x5d, 0xb6, 0x72,
+	0xff, 0x0c, 0x00, 0x36, 0x0c, 0xc0, 0x3b, 0x63, 0x0c, 0x00, 0x00,
 }
 
 func (m *Entry) Marshal() (dAtA []byte, err error) {
@@ -939,6 +1062,39 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.TermBoundaries) > 0 {
+		for iNdEx := len(m.TermBoundaries) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.TermBoundaries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRaft(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x92
+		}
+	}
+	i = encodeVarintRaft(dAtA, i, uint64(m.SupportedCodecs))
+	i--
+	dAtA[i] = 0x1
+	i--
+	dAtA[i] = 0x88
+	if m.CompressedEntries != nil {
+		i -= len(m.CompressedEntries)
+		copy(dAtA[i:], m.CompressedEntries)
+		i = encodeVarintRaft(dAtA, i, uint64(len(m.CompressedEntries)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x82
+	}
+	i = encodeVarintRaft(dAtA, i, uint64(m.EntriesCodec))
+	i--
+	dAtA[i] = 0x78
 	if len(m.Responses) > 0 {
 		for iNdEx := len(m.Responses) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -1024,6 +1180,35 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *TermBoundary) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TermBoundary) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TermBoundary) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	i = encodeVarintRaft(dAtA, i, uint64(m.Index))
+	i--
+	dAtA[i] = 0x10
+	i = encodeVarintRaft(dAtA, i, uint64(m.Term))
+	i--
+	dAtA[i] = 0x8
+	return len(dAtA) - i, nil
+}
+
 func (m *HardState) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1076,6 +1261,40 @@ func (m *ConfState) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.LearnersAutoPromote) > 0 {
+		for iNdEx := len(m.LearnersAutoPromote) - 1; iNdEx >= 0; iNdEx-- {
+			i = encodeVarintRaft(dAtA, i, uint64(m.LearnersAutoPromote[iNdEx]))
+			i--
+			dAtA[i] = 0x40
+		}
+	}
+	if len(m.Priorities) > 0 {
+		keysForPriorities := make([]uint64, 0, len(m.Priorities))
+		for k := range m.Priorities {
+			keysForPriorities = append(keysForPriorities, uint64(k))
+		}
+		github_com_gogo_protobuf_sortkeys.Uint64s(keysForPriorities)
+		for iNdEx := len(keysForPriorities) - 1; iNdEx >= 0; iNdEx-- {
+			v := m.Priorities[uint64(keysForPriorities[iNdEx])]
+			baseI := i
+			i = encodeVarintRaft(dAtA, i, uint64(v))
+			i--
+			dAtA[i] = 0x10
+			i = encodeVarintRaft(dAtA, i, uint64(keysForPriorities[iNdEx]))
+			i--
+			dAtA[i] = 0x8
+			i = encodeVarintRaft(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x3a
+		}
+	}
+	if len(m.Witnesses) > 0 {
+		for iNdEx := len(m.Witnesses) - 1; iNdEx >= 0; iNdEx-- {
+			i = encodeVarintRaft(dAtA, i, uint64(m.Witnesses[iNdEx]))
+			i--
+			dAtA[i] = 0x30
+		}
+	}
 	i--
 	if m.AutoLeave {
 		dAtA[i] = 1
@@ -1135,6 +1354,9 @@ func (m *ConfChange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	i = encodeVarintRaft(dAtA, i, uint64(m.Priority))
+	i--
+	dAtA[i] = 0x28
 	if m.Context != nil {
 		i -= len(m.Context)
 		copy(dAtA[i:], m.Context)
@@ -1174,6 +1396,9 @@ func (m *ConfChangeSingle) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	i = encodeVarintRaft(dAtA, i, uint64(m.Priority))
+	i--
+	dAtA[i] = 0x18
 	i = encodeVarintRaft(dAtA, i, uint64(m.NodeID))
 	i--
 	dAtA[i] = 0x10
@@ -1321,6 +1546,29 @@ func (m *Message) Size() (n int) {
 			n += 1 + l + sovRaft(uint64(l))
 		}
 	}
+	n += 1 + sovRaft(uint64(m.EntriesCodec))
+	if m.CompressedEntries != nil {
+		l = len(m.CompressedEntries)
+		n += 2 + l + sovRaft(uint64(l))
+	}
+	n += 2 + sovRaft(uint64(m.SupportedCodecs))
+	if len(m.TermBoundaries) > 0 {
+		for _, e := range m.TermBoundaries {
+			l = e.Size()
+			n += 2 + l + sovRaft(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TermBoundary) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	n += 1 + sovRaft(uint64(m.Term))
+	n += 1 + sovRaft(uint64(m.Index))
 	return n
 }
 
@@ -1363,6 +1611,24 @@ func (m *ConfState) Size() (n int) {
 		}
 	}
 	n += 2
+	if len(m.Witnesses) > 0 {
+		for _, e := range m.Witnesses {
+			n += 1 + sovRaft(uint64(e))
+		}
+	}
+	if len(m.Priorities) > 0 {
+		for k, v := range m.Priorities {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + sovRaft(uint64(k)) + 1 + sovRaft(uint64(v))
+			n += mapEntrySize + 1 + sovRaft(uint64(mapEntrySize))
+		}
+	}
+	if len(m.LearnersAutoPromote) > 0 {
+		for _, e := range m.LearnersAutoPromote {
+			n += 1 + sovRaft(uint64(e))
+		}
+	}
 	return n
 }
 
@@ -1379,6 +1645,7 @@ func (m *ConfChange) Size() (n int) {
 		l = len(m.Context)
 		n += 1 + l + sovRaft(uint64(l))
 	}
+	n += 1 + sovRaft(uint64(m.Priority))
 	return n
 }
 
@@ -1390,6 +1657,7 @@ func (m *ConfChangeSingle) Size() (n int) {
 	_ = l
 	n += 1 + sovRaft(uint64(m.Type))
 	n += 1 + sovRaft(uint64(m.NodeID))
+	n += 1 + sovRaft(uint64(m.Priority))
 	return n
 }
 
@@ -2156,6 +2424,112 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EntriesCodec", wireType)
+			}
+			m.EntriesCodec = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EntriesCodec |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CompressedEntries", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRaft
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRaft
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CompressedEntries = append(m.CompressedEntries[:0], dAtA[iNdEx:postIndex]...)
+			if m.CompressedEntries == nil {
+				m.CompressedEntries = []byte{}
+			}
+			iNdEx = postIndex
+		case 17:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SupportedCodecs", wireType)
+			}
+			m.SupportedCodecs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SupportedCodecs |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TermBoundaries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRaft
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRaft
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TermBoundaries = append(m.TermBoundaries, TermBoundary{})
+			if err := m.TermBoundaries[len(m.TermBoundaries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRaft(dAtA[iNdEx:])
@@ -2177,7 +2551,7 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *HardState) Unmarshal(dAtA []byte) error {
+func (m *TermBoundary) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2200,10 +2574,10 @@ func (m *HardState) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: HardState: wiretype end group for non-group")
+			return fmt.Errorf("proto: TermBoundary: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: HardState: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TermBoundary: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -2227,28 +2601,9 @@ func (m *HardState) Unmarshal(dAtA []byte) error {
 			}
 		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Vote", wireType)
-			}
-			m.Vote = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRaft
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Vote |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Commit", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
 			}
-			m.Commit = 0
+			m.Index = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRaft
@@ -2258,7 +2613,7 @@ func (m *HardState) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Commit |= uint64(b&0x7F) << shift
+				m.Index |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -2284,7 +2639,7 @@ func (m *HardState) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ConfState) Unmarshal(dAtA []byte) error {
+func (m *HardState) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2307,21 +2662,128 @@ func (m *ConfState) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ConfState: wiretype end group for non-group")
+			return fmt.Errorf("proto: HardState: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ConfState: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: HardState: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType == 0 {
-				var v uint64
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowRaft
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Term", wireType)
+			}
+			m.Term = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Term |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Vote", wireType)
+			}
+			m.Vote = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Vote |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Commit", wireType)
+			}
+			m.Commit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Commit |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRaft(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRaft
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ConfState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRaft
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ConfState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ConfState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
@@ -2637,6 +3099,257 @@ func (m *ConfState) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.AutoLeave = bool(v != 0)
+		case 6:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.Witnesses = append(m.Witnesses, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthRaft
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthRaft
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Witnesses) == 0 {
+					m.Witnesses = make([]uint64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRaft
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Witnesses = append(m.Witnesses, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Witnesses", wireType)
+			}
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priorities", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRaft
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRaft
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Priorities == nil {
+				m.Priorities = make(map[uint64]uint64)
+			}
+			var mapkey uint64
+			var mapvalue uint64
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRaft
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+				} else if fieldNum == 2 {
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRaft
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipRaft(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthRaft
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Priorities[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 8:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.LearnersAutoPromote = append(m.LearnersAutoPromote, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRaft
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthRaft
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthRaft
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.LearnersAutoPromote) == 0 {
+					m.LearnersAutoPromote = make([]uint64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRaft
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.LearnersAutoPromote = append(m.LearnersAutoPromote, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field LearnersAutoPromote", wireType)
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRaft(dAtA[iNdEx:])
@@ -2778,6 +3491,25 @@ func (m *ConfChange) Unmarshal(dAtA []byte) error {
 				m.Context = []byte{}
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priority", wireType)
+			}
+			m.Priority = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Priority |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRaft(dAtA[iNdEx:])
@@ -2866,6 +3598,25 @@ func (m *ConfChangeSingle) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priority", wireType)
+			}
+			m.Priority = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRaft
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Priority |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRaft(dAtA[iNdEx:])
@@ -34,25 +34,28 @@ func TestProtoMemorySizes(t *testing.T) {
 	assert.Equal(t, if64Bit(48, 32), unsafe.Sizeof(e), "Entry size check")
 
 	var sm SnapshotMetadata
-	assert.Equal(t, if64Bit(120, 68), unsafe.Sizeof(sm), "SnapshotMetadata size check")
+	assert.Equal(t, if64Bit(176, 96), unsafe.Sizeof(sm), "SnapshotMetadata size check")
 
 	var s Snapshot
-	assert.Equal(t, if64Bit(144, 80), unsafe.Sizeof(s), "Snapshot size check")
+	assert.Equal(t, if64Bit(200, 108), unsafe.Sizeof(s), "Snapshot size check")
 
 	var m Message
-	assert.Equal(t, if64Bit(160, 112), unsafe.Sizeof(m), "Message size check")
+	assert.Equal(t, if64Bit(224, 148), unsafe.Sizeof(m), "Message size check")
+
+	var tb TermBoundary
+	assert.Equal(t, uintptr(16), unsafe.Sizeof(tb), "TermBoundary size check")
 
 	var hs HardState
 	assert.Equal(t, uintptr(24), unsafe.Sizeof(hs), "HardState size check")
 
 	var cs ConfState
-	assert.Equal(t, if64Bit(104, 52), unsafe.Sizeof(cs), "ConfState size check")
+	assert.Equal(t, if64Bit(160, 80), unsafe.Sizeof(cs), "ConfState size check")
 
 	var cc ConfChange
-	assert.Equal(t, if64Bit(48, 32), unsafe.Sizeof(cc), "ConfChange size check")
+	assert.Equal(t, if64Bit(56, 40), unsafe.Sizeof(cc), "ConfChange size check")
 
 	var ccs ConfChangeSingle
-	assert.Equal(t, if64Bit(16, 12), unsafe.Sizeof(ccs), "ConfChangeSingle size check")
+	assert.Equal(t, if64Bit(24, 20), unsafe.Sizeof(ccs), "ConfChangeSingle size check")
 
 	var ccv2 ConfChangeV2
 	assert.Equal(t, if64Bit(56, 28), unsafe.Sizeof(ccv2), "ConfChangeV2 size check")
@@ -35,13 +35,17 @@ type InteractionOpts struct {
 
 // Node is a member of a raft group tested via an InteractionEnv.
 type Node struct {
-	*raft.RawNode
+	*raft.RawNode // nil if the node is crashed
 	Storage
 
 	Config     *raft.Config
 	AppendWork []pb.Message // []MsgStorageAppend
 	ApplyWork  []pb.Message // []MsgStorageApply
 	History    []pb.Snapshot
+
+	// durable is the state of the Storage as of its last sync, which survives a
+	// crash of the node.
+	durable durableState
 }
 
 // InteractionEnv facilitates testing of complex interactions between the
@@ -52,6 +56,14 @@ type InteractionEnv struct {
 	Messages []pb.Message // in-flight messages
 
 	Output *RedirectLogger
+
+	// partitioned contains the pairs of nodes which can't talk to each other,
+	// as {lower ID, higher ID}.
+	partitioned map[[2]uint64]bool
+	// holds are the filters of the messages held back from delivery, and held
+	// contains these messages.
+	holds []MsgFilter
+	held  []pb.Message
 }
 
 // NewInteractionEnv initializes an InteractionEnv. opts may be nil.
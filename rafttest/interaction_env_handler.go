@@ -28,6 +28,9 @@ import (
 // storage engine are reported to the output buffer.
 func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 	env.Output.Reset()
+	if err := env.checkCrashed(t, d); err != nil {
+		return env.result(err)
+	}
 	var err error
 	switch d.Cmd {
 	case "_breakpoint":
@@ -44,6 +47,12 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// Example:
 		//
 		// add-nodes <number-of-nodes-to-add> voters=(1 2 3) learners=(4 5) index=2 content=foo async-storage-writes=true
+		// add-nodes 3 voters=(1 2 3) priorities=(1:2 2:1) priority=1
+		//
+		// With bootstrap=true, the nodes are bootstrapped with the voters by
+		// raft.RawNode.Bootstrap rather than from a snapshot.
+		//
+		// add-nodes 3 voters=(1 2 3) bootstrap=true
 		err = env.handleAddNodes(t, d)
 	case "campaign":
 		// Example:
@@ -62,6 +71,87 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		//
 		// deliver-msgs <idx> type=MsgApp drop=(2,3)
 		err = env.handleDeliverMsgs(t, d)
+	case "deliver-msg":
+		// Deliver the first in-flight message of the given type from the given
+		// sender to the given recipient, whose other fields match the given
+		// ones. The entries are the number of entries, or the snapshot index
+		// of a MsgSnap.
+		//
+		// Example:
+		//
+		// deliver-msg from=1 to=2 type=MsgApp term=2 log-term=1 index=5 entries=1
+		err = env.handleDeliverMsg(t, d)
+	case "duplicate-msgs":
+		// Duplicate the in-flight messages for the given recipients (all if
+		// none are given), optionally only those of the given type. The copies
+		// are delivered after the originals.
+		//
+		// Example:
+		//
+		// duplicate-msgs 2 type=MsgApp
+		err = env.handleDuplicateMsgs(t, d)
+	case "reorder-msgs":
+		// Reorder the in-flight messages for the given recipient, optionally
+		// only those of the given type. The order lists the (1-based) positions
+		// of the messages in their new order, and defaults to the reverse
+		// order. The new order is printed.
+		//
+		// Example:
+		//
+		// reorder-msgs 2 type=MsgApp order=(3,1,2)
+		err = env.handleReorderMsgs(t, d)
+	case "hold-msgs":
+		// Hold back the messages for the given recipients (all if none are
+		// given), optionally only those of the given type, from delivery until
+		// they are released. This applies to the messages in flight, which are
+		// printed, and to the messages sent later.
+		//
+		// Example:
+		//
+		// hold-msgs 3 type=MsgApp
+		err = env.handleHoldMsgs(t, d)
+	case "release-msgs":
+		// Stop holding back the messages, and put the held messages back in
+		// flight. The arguments must match those of a previous hold-msgs, and
+		// all messages are released if none are given.
+		//
+		// Example:
+		//
+		// release-msgs 3 type=MsgApp
+		err = env.handleReleaseMsgs(t, d)
+	case "crash":
+		// Crash the node. Its pending append and apply work is discarded, and
+		// its storage is rolled back to the last synced state, which is
+		// printed. The messages to a crashed node are dropped, and the
+		// commands operating on it fail until it is restarted.
+		//
+		// Example:
+		//
+		// crash 2
+		err = env.handleCrash(t, d)
+	case "restart":
+		// Restart a crashed node from its storage, and print its state.
+		//
+		// Example:
+		//
+		// restart 2
+		err = env.handleRestart(t, d)
+	case "partition":
+		// Cut the links between the given nodes and all the other nodes, until
+		// heal is called. The messages sent over a cut link are dropped when
+		// they are delivered. Partitions accumulate.
+		//
+		// Example:
+		//
+		// partition 1 2
+		err = env.handlePartition(t, d)
+	case "heal":
+		// Restore all the links cut by partition.
+		//
+		// Example:
+		//
+		// heal
+		err = env.handleHeal()
 	case "process-ready":
 		// Example:
 		//
@@ -73,9 +163,15 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// process-append-thread 3
 		err = env.handleProcessAppendThread(t, d)
 	case "process-apply-thread":
+		// Processes the first message on the apply thread. With entries=N, at
+		// most N of its entries are applied, and the apply progress is
+		// reported to raft. The responses are delivered once all entries of
+		// the message have been applied.
+		//
 		// Example:
 		//
 		// process-apply-thread 3
+		// process-apply-thread 3 entries=2
 		err = env.handleProcessApplyThread(t, d)
 	case "log-level":
 		// Set the log level. NONE disables all output, including from the test
@@ -136,11 +232,13 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// tick-heartbeat 3
 		err = env.handleTickHeartbeat(t, d)
 	case "transfer-leadership":
-		// Transfer the Raft leader.
+		// Transfer the Raft leader. If "to" is omitted, the leader picks the
+		// target.
 		//
 		// Example:
 		//
 		// transfer-leadership from=1 to=4
+		// transfer-leadership from=1
 		err = env.handleTransferLeadership(t, d)
 	case "forget-leader":
 		// Forgets the current leader of the given node.
@@ -186,15 +284,34 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// propose-conf-change 2 v1=true
 		// v5
 		err = env.handleProposeConfChange(t, d)
+	case "read-index":
+		// Request a read index with the given context.
+		//
+		// Example:
+		//
+		// read-index 1 foo
+		err = env.handleReadIndex(t, d)
 	case "report-unreachable":
 		// Calls <1st>.ReportUnreachable(<2nd>).
 		//
 		// Example:
 		// report-unreachable 1 2
 		err = env.handleReportUnreachable(t, d)
+	case "unquiesce":
+		// Wakes up the given quiesced node.
+		//
+		// Example:
+		//
+		// unquiesce 2
+		err = env.handleUnquiesce(t, d)
 	default:
 		err = fmt.Errorf("unknown command")
 	}
+	return env.result(err)
+}
+
+// result returns the output of a command which returned the given error.
+func (env *InteractionEnv) result(err error) string {
 	// NB: the highest log level suppresses all output, including that of the
 	// handlers. This comes in useful during setup which can be chatty.
 	// However, errors are always logged.
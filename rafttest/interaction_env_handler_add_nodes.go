@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/datadriven"
@@ -29,6 +31,7 @@ import (
 func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) error {
 	n := firstAsInt(t, d)
 	var snap pb.Snapshot
+	var bootstrap bool
 	cfg := raftConfigStub()
 	for _, arg := range d.CmdArgs[1:] {
 		for i := range arg.Vals {
@@ -41,6 +44,11 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 				var id uint64
 				arg.Scan(t, i, &id)
 				snap.Metadata.ConfState.Learners = append(snap.Metadata.ConfState.Learners, id)
+			case "witnesses":
+				var id uint64
+				arg.Scan(t, i, &id)
+				snap.Metadata.ConfState.Voters = append(snap.Metadata.ConfState.Voters, id)
+				snap.Metadata.ConfState.Witnesses = append(snap.Metadata.ConfState.Witnesses, id)
 			case "inflight":
 				arg.Scan(t, i, &cfg.MaxInflightMsgs)
 			case "index":
@@ -48,6 +56,8 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 				cfg.Applied = snap.Metadata.Index
 			case "content":
 				arg.Scan(t, i, &snap.Data)
+			case "bootstrap":
+				arg.Scan(t, i, &bootstrap)
 			case "async-storage-writes":
 				arg.Scan(t, i, &cfg.AsyncStorageWrites)
 			case "prevote":
@@ -56,6 +66,10 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 				arg.Scan(t, i, &cfg.CheckQuorum)
 			case "max-committed-size-per-ready":
 				arg.Scan(t, i, &cfg.MaxCommittedSizePerReady)
+			case "max-apply-lag":
+				arg.Scan(t, i, &cfg.MaxApplyLag)
+			case "max-proposal-apply-lag":
+				arg.Scan(t, i, &cfg.MaxProposalApplyLag)
 			case "disable-conf-change-validation":
 				arg.Scan(t, i, &cfg.DisableConfChangeValidation)
 			case "read-only":
@@ -69,10 +83,66 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 				}
 			case "step-down-on-removal":
 				arg.Scan(t, i, &cfg.StepDownOnRemoval)
+			case "lease-ticks":
+				arg.Scan(t, i, &cfg.LeaseTicks)
+			case "max-clock-skew-ticks":
+				arg.Scan(t, i, &cfg.MaxClockSkewTicks)
+			case "quiescence":
+				arg.Scan(t, i, &cfg.Quiescence)
+			case "max-append-bytes-per-tick":
+				arg.Scan(t, i, &cfg.MaxAppendBytesPerTick)
+			case "max-append-burst-bytes":
+				arg.Scan(t, i, &cfg.MaxAppendBurstBytes)
+			case "priority":
+				arg.Scan(t, i, &cfg.Priority)
+			case "priorities":
+				// Priorities in the membership config, as id:priority.
+				var s string
+				arg.Scan(t, i, &s)
+				idStr, prioStr, _ := strings.Cut(s, ":")
+				id, err := strconv.ParseUint(idStr, 10, 64)
+				if err != nil {
+					return err
+				}
+				prio, err := strconv.ParseUint(prioStr, 10, 64)
+				if err != nil {
+					return err
+				}
+				if snap.Metadata.ConfState.Priorities == nil {
+					snap.Metadata.ConfState.Priorities = map[uint64]uint64{}
+				}
+				snap.Metadata.ConfState.Priorities[id] = prio
+			case "entry-codecs":
+				switch arg.Vals[i] {
+				case "flate":
+					cfg.EntryCodecs = append(cfg.EntryCodecs, raft.FlateCodec{})
+				default:
+					return fmt.Errorf("unknown codec %q", arg.Vals[i])
+				}
+			case "entry-compression-threshold":
+				arg.Scan(t, i, &cfg.EntryCompressionThreshold)
+			case "auto-promote-max-lag":
+				arg.Scan(t, i, &cfg.AutoPromoteMaxLag)
+			case "transfer-preference":
+				var id uint64
+				arg.Scan(t, i, &id)
+				cfg.LeaderTransferPreference = append(cfg.LeaderTransferPreference, id)
 			}
 		}
 	}
-	return env.AddNodes(n, cfg, snap)
+	if !bootstrap {
+		return env.AddNodes(n, cfg, snap)
+	}
+	cs := snap.Metadata.ConfState
+	if snap.Metadata.Index != 0 || len(cs.Learners) != 0 || len(cs.Witnesses) != 0 ||
+		len(cs.Priorities) != 0 || len(snap.Data) != 0 {
+		return errors.New("bootstrap only supports voters")
+	}
+	peers := make([]raft.Peer, len(cs.Voters))
+	for i, id := range cs.Voters {
+		peers[i].ID = id
+	}
+	return env.addNodes(n, cfg, pb.Snapshot{}, peers)
 }
 
 type snapOverrideStorage struct {
@@ -92,22 +162,16 @@ var _ raft.Storage = snapOverrideStorage{}
 // AddNodes adds n new nodes initialized from the given snapshot (which may be
 // empty), and using the cfg as template. They will be assigned consecutive IDs.
 func (env *InteractionEnv) AddNodes(n int, cfg raft.Config, snap pb.Snapshot) error {
+	return env.addNodes(n, cfg, snap, nil /* peers */)
+}
+
+// addNodes is like AddNodes, but the nodes are bootstrapped with
+// RawNode.Bootstrap if peers are given, rather than from the snapshot.
+func (env *InteractionEnv) addNodes(n int, cfg raft.Config, snap pb.Snapshot, peers []raft.Peer) error {
 	bootstrap := !reflect.DeepEqual(snap, pb.Snapshot{})
 	for i := 0; i < n; i++ {
 		id := uint64(1 + len(env.Nodes))
-		s := snapOverrideStorage{
-			Storage: raft.NewMemoryStorage(),
-			// When you ask for a snapshot, you get the most recent snapshot.
-			//
-			// TODO(tbg): this is sort of clunky, but MemoryStorage itself will
-			// give you some fixed snapshot and also the snapshot changes
-			// whenever you compact the logs and vice versa, so it's all a bit
-			// awkward to use.
-			snapshotOverride: func() (pb.Snapshot, error) {
-				snaps := env.Nodes[int(id-1)].History
-				return snaps[len(snaps)-1], nil
-			},
-		}
+		s := env.newStorage(id)
 		if bootstrap {
 			// NB: we could make this work with 1, but MemoryStorage just
 			// doesn't play well with that and it's not a loss of generality.
@@ -148,6 +212,11 @@ func (env *InteractionEnv) AddNodes(n int, cfg raft.Config, snap pb.Snapshot) er
 		if err != nil {
 			return err
 		}
+		if len(peers) != 0 {
+			if err := rn.Bootstrap(peers); err != nil {
+				return err
+			}
+		}
 
 		node := Node{
 			RawNode: rn,
@@ -157,7 +226,27 @@ func (env *InteractionEnv) AddNodes(n int, cfg raft.Config, snap pb.Snapshot) er
 			Config:  &cfg,
 			History: []pb.Snapshot{snap},
 		}
+		if err := node.sync(); err != nil {
+			return err
+		}
 		env.Nodes = append(env.Nodes, node)
 	}
 	return nil
 }
+
+// newStorage returns an empty storage for the node with the given ID.
+func (env *InteractionEnv) newStorage(id uint64) snapOverrideStorage {
+	return snapOverrideStorage{
+		Storage: raft.NewMemoryStorage(),
+		// When you ask for a snapshot, you get the most recent snapshot.
+		//
+		// TODO(tbg): this is sort of clunky, but MemoryStorage itself will
+		// give you some fixed snapshot and also the snapshot changes
+		// whenever you compact the logs and vice versa, so it's all a bit
+		// awkward to use.
+		snapshotOverride: func() (pb.Snapshot, error) {
+			snaps := env.Nodes[int(id-1)].History
+			return snaps[len(snaps)-1], nil
+		},
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func (env *InteractionEnv) handleCrash(t *testing.T, d datadriven.TestData) error {
+	idx := firstAsNodeIdx(t, d)
+	return env.Crash(idx)
+}
+
+func (env *InteractionEnv) handleRestart(t *testing.T, d datadriven.TestData) error {
+	idx := firstAsNodeIdx(t, d)
+	return env.Restart(idx)
+}
+
+// checkCrashed returns an error if the command operates on the RawNode of a
+// crashed node.
+func (env *InteractionEnv) checkCrashed(t *testing.T, d datadriven.TestData) error {
+	var idxs []int
+	switch d.Cmd {
+	case "campaign", "forget-leader", "propose", "propose-conf-change", "read-index",
+		"set-randomized-election-timeout", "status", "tick-election", "tick-heartbeat", "unquiesce":
+		idxs = []int{firstAsNodeIdx(t, d)}
+	case "process-ready", "process-append-thread", "process-apply-thread":
+		idxs = nodeIdxs(t, d)
+	case "report-unreachable", "send-snapshot":
+		// Only the first node is used.
+		if idxs = nodeIdxs(t, d); len(idxs) > 1 {
+			idxs = idxs[:1]
+		}
+	case "transfer-leadership":
+		var from int
+		d.ScanArgs(t, "from", &from)
+		idxs = []int{from - 1}
+	}
+	for _, idx := range idxs {
+		if idx >= 0 && idx < len(env.Nodes) && env.Nodes[idx].RawNode == nil {
+			return fmt.Errorf("node %d is crashed", idx+1)
+		}
+	}
+	return nil
+}
+
+// Crash crashes the node at the given index. Its pending append and apply work
+// is discarded, and its Storage is rolled back to the last synced state: the
+// one acknowledged by the last processed MsgStorageAppend with asynchronous
+// storage writes, or by the last Ready which had to be synced otherwise. The
+// applied state, i.e. the History, is durable. The messages to a crashed node
+// are dropped when they are delivered.
+func (env *InteractionEnv) Crash(idx int) error {
+	n := &env.Nodes[idx]
+	if n.RawNode == nil {
+		return fmt.Errorf("node %d is already crashed", idx+1)
+	}
+	for _, m := range n.AppendWork {
+		fmt.Fprintln(env.Output, "discarded:", raft.DescribeMessage(m, defaultEntryFormatter))
+	}
+	for _, m := range n.ApplyWork {
+		fmt.Fprintln(env.Output, "discarded:", raft.DescribeMessage(m, defaultEntryFormatter))
+	}
+	n.RawNode, n.AppendWork, n.ApplyWork = nil, nil, nil
+	if err := env.rollback(idx); err != nil {
+		return err
+	}
+	return env.describeStorage(idx)
+}
+
+// Restart restarts the crashed node at the given index from its Storage, like
+// raft.RestartNode does.
+func (env *InteractionEnv) Restart(idx int) error {
+	n := &env.Nodes[idx]
+	if n.RawNode != nil {
+		return fmt.Errorf("node %d is not crashed", idx+1)
+	}
+	cfg := *n.Config // fork the config
+	cfg.Storage = n.Storage
+	cfg.Applied = n.History[len(n.History)-1].Metadata.Index
+	rn, err := raft.NewRawNode(&cfg)
+	if err != nil {
+		return err
+	}
+	n.RawNode, n.Config = rn, &cfg
+	st := rn.BasicStatus()
+	fmt.Fprintf(env.Output, "%d: %s Term:%d Commit:%d Applied:%d\n",
+		st.ID, st.RaftState, st.Term, st.Commit, st.Applied)
+	return nil
+}
+
+// rollback replaces the Storage of the node at the given index with one
+// containing its durable state.
+func (env *InteractionEnv) rollback(idx int) error {
+	n := &env.Nodes[idx]
+	d := n.durable
+	// The node restarts from the latest snapshot of the state machine, or from
+	// the snapshot received from the leader if it's more recent.
+	snap := n.History[len(n.History)-1]
+	if d.snap.Metadata.Index > snap.Metadata.Index {
+		snap = d.snap
+		n.History = append(n.History, snap)
+	}
+	s := env.newStorage(uint64(idx + 1))
+	if !raft.IsEmptySnap(snap) {
+		if err := s.ApplySnapshot(snap); err != nil {
+			return err
+		}
+	}
+	ents := d.ents
+	for len(ents) != 0 && ents[0].Index <= snap.Metadata.Index {
+		ents = ents[1:]
+	}
+	if len(ents) != 0 && ents[0].Index != snap.Metadata.Index+1 {
+		return fmt.Errorf("durable log starting at index %d doesn't connect to the applied index %d",
+			ents[0].Index, snap.Metadata.Index)
+	}
+	if err := s.Append(ents); err != nil {
+		return err
+	}
+	hs := d.hs
+	// The applied entries are committed, even if the commit index was not
+	// synced.
+	hs.Commit = max(hs.Commit, snap.Metadata.Index)
+	if err := s.SetHardState(hs); err != nil {
+		return err
+	}
+	n.Storage = s
+	return nil
+}
+
+// describeStorage prints the state of the Storage of the node at the given
+// index.
+func (env *InteractionEnv) describeStorage(idx int) error {
+	hs, _, err := env.Nodes[idx].Storage.InitialState()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Output, raft.DescribeHardState(hs))
+	return env.RaftLog(idx)
+}
+
+// sync makes the current state of the node's Storage durable.
+func (n *Node) sync() error {
+	hs, _, err := n.Storage.InitialState()
+	if err != nil {
+		return err
+	}
+	// Bypass the snapshot override, which returns the state machine's snapshot
+	// rather than the one in the Storage.
+	var snap pb.Snapshot
+	if s, ok := n.Storage.(snapOverrideStorage); ok {
+		snap, err = s.Storage.Snapshot()
+	} else {
+		snap, err = n.Storage.Snapshot()
+	}
+	if err != nil {
+		return err
+	}
+	fi, err := n.Storage.FirstIndex()
+	if err != nil {
+		return err
+	}
+	li, err := n.Storage.LastIndex()
+	if err != nil {
+		return err
+	}
+	var ents []pb.Entry
+	if li >= fi {
+		if ents, err = n.Storage.Entries(fi, li+1, math.MaxUint64); err != nil {
+			return err
+		}
+		ents = append([]pb.Entry(nil), ents...)
+	}
+	n.durable = durableState{hs: hs, snap: snap, ents: ents}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+func (env *InteractionEnv) handleDeliverMsg(t *testing.T, d datadriven.TestData) error {
+	var p MsgPattern
+	var hasType bool
+	for _, arg := range d.CmdArgs {
+		for i := range arg.Vals {
+			switch arg.Key {
+			case "from":
+				arg.Scan(t, i, &p.From)
+			case "to":
+				arg.Scan(t, i, &p.To)
+			case "type":
+				p.Type, hasType = parseMsgType(t, arg, i), true
+			case "term":
+				arg.Scan(t, i, &p.Term)
+			case "log-term":
+				arg.Scan(t, i, &p.LogTerm)
+			case "index":
+				arg.Scan(t, i, &p.Index)
+			case "commit":
+				arg.Scan(t, i, &p.Commit)
+			case "entries":
+				arg.Scan(t, i, &p.Entries)
+			case "reject":
+				arg.Scan(t, i, &p.Reject)
+			case "reject-hint":
+				arg.Scan(t, i, &p.RejectHint)
+			default:
+				return fmt.Errorf("unknown argument %s", arg.Key)
+			}
+		}
+	}
+	if p.From == 0 || p.To == 0 || !hasType {
+		return errors.New("must specify from, to and type")
+	}
+	if !env.DeliverMsg(p) {
+		env.Output.WriteString("no messages\n")
+	}
+	return nil
+}
+
+// MsgPattern matches the messages of the given type from the given sender to
+// the given recipient, whose other fields equal the non-zero fields of the
+// pattern. Reject is always compared. Entries is the number of entries of the
+// message, or the index of the snapshot of a MsgSnap, like in the traces
+// validated by the TLA+ spec.
+type MsgPattern struct {
+	From, To   uint64
+	Type       raftpb.MessageType
+	Term       uint64
+	LogTerm    uint64
+	Index      uint64
+	Commit     uint64
+	Entries    int
+	Reject     bool
+	RejectHint uint64
+}
+
+func (p MsgPattern) matches(msg raftpb.Message) bool {
+	entries := len(msg.Entries)
+	if msg.Type == raftpb.MsgSnap && msg.Snapshot != nil {
+		entries = int(msg.Snapshot.Metadata.Index)
+	}
+	eq := func(want, got uint64) bool { return want == 0 || want == got }
+	return msg.From == p.From && msg.To == p.To && msg.Type == p.Type &&
+		eq(p.Term, msg.Term) && eq(p.LogTerm, msg.LogTerm) && eq(p.Index, msg.Index) &&
+		eq(p.Commit, msg.Commit) && eq(uint64(p.Entries), uint64(entries)) &&
+		msg.Reject == p.Reject && eq(p.RejectHint, msg.RejectHint)
+}
+
+// String returns the deliver-msg command delivering the matching messages.
+func (p MsgPattern) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "deliver-msg from=%d to=%d type=%s", p.From, p.To, p.Type)
+	for _, f := range []struct {
+		key string
+		val uint64
+	}{
+		{"term", p.Term},
+		{"log-term", p.LogTerm},
+		{"index", p.Index},
+		{"commit", p.Commit},
+		{"entries", uint64(p.Entries)},
+	} {
+		if f.val != 0 {
+			fmt.Fprintf(&b, " %s=%d", f.key, f.val)
+		}
+	}
+	if p.Reject {
+		b.WriteString(" reject=true")
+	}
+	if p.RejectHint != 0 {
+		fmt.Fprintf(&b, " reject-hint=%d", p.RejectHint)
+	}
+	return b.String()
+}
+
+// DeliverMsg delivers the first in-flight message matching the pattern, like
+// DeliverMsgs does, and removes it from env.Messages. The messages held back by
+// HoldMsgs are skipped. Returns false if there is no such message.
+func (env *InteractionEnv) DeliverMsg(p MsgPattern) bool {
+	env.holdMsgs()
+	for i, msg := range env.Messages {
+		if !p.matches(msg) {
+			continue
+		}
+		env.Messages = append(env.Messages[:i], env.Messages[i+1:]...)
+		env.deliverMsg(msg, false /* drop */)
+		return true
+	}
+	return false
+}
@@ -52,13 +52,7 @@ func (env *InteractionEnv) handleDeliverMsgs(t *testing.T, d datadriven.TestData
 				}
 				rs = append(rs, Recipient{ID: id, Drop: true})
 			case "type":
-				var s string
-				arg.Scan(t, i, &s)
-				v, ok := raftpb.MessageType_value[s]
-				if !ok {
-					t.Fatalf("unknown message type %s", s)
-				}
-				typ = raftpb.MessageType(v)
+				typ = parseMsgType(t, arg, i)
 			}
 		}
 	}
@@ -76,29 +70,46 @@ type Recipient struct {
 
 // DeliverMsgs goes through env.Messages and, depending on the Drop flag,
 // delivers or drops messages to the specified Recipients. Only messages of type
-// typ are delivered (-1 for all types). Returns the number of messages handled
-// (i.e. delivered or dropped). A handled message is removed from env.Messages.
+// typ are delivered (-1 for all types). The messages held back by HoldMsgs are
+// skipped, and the ones sent over a link cut by Partition are dropped. Returns
+// the number of messages handled (i.e. delivered or dropped). A handled message
+// is removed from env.Messages.
 func (env *InteractionEnv) DeliverMsgs(typ raftpb.MessageType, rs ...Recipient) int {
+	env.holdMsgs()
 	var n int
 	for _, r := range rs {
 		var msgs []raftpb.Message
 		msgs, env.Messages = splitMsgs(env.Messages, r.ID, typ, r.Drop)
 		n += len(msgs)
 		for _, msg := range msgs {
-			if r.Drop {
-				fmt.Fprint(env.Output, "dropped: ")
-			}
-			fmt.Fprintln(env.Output, raft.DescribeMessage(msg, defaultEntryFormatter))
-			if r.Drop {
-				// NB: it's allowed to drop messages to nodes that haven't been instantiated yet,
-				// we haven't used msg.To yet.
-				continue
-			}
-			toIdx := int(msg.To - 1)
-			if err := env.Nodes[toIdx].Step(msg); err != nil {
-				fmt.Fprintln(env.Output, err)
-			}
+			env.deliverMsg(msg, r.Drop)
 		}
 	}
 	return n
 }
+
+// deliverMsg delivers or drops the given message, which must have been removed
+// from env.Messages.
+func (env *InteractionEnv) deliverMsg(msg raftpb.Message, drop bool) {
+	if drop {
+		fmt.Fprint(env.Output, "dropped: ")
+	} else if !isLocalMsg(msg) && env.isPartitioned(msg.From, msg.To) {
+		fmt.Fprint(env.Output, "dropped (partitioned): ")
+	} else if env.Nodes[msg.To-1].RawNode == nil {
+		fmt.Fprint(env.Output, "dropped (crashed): ")
+	}
+	fmt.Fprintln(env.Output, raft.DescribeMessage(msg, defaultEntryFormatter))
+	if drop {
+		// NB: it's allowed to drop messages to nodes that haven't been instantiated yet,
+		// we haven't used msg.To yet.
+		return
+	}
+	if !isLocalMsg(msg) && env.isPartitioned(msg.From, msg.To) ||
+		env.Nodes[msg.To-1].RawNode == nil {
+		return
+	}
+	toIdx := int(msg.To - 1)
+	if err := env.Nodes[toIdx].Step(msg); err != nil {
+		fmt.Fprintln(env.Output, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+
+	"go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+func (env *InteractionEnv) handleDuplicateMsgs(t *testing.T, d datadriven.TestData) error {
+	if n := env.DuplicateMsgs(parseMsgFilters(t, d)...); n == 0 {
+		env.Output.WriteString("no messages\n")
+	}
+	return nil
+}
+
+// DuplicateMsgs duplicates the in-flight messages matching any of the given
+// filters. The copies are put in flight after the other messages, so they are
+// delivered after the originals. Local messages are never duplicated. Returns
+// the number of duplicated messages.
+func (env *InteractionEnv) DuplicateMsgs(fs ...MsgFilter) int {
+	var dups []raftpb.Message
+	for _, msg := range env.Messages {
+		if isLocalMsg(msg) {
+			continue
+		}
+		for _, f := range fs {
+			if f.matches(msg) {
+				dups = append(dups, copyMsg(msg))
+				break
+			}
+		}
+	}
+	for _, msg := range dups {
+		fmt.Fprintln(env.Output, "duplicated:", raft.DescribeMessage(msg, defaultEntryFormatter))
+	}
+	env.Messages = append(env.Messages, dups...)
+	return len(dups)
+}
+
+// copyMsg returns a deep copy of the message, which shares no memory with it,
+// like a message received from the network.
+func copyMsg(msg raftpb.Message) raftpb.Message {
+	b, err := msg.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	var cpy raftpb.Message
+	if err := cpy.Unmarshal(b); err != nil {
+		panic(err)
+	}
+	return cpy
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+
+	"go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+// MsgFilter matches the messages of the given type (-1 for all types) to the
+// given recipient (0 for all recipients).
+type MsgFilter struct {
+	To   uint64
+	Type raftpb.MessageType
+}
+
+func (f MsgFilter) matches(msg raftpb.Message) bool {
+	return (f.To == 0 || msg.To == f.To) && (f.Type < 0 || msg.Type == f.Type)
+}
+
+// parseMsgFilters parses the recipients and the type= argument of a command
+// into filters, one per recipient, or a single one matching all recipients.
+func parseMsgFilters(t *testing.T, d datadriven.TestData) []MsgFilter {
+	var typ raftpb.MessageType = -1
+	var tos []uint64
+	for _, arg := range d.CmdArgs {
+		if len(arg.Vals) == 0 {
+			id, err := strconv.ParseUint(arg.Key, 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tos = append(tos, id)
+		}
+		for i := range arg.Vals {
+			switch arg.Key {
+			case "type":
+				typ = parseMsgType(t, arg, i)
+			}
+		}
+	}
+	if len(tos) == 0 {
+		return []MsgFilter{{Type: typ}}
+	}
+	fs := make([]MsgFilter, len(tos))
+	for i, to := range tos {
+		fs[i] = MsgFilter{To: to, Type: typ}
+	}
+	return fs
+}
+
+// parseMsgType parses the i-th value of the argument as a message type.
+func parseMsgType(t *testing.T, arg datadriven.CmdArg, i int) raftpb.MessageType {
+	var s string
+	arg.Scan(t, i, &s)
+	v, ok := raftpb.MessageType_value[s]
+	if !ok {
+		t.Fatalf("unknown message type %s", s)
+	}
+	return raftpb.MessageType(v)
+}
+
+func (env *InteractionEnv) handleHoldMsgs(t *testing.T, d datadriven.TestData) error {
+	env.HoldMsgs(parseMsgFilters(t, d)...)
+	return nil
+}
+
+func (env *InteractionEnv) handleReleaseMsgs(t *testing.T, d datadriven.TestData) error {
+	if len(d.CmdArgs) == 0 {
+		env.ReleaseMsgs()
+		return nil
+	}
+	env.ReleaseMsgs(parseMsgFilters(t, d)...)
+	return nil
+}
+
+// HoldMsgs holds back the in-flight messages matching any of the given filters,
+// and the ones sent later, from delivery until they are released. Local
+// messages are never held.
+func (env *InteractionEnv) HoldMsgs(fs ...MsgFilter) {
+	env.holds = append(env.holds, fs...)
+	for _, msg := range env.holdMsgs() {
+		fmt.Fprintln(env.Output, "held:", raft.DescribeMessage(msg, defaultEntryFormatter))
+	}
+}
+
+// ReleaseMsgs stops holding the messages matching the given filters, which must
+// be the ones passed to HoldMsgs, or all the messages if no filters are given.
+// The released messages are put back in flight, after the other messages.
+func (env *InteractionEnv) ReleaseMsgs(fs ...MsgFilter) {
+	if len(fs) == 0 {
+		env.holds = nil
+	}
+	for _, f := range fs {
+		for i := range env.holds {
+			if env.holds[i] == f {
+				env.holds = append(env.holds[:i], env.holds[i+1:]...)
+				break
+			}
+		}
+	}
+	held := env.held[:0]
+	for _, msg := range env.held {
+		if env.isHeld(msg) {
+			held = append(held, msg)
+			continue
+		}
+		fmt.Fprintln(env.Output, "released:", raft.DescribeMessage(msg, defaultEntryFormatter))
+		env.Messages = append(env.Messages, msg)
+	}
+	env.held = held
+}
+
+// isHeld returns true if the message is held back from delivery.
+func (env *InteractionEnv) isHeld(msg raftpb.Message) bool {
+	if isLocalMsg(msg) {
+		return false
+	}
+	for _, f := range env.holds {
+		if f.matches(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdMsgs moves the held messages from the in-flight ones aside, and returns
+// them.
+func (env *InteractionEnv) holdMsgs() []raftpb.Message {
+	if len(env.holds) == 0 {
+		return nil
+	}
+	var held []raftpb.Message
+	msgs := env.Messages[:0]
+	for _, msg := range env.Messages {
+		if env.isHeld(msg) {
+			held = append(held, msg)
+		} else {
+			msgs = append(msgs, msg)
+		}
+	}
+	env.Messages = msgs
+	env.held = append(env.held, held...)
+	return held
+}
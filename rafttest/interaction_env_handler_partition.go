@@ -0,0 +1,76 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func (env *InteractionEnv) handlePartition(t *testing.T, d datadriven.TestData) error {
+	idxs := nodeIdxs(t, d)
+	if len(idxs) == 0 {
+		return errors.New("must specify the nodes to partition")
+	}
+	ids := make([]uint64, len(idxs))
+	for i, idx := range idxs {
+		ids[i] = uint64(idx + 1)
+	}
+	env.Partition(ids...)
+	return nil
+}
+
+func (env *InteractionEnv) handleHeal() error {
+	env.Heal()
+	return nil
+}
+
+// Partition cuts the links between the given nodes and all the other nodes. The
+// messages sent over a cut link are dropped when they are delivered. The cuts
+// accumulate until Heal is called, so e.g. partitioning each node in turn
+// isolates all of them.
+func (env *InteractionEnv) Partition(ids ...uint64) {
+	in := map[uint64]bool{}
+	for _, id := range ids {
+		in[id] = true
+	}
+	if env.partitioned == nil {
+		env.partitioned = map[[2]uint64]bool{}
+	}
+	for _, id := range ids {
+		for i := range env.Nodes {
+			if other := uint64(i + 1); !in[other] {
+				env.partitioned[link(id, other)] = true
+			}
+		}
+	}
+}
+
+// Heal restores all the links cut by Partition.
+func (env *InteractionEnv) Heal() {
+	env.partitioned = nil
+}
+
+// isPartitioned returns true if the link between the given nodes is cut.
+func (env *InteractionEnv) isPartitioned(a, b uint64) bool {
+	return env.partitioned[link(a, b)]
+}
+
+// link returns the key of the link between the given nodes.
+func link(a, b uint64) [2]uint64 {
+	return [2]uint64{min(a, b), max(a, b)}
+}
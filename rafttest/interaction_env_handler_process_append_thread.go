@@ -69,6 +69,10 @@ func (env *InteractionEnv) ProcessAppendThread(idx int) error {
 	if err := processAppend(n, st, m.Entries, snap); err != nil {
 		return err
 	}
+	// The responses acknowledge that the write is durable.
+	if err := n.sync(); err != nil {
+		return err
+	}
 
 	env.Output.WriteString("Responses:\n")
 	for _, m := range resps {
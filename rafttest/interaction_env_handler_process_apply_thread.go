@@ -25,14 +25,18 @@ import (
 )
 
 func (env *InteractionEnv) handleProcessApplyThread(t *testing.T, d datadriven.TestData) error {
+	maxEnts := -1
+	if d.HasArg("entries") {
+		d.ScanArgs(t, "entries", &maxEnts)
+	}
 	idxs := nodeIdxs(t, d)
 	for _, idx := range idxs {
 		var err error
 		if len(idxs) > 1 {
 			fmt.Fprintf(env.Output, "> %d processing apply thread\n", idx+1)
-			env.withIndent(func() { err = env.ProcessApplyThread(idx) })
+			env.withIndent(func() { err = env.processApplyThread(idx, maxEnts) })
 		} else {
-			err = env.ProcessApplyThread(idx)
+			err = env.processApplyThread(idx, maxEnts)
 		}
 		if err != nil {
 			return err
@@ -44,6 +48,22 @@ func (env *InteractionEnv) handleProcessApplyThread(t *testing.T, d datadriven.T
 // ProcessApplyThread runs processes a single message on the "apply" thread of
 // the node with the given index.
 func (env *InteractionEnv) ProcessApplyThread(idx int) error {
+	return env.processApplyThread(idx, -1)
+}
+
+// ProcessApplyThreadEntries applies up to maxEnts entries of the first message
+// on the "apply" thread of the node with the given index, and reports the
+// apply progress with RawNode.ReportApplyProgress. The rest of the entries
+// remain queued, and the responses are only delivered once all entries of the
+// message have been applied.
+func (env *InteractionEnv) ProcessApplyThreadEntries(idx, maxEnts int) error {
+	return env.processApplyThread(idx, maxEnts)
+}
+
+// processApplyThread processes the first message on the "apply" thread of the
+// node with the given index. If maxEnts is not negative, at most maxEnts
+// entries are applied, and the apply progress is reported.
+func (env *InteractionEnv) processApplyThread(idx, maxEnts int) error {
 	n := &env.Nodes[idx]
 	if len(n.ApplyWork) == 0 {
 		env.Output.WriteString("no apply work to perform")
@@ -51,6 +71,16 @@ func (env *InteractionEnv) ProcessApplyThread(idx int) error {
 	}
 	m := n.ApplyWork[0]
 	n.ApplyWork = n.ApplyWork[1:]
+	partial := maxEnts >= 0 && maxEnts < len(m.Entries)
+	if partial {
+		// Apply a prefix of the entries, and keep the rest queued along with
+		// the responses.
+		rest := m
+		rest.Entries = m.Entries[maxEnts:]
+		n.ApplyWork = append([]raftpb.Message{rest}, n.ApplyWork...)
+		m.Entries = m.Entries[:maxEnts]
+		m.Responses = nil
+	}
 
 	resps := m.Responses
 	m.Responses = nil
@@ -59,6 +89,18 @@ func (env *InteractionEnv) ProcessApplyThread(idx int) error {
 	if err := processApply(n, m.Entries); err != nil {
 		return err
 	}
+	if maxEnts >= 0 {
+		applied := n.History[len(n.History)-1].Metadata.Index
+		var queued uint64
+		for _, m := range n.ApplyWork {
+			queued += uint64(len(m.Entries))
+		}
+		n.ReportApplyProgress(applied, queued)
+		fmt.Fprintf(env.Output, "Reported apply progress: applied=%d queue=%d\n", applied, queued)
+	}
+	if partial {
+		return nil
+	}
 
 	env.Output.WriteString("Responses:\n")
 	for _, m := range resps {
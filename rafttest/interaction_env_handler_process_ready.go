@@ -52,6 +52,11 @@ func (env *InteractionEnv) ProcessReady(idx int) error {
 		if err := processAppend(n, rd.HardState, rd.Entries, rd.Snapshot); err != nil {
 			return err
 		}
+		if rd.MustSync || !raft.IsEmptySnap(rd.Snapshot) {
+			if err := n.sync(); err != nil {
+				return err
+			}
+		}
 		if err := processApply(n, rd.CommittedEntries); err != nil {
 			return err
 		}
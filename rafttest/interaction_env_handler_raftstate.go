@@ -35,6 +35,10 @@ func isVoter(id uint64, st raft.Status) bool {
 // For each node, the information is based on its own configuration view.
 func (env *InteractionEnv) handleRaftState() error {
 	for _, n := range env.Nodes {
+		if n.RawNode == nil {
+			fmt.Fprintf(env.Output, "%d: crashed\n", n.Config.ID)
+			continue
+		}
 		st := n.Status()
 		var voterStatus string
 		if isVoter(st.ID, st) {
@@ -42,8 +46,12 @@ func (env *InteractionEnv) handleRaftState() error {
 		} else {
 			voterStatus = "(Non-Voter)"
 		}
-		fmt.Fprintf(env.Output, "%d: %s %s Term:%d Lead:%d\n",
-			st.ID, st.RaftState, voterStatus, st.Term, st.Lead)
+		var quiesced string
+		if st.Quiesced {
+			quiesced = " Quiesced"
+		}
+		fmt.Fprintf(env.Output, "%d: %s %s Term:%d Lead:%d%s\n",
+			st.ID, st.RaftState, voterStatus, st.Term, st.Lead, quiesced)
 	}
 	return nil
 }
@@ -0,0 +1,84 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+
+	"go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+func (env *InteractionEnv) handleReorderMsgs(t *testing.T, d datadriven.TestData) error {
+	var order []int
+	for _, arg := range d.CmdArgs {
+		for i := range arg.Vals {
+			switch arg.Key {
+			case "order":
+				var pos int
+				arg.Scan(t, i, &pos)
+				order = append(order, pos-1)
+			}
+		}
+	}
+	fs := parseMsgFilters(t, d)
+	if len(fs) != 1 || fs[0].To == 0 {
+		return errors.New("must specify exactly one recipient")
+	}
+	return env.ReorderMsgs(fs[0], order)
+}
+
+// ReorderMsgs reorders the in-flight messages matching the given filter, which
+// must name a recipient. The order lists the current (0-based) positions of the
+// matching messages in their new order, and is the reverse order if empty. The
+// other messages keep their positions. Local messages are never reordered.
+func (env *InteractionEnv) ReorderMsgs(f MsgFilter, order []int) error {
+	var idxs []int
+	for i, msg := range env.Messages {
+		if !isLocalMsg(msg) && f.matches(msg) {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) == 0 {
+		env.Output.WriteString("no messages\n")
+		return nil
+	}
+	if len(order) == 0 {
+		for i := range idxs {
+			order = append(order, len(idxs)-1-i)
+		}
+	}
+	if len(order) != len(idxs) {
+		return fmt.Errorf("order must list %d positions, got %d", len(idxs), len(order))
+	}
+	msgs := make([]raftpb.Message, len(idxs))
+	seen := make([]bool, len(idxs))
+	for i, pos := range order {
+		if pos < 0 || pos >= len(idxs) || seen[pos] {
+			return fmt.Errorf("order must be a permutation of the %d positions", len(idxs))
+		}
+		seen[pos] = true
+		msgs[i] = env.Messages[idxs[pos]]
+	}
+	for i, idx := range idxs {
+		env.Messages[idx] = msgs[i]
+		fmt.Fprintln(env.Output, raft.DescribeMessage(msgs[i], defaultEntryFormatter))
+	}
+	return nil
+}
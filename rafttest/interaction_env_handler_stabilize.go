@@ -57,10 +57,17 @@ func (env *InteractionEnv) Stabilize(idxs ...int) error {
 			nodes = append(nodes, &env.Nodes[i])
 		}
 	}
+	// The crashed nodes have no work to do, but receive (and drop) messages.
+	var live []*Node
+	for _, n := range nodes {
+		if n.RawNode != nil {
+			live = append(live, n)
+		}
+	}
 
 	for {
 		done := true
-		for _, rn := range nodes {
+		for _, rn := range live {
 			if rn.HasReady() {
 				idx := int(rn.Status().ID - 1)
 				fmt.Fprintf(env.Output, "> %d handling Ready\n", idx+1)
@@ -72,8 +79,9 @@ func (env *InteractionEnv) Stabilize(idxs ...int) error {
 				done = false
 			}
 		}
+		env.holdMsgs()
 		for _, rn := range nodes {
-			id := rn.Status().ID
+			id := rn.Config.ID
 			// NB: we grab the messages just to see whether to print the header.
 			// DeliverMsgs will do it again.
 			if msgs, _ := splitMsgs(env.Messages, id, -1 /* typ */, false /* drop */); len(msgs) > 0 {
@@ -82,7 +90,7 @@ func (env *InteractionEnv) Stabilize(idxs ...int) error {
 				done = false
 			}
 		}
-		for _, rn := range nodes {
+		for _, rn := range live {
 			idx := int(rn.Status().ID - 1)
 			if len(rn.AppendWork) > 0 {
 				fmt.Fprintf(env.Output, "> %d processing append thread\n", idx+1)
@@ -96,7 +104,7 @@ func (env *InteractionEnv) Stabilize(idxs ...int) error {
 				done = false
 			}
 		}
-		for _, rn := range nodes {
+		for _, rn := range live {
 			idx := int(rn.Status().ID - 1)
 			if len(rn.ApplyWork) > 0 {
 				fmt.Fprintf(env.Output, "> %d processing apply thread\n", idx+1)
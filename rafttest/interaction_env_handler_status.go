@@ -20,6 +20,7 @@ import (
 
 	"github.com/cockroachdb/datadriven"
 
+	"go.etcd.io/raft/v3"
 	"go.etcd.io/raft/v3/tracker"
 )
 
@@ -39,5 +40,12 @@ func (env *InteractionEnv) Status(idx int) error {
 		m[id] = &pr
 	}
 	fmt.Fprint(env.Output, m)
+	if l := st.Lease; l != (raft.LeaseStatus{}) {
+		fmt.Fprintf(env.Output, "lease: now=%d expiry=%d support=%d\n", l.Now, l.Expiry, l.SupportExpiry)
+	}
+	if a := st.Apply; a != (raft.ApplyStatus{}) {
+		fmt.Fprintf(env.Output, "apply: applying=%d applied=%d queue=%d throttled=%t proposals-blocked=%t\n",
+			a.Applying, a.Applied, a.QueueDepth, a.Throttled, a.ProposalsBlocked)
+	}
 	return nil
 }
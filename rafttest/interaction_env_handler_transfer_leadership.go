@@ -23,12 +23,15 @@ import (
 func (env *InteractionEnv) handleTransferLeadership(t *testing.T, d datadriven.TestData) error {
 	var from, to uint64
 	d.ScanArgs(t, "from", &from)
-	d.ScanArgs(t, "to", &to)
 	if from == 0 || from > uint64(len(env.Nodes)) {
 		t.Fatalf(`expected valid "from" argument`)
 	}
-	if to == 0 || to > uint64(len(env.Nodes)) {
-		t.Fatalf(`expected valid "to" argument`)
+	// Without the "to" argument, the leader picks the target.
+	if d.HasArg("to") {
+		d.ScanArgs(t, "to", &to)
+		if to == 0 || to > uint64(len(env.Nodes)) {
+			t.Fatalf(`expected valid "to" argument`)
+		}
 	}
 	return env.transferLeadership(from, to)
 }
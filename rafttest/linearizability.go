@@ -0,0 +1,208 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// KVOp is an operation of a client of a key-value store, in which each key is
+// a register initially containing the empty string.
+type KVOp struct {
+	// Client identifies the client which executed the operation. A client
+	// executes one operation at a time.
+	Client int
+	// Put is true if the operation writes Value to Key, and false if it reads
+	// Key and returns Value.
+	Put   bool
+	Key   string
+	Value string
+	// Call and Return are the logical times at which the operation was invoked
+	// and returned. Return is -1 if the operation never returned, in which case
+	// it may or may not have taken effect.
+	Call, Return int64
+}
+
+func (op KVOp) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "c%d: ", op.Client)
+	if op.Put {
+		fmt.Fprintf(&buf, "put %s=%q", op.Key, op.Value)
+	} else {
+		fmt.Fprintf(&buf, "get %s", op.Key)
+	}
+	fmt.Fprintf(&buf, " [%d, ", op.Call)
+	if op.Return < 0 {
+		buf.WriteString("-)")
+	} else {
+		fmt.Fprintf(&buf, "%d]", op.Return)
+	}
+	if !op.Put && op.Return >= 0 {
+		fmt.Fprintf(&buf, " -> %q", op.Value)
+	}
+	return buf.String()
+}
+
+// CheckLinearizable checks that the given history of operations on a key-value
+// store is linearizable, i.e. that there is a sequential order of the
+// operations, consistent with their real-time order, in which each read returns
+// the value of the latest write to its key.
+//
+// The keys are independent, so the history of each key is checked separately,
+// using the algorithm of Wing and Gong with the memoization of Lowe, as in
+// Porcupine. The check takes exponential time in the worst case, but is fast
+// for the histories of a few concurrent clients.
+func CheckLinearizable(history []KVOp) error {
+	byKey := map[string][]KVOp{}
+	for _, op := range history {
+		if !op.Put && op.Return < 0 {
+			// A read which never returned has no effect.
+			continue
+		}
+		byKey[op.Key] = append(byKey[op.Key], op)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if ops := byKey[key]; !checkRegister(ops) {
+			var buf strings.Builder
+			fmt.Fprintf(&buf, "history of key %s is not linearizable:", key)
+			for _, op := range ops {
+				buf.WriteString("\n  ")
+				buf.WriteString(op.String())
+			}
+			return fmt.Errorf("%s", buf.String())
+		}
+	}
+	return nil
+}
+
+// linEntry is the invocation or the response of an operation in the list of
+// the events of a history.
+type linEntry struct {
+	op   int
+	call bool
+	time int64
+	// match is the response of an invocation.
+	match      *linEntry
+	prev, next *linEntry
+}
+
+// checkRegister returns true if the given history of operations on a single
+// register is linearizable.
+func checkRegister(ops []KVOp) bool {
+	entries := make([]*linEntry, 0, 2*len(ops))
+	for i, op := range ops {
+		ret := op.Return
+		if ret < 0 {
+			// The operation may take effect at any time after the invocation.
+			ret = math.MaxInt64
+		}
+		call := &linEntry{op: i, call: true, time: op.Call}
+		call.match = &linEntry{op: i, time: ret}
+		entries = append(entries, call, call.match)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if a, b := entries[i], entries[j]; a.time != b.time {
+			return a.time < b.time
+		} else if a.call != b.call {
+			// An operation invoked at the time another one returns is concurrent
+			// with it.
+			return a.call
+		}
+		return false
+	})
+	head := &linEntry{}
+	for prev, i := head, 0; i < len(entries); prev, i = entries[i], i+1 {
+		prev.next, entries[i].prev = entries[i], prev
+	}
+
+	type frame struct {
+		entry *linEntry
+		state string
+	}
+	var stack []frame
+	state := ""
+	linearized := make([]byte, (len(ops)+7)/8)
+	// seen contains the explored combinations of the linearized operations and
+	// the resulting state.
+	seen := map[string]struct{}{}
+	for entry := head.next; head.next != nil; {
+		if !entry.call {
+			// The operation of this response must be linearized before the ones
+			// invoked later. Backtrack.
+			if len(stack) == 0 {
+				return false
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state = f.state
+			linearized[f.entry.op/8] &^= 1 << (f.entry.op % 8)
+			unlift(f.entry)
+			entry = f.entry.next
+			continue
+		}
+		op := ops[entry.op]
+		next, ok := state, true
+		if op.Put {
+			next = op.Value
+		} else {
+			ok = op.Value == state
+		}
+		if ok {
+			linearized[entry.op/8] |= 1 << (entry.op % 8)
+			key := string(linearized) + "\x00" + next
+			if _, found := seen[key]; !found {
+				seen[key] = struct{}{}
+				stack = append(stack, frame{entry: entry, state: state})
+				state = next
+				lift(entry)
+				entry = head.next
+				continue
+			}
+			linearized[entry.op/8] &^= 1 << (entry.op % 8)
+		}
+		entry = entry.next
+	}
+	return true
+}
+
+// lift removes the invocation and its response from the list.
+func lift(entry *linEntry) {
+	entry.prev.next = entry.next
+	entry.next.prev = entry.prev
+	match := entry.match
+	match.prev.next = match.next
+	if match.next != nil {
+		match.next.prev = match.prev
+	}
+}
+
+// unlift reverts the lift of the invocation.
+func unlift(entry *linEntry) {
+	match := entry.match
+	match.prev.next = match
+	if match.next != nil {
+		match.next.prev = match
+	}
+	entry.prev.next = entry
+	entry.next.prev = entry
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.etcd.io/raft/v3"
+)
+
+func TestCheckLinearizable(t *testing.T) {
+	put := func(client int, key, value string, call, ret int64) KVOp {
+		return KVOp{Client: client, Put: true, Key: key, Value: value, Call: call, Return: ret}
+	}
+	get := func(client int, key, value string, call, ret int64) KVOp {
+		return KVOp{Client: client, Key: key, Value: value, Call: call, Return: ret}
+	}
+	for i, tt := range []struct {
+		history []KVOp
+		ok      bool
+	}{
+		{nil, true},
+		{[]KVOp{get(0, "a", "", 1, 2)}, true},
+		{[]KVOp{get(0, "a", "x", 1, 2)}, false},
+		{[]KVOp{put(0, "a", "x", 1, 2), get(1, "a", "x", 3, 4)}, true},
+		// A read after a write returns its value.
+		{[]KVOp{put(0, "a", "x", 1, 2), get(1, "a", "", 3, 4)}, false},
+		// A read concurrent with a write returns either value.
+		{[]KVOp{put(0, "a", "x", 1, 4), get(1, "a", "", 2, 3)}, true},
+		{[]KVOp{put(0, "a", "x", 1, 4), get(1, "a", "x", 2, 3)}, true},
+		// Once a read returns the new value, the later reads can't return the
+		// old one.
+		{[]KVOp{put(0, "a", "x", 1, 10), get(1, "a", "x", 2, 3), get(2, "a", "", 4, 5)}, false},
+		{[]KVOp{put(0, "a", "x", 1, 10), get(1, "a", "", 2, 3), get(2, "a", "x", 4, 5)}, true},
+		// The concurrent writes can be ordered either way, but consistently.
+		{[]KVOp{
+			put(0, "a", "x", 1, 5), put(1, "a", "y", 2, 6),
+			get(2, "a", "x", 7, 8), get(3, "a", "x", 9, 10),
+		}, true},
+		{[]KVOp{
+			put(0, "a", "x", 1, 5), put(1, "a", "y", 2, 6),
+			get(2, "a", "x", 7, 8), get(3, "a", "y", 9, 10),
+		}, false},
+		// A write which never returned may take effect at any time after its
+		// invocation, or never.
+		{[]KVOp{put(0, "a", "x", 1, -1), get(1, "a", "", 2, 3), get(1, "a", "x", 4, 5)}, true},
+		{[]KVOp{put(0, "a", "x", 1, -1), get(1, "a", "", 2, 3)}, true},
+		{[]KVOp{put(0, "a", "x", 3, -1), get(1, "a", "x", 1, 2)}, false},
+		// A read which never returned is ignored.
+		{[]KVOp{get(0, "a", "x", 1, -1)}, true},
+		// The keys are independent.
+		{[]KVOp{put(0, "a", "x", 1, 2), get(1, "b", "", 3, 4), get(1, "a", "x", 5, 6)}, true},
+		{[]KVOp{put(0, "a", "x", 1, 2), get(1, "b", "x", 3, 4)}, false},
+	} {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			err := CheckLinearizable(tt.history)
+			if tt.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+// TestSimulatorLinearizable runs the key-value workload on the simulated
+// cluster, and checks that the reads served by both read paths are
+// linearizable.
+func TestSimulatorLinearizable(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		configure func(*raft.Config)
+		crash     int
+	}{
+		{"safe", func(c *raft.Config) { c.ReadOnlyOption = raft.ReadOnlySafe }, 1},
+		{"safe-pre-vote", func(c *raft.Config) {
+			c.ReadOnlyOption, c.PreVote, c.CheckQuorum = raft.ReadOnlySafe, true, true
+		}, 1},
+		// The lease relies on the followers remembering the leader, which they
+		// forget when they crash.
+		{"lease-based", func(c *raft.Config) {
+			c.ReadOnlyOption, c.CheckQuorum = raft.ReadOnlyLeaseBased, true
+		}, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops, returned int
+			for seed := int64(0); seed < 10; seed++ {
+				s := NewSimulator(SimConfig{
+					Seed:      seed,
+					Steps:     3000,
+					Drop:      5,
+					Duplicate: 5,
+					Partition: 1,
+					Crash:     tt.crash,
+					Clients:   3,
+					Configure: tt.configure,
+				})
+				require.NoError(t, s.Run())
+				for _, op := range s.History() {
+					ops++
+					if op.Return >= 0 {
+						returned++
+					}
+				}
+			}
+			// Make sure that the check is not vacuous.
+			require.Greater(t, returned, ops/2)
+		})
+	}
+}
@@ -0,0 +1,780 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// SimConfig configures a Simulator. The zero value of each field except Seed
+// selects a reasonable default.
+type SimConfig struct {
+	// Seed seeds all the randomness of the simulation. Two simulations with the
+	// same config execute the same sequence of events.
+	Seed int64
+	// Nodes is the number of voters in the cluster. Defaults to 3.
+	Nodes int
+	// Steps is the number of events to execute. Defaults to 1000.
+	Steps int
+
+	// The weights of the fault events, relative to the delivery of a message
+	// which has the weight of 100. Negative values disable the fault.
+	//
+	// Drop drops a message in flight, and Duplicate delivers one without
+	// removing it from the network. Partition splits the nodes into two random
+	// groups which can't talk to each other, or heals the network if it is
+	// partitioned. Crash stops a node and rolls back its storage to the last
+	// synced state, or restarts a crashed node.
+	Drop, Duplicate, Partition, Crash int
+
+	// Clients is the number of clients running a key-value workload against the
+	// cluster, with writes going through proposals and reads through ReadIndex.
+	// If not zero, the client operations replace the plain proposals, and Run
+	// checks that the history of the operations is linearizable.
+	Clients int
+
+	// Configure, if set, is called with the config of each node, e.g. to enable
+	// PreVote or CheckQuorum. It must not change ID, Storage, Logger or Rand.
+	Configure func(*raft.Config)
+	// CheckApplied, if set, is called with the entries applied by each node, in
+	// addition to the built-in checks. A returned error fails the simulation.
+	CheckApplied func(id uint64, ents []pb.Entry) error
+}
+
+// Simulator drives a cluster of RawNodes in a single goroutine, with a virtual
+// clock and a simulated network. At every step, it picks a random event: a
+// tick, the handling of a Ready, the delivery of a message, a proposal, or one
+// of the faults enabled by the SimConfig. After every step, it checks the
+// safety invariants of raft:
+//
+//   - election safety: there is at most one leader per term;
+//   - log matching: if two logs contain an entry with the same index and term,
+//     the logs are identical up to this index;
+//   - leader completeness: an entry committed in a term is in the logs of the
+//     leaders of all higher terms;
+//   - state machine safety: all nodes apply the same entry at a given index.
+//
+// Everything is deterministic given the seed, so a failure is reproduced by
+// running the simulation with the same config.
+type Simulator struct {
+	cfg SimConfig
+	rnd *rand.Rand
+
+	nodes []*simNode
+	// msgs contains the messages in flight, indexed by [from-1][to-1].
+	msgs [][][]pb.Message
+	// side partitions the nodes into two groups when it is not 0. The nodes
+	// with the bit (1 << (id-1)) set can't talk to the rest.
+	side uint64
+	// proposals counts the proposals, to make their data unique.
+	proposals int
+	// now is the logical time, incremented by every event.
+	now int64
+	// ticks counts the ticks of the virtual clock.
+	ticks int
+
+	// clients are the clients of the key-value workload, and history contains
+	// their operations.
+	clients []*simClient
+	history []KVOp
+
+	// leaders contains the leader of each term.
+	leaders map[uint64]uint64
+	// applied contains the entries applied at each index.
+	applied map[uint64]pb.Entry
+	// commitTerm contains, for each committed index, an upper bound of the term
+	// in which the entry at this index was committed.
+	commitTerm map[uint64]uint64
+
+	// trace records the executed events, if not nil.
+	trace *[]string
+}
+
+// simNode is a node of the simulated cluster.
+type simNode struct {
+	id uint64
+	rn *raft.RawNode // nil if the node is crashed
+	// storage contains the state written by the node, some of which may not be
+	// synced yet.
+	storage *raft.MemoryStorage
+	// durable contains the state which survives a crash.
+	durable durableState
+	// rand is the source of randomness of the node. It persists across crashes
+	// so that the simulation remains deterministic.
+	rand *rand.Rand
+	// kv is the state machine of the key-value workload.
+	kv simKV
+}
+
+// durableState is the state of a node as of the last sync of its storage.
+type durableState struct {
+	hs   pb.HardState
+	snap pb.Snapshot
+	ents []pb.Entry
+}
+
+// simEventType is the type of a simulation event.
+type simEventType int
+
+const (
+	simTick simEventType = iota
+	simReady
+	simDeliver
+	simDrop
+	simDuplicate
+	simPropose
+	simPartition
+	simHeal
+	simCrash
+	simRestart
+	simInvoke
+)
+
+// simEvent is an event of the simulation. It refers to nodes and messages in a
+// way that remains meaningful when the events preceding it change, so that a
+// subset of a trace can be replayed. An event that is not applicable when it
+// is replayed, e.g. the delivery of a message that was never sent, is skipped.
+type simEvent struct {
+	typ simEventType
+	// id is the node of the event, or the sender of the message.
+	id uint64
+	// to is the recipient of the message.
+	to uint64
+	// k is the position of the message in the queue from id to to.
+	k int
+	// side is the group of nodes split from the rest by a partition.
+	side uint64
+	// data is the data of a proposal, or the value written by a client.
+	data []byte
+	// client is the client invoking an operation, put is true if the operation
+	// is a write, and key is the key of the operation.
+	client int
+	put    bool
+	key    string
+}
+
+// SimulationError is returned by Simulator.Run when an invariant is violated.
+type SimulationError struct {
+	// Seed is the seed of the failed simulation.
+	Seed int64
+	// Invariant names the violated invariant.
+	Invariant string
+	// Err describes the violation.
+	Err error
+	// Trace is the minimized sequence of events leading to the violation.
+	Trace []string
+}
+
+func (e *SimulationError) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "seed %d: %s violated: %v\ntrace (%d events):", e.Seed, e.Invariant, e.Err, len(e.Trace))
+	for _, ev := range e.Trace {
+		buf.WriteString("\n  ")
+		buf.WriteString(ev)
+	}
+	return buf.String()
+}
+
+func (e *SimulationError) Unwrap() error { return e.Err }
+
+// violation is an invariant violation found at some step of a simulation.
+type violation struct {
+	invariant string
+	err       error
+}
+
+// maxMinimizeReplays limits the number of replays when minimizing a trace.
+const maxMinimizeReplays = 500
+
+// NewSimulator returns a simulator of a fresh cluster with the given config.
+func NewSimulator(cfg SimConfig) *Simulator {
+	if cfg.Nodes == 0 {
+		cfg.Nodes = 3
+	}
+	if cfg.Steps == 0 {
+		cfg.Steps = 1000
+	}
+	s := &Simulator{
+		cfg:        cfg,
+		rnd:        rand.New(rand.NewSource(cfg.Seed)),
+		msgs:       make([][][]pb.Message, cfg.Nodes),
+		leaders:    map[uint64]uint64{},
+		applied:    map[uint64]pb.Entry{},
+		commitTerm: map[uint64]uint64{},
+	}
+	var cs pb.ConfState
+	for id := uint64(1); id <= uint64(cfg.Nodes); id++ {
+		cs.Voters = append(cs.Voters, id)
+	}
+	for i := range s.msgs {
+		s.msgs[i] = make([][]pb.Message, cfg.Nodes)
+	}
+	for i := 0; i < cfg.Clients; i++ {
+		s.clients = append(s.clients, &simClient{op: -1})
+	}
+	for id := uint64(1); id <= uint64(cfg.Nodes); id++ {
+		n := &simNode{
+			id: id,
+			durable: durableState{snap: pb.Snapshot{Metadata: pb.SnapshotMetadata{
+				ConfState: cs, Index: 1, Term: 1,
+			}}},
+			rand: rand.New(rand.NewSource(cfg.Seed ^ int64(id)<<32)),
+		}
+		s.nodes = append(s.nodes, n)
+		s.start(n)
+	}
+	return s
+}
+
+// Run runs the simulation. If an invariant is violated, it returns a
+// *SimulationError with a minimized trace of the events leading to it.
+func (s *Simulator) Run() error {
+	var events []simEvent
+	for step := 0; step < s.cfg.Steps; step++ {
+		ev, ok := s.pick()
+		if !ok {
+			continue
+		}
+		events = append(events, ev)
+		if _, v := s.apply(ev); v != nil {
+			return s.fail(events, v)
+		}
+	}
+	if v := s.checkHistory(); v != nil {
+		return s.fail(events, v)
+	}
+	return nil
+}
+
+// fail returns the error for the violation found after the given events.
+func (s *Simulator) fail(events []simEvent, v *violation) error {
+	err := &SimulationError{Seed: s.cfg.Seed, Invariant: v.invariant, Err: v.err}
+	if _, tv := replay(s.cfg, minimize(s.cfg, events, v.invariant), true); tv != nil {
+		err.Err, err.Trace = tv.err, *tv.trace
+	}
+	return err
+}
+
+// History returns the history of the operations of the key-value workload.
+func (s *Simulator) History() []KVOp {
+	return s.history
+}
+
+// Committed returns the highest index known to be committed.
+func (s *Simulator) Committed() uint64 {
+	var index uint64
+	for i := range s.commitTerm {
+		index = max(index, i)
+	}
+	return index
+}
+
+// tracedViolation is a violation found by a replay, with the trace of the
+// replayed events.
+type tracedViolation struct {
+	violation
+	trace *[]string
+}
+
+// replay runs a fresh simulation of the given events, and returns the number of
+// events executed until an invariant is violated, and the violation. Returns
+// nil if there is no violation. If traced is true, the violation includes the
+// descriptions of the executed events.
+func replay(cfg SimConfig, events []simEvent, traced bool) (int, *tracedViolation) {
+	s := NewSimulator(cfg)
+	var trace []string
+	if traced {
+		s.trace = &trace
+	}
+	for i, ev := range events {
+		if ok, v := s.apply(ev); ok && v != nil {
+			return i + 1, &tracedViolation{violation: *v, trace: &trace}
+		}
+	}
+	if v := s.checkHistory(); v != nil {
+		return len(events), &tracedViolation{violation: *v, trace: &trace}
+	}
+	return len(events), nil
+}
+
+// minimize returns a subset of the given events which still violates the given
+// invariant, by repeatedly removing chunks of events as long as the violation
+// reproduces, in the manner of delta debugging.
+func minimize(cfg SimConfig, events []simEvent, invariant string) []simEvent {
+	fails := func(evs []simEvent) ([]simEvent, bool) {
+		n, v := replay(cfg, evs, false)
+		if v == nil || v.invariant != invariant {
+			return nil, false
+		}
+		return evs[:n], true
+	}
+	for chunks, replays := 2, 0; len(events) > 1 && replays < maxMinimizeReplays; {
+		size := (len(events) + chunks - 1) / chunks
+		reduced := false
+		for start := 0; start < len(events) && replays < maxMinimizeReplays; start += size {
+			end := min(start+size, len(events))
+			cand := append(append([]simEvent(nil), events[:start]...), events[end:]...)
+			replays++
+			if evs, ok := fails(cand); ok {
+				events, reduced = evs, true
+				chunks = max(chunks-1, 2)
+				break
+			}
+		}
+		if !reduced {
+			if size == 1 {
+				break
+			}
+			chunks = min(chunks*2, len(events))
+		}
+	}
+	return events
+}
+
+// start creates the RawNode of the given node from its durable state.
+func (s *Simulator) start(n *simNode) {
+	n.storage = raft.NewMemoryStorage()
+	d := n.durable
+	if err := n.storage.ApplySnapshot(d.snap); err != nil {
+		panic(err)
+	}
+	if err := n.storage.Append(d.ents); err != nil {
+		panic(err)
+	}
+	if err := n.storage.SetHardState(d.hs); err != nil {
+		panic(err)
+	}
+	cfg := raft.Config{
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		MaxSizePerMsg:   1 << 20,
+		MaxInflightMsgs: 256,
+	}
+	if s.cfg.Configure != nil {
+		s.cfg.Configure(&cfg)
+	}
+	cfg.ID, cfg.Storage, cfg.Rand = n.id, n.storage, n.rand
+	cfg.Logger = &raft.DefaultLogger{Logger: log.New(io.Discard, "", 0)}
+	rn, err := raft.NewRawNode(&cfg)
+	if err != nil {
+		panic(err)
+	}
+	n.rn = rn
+	n.kv = simKV{data: map[string]string{}, applied: d.snap.Metadata.Index, seqs: map[int]int{}}
+}
+
+// sync makes the current state of the node's storage durable.
+func (n *simNode) sync() {
+	hs, _, err := n.storage.InitialState()
+	if err != nil {
+		panic(err)
+	}
+	snap, err := n.storage.Snapshot()
+	if err != nil {
+		panic(err)
+	}
+	n.durable = durableState{hs: hs, snap: snap, ents: n.entries()}
+}
+
+// entries returns the entries in the node's storage.
+func (n *simNode) entries() []pb.Entry {
+	first, _ := n.storage.FirstIndex()
+	last, _ := n.storage.LastIndex()
+	if last < first {
+		return nil
+	}
+	ents, err := n.storage.Entries(first, last+1, noLimit)
+	if err != nil {
+		panic(err)
+	}
+	return ents
+}
+
+const noLimit = 1<<64 - 1
+
+// pick picks a random event applicable in the current state.
+func (s *Simulator) pick() (simEvent, bool) {
+	weight := func(w int) int {
+		if w < 0 {
+			return 0
+		} else if w == 0 {
+			return 2
+		}
+		return w
+	}
+	var inFlight [][2]uint64
+	for i := range s.msgs {
+		for j := range s.msgs[i] {
+			if len(s.msgs[i][j]) != 0 {
+				inFlight = append(inFlight, [2]uint64{uint64(i + 1), uint64(j + 1)})
+			}
+		}
+	}
+	var ready []uint64
+	for _, n := range s.nodes {
+		if n.rn != nil && n.rn.HasReady() {
+			ready = append(ready, n.id)
+		}
+	}
+	type choice struct {
+		typ    simEventType
+		weight int
+	}
+	var idle []int
+	for i, c := range s.clients {
+		if c.op < 0 {
+			idle = append(idle, i)
+		}
+	}
+	choices := []choice{{simTick, 20}}
+	if len(s.clients) == 0 {
+		choices = append(choices, choice{simPropose, 10})
+	} else if len(idle) != 0 {
+		choices = append(choices, choice{simInvoke, 20})
+	}
+	if len(ready) != 0 {
+		choices = append(choices, choice{simReady, 100})
+	}
+	if len(inFlight) != 0 {
+		choices = append(choices,
+			choice{simDeliver, 100},
+			choice{simDrop, weight(s.cfg.Drop)},
+			choice{simDuplicate, weight(s.cfg.Duplicate)})
+	}
+	if len(s.nodes) < 2 {
+		// There is nothing to partition.
+	} else if s.side == 0 {
+		choices = append(choices, choice{simPartition, weight(s.cfg.Partition)})
+	} else {
+		choices = append(choices, choice{simHeal, 10 * weight(s.cfg.Partition)})
+	}
+	choices = append(choices, choice{simCrash, weight(s.cfg.Crash)}, choice{simRestart, 10 * weight(s.cfg.Crash)})
+
+	total := 0
+	for _, c := range choices {
+		total += c.weight
+	}
+	r := s.rnd.Intn(total)
+	var typ simEventType
+	for _, c := range choices {
+		if r < c.weight {
+			typ = c.typ
+			break
+		}
+		r -= c.weight
+	}
+
+	ev := simEvent{typ: typ}
+	node := func() uint64 { return uint64(s.rnd.Intn(len(s.nodes)) + 1) }
+	switch typ {
+	case simReady:
+		ev.id = ready[s.rnd.Intn(len(ready))]
+	case simDeliver, simDrop, simDuplicate:
+		link := inFlight[s.rnd.Intn(len(inFlight))]
+		ev.id, ev.to = link[0], link[1]
+		// Deliver the oldest message on the link most of the time, and some other
+		// message otherwise, to reorder the messages.
+		if n := len(s.msgs[ev.id-1][ev.to-1]); n > 1 && s.rnd.Intn(4) == 0 {
+			ev.k = s.rnd.Intn(n)
+		}
+	case simPropose:
+		ev.id = node()
+		s.proposals++
+		ev.data = []byte(fmt.Sprintf("prop-%d", s.proposals))
+	case simInvoke:
+		ev.client, ev.id = idle[s.rnd.Intn(len(idle))], node()
+		ev.key = simKeys[s.rnd.Intn(len(simKeys))]
+		if ev.put = s.rnd.Intn(2) == 0; ev.put {
+			s.proposals++
+			ev.data = []byte(fmt.Sprintf("v%d", s.proposals))
+		}
+	case simPartition:
+		// Pick a random non-empty proper subset of the nodes.
+		all := uint64(1)<<len(s.nodes) - 1
+		ev.side = uint64(s.rnd.Int63n(int64(all-1))) + 1
+	case simCrash, simRestart:
+		ev.id = node()
+		if crashed := s.nodes[ev.id-1].rn == nil; crashed != (typ == simRestart) {
+			return simEvent{}, false
+		}
+	}
+	return ev, true
+}
+
+// apply executes the given event, and checks the invariants. Returns false if
+// the event is not applicable.
+func (s *Simulator) apply(ev simEvent) (ok bool, v *violation) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok, v = true, &violation{invariant: "no panics", err: fmt.Errorf("%v", r)}
+		}
+	}()
+	desc, v := s.execute(ev)
+	if desc == "" {
+		return false, nil
+	}
+	if s.trace != nil {
+		*s.trace = append(*s.trace, desc)
+	}
+	if v == nil {
+		v = s.check()
+	}
+	return true, v
+}
+
+// execute executes the given event, and returns its description, or an empty
+// string if the event is not applicable.
+func (s *Simulator) execute(ev simEvent) (string, *violation) {
+	s.now++
+	switch ev.typ {
+	case simTick:
+		for _, n := range s.nodes {
+			if n.rn != nil {
+				n.rn.Tick()
+			}
+		}
+		s.ticks++
+		s.expireClients()
+		return "tick", nil
+	case simReady:
+		n := s.nodes[ev.id-1]
+		if n.rn == nil || !n.rn.HasReady() {
+			return "", nil
+		}
+		return fmt.Sprintf("%d: ready", ev.id), s.handleReady(n)
+	case simDeliver, simDrop, simDuplicate:
+		q := &s.msgs[ev.id-1][ev.to-1]
+		if ev.k >= len(*q) {
+			return "", nil
+		}
+		m := (*q)[ev.k]
+		if ev.typ != simDuplicate {
+			*q = append((*q)[:ev.k], (*q)[ev.k+1:]...)
+		}
+		desc := raft.DescribeMessage(m, nil)
+		to := s.nodes[ev.to-1]
+		switch {
+		case ev.typ == simDrop:
+			return "drop " + desc, nil
+		case s.partitioned(ev.id, ev.to):
+			return "drop (partition) " + desc, nil
+		case to.rn == nil:
+			return "drop (crashed) " + desc, nil
+		}
+		if ev.typ == simDuplicate {
+			desc = "duplicate " + desc
+		}
+		// Errors are expected, e.g. for messages from a stale configuration.
+		_ = to.rn.Step(m)
+		return desc, nil
+	case simPropose:
+		n := s.nodes[ev.id-1]
+		if n.rn == nil {
+			return "", nil
+		}
+		// The proposal is dropped if there is no leader.
+		_ = n.rn.Propose(ev.data)
+		return fmt.Sprintf("%d: propose %q", ev.id, ev.data), nil
+	case simInvoke:
+		return s.invoke(ev), nil
+	case simPartition:
+		if s.side != 0 {
+			return "", nil
+		}
+		s.side = ev.side
+		var in, out []uint64
+		for _, n := range s.nodes {
+			if ev.side&(1<<(n.id-1)) != 0 {
+				in = append(in, n.id)
+			} else {
+				out = append(out, n.id)
+			}
+		}
+		return fmt.Sprintf("partition %v from %v", in, out), nil
+	case simHeal:
+		if s.side == 0 {
+			return "", nil
+		}
+		s.side = 0
+		return "heal", nil
+	case simCrash:
+		n := s.nodes[ev.id-1]
+		if n.rn == nil {
+			return "", nil
+		}
+		n.rn, n.storage = nil, nil
+		return fmt.Sprintf("%d: crash", ev.id), nil
+	case simRestart:
+		n := s.nodes[ev.id-1]
+		if n.rn != nil {
+			return "", nil
+		}
+		s.start(n)
+		return fmt.Sprintf("%d: restart", ev.id), nil
+	}
+	panic(fmt.Sprintf("unknown event type %d", ev.typ))
+}
+
+// partitioned returns true if the nodes can't talk to each other.
+func (s *Simulator) partitioned(a, b uint64) bool {
+	return s.side != 0 && (s.side>>(a-1))&1 != (s.side>>(b-1))&1
+}
+
+// handleReady handles a Ready of the given node: writes to storage, syncs if
+// needed, sends the messages, and applies the committed entries.
+func (s *Simulator) handleReady(n *simNode) *violation {
+	rd := n.rn.Ready()
+	if !raft.IsEmptySnap(rd.Snapshot) {
+		if err := n.storage.ApplySnapshot(rd.Snapshot); err != nil {
+			panic(err)
+		}
+	}
+	if err := n.storage.Append(rd.Entries); err != nil {
+		panic(err)
+	}
+	if !raft.IsEmptyHardState(rd.HardState) {
+		if err := n.storage.SetHardState(rd.HardState); err != nil {
+			panic(err)
+		}
+	}
+	if rd.MustSync || !raft.IsEmptySnap(rd.Snapshot) {
+		n.sync()
+	}
+	for _, m := range rd.Messages {
+		q := &s.msgs[m.From-1][m.To-1]
+		*q = append(*q, m)
+	}
+
+	term := n.rn.BasicStatus().Term
+	for _, e := range rd.CommittedEntries {
+		if t, ok := s.commitTerm[e.Index]; !ok || term < t {
+			s.commitTerm[e.Index] = term
+		}
+		if prev, ok := s.applied[e.Index]; !ok {
+			s.applied[e.Index] = e
+		} else if prev.Term != e.Term || !bytes.Equal(prev.Data, e.Data) {
+			return &violation{invariant: "state machine safety", err: fmt.Errorf(
+				"node %d applied %d/%d %q, another node applied %d/%d %q",
+				n.id, e.Index, e.Term, e.Data, prev.Index, prev.Term, prev.Data)}
+		}
+		if e.Type == pb.EntryConfChange || e.Type == pb.EntryConfChangeV2 {
+			var cc pb.ConfChangeI
+			if e.Type == pb.EntryConfChange {
+				var c pb.ConfChange
+				if err := c.Unmarshal(e.Data); err != nil {
+					panic(err)
+				}
+				cc = c
+			} else {
+				var c pb.ConfChangeV2
+				if err := c.Unmarshal(e.Data); err != nil {
+					panic(err)
+				}
+				cc = c
+			}
+			n.rn.ApplyConfChange(cc)
+		}
+	}
+	if s.cfg.CheckApplied != nil && len(rd.CommittedEntries) != 0 {
+		if err := s.cfg.CheckApplied(n.id, rd.CommittedEntries); err != nil {
+			return &violation{invariant: "CheckApplied", err: err}
+		}
+	}
+	s.applyKV(n, rd.CommittedEntries, rd.ReadStates)
+	n.rn.Advance(rd)
+	return nil
+}
+
+// check checks the invariants in the current state of the cluster.
+func (s *Simulator) check() *violation {
+	logs := make([][]pb.Entry, len(s.nodes))
+	for i, n := range s.nodes {
+		if n.rn == nil {
+			continue
+		}
+		logs[i] = n.entries()
+		st := n.rn.BasicStatus()
+		if st.RaftState != raft.StateLeader {
+			continue
+		}
+		if id, ok := s.leaders[st.Term]; !ok {
+			s.leaders[st.Term] = n.id
+		} else if id != n.id {
+			return &violation{invariant: "election safety", err: fmt.Errorf(
+				"nodes %d and %d are both leaders in term %d", id, n.id, st.Term)}
+		}
+		// The leader's log must contain all the entries committed in the lower
+		// terms. Its storage contains them, since the votes for the leader were
+		// solicited after it had synced its log.
+		first, _ := n.storage.FirstIndex()
+		for index, term := range s.commitTerm {
+			if term >= st.Term || index < first {
+				continue
+			}
+			e, ok := s.applied[index]
+			if !ok {
+				continue
+			}
+			if t, err := n.storage.Term(index); err != nil || t != e.Term {
+				return &violation{invariant: "leader completeness", err: fmt.Errorf(
+					"leader %d in term %d doesn't have entry %d/%d committed in term <= %d",
+					n.id, st.Term, e.Index, e.Term, term)}
+			}
+		}
+	}
+	for i := range logs {
+		for j := i + 1; j < len(logs); j++ {
+			if err := checkLogMatching(logs[i], logs[j]); err != nil {
+				return &violation{invariant: "log matching", err: fmt.Errorf(
+					"nodes %d and %d: %v", i+1, j+1, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkLogMatching checks that the given logs are identical up to the last index
+// at which they have entries with the same term.
+func checkLogMatching(a, b []pb.Entry) error {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	// Find the last matching entry in the overlap of the logs.
+	lo, hi := max(a[0].Index, b[0].Index), min(a[len(a)-1].Index, b[len(b)-1].Index)
+	at := func(ents []pb.Entry, index uint64) pb.Entry { return ents[index-ents[0].Index] }
+	match := uint64(0)
+	for index := hi; index >= lo && index > 0; index-- {
+		if at(a, index).Term == at(b, index).Term {
+			match = index
+			break
+		}
+	}
+	for index := lo; index <= match; index++ {
+		if ea, eb := at(a, index), at(b, index); ea.Term != eb.Term || ea.Type != eb.Type ||
+			!bytes.Equal(ea.Data, eb.Data) {
+			return fmt.Errorf("entries %d/%d and %d/%d differ, but both logs contain %d/%d",
+				ea.Index, ea.Term, eb.Index, eb.Term, match, at(a, match).Term)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// simKeys are the keys used by the clients of the key-value workload. There are
+// few of them, so that the operations conflict.
+var simKeys = []string{"a", "b"}
+
+// simClientTimeout is the number of ticks after which a client gives up on an
+// operation, and moves on to the next one.
+const simClientTimeout = 50
+
+// simClient is a client of the key-value workload.
+type simClient struct {
+	// seq is the sequence number of the last operation of the client.
+	seq int
+	// op is the position of the pending operation in the history, or -1 if the
+	// client is idle.
+	op int
+	// node is the node handling the pending operation.
+	node uint64
+	// start is the tick at which the pending operation was invoked.
+	start int
+}
+
+// simKV is the key-value state machine of a node.
+type simKV struct {
+	data map[string]string
+	// applied is the index of the last applied entry.
+	applied uint64
+	// seqs contains the sequence number of the last write of each client
+	// applied to the state machine. Writes are applied at most once, even if
+	// the network duplicates their proposals.
+	seqs map[int]int
+	// reads contains the pending reads.
+	reads []simRead
+}
+
+// simRead is a read waiting for the read index, and then for the state machine
+// to catch up with it.
+type simRead struct {
+	client, seq int
+	key         string
+	index       uint64 // 0 if the read index is not known yet
+}
+
+// invoke executes the invocation of an operation by a client, and returns its
+// description, or an empty string if the invocation is not applicable.
+func (s *Simulator) invoke(ev simEvent) string {
+	c, n := s.clients[ev.client], s.nodes[ev.id-1]
+	if c.op >= 0 || n.rn == nil {
+		return ""
+	}
+	c.seq++
+	c.op, c.node, c.start = len(s.history), n.id, s.ticks
+	op := KVOp{Client: ev.client, Put: ev.put, Key: ev.key, Call: s.now, Return: -1}
+	if ev.put {
+		op.Value = string(ev.data)
+		// The proposal is dropped if there is no leader, in which case the
+		// client times out.
+		_ = n.rn.Propose([]byte(fmt.Sprintf("%d %d %s %s", ev.client, c.seq, ev.key, ev.data)))
+	} else {
+		n.kv.reads = append(n.kv.reads, simRead{client: ev.client, seq: c.seq, key: ev.key})
+		n.rn.ReadIndex([]byte(fmt.Sprintf("%d %d", ev.client, c.seq)))
+	}
+	s.history = append(s.history, op)
+	return fmt.Sprintf("%d: invoke %s", ev.id, op)
+}
+
+// expireClients makes the clients give up on the operations which take too
+// long. The operations remain in the history without a response.
+func (s *Simulator) expireClients() {
+	for _, c := range s.clients {
+		if c.op >= 0 && s.ticks-c.start >= simClientTimeout {
+			c.op = -1
+		}
+	}
+}
+
+// respond records the response to the pending operation of the client, if the
+// operation is the given one.
+func (s *Simulator) respond(client, seq int, node uint64, value string) {
+	c := s.clients[client]
+	if c.op < 0 || c.seq != seq || c.node != node {
+		return
+	}
+	op := &s.history[c.op]
+	op.Return = s.now
+	if !op.Put {
+		op.Value = value
+	}
+	c.op = -1
+	if s.trace != nil {
+		*s.trace = append(*s.trace, fmt.Sprintf("%d: return %s", node, op))
+	}
+}
+
+// applyKV applies the committed entries of a Ready to the key-value state
+// machine of the node, and serves the reads for which the read index is known
+// and applied.
+func (s *Simulator) applyKV(n *simNode, ents []pb.Entry, rss []raft.ReadState) {
+	kv := &n.kv
+	for _, e := range ents {
+		kv.applied = e.Index
+		if e.Type != pb.EntryNormal || len(e.Data) == 0 {
+			continue
+		}
+		var client, seq int
+		var key, value string
+		if _, err := fmt.Sscanf(string(e.Data), "%d %d %s %s", &client, &seq, &key, &value); err != nil {
+			continue
+		}
+		if seq > kv.seqs[client] {
+			kv.seqs[client] = seq
+			kv.data[key] = value
+		}
+		s.respond(client, seq, n.id, "")
+	}
+	for _, rs := range rss {
+		var client, seq int
+		if _, err := fmt.Sscanf(string(rs.RequestCtx), "%d %d", &client, &seq); err != nil {
+			continue
+		}
+		for i := range kv.reads {
+			if r := &kv.reads[i]; r.client == client && r.seq == seq && r.index == 0 {
+				r.index = rs.Index
+			}
+		}
+	}
+	reads := kv.reads[:0]
+	for _, r := range kv.reads {
+		if r.index != 0 && r.index <= kv.applied {
+			s.respond(r.client, r.seq, n.id, kv.data[r.key])
+		} else if c := s.clients[r.client]; c.op >= 0 && c.seq == r.seq {
+			reads = append(reads, r)
+		}
+	}
+	kv.reads = reads
+}
+
+// checkHistory checks that the history of the clients is linearizable.
+func (s *Simulator) checkHistory() *violation {
+	if err := CheckLinearizable(s.history); err != nil {
+		return &violation{invariant: "linearizability", err: err}
+	}
+	return nil
+}
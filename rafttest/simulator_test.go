@@ -0,0 +1,110 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+func TestSimulator(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		nodes     int
+		configure func(*raft.Config)
+	}{
+		{"default", 3, nil},
+		{"five-nodes", 5, nil},
+		{"pre-vote", 3, func(c *raft.Config) { c.PreVote, c.CheckQuorum = true, true }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 10; seed++ {
+				s := NewSimulator(SimConfig{
+					Seed:      seed,
+					Nodes:     tt.nodes,
+					Steps:     3000,
+					Drop:      5,
+					Duplicate: 5,
+					Partition: 1,
+					Crash:     1,
+					Configure: tt.configure,
+				})
+				require.NoError(t, s.Run())
+			}
+		})
+	}
+}
+
+// TestSimulatorProgress checks that the cluster makes progress when there are
+// no faults, so that the safety checks are not vacuous.
+func TestSimulatorProgress(t *testing.T) {
+	s := NewSimulator(SimConfig{Seed: 1, Drop: -1, Duplicate: -1, Partition: -1, Crash: -1})
+	require.NoError(t, s.Run())
+	require.Greater(t, s.Committed(), uint64(20))
+}
+
+// TestSimulatorDeterministic checks that the simulations with the same seed
+// execute the same events.
+func TestSimulatorDeterministic(t *testing.T) {
+	run := func(seed int64) []string {
+		var trace []string
+		s := NewSimulator(SimConfig{Seed: seed, Steps: 500, Drop: 5, Partition: 1, Crash: 1})
+		s.trace = &trace
+		require.NoError(t, s.Run())
+		return trace
+	}
+	require.Equal(t, run(7), run(7))
+	require.NotEqual(t, run(7), run(8))
+}
+
+// TestSimulatorFailure checks that a violation reports the seed and a minimized
+// trace of the events leading to it.
+func TestSimulatorFailure(t *testing.T) {
+	cfg := SimConfig{
+		Seed:      3,
+		Drop:      5,
+		Duplicate: 5,
+		Crash:     1,
+		CheckApplied: func(id uint64, ents []pb.Entry) error {
+			for _, e := range ents {
+				if e.Index == 5 {
+					return fmt.Errorf("node %d applied index 5", id)
+				}
+			}
+			return nil
+		},
+	}
+	err := NewSimulator(cfg).Run()
+	var serr *SimulationError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Equal(t, int64(3), serr.Seed)
+	require.Equal(t, "CheckApplied", serr.Invariant)
+	require.Contains(t, err.Error(), "seed 3: CheckApplied violated")
+
+	// The minimized trace still fails, and is shorter than the original one.
+	var full []string
+	s := NewSimulator(cfg)
+	s.trace = &full
+	require.Error(t, s.Run())
+	require.NotEmpty(t, serr.Trace)
+	require.Less(t, len(serr.Trace), len(full))
+	t.Log(err)
+}
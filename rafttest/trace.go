@@ -0,0 +1,115 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// TraceEvent is a state transition of a raft node, as traced with the with_tla
+// build tag (see raft.TracingEvent) and validated by tla/Traceetcdraft.tla. It
+// mirrors raft.TracingEvent, which only exists with the build tag.
+type TraceEvent struct {
+	Name    string      `json:"name"`
+	NodeID  string      `json:"nid"`
+	State   TraceState  `json:"state"`
+	Role    string      `json:"role"`
+	LogSize uint64      `json:"log"`
+	Conf    [2][]string `json:"conf"`
+	Message *TraceMsg   `json:"msg,omitempty"`
+	Prop    *TraceProp  `json:"prop,omitempty"`
+	Line    int         `json:"-"` // the 1-based line of the event in the trace
+}
+
+// TraceState is the HardState of a node in a TraceEvent.
+type TraceState struct {
+	Term   uint64 `json:"term"`
+	Vote   string `json:"vote"`
+	Commit uint64 `json:"commit"`
+}
+
+// TraceMsg is the message sent or received in a TraceEvent. Entries is the
+// number of entries, or the snapshot index of a MsgSnap.
+type TraceMsg struct {
+	Type       string `json:"type"`
+	Term       uint64 `json:"term"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Entries    int    `json:"entries"`
+	LogTerm    uint64 `json:"logTerm"`
+	Index      uint64 `json:"index"`
+	Commit     uint64 `json:"commit"`
+	Vote       string `json:"vote"`
+	Reject     bool   `json:"reject"`
+	RejectHint uint64 `json:"rejectHint"`
+}
+
+// TraceProp holds the properties of a TraceEvent used by ReplayTrace: the
+// configuration change of the ChangeConf and ApplyConfChange events, and the
+// target of the leadership transfer events.
+type TraceProp struct {
+	ConfChange *TraceConfChange `json:"cc,omitempty"`
+	Target     string           `json:"target,omitempty"`
+}
+
+// TraceConfChange is the configuration change of a TraceEvent. The Changes
+// are set for a ChangeConf event, and NewConf, the resulting voters, for an
+// ApplyConfChange event.
+type TraceConfChange struct {
+	Changes []struct {
+		NodeID string `json:"nid"`
+		Action string `json:"action"`
+	} `json:"changes,omitempty"`
+	NewConf []string `json:"newconf,omitempty"`
+}
+
+// ReadTrace reads the events of an NDJSON trace. Each line is a JSON object
+// holding a TraceEvent in its "event" field, like the ones logged by the
+// TraceLogger of tla/README.md. The other lines are skipped.
+func ReadTrace(r io.Reader) ([]TraceEvent, error) {
+	var evs []TraceEvent
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var l struct {
+			Event *TraceEvent `json:"event"`
+		}
+		if err := json.Unmarshal(s.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if l.Event == nil || l.Event.Name == "" {
+			continue
+		}
+		l.Event.Line = line
+		evs = append(evs, *l.Event)
+	}
+	return evs, s.Err()
+}
+
+// parseID parses a node ID of a trace.
+func parseID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid node ID %q", s)
+	}
+	return id, nil
+}
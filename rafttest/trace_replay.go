@@ -0,0 +1,513 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.etcd.io/raft/v3"
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// TraceReplay is the result of ReplayTrace.
+type TraceReplay struct {
+	// Script is a datadriven InteractionEnv script, with the output of each
+	// command, which reproduces the replay. The divergences are included as
+	// comments.
+	Script string
+	// Divergences are the differences between the recorded and the replayed
+	// state transitions, in the order of the trace.
+	Divergences []TraceDivergence
+}
+
+// TraceDivergence is a difference between the state recorded by an event of a
+// trace and the state of the node in the replay.
+type TraceDivergence struct {
+	Event TraceEvent
+	Diff  string
+}
+
+func (d TraceDivergence) String() string {
+	return fmt.Sprintf("line %d: %s %s: %s", d.Event.Line, d.Event.NodeID, d.Event.Name, d.Diff)
+}
+
+// ReplayTrace replays a trace read by ReadTrace against an InteractionEnv, and
+// diffs the replayed state transitions against the recorded ones.
+//
+// The trace doesn't record all the inputs of the nodes, so they are inferred
+// from the events:
+//   - InitState adds the node, bootstrapped by RawNode.Bootstrap like in its
+//     following ApplyConfChange events, or restarts it if it already exists.
+//   - Ready processes the Ready of the node and the writes on its append
+//     thread, whose acknowledgements are delivered right away, and applies
+//     the committed entries.
+//   - Receive* delivers the matching in-flight message, flushing the Ready of
+//     the sender first if needed.
+//   - ApplyConfChange applies the committed entries up to the next
+//     configuration change, and the change itself. The nodes use asynchronous
+//     storage writes so that these can wait for the trace.
+//   - Replicate proposes an entry, unless it's the empty entry of a new
+//     leader. ChangeConf proposes the configuration change.
+//   - StartLeaderTransfer transfers the leadership.
+//   - BecomeCandidate delivers the MsgTimeoutNow to the node if there is one,
+//     and campaigns otherwise.
+//   - The first of a series of heartbeats sent by a leader is a tick.
+//
+// The term, vote, commit index, role and configuration of the node are
+// compared with the ones recorded by each of these events. The last index of
+// its log is compared at its Ready events. The entries are not traced, so the
+// ones proposed in the replay are placeholders. The nodes use the defaults of
+// add-nodes otherwise, i.e. neither pre-vote nor check quorum, whose messages
+// are not traced.
+func ReplayTrace(evs []TraceEvent) (*TraceReplay, error) {
+	r := &traceReplayer{
+		env:     NewInteractionEnv(nil),
+		evs:     evs,
+		prev:    map[uint64]*TraceEvent{},
+		booting: map[int]bool{},
+	}
+	for i := range evs {
+		if err := r.replay(i); err != nil {
+			return nil, fmt.Errorf("line %d: %w", evs[i].Line, err)
+		}
+	}
+	return &TraceReplay{Script: r.script.String(), Divergences: r.divs}, nil
+}
+
+type traceReplayer struct {
+	env    *InteractionEnv
+	evs    []TraceEvent
+	script strings.Builder
+	divs   []TraceDivergence
+	// prev is the previous event of each node.
+	prev map[uint64]*TraceEvent
+	// booting contains the lines of the events which bootstrap the nodes.
+	booting map[int]bool
+}
+
+// replay replays the event at the given index.
+func (r *traceReplayer) replay(i int) error {
+	e := &r.evs[i]
+	id, err := parseID(e.NodeID)
+	if err != nil {
+		return err
+	}
+	prev := r.prev[id]
+	r.prev[id] = e
+	if e.Name == "InitState" {
+		return r.initState(i, id)
+	}
+	if prev == nil || int(id) > len(r.env.Nodes) {
+		return fmt.Errorf("no InitState event for node %d", id)
+	}
+	idx := int(id - 1)
+	switch e.Name {
+	case "Ready":
+		r.check(e, idx)
+		r.flush(idx)
+		r.checkLog(e, idx)
+	case "ReceiveAppendEntriesRequest", "ReceiveAppendEntriesResponse",
+		"ReceiveRequestVoteRequest", "ReceiveRequestVoteResponse", "ReceiveSnapshot":
+		if e.Message == nil {
+			return errors.New("no message")
+		}
+		r.check(e, idx)
+		return r.receive(e)
+	case "ApplyConfChange":
+		if r.booting[e.Line] {
+			return nil
+		}
+		if !r.pendingConfChange(idx) {
+			r.flush(idx)
+		}
+		r.apply(idx, true /* conf */)
+	case "Replicate":
+		if prev.Name == "BecomeLeader" {
+			// The empty entry appended by the new leader.
+			return nil
+		}
+		r.check(e, idx)
+		data := fmt.Sprintf("e%d", e.LogSize+1)
+		r.run(fmt.Sprintf("propose %d %s", id, data), func() error {
+			return r.env.Propose(idx, []byte(data))
+		})
+	case "ChangeConf":
+		r.check(e, idx)
+		return r.changeConf(e, idx)
+	case "StartLeaderTransfer":
+		r.check(e, idx)
+		if e.Prop == nil {
+			return errors.New("no leadership transfer target")
+		}
+		to, err := parseID(e.Prop.Target)
+		if err != nil {
+			return err
+		}
+		r.run(fmt.Sprintf("transfer-leadership from=%d to=%d", id, to), func() error {
+			return r.env.transferLeadership(id, to)
+		})
+	case "BecomeCandidate":
+		p := MsgPattern{To: id, Type: pb.MsgTimeoutNow}
+		for _, msg := range r.env.Messages {
+			if msg.To == id && msg.Type == pb.MsgTimeoutNow {
+				p.From = msg.From
+				break
+			}
+		}
+		if p.From != 0 {
+			r.deliver(p)
+		} else {
+			r.run(fmt.Sprintf("campaign %d", id), func() error {
+				return r.env.Campaign(idx)
+			})
+		}
+		r.check(e, idx)
+	case "SendAppendEntriesRequest":
+		if e.Message == nil {
+			return errors.New("no message")
+		}
+		if e.Message.Type != pb.MsgHeartbeat.String() ||
+			prev.Name == e.Name && prev.Message != nil && prev.Message.Type == e.Message.Type {
+			return nil
+		}
+		r.run(fmt.Sprintf("tick-heartbeat %d", id), func() error {
+			return r.env.Tick(idx, r.env.Nodes[idx].Config.HeartbeatTick)
+		})
+		r.check(e, idx)
+	}
+	return nil
+}
+
+// initState adds the node of the InitState event at the given index, or
+// restarts it.
+func (r *traceReplayer) initState(i int, id uint64) error {
+	e := &r.evs[i]
+	// The node applies the configuration it starts with, and then the
+	// configuration changes of its initial log right away if it's bootstrapped
+	// by RawNode.Bootstrap.
+	var boot *TraceEvent
+	for _, f := range r.evs[i+1:] {
+		if f.NodeID != e.NodeID {
+			continue
+		}
+		if f.Name != "ApplyConfChange" && f.Name != "BecomeFollower" {
+			break
+		}
+		if f.Name == "ApplyConfChange" && f.LogSize != 0 && f.Prop != nil && f.Prop.ConfChange != nil {
+			boot = &f
+		}
+		r.booting[f.Line] = true
+	}
+	if int(id) <= len(r.env.Nodes) {
+		idx := int(id - 1)
+		if r.env.Nodes[idx].RawNode != nil {
+			r.run(fmt.Sprintf("crash %d", id), func() error { return r.env.Crash(idx) })
+		}
+		r.run(fmt.Sprintf("restart %d", id), func() error { return r.env.Restart(idx) })
+		r.check(e, idx)
+		r.checkLog(e, idx)
+		return nil
+	}
+	if e.LogSize != 0 {
+		return fmt.Errorf("node %d starts with a log which is not in the trace", id)
+	}
+	if n := int(id) - 1 - len(r.env.Nodes); n > 0 {
+		// The nodes missing from the trace.
+		r.run(fmt.Sprintf("add-nodes %d async-storage-writes=true", n), func() error {
+			return r.env.AddNodes(n, traceConfig(), pb.Snapshot{})
+		})
+	}
+	if boot == nil {
+		r.run("add-nodes 1 async-storage-writes=true", func() error {
+			return r.env.AddNodes(1, traceConfig(), pb.Snapshot{})
+		})
+		return nil
+	}
+	var peers []raft.Peer
+	for _, s := range boot.Prop.ConfChange.NewConf {
+		voter, err := parseID(s)
+		if err != nil {
+			return err
+		}
+		peers = append(peers, raft.Peer{ID: voter})
+	}
+	cmd := fmt.Sprintf("add-nodes 1 voters=(%s) bootstrap=true async-storage-writes=true",
+		strings.Join(boot.Prop.ConfChange.NewConf, ","))
+	r.run(cmd, func() error {
+		return r.env.addNodes(1, traceConfig(), pb.Snapshot{}, peers)
+	})
+	return nil
+}
+
+// receive delivers the message received in the event.
+func (r *traceReplayer) receive(e *TraceEvent) error {
+	m := e.Message
+	from, err := parseID(m.From)
+	if err != nil {
+		return err
+	}
+	to, err := parseID(m.To)
+	if err != nil {
+		return err
+	}
+	typ, ok := pb.MessageType_value[m.Type]
+	if !ok {
+		return fmt.Errorf("unknown message type %s", m.Type)
+	}
+	p := MsgPattern{
+		From:       from,
+		To:         to,
+		Type:       pb.MessageType(typ),
+		Term:       m.Term,
+		LogTerm:    m.LogTerm,
+		Index:      m.Index,
+		Commit:     m.Commit,
+		Entries:    m.Entries,
+		Reject:     m.Reject,
+		RejectHint: m.RejectHint,
+	}
+	if _, ok := r.inFlight(p); !ok && int(from) <= len(r.env.Nodes) {
+		r.flush(int(from - 1))
+	}
+	if _, ok := r.inFlight(p); ok {
+		r.deliver(p)
+		return nil
+	}
+	recorded := fmt.Sprintf("%s->%s %s Term:%d Log:%d/%d Commit:%d Entries:%d Reject:%t RejectHint:%d",
+		m.From, m.To, m.Type, m.Term, m.LogTerm, m.Index, m.Commit, m.Entries, m.Reject, m.RejectHint)
+	// Deliver the closest message, if any, so that the replay goes on.
+	p = MsgPattern{From: p.From, To: p.To, Type: p.Type, Term: p.Term}
+	msg, ok := r.inFlight(p)
+	if !ok {
+		r.diverge(e, fmt.Sprintf("message %s was not sent", recorded))
+		return nil
+	}
+	p.Reject = msg.Reject
+	r.diverge(e, fmt.Sprintf("message %s was not sent, delivering %s",
+		recorded, raft.DescribeMessage(msg, defaultEntryFormatter)))
+	r.deliver(p)
+	return nil
+}
+
+// changeConf proposes the configuration change of the ChangeConf event on the
+// node at the given index.
+func (r *traceReplayer) changeConf(e *TraceEvent, idx int) error {
+	if e.Prop == nil || e.Prop.ConfChange == nil {
+		return errors.New("no configuration change")
+	}
+	var ops []string
+	for _, c := range e.Prop.ConfChange.Changes {
+		switch c.Action {
+		case "AddNewServer":
+			ops = append(ops, "v"+c.NodeID)
+		case "RemoveServer":
+			ops = append(ops, "r"+c.NodeID)
+		case "AddLearner":
+			ops = append(ops, "l"+c.NodeID)
+		default:
+			return fmt.Errorf("unknown configuration change action %s", c.Action)
+		}
+	}
+	input := strings.Join(ops, " ")
+	ccs, err := pb.ConfChangesFromString(input)
+	if err != nil {
+		return err
+	}
+	// Single changes are proposed like v1 changes, which are applied without
+	// a joint configuration.
+	var c pb.ConfChangeI = pb.ConfChangeV2{Changes: ccs}
+	cmd := fmt.Sprintf("propose-conf-change %d\n%s", idx+1, input)
+	if len(ccs) == 1 {
+		c = pb.ConfChange{Type: ccs[0].Type, NodeID: ccs[0].NodeID}
+		cmd = fmt.Sprintf("propose-conf-change %d v1=true\n%s", idx+1, input)
+	}
+	r.run(cmd, func() error { return r.env.ProposeConfChange(idx, c) })
+	return nil
+}
+
+// flush processes the Ready of the node at the given index, if any, and its
+// append thread, and delivers the acknowledgements of the writes to the node.
+// The messages sent by the node are then in flight. The entries to apply are
+// applied, up to the first configuration change.
+func (r *traceReplayer) flush(idx int) {
+	id := idx + 1
+	if rn := r.env.Nodes[idx].RawNode; rn != nil && rn.HasReady() {
+		r.run(fmt.Sprintf("process-ready %d", id), func() error {
+			return r.env.ProcessReady(idx)
+		})
+	}
+	for len(r.env.Nodes[idx].AppendWork) != 0 {
+		r.run(fmt.Sprintf("process-append-thread %d", id), func() error {
+			return r.env.ProcessAppendThread(idx)
+		})
+	}
+	r.deliverLocal(idx, pb.MsgStorageAppendResp)
+	r.apply(idx, false /* conf */)
+}
+
+// apply applies the entries on the apply thread of the node at the given
+// index, up to the first configuration change, which is also applied if conf
+// is true. The configuration changes are applied when the trace does so.
+func (r *traceReplayer) apply(idx int, conf bool) {
+	id := idx + 1
+	for len(r.env.Nodes[idx].ApplyWork) != 0 {
+		ents := r.env.Nodes[idx].ApplyWork[0].Entries
+		k := slices.IndexFunc(ents, func(ent pb.Entry) bool {
+			return ent.Type == pb.EntryConfChange || ent.Type == pb.EntryConfChangeV2
+		})
+		if k >= 0 && conf {
+			k, conf = k+1, false
+		}
+		if k < 0 || k == len(ents) {
+			r.run(fmt.Sprintf("process-apply-thread %d", id), func() error {
+				return r.env.ProcessApplyThread(idx)
+			})
+			continue
+		}
+		if k > 0 {
+			r.run(fmt.Sprintf("process-apply-thread %d entries=%d", id, k), func() error {
+				return r.env.ProcessApplyThreadEntries(idx, k)
+			})
+		}
+		break
+	}
+	r.deliverLocal(idx, pb.MsgStorageApplyResp)
+}
+
+// pendingConfChange returns true if the apply thread of the node at the given
+// index has a configuration change to apply.
+func (r *traceReplayer) pendingConfChange(idx int) bool {
+	for _, m := range r.env.Nodes[idx].ApplyWork {
+		for _, ent := range m.Entries {
+			if ent.Type == pb.EntryConfChange || ent.Type == pb.EntryConfChangeV2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deliverLocal delivers the in-flight messages of the given type to the node
+// at the given index, if any.
+func (r *traceReplayer) deliverLocal(idx int, typ pb.MessageType) {
+	id := uint64(idx + 1)
+	if !slices.ContainsFunc(r.env.Messages, func(msg pb.Message) bool {
+		return msg.To == id && msg.Type == typ
+	}) {
+		return
+	}
+	r.run(fmt.Sprintf("deliver-msgs %d type=%s", id, typ), func() error {
+		r.env.DeliverMsgs(typ, Recipient{ID: id})
+		return nil
+	})
+}
+
+// inFlight returns the first in-flight message matching the pattern.
+func (r *traceReplayer) inFlight(p MsgPattern) (pb.Message, bool) {
+	for _, msg := range r.env.Messages {
+		if p.matches(msg) {
+			return msg, true
+		}
+	}
+	return pb.Message{}, false
+}
+
+// deliver delivers the first in-flight message matching the pattern.
+func (r *traceReplayer) deliver(p MsgPattern) {
+	r.run(p.String(), func() error {
+		if !r.env.DeliverMsg(p) {
+			r.env.Output.WriteString("no messages\n")
+		}
+		return nil
+	})
+}
+
+// check compares the state of the node at the given index with the one
+// recorded by the event. The last index of the log is only compared if the
+// node has no unstable entries, i.e. no Ready.
+func (r *traceReplayer) check(e *TraceEvent, idx int) {
+	rn := r.env.Nodes[idx].RawNode
+	if rn == nil {
+		r.diverge(e, "node is crashed")
+		return
+	}
+	st := rn.Status()
+	var diffs []string
+	diff := func(field string, recorded, replayed interface{}) {
+		if a, b := fmt.Sprint(recorded), fmt.Sprint(replayed); a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %s, replayed %s", field, a, b))
+		}
+	}
+	diff("term", e.State.Term, st.Term)
+	diff("vote", e.State.Vote, st.Vote)
+	diff("commit", e.State.Commit, st.Commit)
+	diff("role", e.Role, st.RaftState)
+	diff("conf", e.Conf, [2][]uint64{st.Config.Voters[0].Slice(), st.Config.Voters[1].Slice()})
+	if !rn.HasReady() {
+		if li, err := r.env.Nodes[idx].LastIndex(); err != nil || li != e.LogSize {
+			diff("log", e.LogSize, li)
+		}
+	}
+	if len(diffs) != 0 {
+		r.diverge(e, strings.Join(diffs, "; "))
+	}
+}
+
+// checkLog compares the last index of the log of the node at the given index,
+// which must have no Ready, with the one recorded by the event.
+func (r *traceReplayer) checkLog(e *TraceEvent, idx int) {
+	li, err := r.env.Nodes[idx].LastIndex()
+	if err != nil {
+		r.diverge(e, err.Error())
+	} else if li != e.LogSize {
+		r.diverge(e, fmt.Sprintf("log: recorded %d, replayed %d", e.LogSize, li))
+	}
+}
+
+// diverge records a divergence, also as a comment of the script.
+func (r *traceReplayer) diverge(e *TraceEvent, diff string) {
+	d := TraceDivergence{Event: *e, Diff: diff}
+	r.divs = append(r.divs, d)
+	fmt.Fprintf(&r.script, "# %s\n\n", d)
+}
+
+// run runs a command of the script, and appends it to the script with its
+// output.
+func (r *traceReplayer) run(cmd string, f func() error) {
+	r.env.Output.Reset()
+	out := r.env.result(f())
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	if strings.Contains(out, "\n\n") {
+		// The output has blank lines, which datadriven only allows between
+		// double separators.
+		fmt.Fprintf(&r.script, "%s\n----\n----\n%s----\n----\n\n", cmd, out)
+		return
+	}
+	fmt.Fprintf(&r.script, "%s\n----\n%s\n", cmd, out)
+}
+
+// traceConfig returns the configuration of the nodes of a replay, which use
+// asynchronous storage writes so that the entries can be applied when the
+// trace does so.
+func traceConfig() raft.Config {
+	cfg := raftConfigStub()
+	cfg.AsyncStorageWrites = true
+	return cfg
+}
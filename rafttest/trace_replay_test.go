@@ -0,0 +1,136 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	replayTrace  = flag.String("replay-trace", "../tla/example.ndjson", "the trace replayed by TestReplayTrace")
+	replayScript = flag.String("replay-script", "", "if set, TestReplayTrace writes the script of the replay to this file")
+)
+
+func readTrace(t *testing.T, path string) []TraceEvent {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	evs, err := ReadTrace(f)
+	require.NoError(t, err)
+	return evs
+}
+
+// TestReplayTrace replays a trace, by default tla/example.ndjson, and checks
+// that it doesn't diverge from the recorded state transitions, and that the
+// script of the replay reproduces it. Run it with -replay-trace to replay
+// another trace, and with -replay-script to write the script.
+func TestReplayTrace(t *testing.T) {
+	evs := readTrace(t, *replayTrace)
+	replay, err := ReplayTrace(evs)
+	require.NoError(t, err)
+	if *replayScript != "" {
+		require.NoError(t, os.WriteFile(*replayScript, []byte(replay.Script), 0644))
+	}
+	for _, d := range replay.Divergences {
+		t.Error(d)
+	}
+
+	env := NewInteractionEnv(nil)
+	datadriven.RunTestFromString(t, replay.Script, func(t *testing.T, d *datadriven.TestData) string {
+		return env.Handle(t, *d)
+	})
+}
+
+func TestReplayTraceDivergence(t *testing.T) {
+	evs := readTrace(t, "../tla/example.ndjson")
+	find := func(f func(e TraceEvent) bool) int {
+		for i := len(evs) / 2; i < len(evs); i++ {
+			if f(evs[i]) {
+				return i
+			}
+		}
+		t.Fatal("no such event")
+		return 0
+	}
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(evs []TraceEvent) int
+		diff   string
+	}{{
+		name: "commit",
+		mutate: func(evs []TraceEvent) int {
+			i := find(func(e TraceEvent) bool { return e.Name == "Ready" && e.NodeID == "3" })
+			evs[i].State.Commit++
+			return i
+		},
+		diff: "commit: recorded",
+	}, {
+		name: "role",
+		mutate: func(evs []TraceEvent) int {
+			i := find(func(e TraceEvent) bool { return e.Name == "ReceiveAppendEntriesResponse" })
+			evs[i].Role = "StateFollower"
+			return i
+		},
+		diff: "role: recorded StateFollower, replayed StateLeader",
+	}, {
+		name: "message",
+		mutate: func(evs []TraceEvent) int {
+			i := find(func(e TraceEvent) bool {
+				return e.Name == "ReceiveAppendEntriesRequest" && e.Message.Type == "MsgApp"
+			})
+			msg := *evs[i].Message
+			msg.Commit += 10
+			evs[i].Message = &msg
+			return i
+		},
+		diff: "was not sent, delivering",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			evs := append([]TraceEvent(nil), evs...)
+			i := tt.mutate(evs)
+			replay, err := ReplayTrace(evs)
+			require.NoError(t, err)
+			require.NotEmpty(t, replay.Divergences)
+			d := replay.Divergences[0]
+			require.Equal(t, evs[i].Line, d.Event.Line, d.String())
+			require.Contains(t, d.Diff, tt.diff)
+			require.True(t, strings.Contains(replay.Script, "# "+d.String()))
+		})
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	evs, err := ReadTrace(strings.NewReader(`{"level":"info","msg":"starting"}
+
+{"msg":"trace","tag":"trace","event":{"name":"InitState","nid":"1","state":{"term":0,"vote":"0","commit":0},"role":"StateFollower","log":0,"conf":[[],[]]}}
+{"msg":"trace","tag":"trace","event":{"name":"ApplyConfChange","nid":"1","state":{"term":1,"vote":"0","commit":1},"role":"StateFollower","log":1,"conf":[[],[]],"prop":{"cc":{"newconf":["1"]}}}}
+`))
+	require.NoError(t, err)
+	require.Len(t, evs, 2)
+	require.Equal(t, 3, evs[0].Line)
+	require.Equal(t, "InitState", evs[0].Name)
+	require.Equal(t, 4, evs[1].Line)
+	require.Equal(t, []string{"1"}, evs[1].Prop.ConfChange.NewConf)
+
+	_, err = ReadTrace(strings.NewReader("{\n"))
+	require.ErrorContains(t, err, "line 1")
+}
@@ -63,6 +63,8 @@ func NewRawNode(config *Config) (*RawNode, error) {
 // Tick advances the internal logical clock by a single tick.
 func (rn *RawNode) Tick() {
 	rn.raft.tick()
+	rn.raft.proposals.expire(rn.raft.ticks)
+	rn.raft.readWaiters.expire(rn.raft.ticks)
 }
 
 // TickQuiesced advances the internal logical clock by a single tick without
@@ -74,9 +76,13 @@ func (rn *RawNode) Tick() {
 // WARNING: Be very careful about using this method as it subverts the Raft
 // state machine. You should probably be using Tick instead.
 //
-// DEPRECATED: This method will be removed in a future release.
+// DEPRECATED: This method will be removed in a future release. Use
+// Config.Quiescence instead.
 func (rn *RawNode) TickQuiesced() {
+	rn.raft.ticks++
 	rn.raft.electionElapsed++
+	rn.raft.proposals.expire(rn.raft.ticks)
+	rn.raft.readWaiters.expire(rn.raft.ticks)
 }
 
 // Campaign causes this RawNode to transition to candidate state.
@@ -86,7 +92,8 @@ func (rn *RawNode) Campaign() error {
 	})
 }
 
-// Propose proposes data be appended to the raft log.
+// Propose proposes data be appended to the raft log. If the proposal is
+// dropped, the returned error is a *ProposalDroppedError describing why.
 func (rn *RawNode) Propose(data []byte) error {
 	return rn.raft.Step(pb.Message{
 		Type: pb.MsgProp,
@@ -96,6 +103,24 @@ func (rn *RawNode) Propose(data []byte) error {
 		}})
 }
 
+// ProposeWithTracking proposes data be appended to the raft log, like Propose,
+// and returns a handle which is notified when the proposal is committed and
+// applied, or fails. See ProposalHandle.
+//
+// Only the leader can track proposals. On other nodes, the proposal is dropped
+// with ProposalDropNotLeader, and the caller can redirect it to the leader.
+func (rn *RawNode) ProposeWithTracking(data []byte) (*ProposalHandle, error) {
+	h := newProposalHandle()
+	if err := rn.raft.proposeTracked(pb.Message{
+		Type:    pb.MsgProp,
+		From:    rn.raft.id,
+		Entries: []pb.Entry{{Data: data}},
+	}, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
 // ProposeConfChange proposes a config change. See (Node).ProposeConfChange for
 // details.
 func (rn *RawNode) ProposeConfChange(cc pb.ConfChangeI) error {
@@ -146,6 +171,11 @@ func (rn *RawNode) readyWithoutAccept() Ready {
 		CommittedEntries: r.raftLog.nextCommittedEnts(rn.applyUnstableEntries()),
 		Messages:         r.msgs,
 	}
+	if r.isWitness {
+		// Witnesses don't hold the state machine, so they get the normal entries
+		// without payloads, like the leader sends them.
+		rd.CommittedEntries = witnessEntries(rd.CommittedEntries)
+	}
 	if softSt := r.softState(); !softSt.equal(rn.prevSoftSt) {
 		// Allocate only when SoftState changes.
 		escapingSoftSt := softSt
@@ -160,6 +190,12 @@ func (rn *RawNode) readyWithoutAccept() Ready {
 	if len(r.readStates) != 0 {
 		rd.ReadStates = r.readStates
 	}
+	if len(r.leadTransferResults) != 0 {
+		rd.LeaderTransfers = r.leadTransferResults
+	}
+	if len(r.promotionResults) != 0 {
+		rd.LearnerPromotions = r.promotionResults
+	}
 	rd.MustSync = MustSync(r.hardState(), rn.prevHardSt, len(rd.Entries))
 
 	if rn.asyncStorageWrites {
@@ -412,6 +448,12 @@ func (rn *RawNode) acceptReady(rd Ready) {
 	if len(rd.ReadStates) != 0 {
 		rn.raft.readStates = nil
 	}
+	if len(rd.LeaderTransfers) != 0 {
+		rn.raft.leadTransferResults = nil
+	}
+	if len(rd.LearnerPromotions) != 0 {
+		rn.raft.promotionResults = nil
+	}
 	if !rn.asyncStorageWrites {
 		if len(rn.stepsOnAdvance) != 0 {
 			rn.raft.logger.Panicf("two accepted Ready structs without call to Advance")
@@ -437,6 +479,7 @@ func (rn *RawNode) acceptReady(rd Ready) {
 		ents := rd.CommittedEntries
 		index := ents[len(ents)-1].Index
 		rn.raft.raftLog.acceptApplying(index, entsSize(ents), rn.applyUnstableEntries())
+		rn.raft.proposals.commit(ents)
 	}
 
 	traceReady(rn.raft)
@@ -468,7 +511,7 @@ func (rn *RawNode) HasReady() bool {
 	if r.raftLog.hasNextUnstableEnts() || r.raftLog.hasNextCommittedEnts(rn.applyUnstableEntries()) {
 		return true
 	}
-	if len(r.readStates) != 0 {
+	if len(r.readStates) != 0 || len(r.leadTransferResults) != 0 || len(r.promotionResults) != 0 {
 		return true
 	}
 	return false
@@ -526,6 +569,7 @@ func (rn *RawNode) WithProgress(visitor func(id uint64, typ ProgressType, pr tra
 		}
 		p := *pr
 		p.Inflights = nil
+		p.RateLimit = nil
 		visitor(id, typ, p)
 	})
 }
@@ -535,6 +579,19 @@ func (rn *RawNode) ReportUnreachable(id uint64) {
 	_ = rn.raft.Step(pb.Message{Type: pb.MsgUnreachable, From: id})
 }
 
+// ReportApplyProgress reports the progress of the application's apply thread:
+// the highest log index applied to the state machine, and the number of
+// committed entries queued for application. It allows raft to hand out more
+// committed entries and accept proposals before the corresponding Ready or
+// MsgStorageApply is acknowledged, see Config.MaxApplyLag and
+// Config.MaxProposalApplyLag.
+//
+// The report is only a hint: the application must still acknowledge the
+// applied entries with Advance or the MsgStorageApply responses.
+func (rn *RawNode) ReportApplyProgress(applied, queueDepth uint64) {
+	rn.raft.reportApplyProgress(applied, queueDepth)
+}
+
 // ReportSnapshot reports the status of the sent snapshot.
 func (rn *RawNode) ReportSnapshot(id uint64, status SnapshotStatus) {
 	rej := status == SnapshotFailure
@@ -542,7 +599,10 @@ func (rn *RawNode) ReportSnapshot(id uint64, status SnapshotStatus) {
 	_ = rn.raft.Step(pb.Message{Type: pb.MsgSnapStatus, From: id, Reject: rej})
 }
 
-// TransferLeader tries to transfer leadership to the given transferee.
+// TransferLeader tries to transfer leadership to the given transferee. If the
+// transferee is None, the leader picks the target itself, see
+// Config.LeaderTransferPreference. The outcome of the transfer is reported in
+// Ready.LeaderTransfers of the leader.
 func (rn *RawNode) TransferLeader(transferee uint64) {
 	_ = rn.raft.Step(pb.Message{Type: pb.MsgTransferLeader, From: transferee})
 }
@@ -553,10 +613,31 @@ func (rn *RawNode) ForgetLeader() error {
 	return rn.raft.Step(pb.Message{Type: pb.MsgForgetLeader})
 }
 
+// Unquiesce wakes up a quiesced peer. See Config.Quiescence for details.
+func (rn *RawNode) Unquiesce() error {
+	return rn.raft.Step(pb.Message{Type: pb.MsgUnquiesce})
+}
+
 // ReadIndex requests a read state. The read state will be set in ready.
 // Read State has a read index. Once the application advances further than the read
 // index, any linearizable read requests issued before the read request can be
-// processed safely. The read state will have the same rctx attached.
+// processed safely. The read state will have the same rctx attached. The rctx
+// must not start with "raft-read-index/", which is reserved for the reads made
+// with ReadIndexWithWaiter.
 func (rn *RawNode) ReadIndex(rctx []byte) {
 	_ = rn.raft.Step(pb.Message{Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: rctx}}})
 }
+
+// ReadIndexWithWaiter issues a linearizable read, like ReadIndex, and returns a
+// waiter which is notified when the read index has been applied, i.e. when the
+// read can be served from the local state machine, or when the read fails. See
+// ReadIndexWaiter.
+//
+// If there is no known leader, the read is dropped with ErrReadIndexDropped.
+func (rn *RawNode) ReadIndexWithWaiter() (*ReadIndexWaiter, error) {
+	w := newReadIndexWaiter()
+	if err := rn.raft.readIndexWithWaiter(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -45,6 +45,14 @@ func (a *rawNodeAdapter) TransferLeadership(_ context.Context, _, transferee uin
 // ForgetLeader takes a context, RawNode doesn't need it.
 func (a *rawNodeAdapter) ForgetLeader(context.Context) error { return a.RawNode.ForgetLeader() }
 
+// Unquiesce takes a context, RawNode doesn't need it.
+func (a *rawNodeAdapter) Unquiesce(context.Context) error { return a.RawNode.Unquiesce() }
+
+// ProposeWithTracking takes a context, RawNode doesn't need it.
+func (a *rawNodeAdapter) ProposeWithTracking(_ context.Context, data []byte) (*ProposalHandle, error) {
+	return a.RawNode.ProposeWithTracking(data)
+}
+
 // Stop when node has a goroutine, RawNode doesn't need this.
 func (a *rawNodeAdapter) Stop() {}
 
@@ -66,6 +74,11 @@ func (a *rawNodeAdapter) ReadIndex(_ context.Context, rctx []byte) error {
 	// RawNode swallowed the error in ReadIndex, it probably should not do that.
 	return nil
 }
+
+// ReadIndexWithWaiter takes a context, RawNode doesn't need it.
+func (a *rawNodeAdapter) ReadIndexWithWaiter(_ context.Context) (*ReadIndexWaiter, error) {
+	return a.RawNode.ReadIndexWithWaiter()
+}
 func (a *rawNodeAdapter) Step(_ context.Context, m pb.Message) error { return a.RawNode.Step(m) }
 func (a *rawNodeAdapter) Propose(_ context.Context, data []byte) error {
 	return a.RawNode.Propose(data)
@@ -505,6 +518,27 @@ func TestRawNodeProposeAddDuplicateNode(t *testing.T) {
 	assert.Equal(t, ccdata2, entries[2].Data)
 }
 
+// TestRawNodeProposeDropped checks that the proposals dropped by a RawNode
+// return a ProposalDroppedError describing why.
+func TestRawNodeProposeDropped(t *testing.T) {
+	cfg := newTestConfig(2, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+	cfg.DisableProposalForwarding = true
+	rn, err := NewRawNode(cfg)
+	require.NoError(t, err)
+
+	err = rn.Propose([]byte("foo"))
+	require.ErrorIs(t, err, ErrProposalDropped)
+	var pErr *ProposalDroppedError
+	require.ErrorAs(t, err, &pErr)
+	require.Equal(t, ProposalDroppedError{Reason: ProposalDropNoLeader, Lead: None, Term: 0}, *pErr)
+
+	require.NoError(t, rn.Step(pb.Message{From: 1, To: 2, Term: 3, Type: pb.MsgHeartbeat}))
+	err = rn.ProposeConfChange(pb.ConfChange{Type: pb.ConfChangeAddNode, NodeID: 3})
+	require.ErrorAs(t, err, &pErr)
+	require.Equal(t, ProposalDroppedError{Reason: ProposalDropForwardingDisabled, Lead: 1, Term: 3}, *pErr)
+	require.Equal(t, "raft proposal dropped: forwarding disabled (lead: 1, term: 3)", err.Error())
+}
+
 // TestRawNodeReadIndex ensures that Rawnode.ReadIndex sends the MsgReadIndex message
 // to the underlying raft. It also ensures that ReadState can be read out.
 func TestRawNodeReadIndex(t *testing.T) {
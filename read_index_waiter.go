@@ -0,0 +1,205 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+var (
+	// ErrReadIndexDropped is returned for a read made with ReadIndexWithWaiter
+	// when there is no known leader to serve it.
+	ErrReadIndexDropped = errors.New("raft: read index request dropped")
+	// ErrReadIndexLeaderChanged is returned for a read made with
+	// ReadIndexWithWaiter when the leader or the term changed before the read
+	// index was known. The request may have been lost, and should be retried.
+	ErrReadIndexLeaderChanged = errors.New("raft: leader changed during read index request")
+	// ErrReadIndexTimeout is returned for a read made with ReadIndexWithWaiter
+	// whose read index has not been received within Config.ReadIndexTimeout
+	// ticks.
+	ErrReadIndexTimeout = errors.New("raft: read index request timed out")
+)
+
+// readIndexWaiterCtxPrefix prefixes the request contexts of the reads made with
+// ReadIndexWithWaiter. The contexts passed to ReadIndex must not start with it.
+var readIndexWaiterCtxPrefix = []byte("raft-read-index/")
+
+// ReadIndexWaiter tracks a linearizable read made with
+// RawNode.ReadIndexWithWaiter or Node.ReadIndexWithWaiter. It is notified when
+// the local state machine has applied the read index, i.e. when the read can be
+// served from it, or when the read fails.
+//
+// Unlike with ReadIndex, the read states of such reads are not returned in
+// Ready.ReadStates.
+type ReadIndexWaiter struct {
+	rctx []byte
+	// index is the read index, if resolved is true.
+	index    uint64
+	resolved bool
+	// deadline is the tick at which the read times out if its read index has
+	// not been received by then. Zero if there is no deadline.
+	deadline uint64
+
+	ready chan struct{}
+	err   error
+}
+
+func newReadIndexWaiter() *ReadIndexWaiter {
+	return &ReadIndexWaiter{ready: make(chan struct{})}
+}
+
+// Index returns the read index, or zero if it is not known yet. It must only be
+// read after the Ready channel is closed.
+func (w *ReadIndexWaiter) Index() uint64 { return w.index }
+
+// Ready returns a channel which is closed when the read can be served from the
+// local state machine, or when the read fails.
+func (w *ReadIndexWaiter) Ready() <-chan struct{} { return w.ready }
+
+// Err returns the reason why the read has failed, or nil if it has not. It
+// must only be called after the Ready channel is closed.
+func (w *ReadIndexWaiter) Err() error { return w.err }
+
+// Wait blocks until the read can be served or fails, and returns the error if
+// it fails. Returns ctx.Err() if the context is done first.
+func (w *ReadIndexWaiter) Wait(ctx context.Context) error {
+	select {
+	case <-w.ready:
+		return w.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *ReadIndexWaiter) fail(err error) {
+	w.err = err
+	close(w.ready)
+}
+
+// readIndexWaiters holds the reads made with ReadIndexWithWaiter which are not
+// ready and have not failed yet, in the order in which they were issued.
+type readIndexWaiters struct {
+	pending []*ReadIndexWaiter
+	// seq is the sequence number of the last issued read.
+	seq uint64
+	// timeout is the number of ticks after which a read without a read index
+	// fails.
+	timeout uint64
+}
+
+// track starts tracking the read, issued at the given tick, and assigns it a
+// request context which is unique among the peers of the group.
+func (ws *readIndexWaiters) track(w *ReadIndexWaiter, id, now uint64) {
+	ws.seq++
+	w.rctx = fmt.Appendf(append([]byte(nil), readIndexWaiterCtxPrefix...), "%x/%d", id, ws.seq)
+	if ws.timeout > 0 {
+		w.deadline = now + ws.timeout
+	}
+	ws.pending = append(ws.pending, w)
+}
+
+// resolve records the read index of the read with the given request context.
+// Returns false if the read is not tracked.
+func (ws *readIndexWaiters) resolve(rs ReadState) bool {
+	for _, w := range ws.pending {
+		if bytes.Equal(w.rctx, rs.RequestCtx) {
+			if !w.resolved {
+				w.index, w.resolved = rs.Index, true
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// applied notifies the reads whose read index is applied up to the given
+// index.
+func (ws *readIndexWaiters) applied(index uint64) {
+	kept := ws.pending[:0]
+	for _, w := range ws.pending {
+		if w.resolved && w.index <= index {
+			close(w.ready)
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	ws.pending = kept
+}
+
+// failUnresolved fails the reads whose read index is not known yet.
+func (ws *readIndexWaiters) failUnresolved(err error) {
+	kept := ws.pending[:0]
+	for _, w := range ws.pending {
+		if w.resolved {
+			kept = append(kept, w)
+		} else {
+			w.fail(err)
+		}
+	}
+	ws.pending = kept
+}
+
+// expire fails the reads without a read index whose deadline has passed.
+func (ws *readIndexWaiters) expire(now uint64) {
+	if ws.timeout == 0 {
+		return
+	}
+	kept := ws.pending[:0]
+	for _, w := range ws.pending {
+		if w.resolved || now < w.deadline {
+			kept = append(kept, w)
+		} else {
+			w.fail(ErrReadIndexTimeout)
+		}
+	}
+	ws.pending = kept
+}
+
+// readIndexWithWaiter issues a linearizable read, and starts tracking it with
+// the given waiter. The read is dropped with ErrReadIndexDropped if there is no
+// known leader to serve it.
+func (r *raft) readIndexWithWaiter(w *ReadIndexWaiter) error {
+	if r.state != StateLeader && r.lead == None {
+		r.logger.Infof("%x no leader at term %d; dropping tracked read index request", r.id, r.Term)
+		return ErrReadIndexDropped
+	}
+	r.readWaiters.track(w, r.id, r.ticks)
+	if err := r.Step(pb.Message{Type: pb.MsgReadIndex, Entries: []pb.Entry{{Data: w.rctx}}}); err != nil {
+		r.readWaiters.pending = r.readWaiters.pending[:len(r.readWaiters.pending)-1]
+		return err
+	}
+	// On a single-voter leader, the read index is known immediately.
+	r.readWaiters.applied(r.raftLog.applied)
+	return nil
+}
+
+// addReadState hands the read state over to its ReadIndexWaiter, or returns it
+// to the application in the next Ready if the read was made with ReadIndex.
+func (r *raft) addReadState(rs ReadState) {
+	if !bytes.HasPrefix(rs.RequestCtx, readIndexWaiterCtxPrefix) {
+		r.readStates = append(r.readStates, rs)
+		return
+	}
+	if !r.readWaiters.resolve(rs) {
+		r.logger.Debugf("%x ignoring read state for untracked read index request %q", r.id, rs.RequestCtx)
+		return
+	}
+	r.readWaiters.applied(r.raftLog.applied)
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+// requireReadIndexRequest checks that the Ready of the given RawNode forwards
+// a single read index request to the leader, and returns it.
+func requireReadIndexRequest(t *testing.T, rn *RawNode) pb.Message {
+	t.Helper()
+	rd := rn.Ready()
+	require.Len(t, rd.Messages, 1)
+	m := rd.Messages[0]
+	require.Equal(t, pb.MsgReadIndex, m.Type)
+	require.Equal(t, uint64(2), m.To)
+	require.Len(t, m.Entries, 1)
+	rn.Advance(rd)
+	return m
+}
+
+func TestReadIndexWithWaiterFollower(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn.raft.becomeFollower(1, 2)
+	w, err := rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+	req := requireReadIndexRequest(t, rn)
+	requireOpen(t, w.Ready())
+
+	// The leader responds with a read index which is not applied yet.
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 1, Type: pb.MsgReadIndexResp,
+		Index: 2, Entries: req.Entries}))
+	requireOpen(t, w.Ready())
+	require.False(t, rn.HasReady())
+
+	// The read is ready once the read index is applied.
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 1, Type: pb.MsgApp,
+		Entries: []pb.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}}, Commit: 2}))
+	rd := rn.Ready()
+	require.Empty(t, rd.ReadStates)
+	require.Len(t, rd.CommittedEntries, 2)
+	requireOpen(t, w.Ready())
+	rn.Advance(rd)
+	requireClosed(t, w.Ready())
+	require.NoError(t, w.Wait(context.Background()))
+	require.Equal(t, uint64(2), w.Index())
+	require.Empty(t, rn.raft.readWaiters.pending)
+
+	// A duplicate response is ignored.
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 1, Type: pb.MsgReadIndexResp,
+		Index: 2, Entries: req.Entries}))
+	require.Empty(t, rn.raft.readStates)
+}
+
+func TestReadIndexWithWaiterLeader(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	// The read index of a single-voter leader is its commit index, which is
+	// already applied.
+	w, err := rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+	requireClosed(t, w.Ready())
+	require.NoError(t, w.Err())
+	require.Equal(t, uint64(0), w.Index())
+
+	rd := rn.Ready()
+	require.NoError(t, s.Append(rd.Entries))
+	rn.Advance(rd)
+	rd = rn.Ready()
+	require.Len(t, rd.CommittedEntries, 1)
+	w, err = rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), w.Index())
+	requireOpen(t, w.Ready())
+	rn.Advance(rd)
+	requireClosed(t, w.Ready())
+	require.NoError(t, w.Err())
+	require.Empty(t, rd.ReadStates)
+}
+
+func TestReadIndexWithWaiterNoLeader(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn.raft.becomeFollower(1, 2)
+	rn.raft.becomeFollower(2, None)
+	_, err := rn.ReadIndexWithWaiter()
+	require.ErrorIs(t, err, ErrReadIndexDropped)
+	require.Empty(t, rn.raft.readWaiters.pending)
+	require.Empty(t, rn.raft.msgs)
+}
+
+func TestReadIndexWithWaiterLeaderChanged(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn.raft.becomeFollower(1, 2)
+	w1, err := rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+	req := requireReadIndexRequest(t, rn)
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 1, Type: pb.MsgReadIndexResp,
+		Index: 2, Entries: req.Entries}))
+	w2, err := rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+
+	// The read index of the first read is still valid after the leader change,
+	// but the second read may have been lost.
+	require.NoError(t, rn.Step(pb.Message{From: 3, To: 1, Term: 2, Type: pb.MsgHeartbeat}))
+	requireOpen(t, w1.Ready())
+	requireClosed(t, w2.Ready())
+	require.ErrorIs(t, w2.Err(), ErrReadIndexLeaderChanged)
+	require.Len(t, rn.raft.readWaiters.pending, 1)
+}
+
+func TestReadIndexWithWaiterTimeout(t *testing.T) {
+	rn := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)), func(c *Config) {
+		c.ReadIndexTimeout = 3
+	})
+	rn.raft.becomeFollower(1, 2)
+	w, err := rn.ReadIndexWithWaiter()
+	require.NoError(t, err)
+	req := requireReadIndexRequest(t, rn)
+	rn.Tick()
+	rn.Tick()
+	requireOpen(t, w.Ready())
+	rn.Tick()
+	requireClosed(t, w.Ready())
+	require.ErrorIs(t, w.Err(), ErrReadIndexTimeout)
+	require.Empty(t, rn.raft.readWaiters.pending)
+
+	// A late response is not returned to the application.
+	require.NoError(t, rn.Step(pb.Message{From: 2, To: 1, Term: 1, Type: pb.MsgReadIndexResp,
+		Index: 2, Entries: req.Entries}))
+	require.Empty(t, rn.raft.readStates)
+}
+
+// TestReadIndexWithWaiterContexts checks that the reads of different peers use
+// different request contexts, so that the leader can tell them apart.
+func TestReadIndexWithWaiterContexts(t *testing.T) {
+	rn1 := newTestRawNode(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn1.raft.becomeFollower(1, 2)
+	rn3 := newTestRawNode(3, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	rn3.raft.becomeFollower(1, 2)
+
+	var ctxs []string
+	for _, rn := range []*RawNode{rn1, rn1, rn3} {
+		_, err := rn.ReadIndexWithWaiter()
+		require.NoError(t, err)
+		ctxs = append(ctxs, string(requireReadIndexRequest(t, rn).Entries[0].Data))
+	}
+	require.Equal(t, []string{"raft-read-index/1/1", "raft-read-index/1/2", "raft-read-index/3/1"}, ctxs)
+}
+
+func TestNodeReadIndexWithWaiter(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	n := newNode(rn)
+	go n.run()
+	defer n.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := n.ReadIndexWithWaiter(ctx)
+	require.ErrorIs(t, err, ErrReadIndexDropped)
+	require.NoError(t, n.Campaign(ctx))
+
+	var w *ReadIndexWaiter
+	var ready <-chan struct{} // nil until the read is made
+	for {
+		select {
+		case rd := <-n.Ready():
+			require.NoError(t, s.Append(rd.Entries))
+			n.Advance()
+			if w == nil && rd.SoftState != nil && rd.SoftState.Lead == 1 {
+				w, err = n.ReadIndexWithWaiter(ctx)
+				require.NoError(t, err)
+				ready = w.Ready()
+			}
+		case <-ready:
+			require.NoError(t, w.Err())
+			return
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+}
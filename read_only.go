@@ -37,14 +37,22 @@ type readIndexStatus struct {
 }
 
 type readOnly struct {
-	option           ReadOnlyOption
+	option ReadOnlyOption
+	// batch is true if requests arriving while a confirmation round is in
+	// flight wait for the next round instead of starting their own.
+	batch bool
+	// inflight is the context of the last request covered by the most
+	// recently started confirmation round, or empty if that round completed.
+	// An ack for it confirms all the requests queued before it.
+	inflight         string
 	pendingReadIndex map[string]*readIndexStatus
 	readIndexQueue   []string
 }
 
-func newReadOnly(option ReadOnlyOption) *readOnly {
+func newReadOnly(option ReadOnlyOption, batch bool) *readOnly {
 	return &readOnly{
 		option:           option,
+		batch:            batch,
 		pendingReadIndex: make(map[string]*readIndexStatus),
 	}
 }
@@ -105,6 +113,9 @@ func (ro *readOnly) advance(m pb.Message) []*readIndexStatus {
 		for _, rs := range rss {
 			delete(ro.pendingReadIndex, string(rs.req.Entries[0].Data))
 		}
+		if _, ok := ro.pendingReadIndex[ro.inflight]; !ok {
+			ro.inflight = ""
+		}
 		return rss
 	}
 
@@ -119,3 +130,20 @@ func (ro *readOnly) lastPendingRequestCtx() string {
 	}
 	return ro.readIndexQueue[len(ro.readIndexQueue)-1]
 }
+
+// startRound starts a confirmation round covering all the pending read only
+// requests, and returns the context to attach to its heartbeats. Returns nil
+// if there are no pending requests.
+func (ro *readOnly) startRound() []byte {
+	ro.inflight = ro.lastPendingRequestCtx()
+	if len(ro.inflight) == 0 {
+		return nil
+	}
+	return []byte(ro.inflight)
+}
+
+// roundInflight returns true if the last started confirmation round has not
+// completed yet.
+func (ro *readOnly) roundInflight() bool {
+	return len(ro.inflight) != 0
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"go.etcd.io/raft/v3/tracker"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+const (
+	defaultSnapshotChunkSize         = 1 << 20
+	defaultMaxInflightSnapshotChunks = 4
+)
+
+// SnapshotSource provides the data of snapshots to a leader that streams them
+// to followers in chunks. See Config.SnapshotSource.
+type SnapshotSource interface {
+	// SnapshotInfo returns the metadata of the most recent snapshot, and the
+	// size of its data in bytes. If the snapshot is temporarily unavailable, it
+	// should return ErrSnapshotTemporarilyUnavailable, so raft state machine
+	// could know that Storage needs some time to prepare snapshot and call
+	// SnapshotInfo later.
+	SnapshotInfo() (pb.SnapshotMetadata, uint64, error)
+	// SnapshotChunk returns the data of the snapshot with the given metadata,
+	// starting at the given byte offset, and at most size bytes long. The
+	// returned chunk must not be empty. If the snapshot is no longer available,
+	// it should return ErrSnapOutOfDate, in which case the transfer restarts
+	// with a more recent snapshot. If the chunk can't be read at the moment, it
+	// should return ErrSnapshotTemporarilyUnavailable, and the transfer resumes
+	// later.
+	SnapshotChunk(meta pb.SnapshotMetadata, offset, size uint64) ([]byte, error)
+}
+
+// SnapshotSink receives the data of the snapshots streamed by the leader. See
+// Config.SnapshotSink.
+type SnapshotSink interface {
+	// WriteSnapshotChunk writes the chunk of the data of the snapshot with the
+	// given metadata at the given byte offset. The chunks of a snapshot are
+	// written in order and without gaps. A chunk at offset 0 starts a new
+	// transfer, and the data of any partially received snapshot must be
+	// discarded. Returning an error aborts the transfer, which the leader
+	// retries later.
+	//
+	// Once all the chunks are written, the snapshot is returned in the Ready
+	// with its metadata only. The application must make the received data
+	// durable before persisting the snapshot, and restore the state machine
+	// from the received data when applying it.
+	WriteSnapshotChunk(meta pb.SnapshotMetadata, offset uint64, data []byte) error
+}
+
+// snapshotTransfer describes a snapshot that a follower receives in chunks.
+type snapshotTransfer struct {
+	meta pb.SnapshotMetadata
+	// size is the size of the snapshot data in bytes.
+	size uint64
+	// offset is the number of bytes received so far.
+	offset uint64
+}
+
+// matches returns true if the transfer is of the snapshot with the given
+// metadata.
+func (t *snapshotTransfer) matches(meta pb.SnapshotMetadata) bool {
+	return t.meta.Index == meta.Index && t.meta.Term == meta.Term
+}
+
+// startSnapshotStream starts streaming the most recent snapshot obtained from
+// the SnapshotSource to the given follower. Returns true iff the transfer has
+// started.
+func (r *raft) startSnapshotStream(to uint64, pr *tracker.Progress) bool {
+	meta, size, err := r.snapSource.SnapshotInfo()
+	if err != nil {
+		if err == ErrSnapshotTemporarilyUnavailable {
+			r.logger.Debugf("%x failed to send snapshot to %x because snapshot is temporarily unavailable", r.id, to)
+			return false
+		}
+		r.logger.Panicf("%x failed to get the snapshot to stream to %x: %v", r.id, to, err)
+	}
+	if meta.Index == 0 {
+		r.logger.Panicf("%x got empty snapshot to stream to %x", r.id, to)
+	}
+	r.logger.Debugf("%x [firstindex: %d, commit: %d] started streaming snapshot[index: %d, term: %d, size: %d] to %x [%s]",
+		r.id, r.raftLog.firstIndex(), r.raftLog.committed, meta.Index, meta.Term, size, to, pr)
+	pr.BecomeSnapshot(meta.Index)
+	r.logger.Debugf("%x paused sending replication messages to %x [%s]", r.id, to, pr)
+	r.metrics.SnapshotSent(to, meta.Index)
+	if size == 0 || pr.IsWitness {
+		// There is no data to stream, send the metadata in a regular MsgSnap.
+		r.send(pb.Message{To: to, Type: pb.MsgSnap, Snapshot: &pb.Snapshot{Metadata: meta}})
+		return true
+	}
+	pr.SnapshotMeta = meta
+	pr.SnapshotSize = size
+	pr.SnapshotAckTick = r.ticks
+	r.sendSnapshotChunks(to, pr)
+	return true
+}
+
+// sendSnapshotChunks sends the chunks of the pending snapshot to the given
+// follower, starting at the end of the data sent so far, while the number of
+// unacknowledged chunks in flight is below the limit.
+func (r *raft) sendSnapshotChunks(to uint64, pr *tracker.Progress) {
+	meta := pr.SnapshotMeta
+	window := r.maxInflightSnapChunks * r.snapChunkSize
+	for pr.SnapshotSent < pr.SnapshotSize && pr.SnapshotSent-pr.SnapshotAcked < window {
+		data, err := r.snapSource.SnapshotChunk(meta, pr.SnapshotSent, min(r.snapChunkSize, pr.SnapshotSize-pr.SnapshotSent))
+		if err == ErrSnapshotTemporarilyUnavailable {
+			// Resume on a heartbeat response.
+			r.logger.Debugf("%x failed to send snapshot chunk to %x because it is temporarily unavailable", r.id, to)
+			return
+		} else if err == ErrSnapOutOfDate {
+			r.logger.Debugf("%x snapshot [index: %d, term: %d] is out of date, aborted sending it to %x",
+				r.id, meta.Index, meta.Term, to)
+			pr.PendingSnapshot = 0
+			pr.BecomeProbe()
+			pr.MsgAppFlowPaused = true
+			return
+		} else if err != nil {
+			r.logger.Panicf("%x failed to get snapshot [index: %d, term: %d] chunk at offset %d to stream to %x: %v",
+				r.id, meta.Index, meta.Term, pr.SnapshotSent, to, err)
+		} else if len(data) == 0 {
+			r.logger.Panicf("%x got empty snapshot [index: %d, term: %d] chunk at offset %d of %d to stream to %x",
+				r.id, meta.Index, meta.Term, pr.SnapshotSent, pr.SnapshotSize, to)
+		}
+		r.send(pb.Message{
+			To:         to,
+			Type:       pb.MsgSnapChunk,
+			Index:      pr.SnapshotSent,
+			RejectHint: pr.SnapshotSize,
+			Snapshot:   &pb.Snapshot{Metadata: meta, Data: data},
+		})
+		pr.SentSnapshotChunk(uint64(len(data)))
+	}
+}
+
+// handleSnapshotChunkResp handles the acknowledgement of the chunks of a
+// streamed snapshot by a follower.
+func (r *raft) handleSnapshotChunkResp(m pb.Message, pr *tracker.Progress) {
+	if pr.State != tracker.StateSnapshot || pr.PendingSnapshot != m.RejectHint || pr.SnapshotSize == 0 {
+		return
+	}
+	if m.Reject {
+		// NB: the order here matters, see MsgSnapStatus.
+		pr.PendingSnapshot = 0
+		pr.BecomeProbe()
+		r.logger.Debugf("%x snapshot rejected, resumed sending replication messages to %x [%s]", r.id, m.From, pr)
+		// Wait for a heartbeat interval before the next try.
+		pr.MsgAppFlowPaused = true
+		return
+	}
+	if pr.AckSnapshotChunk(m.Index) {
+		pr.SnapshotAckTick = r.ticks
+	}
+	r.sendSnapshotChunks(m.From, pr)
+}
+
+// handleSnapshotChunk handles a chunk of a snapshot streamed by the leader. The
+// follower responds with the number of bytes of the snapshot data it has
+// received, which tells the leader where to continue from. Once all the data
+// has been received, the snapshot is restored like one received in MsgSnap.
+func (r *raft) handleSnapshotChunk(m pb.Message) {
+	var s pb.Snapshot
+	if m.Snapshot != nil {
+		s = *m.Snapshot
+	}
+	meta, offset := s.Metadata, m.Index
+	if meta.Index <= r.raftLog.committed {
+		r.logger.Infof("%x [commit: %d] ignored snapshot chunk [index: %d, term: %d]",
+			r.id, r.raftLog.committed, meta.Index, meta.Term)
+		r.send(pb.Message{To: m.From, Type: pb.MsgAppResp, Index: r.raftLog.committed})
+		return
+	}
+	resp := pb.Message{To: m.From, Type: pb.MsgSnapChunkResp, RejectHint: meta.Index}
+	if r.snapSink == nil {
+		r.logger.Warningf("%x rejected snapshot chunk [index: %d, term: %d] since there is no snapshot sink",
+			r.id, meta.Index, meta.Term)
+		resp.Reject = true
+		r.send(resp)
+		return
+	}
+	if !r.snapRecv.matches(meta) {
+		if offset != 0 {
+			// The transfer must start from the beginning.
+			r.send(resp)
+			return
+		}
+		r.snapRecv = snapshotTransfer{meta: meta, size: m.RejectHint}
+	}
+	if offset != r.snapRecv.offset {
+		// A duplicate, or there is a gap before the chunk. Let the leader know
+		// where to continue from.
+		resp.Index = r.snapRecv.offset
+		r.send(resp)
+		return
+	}
+	if err := r.snapSink.WriteSnapshotChunk(meta, offset, s.Data); err != nil {
+		r.logger.Warningf("%x failed to write snapshot chunk [index: %d, term: %d, offset: %d]: %v",
+			r.id, meta.Index, meta.Term, offset, err)
+		r.snapRecv = snapshotTransfer{}
+		resp.Reject = true
+		r.send(resp)
+		return
+	}
+	r.snapRecv.offset += uint64(len(s.Data))
+	if r.snapRecv.offset < r.snapRecv.size {
+		resp.Index = r.snapRecv.offset
+		r.send(resp)
+		return
+	}
+	r.snapRecv = snapshotTransfer{}
+	r.handleSnapshot(pb.Message{From: m.From, Snapshot: &pb.Snapshot{Metadata: meta}})
+}
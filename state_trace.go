@@ -48,6 +48,8 @@ const (
 	rsmReceiveRequestVoteResponse
 	rsmSendSnapshot
 	rsmReceiveSnapshot
+	rsmStartLeaderTransfer
+	rsmFinishLeaderTransfer
 )
 
 func (e stateMachineEventType) String() string {
@@ -71,6 +73,8 @@ func (e stateMachineEventType) String() string {
 		"ReceiveRequestVoteResponse",
 		"SendSnapshot",
 		"ReceiveSnapshot",
+		"StartLeaderTransfer",
+		"FinishLeaderTransfer",
 	}[e]
 }
 
@@ -131,13 +135,20 @@ func makeTracingState(r *raft) TracingState {
 	}
 }
 
-func makeTracingMessage(m *raftpb.Message) *TracingMessage {
+func makeTracingMessage(r *raft, m *raftpb.Message) *TracingMessage {
 	if m == nil {
 		return nil
 	}
 
 	logTerm := m.LogTerm
 	entries := len(m.Entries)
+	if m.EntriesCodec != 0 {
+		// The spec doesn't know about compression, trace the number of
+		// compressed entries.
+		if ents, err := r.decompressEntries(*m); err == nil {
+			entries = len(ents)
+		}
+	}
 	index := m.Index
 	if m.Type == raftpb.MsgSnap {
 		index = 0
@@ -175,7 +186,7 @@ func traceEvent(evt stateMachineEventType, r *raft, m *raftpb.Message, prop map[
 		LogSize:    r.raftLog.lastIndex(),
 		Conf:       [2][]string{formatConf(r.trk.Voters[0].Slice()), formatConf(r.trk.Voters[1].Slice())},
 		Role:       r.state.String(),
-		Message:    makeTracingMessage(m),
+		Message:    makeTracingMessage(r, m),
 		Properties: prop,
 	})
 }
@@ -252,7 +263,7 @@ func traceChangeConfEvent(cci raftpb.ConfChangeI, r *raft) {
 				NodeID: strconv.FormatUint(c.NodeID, 10),
 				Action: ConfChangeRemoveServer,
 			})
-		case raftpb.ConfChangeAddLearnerNode:
+		case raftpb.ConfChangeAddLearnerNode, raftpb.ConfChangeAddLearnerNodeAutoPromote:
 			cc.Changes = append(cc.Changes, SingleConfChange{
 				NodeID: strconv.FormatUint(c.NodeID, 10),
 				Action: ConfChangeAddLearner,
@@ -284,6 +295,28 @@ func traceConfChangeEvent(cfg tracker.Config, r *raft) {
 	traceEvent(rsmApplyConfChange, r, nil, p)
 }
 
+func traceStartLeaderTransfer(r *raft, to uint64) {
+	if r.traceLogger == nil {
+		return
+	}
+
+	p := map[string]any{}
+	p["target"] = strconv.FormatUint(to, 10)
+	traceEvent(rsmStartLeaderTransfer, r, nil, p)
+}
+
+func traceFinishLeaderTransfer(r *raft, res LeaderTransferResult) {
+	if r.traceLogger == nil {
+		return
+	}
+
+	p := map[string]any{}
+	p["target"] = strconv.FormatUint(res.Target, 10)
+	p["term"] = res.Term
+	p["outcome"] = res.Outcome.String()
+	traceEvent(rsmFinishLeaderTransfer, r, nil, p)
+}
+
 func traceSendMessage(r *raft, m *raftpb.Message) {
 	if r.traceLogger == nil {
 		return
@@ -45,6 +45,10 @@ func traceChangeConfEvent(raftpb.ConfChangeI, *raft) {}
 
 func traceConfChangeEvent(tracker.Config, *raft) {}
 
+func traceStartLeaderTransfer(*raft, uint64) {}
+
+func traceFinishLeaderTransfer(*raft, LeaderTransferResult) {}
+
 func traceSendMessage(*raft, *raftpb.Message) {}
 
 func traceReceiveMessage(*raft, *raftpb.Message) {}
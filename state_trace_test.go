@@ -0,0 +1,73 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build with_tla
+
+package raft
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	pb "go.etcd.io/raft/v3/raftpb"
+)
+
+type testTraceLogger []*TracingEvent
+
+func (l *testTraceLogger) TraceEvent(e *TracingEvent) {
+	*l = append(*l, e)
+}
+
+// TestTraceCompressedAppend checks that the MsgApp messages with compressed
+// entries are traced with the number of entries, both by the leader and the
+// follower.
+func TestTraceCompressedAppend(t *testing.T) {
+	var evs testTraceLogger
+	newTracedRaft := func(id uint64) *raft {
+		cfg := newTestConfig(id, 10, 1, newTestMemoryStorage(withPeers(1, 2)))
+		cfg.EntryCodecs = []Codec{FlateCodec{}}
+		cfg.TraceLogger = &evs
+		return newRaft(cfg)
+	}
+	leader, follower := newTracedRaft(1), newTracedRaft(2)
+	leader.becomeCandidate()
+	leader.becomeLeader()
+	leader.readMessages()
+	pr := leader.trk.Progress[2]
+	pr.Codecs = 1 << FlateCodecID
+	pr.BecomeReplicate()
+
+	evs = nil
+	for i := 0; i < 3; i++ {
+		require.True(t, leader.appendEntry(pb.Entry{Data: bytes.Repeat([]byte("a"), 100)}))
+	}
+	leader.sendAppend(2)
+	msgs := leader.readMessages()
+	require.Len(t, msgs, 1)
+	require.NotZero(t, msgs[0].EntriesCodec)
+	require.NoError(t, follower.Step(msgs[0]))
+
+	var traced []*TracingMessage
+	for _, e := range evs {
+		if e.Message != nil && e.Message.Type == pb.MsgApp.String() {
+			traced = append(traced, e.Message)
+		}
+	}
+	require.Len(t, traced, 2) // sent and received
+	for _, m := range traced {
+		require.Equal(t, 4, m.EntryLength)
+	}
+}
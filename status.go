@@ -39,6 +39,33 @@ type BasicStatus struct {
 	Applied uint64
 
 	LeadTransferee uint64
+
+	Lease LeaseStatus
+
+	// Apply is only populated if Config.MaxApplyLag or
+	// Config.MaxProposalApplyLag is set.
+	Apply ApplyStatus
+
+	// Quiesced is true if the peer is quiesced, see Config.Quiescence.
+	Quiesced bool
+}
+
+// LeaseStatus describes the leader lease of a Raft peer, see Config.LeaseTicks.
+type LeaseStatus struct {
+	// Now is the current local time in ticks.
+	Now uint64
+	// Expiry is the local time in ticks at which the leader's lease expires.
+	// Zero if this peer is not the leader, leases are disabled, or the lease
+	// can't be used due to a leadership transfer.
+	Expiry uint64
+	// SupportExpiry is the local time in ticks until which this peer has
+	// promised not to vote for another candidate.
+	SupportExpiry uint64
+}
+
+// Valid returns true if the leader's lease has not expired.
+func (s LeaseStatus) Valid() bool {
+	return s.Now < s.Expiry
 }
 
 func getProgressCopy(r *raft) map[uint64]tracker.Progress {
@@ -46,6 +73,7 @@ func getProgressCopy(r *raft) map[uint64]tracker.Progress {
 	r.trk.Visit(func(id uint64, pr *tracker.Progress) {
 		p := *pr
 		p.Inflights = pr.Inflights.Clone()
+		p.RateLimit = pr.RateLimit.Clone()
 		pr = nil
 
 		m[id] = p
@@ -61,6 +89,13 @@ func getBasicStatus(r *raft) BasicStatus {
 	s.HardState = r.hardState()
 	s.SoftState = r.softState()
 	s.Applied = r.raftLog.applied
+	s.Quiesced = r.quiesced
+	if r.leaseTicks > 0 {
+		s.Lease = LeaseStatus{Now: r.ticks, Expiry: r.leaseExpiry(), SupportExpiry: r.leaseSupportExpiry}
+	}
+	if r.raftLog.maxApplyLag > 0 || r.maxProposalApplyLag > 0 {
+		s.Apply = r.applyStatus()
+	}
 	return s
 }
 
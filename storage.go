@@ -37,18 +37,13 @@ var ErrUnavailable = errors.New("requested entry at index is unavailable")
 // snapshot is temporarily unavailable.
 var ErrSnapshotTemporarilyUnavailable = errors.New("snapshot is temporarily unavailable")
 
-// Storage is an interface that may be implemented by the application
+// LogStorage is an interface that may be implemented by the application
 // to retrieve log entries from storage.
 //
-// If any Storage method returns an error, the raft instance will
+// If any LogStorage method returns an error, the raft instance will
 // become inoperable and refuse to participate in elections; the
 // application is responsible for cleanup and recovery in this case.
-type Storage interface {
-	// TODO(tbg): split this into two interfaces, LogStorage and StateStorage.
-
-	// InitialState returns the saved HardState and ConfState information.
-	InitialState() (pb.HardState, pb.ConfState, error)
-
+type LogStorage interface {
 	// Entries returns a slice of consecutive log entries in the range [lo, hi),
 	// starting from lo. The maxSize limits the total size of the log entries
 	// returned, but Entries returns at least one entry if any.
@@ -82,6 +77,17 @@ type Storage interface {
 	// into the latest Snapshot; if storage only contains the dummy entry the
 	// first log entry is not available).
 	FirstIndex() (uint64, error)
+}
+
+// StateStorage is an interface that may be implemented by the application
+// to retrieve the durable state and snapshots from storage.
+//
+// If any StateStorage method returns an error, the raft instance will
+// become inoperable and refuse to participate in elections; the
+// application is responsible for cleanup and recovery in this case.
+type StateStorage interface {
+	// InitialState returns the saved HardState and ConfState information.
+	InitialState() (pb.HardState, pb.ConfState, error)
 	// Snapshot returns the most recent snapshot.
 	// If snapshot is temporarily unavailable, it should return ErrSnapshotTemporarilyUnavailable,
 	// so raft state machine could know that Storage needs some time to prepare
@@ -89,12 +95,52 @@ type Storage interface {
 	Snapshot() (pb.Snapshot, error)
 }
 
+// Storage is an interface that may be implemented by the application
+// to retrieve log entries, the durable state and snapshots from storage.
+// The log and the state can be placed on different backends, see
+// NewSplitStorage.
+type Storage interface {
+	LogStorage
+	StateStorage
+}
+
+// NewSplitStorage returns a Storage which reads the log entries from the given
+// LogStorage, and the durable state and snapshots from the given StateStorage.
+// This allows placing the log and the state machine snapshots on different
+// backends.
+//
+// The application must keep the backends consistent with each other. In
+// particular, the log must be compacted only up to the index of the most
+// recent snapshot, and the HardState must not be committed beyond the last
+// index of the log.
+func NewSplitStorage(log LogStorage, state StateStorage) Storage {
+	return splitStorage{LogStorage: log, StateStorage: state}
+}
+
+// splitStorage combines a LogStorage and a StateStorage into a Storage.
+type splitStorage struct {
+	LogStorage
+	StateStorage
+}
+
+// TermBoundaries implements the TermBoundaryStorage interface, if the
+// LogStorage implements it.
+func (s splitStorage) TermBoundaries() ([]pb.TermBoundary, error) {
+	if ts, ok := s.LogStorage.(TermBoundaryStorage); ok {
+		return ts.TermBoundaries()
+	}
+	return nil, errNoTermBoundaries
+}
+
+var errNoTermBoundaries = errors.New("log storage does not supply term boundaries")
+
 type inMemStorageCallStats struct {
 	initialState, firstIndex, lastIndex, entries, term, snapshot int
 }
 
 // MemoryStorage implements the Storage interface backed by an
-// in-memory array.
+// in-memory array. It can also serve as either part of a split storage, see
+// NewSplitStorage.
 type MemoryStorage struct {
 	// Protects access to all fields. Most methods of MemoryStorage are
 	// run on the raft goroutine, but Append() is run on an application
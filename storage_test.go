@@ -245,3 +245,45 @@ func TestStorageApplySnapshot(t *testing.T) {
 	tt = tests[i]
 	require.Equal(t, ErrSnapOutOfDate, s.ApplySnapshot(tt))
 }
+
+func TestSplitStorage(t *testing.T) {
+	log := NewMemoryStorage()
+	require.NoError(t, log.Append(index(1).terms(1, 1, 2)))
+	state := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, state.SetHardState(pb.HardState{Term: 2, Vote: 1, Commit: 3}))
+
+	newConfig := func() *Config {
+		cfg := newTestConfig(1, 10, 1, nil)
+		cfg.LogStorage, cfg.StateStorage = log, state
+		return cfg
+	}
+	for _, tt := range []struct {
+		change func(*Config)
+		ok     bool
+	}{
+		{change: func(*Config) {}, ok: true},
+		{change: func(c *Config) { c.Storage = log }},
+		{change: func(c *Config) { c.LogStorage = nil }},
+		{change: func(c *Config) { c.StateStorage = nil }},
+		{change: func(c *Config) { c.LogStorage, c.StateStorage = nil, nil }},
+	} {
+		cfg := newConfig()
+		tt.change(cfg)
+		require.Equal(t, tt.ok, cfg.validate() == nil)
+	}
+
+	// The log comes from one storage, and the state from the other.
+	r := newRaft(newConfig())
+	require.Equal(t, uint64(3), r.raftLog.lastIndex())
+	require.Equal(t, uint64(3), r.raftLog.committed)
+	require.Equal(t, uint64(2), r.Term)
+	require.Equal(t, []uint64{1, 2}, r.trk.Voters[0].Slice())
+
+	// The term boundaries are supplied by the log storage, if it can.
+	_, err := NewSplitStorage(log, state).(TermBoundaryStorage).TermBoundaries()
+	require.Equal(t, errNoTermBoundaries, err)
+	bs := []pb.TermBoundary{{Term: 0, Index: 0}, {Term: 1, Index: 1}, {Term: 2, Index: 3}}
+	got, err := NewSplitStorage(termBoundaryStorage{MemoryStorage: log, bs: bs}, state).(TermBoundaryStorage).TermBoundaries()
+	require.NoError(t, err)
+	require.Equal(t, bs, got)
+}
@@ -391,6 +391,7 @@ SkipUnusedLogline ==
           /\ logline.event.msg.from # logline.event.msg.to
        \/ LoglineIsBecomeFollowerInUpdateTermOrReturnToFollower
        \/ LoglineIsEvent("ReduceNextIndex") \* shall not be necessary when this is removed from raft
+       \/ LoglineIsEvents({"StartLeaderTransfer", "FinishLeaderTransfer"}) \* leadership transfers are not modeled
     /\ UNCHANGED <<vars>>
 
 TraceNextNonReceiveActions ==
@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	pb "go.etcd.io/raft/v3/raftpb"
 )
 
 // Progress represents a follower’s progress in the view of the leader. Leader
@@ -84,6 +86,30 @@ type Progress struct {
 	// case the follower does not erroneously remain in StateSnapshot.
 	PendingSnapshot uint64
 
+	// The following fields track the transfer of a snapshot in chunks, in
+	// StateSnapshot. They are only used if the leader streams snapshots, see
+	// raft.Config.SnapshotSource.
+	//
+	// SnapshotMeta is the metadata of the pending snapshot. Each follower
+	// receives the snapshot that was the most recent when its transfer started.
+	SnapshotMeta pb.SnapshotMetadata
+	// SnapshotSize is the size in bytes of the data of the pending snapshot.
+	SnapshotSize uint64
+	// SnapshotSent is the number of bytes of the snapshot data sent to the
+	// follower. All bytes in [SnapshotAcked, SnapshotSent) are in flight.
+	//
+	// Invariant: SnapshotAcked <= SnapshotSent <= SnapshotSize.
+	SnapshotSent uint64
+	// SnapshotAcked is the number of bytes of the snapshot data that the
+	// follower has acknowledged. An interrupted transfer resumes from here.
+	SnapshotAcked uint64
+	// SnapshotChunksAcked is the number of chunks acknowledged by the follower.
+	SnapshotChunksAcked uint64
+	// SnapshotAckTick is the tick of the leader at which the transfer started,
+	// or the acknowledged offset last changed. The chunks in flight are sent
+	// again if it doesn't change for a while.
+	SnapshotAckTick uint64
+
 	// RecentActive is true if the progress is recently active. Receiving any messages
 	// from the corresponding follower indicates the progress is active.
 	// RecentActive can be reset to false after an election timeout.
@@ -112,15 +138,40 @@ type Progress struct {
 	// received entry.
 	Inflights *Inflights
 
+	// RateLimit limits the rate at which the payload bytes of log entries are
+	// sent to the follower. Nil if there is no limit, see
+	// raft.Config.MaxAppendBytesPerTick. Heartbeats and snapshots are not
+	// subject to the limit.
+	RateLimit *TokenBucket
+
 	// IsLearner is true if this progress is tracked for a learner.
 	IsLearner bool
+	// IsWitness is true if this progress is tracked for a witness. The leader
+	// sends log entries to witnesses without their payloads.
+	IsWitness bool
+
+	// LeaseExpiry is the leader's local time (in ticks) until which the
+	// follower is known to support the leader's lease, i.e. has promised not to
+	// vote for another candidate. Zero if the follower's support is unknown.
+	LeaseExpiry uint64
+
+	// Codecs is the bitmask of the codecs that the follower can use to
+	// decompress log entries, as advertised in its most recent response. See
+	// raft.Config.EntryCodecs.
+	Codecs uint64
 }
 
 // ResetState moves the Progress into the specified State, resetting MsgAppFlowPaused,
-// PendingSnapshot, and Inflights.
+// PendingSnapshot, the snapshot transfer, and Inflights.
 func (pr *Progress) ResetState(state StateType) {
 	pr.MsgAppFlowPaused = false
 	pr.PendingSnapshot = 0
+	pr.SnapshotMeta = pb.SnapshotMetadata{}
+	pr.SnapshotSize = 0
+	pr.SnapshotSent = 0
+	pr.SnapshotAcked = 0
+	pr.SnapshotChunksAcked = 0
+	pr.SnapshotAckTick = 0
 	pr.State = state
 	pr.Inflights.reset()
 }
@@ -157,6 +208,46 @@ func (pr *Progress) BecomeSnapshot(snapshoti uint64) {
 	pr.sentCommit = snapshoti
 }
 
+// SentSnapshotChunk updates the progress on a chunk of the snapshot data with
+// the given size being sent.
+//
+// Must be used with StateSnapshot.
+func (pr *Progress) SentSnapshotChunk(bytes uint64) {
+	pr.SnapshotSent += bytes
+}
+
+// AckSnapshotChunk updates the progress on the follower acknowledging that it
+// has received the snapshot data up to the given offset. If the follower
+// reports an offset below the one it acknowledged before, it has lost the data
+// after it, e.g. because it restarted, and the transfer resumes from the
+// offset. Stale and duplicate acknowledgements are ignored. Returns true if the
+// acknowledged offset has changed.
+//
+// Must be used with StateSnapshot.
+func (pr *Progress) AckSnapshotChunk(offset uint64) bool {
+	switch {
+	case offset > pr.SnapshotAcked:
+		pr.SnapshotAcked = offset
+		pr.SnapshotSent = max(pr.SnapshotSent, offset)
+		pr.SnapshotChunksAcked++
+		return true
+	case offset < pr.SnapshotAcked:
+		pr.SnapshotAcked = offset
+		pr.SnapshotSent = offset
+		return true
+	}
+	return false
+}
+
+// ResendSnapshotChunks rewinds the transfer of the snapshot data to the offset
+// acknowledged by the follower, so that the chunks in flight are sent again.
+// Used when they are presumably lost.
+//
+// Must be used with StateSnapshot.
+func (pr *Progress) ResendSnapshotChunks() {
+	pr.SnapshotSent = pr.SnapshotAcked
+}
+
 // SentEntries updates the progress on the given number of consecutive entries
 // being sent in a MsgApp, with the given total bytes size, appended at log
 // indices >= pr.Next.
@@ -278,12 +369,18 @@ func (pr *Progress) String() string {
 	if pr.IsLearner {
 		fmt.Fprint(&buf, " learner")
 	}
+	if pr.IsWitness {
+		fmt.Fprint(&buf, " witness")
+	}
 	if pr.IsPaused() {
 		fmt.Fprint(&buf, " paused")
 	}
 	if pr.PendingSnapshot > 0 {
 		fmt.Fprintf(&buf, " pendingSnap=%d", pr.PendingSnapshot)
 	}
+	if pr.SnapshotSize > 0 {
+		fmt.Fprintf(&buf, " snapSent=%d snapAcked=%d/%d", pr.SnapshotSent, pr.SnapshotAcked, pr.SnapshotSize)
+	}
 	if !pr.RecentActive {
 		fmt.Fprint(&buf, " inactive")
 	}
@@ -293,6 +390,9 @@ func (pr *Progress) String() string {
 			fmt.Fprint(&buf, "[full]")
 		}
 	}
+	if pr.RateLimit != nil {
+		fmt.Fprintf(&buf, " tokens=%d/%d", pr.RateLimit.Tokens(), pr.RateLimit.Burst())
+	}
 	return buf.String()
 }
 
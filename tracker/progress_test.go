@@ -120,6 +120,38 @@ func TestProgressBecomeSnapshot(t *testing.T) {
 	assert.Equal(t, uint64(10), p.PendingSnapshot)
 }
 
+func TestProgressSnapshotChunks(t *testing.T) {
+	p := &Progress{State: StateProbe, Next: 5, Inflights: NewInflights(256, 0)}
+	p.BecomeSnapshot(10)
+	p.SnapshotSize = 100
+	p.SentSnapshotChunk(40)
+	p.SentSnapshotChunk(40)
+	assert.Equal(t, "StateSnapshot match=0 next=11 paused pendingSnap=10 snapSent=80 snapAcked=0/100 inactive", p.String())
+
+	assert.True(t, p.AckSnapshotChunk(40))
+	assert.Equal(t, uint64(40), p.SnapshotAcked)
+	assert.Equal(t, uint64(80), p.SnapshotSent)
+	assert.Equal(t, uint64(1), p.SnapshotChunksAcked)
+
+	// A duplicate acknowledgement doesn't rewind the transfer.
+	assert.False(t, p.AckSnapshotChunk(40))
+	assert.Equal(t, uint64(40), p.SnapshotAcked)
+	assert.Equal(t, uint64(80), p.SnapshotSent)
+
+	p.ResendSnapshotChunks()
+	assert.Equal(t, uint64(40), p.SnapshotAcked)
+	assert.Equal(t, uint64(40), p.SnapshotSent)
+
+	// The follower has lost the data.
+	assert.True(t, p.AckSnapshotChunk(0))
+	assert.Equal(t, uint64(0), p.SnapshotAcked)
+	assert.Equal(t, uint64(0), p.SnapshotSent)
+
+	p.BecomeProbe()
+	assert.Zero(t, p.SnapshotSize)
+	assert.Zero(t, p.SnapshotChunksAcked)
+}
+
 func TestProgressUpdate(t *testing.T) {
 	prevM, prevN := uint64(3), uint64(5)
 	tests := []struct {
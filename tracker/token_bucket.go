@@ -0,0 +1,80 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracker
+
+// TokenBucket limits the rate at which the payload bytes of log entries are sent
+// to a follower. The bucket is refilled with a fixed number of tokens (bytes)
+// on every tick, up to its capacity, which allows bursts of traffic after a
+// period of inactivity. Sending consumes the tokens.
+//
+// Sending is allowed as long as the bucket is not empty, and a single message
+// may consume more tokens than available. The bucket then goes into debt,
+// which is paid back by the subsequent refills before sending resumes. This
+// way, the rate is maintained in the long run, and messages larger than the
+// capacity don't get stuck.
+type TokenBucket struct {
+	rate   uint64 // tokens added on every tick
+	burst  uint64 // the capacity of the bucket
+	tokens int64  // the available tokens, negative if in debt
+}
+
+// NewTokenBucket returns a full TokenBucket refilled with the given number of
+// tokens per tick, up to the given capacity. The capacity is raised to the rate
+// if it is lower. Returns nil if the rate is 0, which means no rate limit.
+func NewTokenBucket(rate, burst uint64) *TokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	burst = max(burst, rate)
+	return &TokenBucket{rate: rate, burst: burst, tokens: int64(burst)}
+}
+
+// Clone returns a *TokenBucket that is identical to but shares no memory with
+// the receiver.
+func (tb *TokenBucket) Clone() *TokenBucket {
+	if tb == nil {
+		return nil
+	}
+	c := *tb
+	return &c
+}
+
+// Tick refills the bucket.
+func (tb *TokenBucket) Tick() {
+	tb.tokens = min(tb.tokens+int64(tb.rate), int64(tb.burst))
+}
+
+// Available returns the number of tokens that can be consumed, or 0 if the
+// bucket is empty or in debt.
+func (tb *TokenBucket) Available() uint64 {
+	return uint64(max(tb.tokens, 0))
+}
+
+// Take consumes the given number of tokens. The bucket goes into debt if there
+// are not enough tokens.
+func (tb *TokenBucket) Take(tokens uint64) {
+	tb.tokens -= int64(tokens)
+}
+
+// Tokens returns the number of tokens in the bucket, negative if the bucket is
+// in debt.
+func (tb *TokenBucket) Tokens() int64 {
+	return tb.tokens
+}
+
+// Burst returns the capacity of the bucket.
+func (tb *TokenBucket) Burst() uint64 {
+	return tb.burst
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	require.Nil(t, NewTokenBucket(0, 100))
+	// The capacity is at least the rate.
+	require.Equal(t, uint64(10), NewTokenBucket(10, 5).Burst())
+
+	tb := NewTokenBucket(10, 25)
+	require.Equal(t, uint64(25), tb.Available())
+	tb.Take(20)
+	require.Equal(t, uint64(5), tb.Available())
+	// The bucket goes into debt.
+	tb.Take(20)
+	require.Equal(t, int64(-15), tb.Tokens())
+	require.Zero(t, tb.Available())
+	tb.Tick()
+	require.Zero(t, tb.Available())
+	tb.Tick()
+	require.Equal(t, uint64(5), tb.Available())
+	// The bucket is refilled up to its capacity.
+	for i := 0; i < 5; i++ {
+		tb.Tick()
+	}
+	require.Equal(t, uint64(25), tb.Available())
+
+	c := tb.Clone()
+	c.Take(25)
+	require.Equal(t, uint64(25), tb.Available())
+	require.Nil(t, (*TokenBucket)(nil).Clone())
+}
@@ -16,6 +16,7 @@ package tracker
 
 import (
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
 	"strings"
@@ -76,6 +77,44 @@ type Config struct {
 	// right away when entering the joint configuration, so that it is caught up
 	// as soon as possible.
 	LearnersNext map[uint64]struct{}
+	// Witnesses is the set of voters (in either half of the joint config) that
+	// are witnesses. A witness votes in elections and counts towards commit
+	// quorums, but doesn't store the payloads of log entries and can't become
+	// the leader.
+	//
+	// Invariant: Witnesses is a subset of the voters. A witness that is
+	// removed from the incoming config remains a witness while it is in the
+	// outgoing config.
+	Witnesses map[uint64]struct{}
+	// Priorities contains the election priorities of the nodes that have a
+	// non-zero one. Nodes with a higher priority are preferred as the leader.
+	//
+	// Invariant: Priorities only contains voters and learners (in either half
+	// of the joint config).
+	Priorities map[uint64]uint64
+	// LearnersAutoPromote is the set of learners that the leader promotes to
+	// voters once they have caught up with the log.
+	//
+	// Invariant: LearnersAutoPromote is a subset of Learners.
+	LearnersAutoPromote map[uint64]struct{}
+}
+
+// Priority returns the election priority of the given node.
+func (c *Config) Priority(id uint64) uint64 {
+	return c.Priorities[id]
+}
+
+// MaxPriority returns the highest election priority among the voters.
+func (c *Config) MaxPriority() uint64 {
+	var res uint64
+	for id, prio := range c.Priorities {
+		_, in0 := c.Voters[0][id]
+		_, in1 := c.Voters[1][id]
+		if (in0 || in1) && prio > res {
+			res = prio
+		}
+	}
+	return res
 }
 
 func (c Config) String() string {
@@ -87,6 +126,27 @@ func (c Config) String() string {
 	if c.LearnersNext != nil {
 		fmt.Fprintf(&buf, " learners_next=%s", quorum.MajorityConfig(c.LearnersNext).String())
 	}
+	if c.Witnesses != nil {
+		fmt.Fprintf(&buf, " witnesses=%s", quorum.MajorityConfig(c.Witnesses).String())
+	}
+	if c.LearnersAutoPromote != nil {
+		fmt.Fprintf(&buf, " learners_auto_promote=%s", quorum.MajorityConfig(c.LearnersAutoPromote).String())
+	}
+	if c.Priorities != nil {
+		ids := make([]uint64, 0, len(c.Priorities))
+		for id := range c.Priorities {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		buf.WriteString(" priorities=(")
+		for i, id := range ids {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			fmt.Fprintf(&buf, "%d:%d", id, c.Priorities[id])
+		}
+		buf.WriteByte(')')
+	}
 	if c.AutoLeave {
 		fmt.Fprint(&buf, " autoleave")
 	}
@@ -106,9 +166,12 @@ func (c *Config) Clone() Config {
 		return mm
 	}
 	return Config{
-		Voters:       quorum.JointConfig{clone(c.Voters[0]), clone(c.Voters[1])},
-		Learners:     clone(c.Learners),
-		LearnersNext: clone(c.LearnersNext),
+		Voters:              quorum.JointConfig{clone(c.Voters[0]), clone(c.Voters[1])},
+		Learners:            clone(c.Learners),
+		LearnersNext:        clone(c.LearnersNext),
+		Witnesses:           clone(c.Witnesses),
+		Priorities:          maps.Clone(c.Priorities),
+		LearnersAutoPromote: clone(c.LearnersAutoPromote),
 	}
 }
 
@@ -124,6 +187,16 @@ type ProgressTracker struct {
 
 	MaxInflight      int
 	MaxInflightBytes uint64
+	// MaxAppendBytesPerTick and MaxAppendBurstBytes configure the RateLimit of
+	// the Progress of each peer. Zero MaxAppendBytesPerTick means no limit.
+	MaxAppendBytesPerTick uint64
+	MaxAppendBurstBytes   uint64
+}
+
+// NewRateLimit returns a new TokenBucket for the Progress of a peer, or nil if
+// the rate is not limited.
+func (p *ProgressTracker) NewRateLimit() *TokenBucket {
+	return NewTokenBucket(p.MaxAppendBytesPerTick, p.MaxAppendBurstBytes)
 }
 
 // MakeProgressTracker initializes a ProgressTracker.
@@ -136,8 +209,11 @@ func MakeProgressTracker(maxInflight int, maxBytes uint64) ProgressTracker {
 				quorum.MajorityConfig{},
 				nil, // only populated when used
 			},
-			Learners:     nil, // only populated when used
-			LearnersNext: nil, // only populated when used
+			Learners:            nil, // only populated when used
+			LearnersNext:        nil, // only populated when used
+			Witnesses:           nil, // only populated when used
+			Priorities:          nil, // only populated when used
+			LearnersAutoPromote: nil, // only populated when used
 		},
 		Votes:    map[uint64]bool{},
 		Progress: map[uint64]*Progress{},
@@ -148,11 +224,14 @@ func MakeProgressTracker(maxInflight int, maxBytes uint64) ProgressTracker {
 // ConfState returns a ConfState representing the active configuration.
 func (p *ProgressTracker) ConfState() pb.ConfState {
 	return pb.ConfState{
-		Voters:         p.Voters[0].Slice(),
-		VotersOutgoing: p.Voters[1].Slice(),
-		Learners:       quorum.MajorityConfig(p.Learners).Slice(),
-		LearnersNext:   quorum.MajorityConfig(p.LearnersNext).Slice(),
-		AutoLeave:      p.AutoLeave,
+		Voters:              p.Voters[0].Slice(),
+		VotersOutgoing:      p.Voters[1].Slice(),
+		Learners:            quorum.MajorityConfig(p.Learners).Slice(),
+		LearnersNext:        quorum.MajorityConfig(p.LearnersNext).Slice(),
+		AutoLeave:           p.AutoLeave,
+		Witnesses:           quorum.MajorityConfig(p.Witnesses).Slice(),
+		Priorities:          maps.Clone(p.Priorities),
+		LearnersAutoPromote: quorum.MajorityConfig(p.LearnersAutoPromote).Slice(),
 	}
 }
 
@@ -176,9 +255,29 @@ func (l matchAckIndexer) AckedIndex(id uint64) (quorum.Index, bool) {
 }
 
 // Committed returns the largest log index known to be committed based on what
-// the voting members of the group have acknowledged.
+// the voting members of the group have acknowledged. An index acknowledged
+// only by witnesses is not committed.
 func (p *ProgressTracker) Committed() uint64 {
-	return uint64(p.Voters.CommittedIndex(matchAckIndexer(p.Progress)))
+	return uint64(p.Voters.CommittedIndexWithWitnesses(matchAckIndexer(p.Progress), p.Witnesses))
+}
+
+type leaseAckIndexer map[uint64]*Progress
+
+var _ quorum.AckedIndexer = leaseAckIndexer(nil)
+
+// AckedIndex implements IndexLookuper.
+func (l leaseAckIndexer) AckedIndex(id uint64) (quorum.Index, bool) {
+	pr, ok := l[id]
+	if !ok {
+		return 0, false
+	}
+	return quorum.Index(pr.LeaseExpiry), true
+}
+
+// LeaseExpiry returns the largest time (in ticks) until which the leader's
+// lease is known to be supported by the voting members of the group.
+func (p *ProgressTracker) LeaseExpiry() uint64 {
+	return uint64(p.Voters.CommittedIndex(leaseAckIndexer(p.Progress)))
 }
 
 // Visit invokes the supplied closure for all tracked progresses in stable order.
@@ -47,6 +47,7 @@ var isResponseMsg = [...]bool{
 	pb.MsgPreVoteResp:       true,
 	pb.MsgStorageAppendResp: true,
 	pb.MsgStorageApplyResp:  true,
+	pb.MsgSnapChunkResp:     true,
 }
 
 func isMsgInArray(msgt pb.MessageType, arr []bool) bool {
@@ -93,10 +94,20 @@ func DescribeSoftState(ss SoftState) string {
 }
 
 func DescribeConfState(state pb.ConfState) string {
-	return fmt.Sprintf(
+	s := fmt.Sprintf(
 		"Voters:%v VotersOutgoing:%v Learners:%v LearnersNext:%v AutoLeave:%v",
 		state.Voters, state.VotersOutgoing, state.Learners, state.LearnersNext, state.AutoLeave,
 	)
+	if len(state.Witnesses) > 0 {
+		s += fmt.Sprintf(" Witnesses:%v", state.Witnesses)
+	}
+	if len(state.Priorities) > 0 {
+		s += fmt.Sprintf(" Priorities:%v", state.Priorities)
+	}
+	if len(state.LearnersAutoPromote) > 0 {
+		s += fmt.Sprintf(" LearnersAutoPromote:%v", state.LearnersAutoPromote)
+	}
+	return s
 }
 
 func DescribeSnapshot(snap pb.Snapshot) string {
@@ -117,6 +128,12 @@ func DescribeReady(rd Ready, f EntryFormatter) string {
 	if len(rd.ReadStates) > 0 {
 		fmt.Fprintf(&buf, "ReadStates %v\n", rd.ReadStates)
 	}
+	for _, res := range rd.LeaderTransfers {
+		fmt.Fprintf(&buf, "LeaderTransfer %s\n", res)
+	}
+	for _, res := range rd.LearnerPromotions {
+		fmt.Fprintf(&buf, "LearnerPromotion %s\n", res)
+	}
 	if len(rd.Entries) > 0 {
 		buf.WriteString("Entries:\n")
 		fmt.Fprint(&buf, DescribeEntries(rd.Entries, f))
@@ -158,6 +175,16 @@ func describeMessageWithIndent(indent string, m pb.Message, f EntryFormatter) st
 	if m.Reject {
 		fmt.Fprintf(&buf, " Rejected (Hint: %d)", m.RejectHint)
 	}
+	if len(m.TermBoundaries) > 0 {
+		fmt.Fprint(&buf, " Terms:[")
+		for i, b := range m.TermBoundaries {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			fmt.Fprintf(&buf, "%d@%d", b.Term, b.Index)
+		}
+		buf.WriteByte(']')
+	}
 	if m.Commit != 0 {
 		fmt.Fprintf(&buf, " Commit:%d", m.Commit)
 	}
@@ -174,6 +201,12 @@ func describeMessageWithIndent(indent string, m pb.Message, f EntryFormatter) st
 		}
 		fmt.Fprintf(&buf, "\n%s]", indent)
 	}
+	if m.EntriesCodec != 0 {
+		fmt.Fprintf(&buf, " Entries:[compressed with codec %d]", m.EntriesCodec)
+	}
+	if m.SupportedCodecs != 0 {
+		fmt.Fprintf(&buf, " Codecs:%#x", m.SupportedCodecs)
+	}
 	if s := m.Snapshot; s != nil && !IsEmptySnap(*s) {
 		fmt.Fprintf(&buf, "\n%s  Snapshot: %s", indent, DescribeSnapshot(*s))
 	}
@@ -299,6 +332,24 @@ func payloadsSize(ents []pb.Entry) entryPayloadSize {
 	return s
 }
 
+// witnessEntries returns the entries with the payloads of normal entries
+// removed, as they are replicated to witnesses. Configuration changes are kept
+// intact, since witnesses need them to track the group membership. The input
+// slice is not modified.
+func witnessEntries(ents []pb.Entry) []pb.Entry {
+	if len(ents) == 0 {
+		return ents
+	}
+	res := make([]pb.Entry, len(ents))
+	for i, e := range ents {
+		if e.Type == pb.EntryNormal {
+			e.Data = nil
+		}
+		res[i] = e
+	}
+	return res
+}
+
 func assertConfStatesEquivalent(l Logger, cs1, cs2 pb.ConfState) {
 	err := cs1.Equivalent(cs2)
 	if err == nil {
@@ -97,6 +97,11 @@ func TestIsLocalMsg(t *testing.T) {
 		{pb.MsgStorageAppendResp, true},
 		{pb.MsgStorageApply, true},
 		{pb.MsgStorageApplyResp, true},
+		{pb.MsgForgetLeader, false},
+		{pb.MsgQuiesce, false},
+		{pb.MsgUnquiesce, false},
+		{pb.MsgSnapChunk, false},
+		{pb.MsgSnapChunkResp, false},
 	}
 
 	for _, tt := range tests {
@@ -134,6 +139,11 @@ func TestIsResponseMsg(t *testing.T) {
 		{pb.MsgStorageAppendResp, true},
 		{pb.MsgStorageApply, false},
 		{pb.MsgStorageApplyResp, true},
+		{pb.MsgForgetLeader, false},
+		{pb.MsgQuiesce, false},
+		{pb.MsgUnquiesce, false},
+		{pb.MsgSnapChunk, false},
+		{pb.MsgSnapChunkResp, true},
 	}
 
 	for i, tt := range tests {